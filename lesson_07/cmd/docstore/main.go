@@ -0,0 +1,67 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+
+	"github.com/Nick2603/golang/lesson_07/internal/shell"
+)
+
+func main() {
+	file := flag.String("file", "", "dump file to open; created on save if missing")
+	dir := flag.String("dir", "", "data directory with snapshots and a change log to open")
+	verbose := flag.Bool("v", false, "log store operations to stderr")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: docstore [-file dump.json | -dir data] [command [args...]]\n\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "Without a command, reads commands from stdin; run \"docstore help\" to list them.\n\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	level := slog.LevelError
+	if *verbose {
+		level = slog.LevelInfo
+	}
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: level}))
+	slog.SetDefault(logger)
+
+	sh, err := shell.Open(shell.Source{File: *file, Dir: *dir}, os.Stdout, logger)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "docstore: %v\n", err)
+		os.Exit(1)
+	}
+
+	if err := run(sh, flag.Args(), *file != "" || *dir != ""); err != nil {
+		fmt.Fprintf(os.Stderr, "docstore: %v\n", err)
+		sh.Close()
+		os.Exit(1)
+	}
+	if err := sh.Close(); err != nil {
+		fmt.Fprintf(os.Stderr, "docstore: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// run executes a single command given on the command line and saves its
+// changes if the store was opened from disk, or starts the shell on stdin
+// when there is no command.
+func run(sh *shell.Shell, args []string, persistent bool) error {
+	if len(args) == 0 {
+		prompt := ""
+		if stat, err := os.Stdin.Stat(); err == nil && stat.Mode()&os.ModeCharDevice != 0 {
+			prompt = "docstore> "
+		}
+		return sh.Run(os.Stdin, prompt)
+	}
+
+	if err := sh.Exec(args); err != nil && !errors.Is(err, shell.ErrQuit) {
+		return err
+	}
+	if persistent && sh.Dirty() {
+		return sh.Save()
+	}
+	return nil
+}
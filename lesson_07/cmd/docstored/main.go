@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/Nick2603/golang/lesson_07/internal/auth"
+	"github.com/Nick2603/golang/lesson_07/internal/daemon"
+	"github.com/Nick2603/golang/lesson_07/internal/httpapi"
+)
+
+func main() {
+	addr := flag.String("addr", ":8080", "address to listen on")
+	dumpFile := flag.String("dump", "", "dump file loaded on start and written on shutdown")
+	authFile := flag.String("auth", "", "JSON file with roles and API keys; requests are unauthenticated if empty")
+	dataRoot := flag.String("data-root", "", "directory clients may create persistent collections in; only in-memory collections if empty")
+	flag.Parse()
+
+	logger := slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{
+		Level: slog.LevelInfo,
+	}))
+	slog.SetDefault(logger)
+
+	store, err := daemon.OpenStore(*dumpFile, logger)
+	if err != nil {
+		logger.Error("failed to open store", "dump", *dumpFile, "error", err)
+		os.Exit(1)
+	}
+
+	api := httpapi.NewServer(store, logger)
+	if *dataRoot != "" {
+		api.SetDataRoot(*dataRoot)
+	}
+
+	var handler http.Handler = api
+	if *authFile != "" {
+		authn, err := daemon.LoadAuth(*authFile)
+		if err != nil {
+			logger.Error("failed to load auth config", "auth", *authFile, "error", err)
+			os.Exit(1)
+		}
+		handler = auth.Middleware(authn, logger, handler)
+	}
+
+	srv := &http.Server{
+		Addr:              *addr,
+		Handler:           handler,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	go func() {
+		logger.Info("docstored listening", "addr", *addr)
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Error("server failed", "error", err)
+			stop()
+		}
+	}()
+
+	<-ctx.Done()
+	logger.Info("shutting down")
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		logger.Error("failed to shut down server", "error", err)
+	}
+
+	if *dumpFile != "" {
+		if err := store.DumpToFile(*dumpFile); err != nil {
+			logger.Error("failed to dump store", "dump", *dumpFile, "error", err)
+		}
+	}
+	if err := store.Close(); err != nil {
+		logger.Error("failed to close store", "error", err)
+	}
+}
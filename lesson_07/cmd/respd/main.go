@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/Nick2603/golang/lesson_07/internal/daemon"
+	"github.com/Nick2603/golang/lesson_07/internal/documentstore"
+	"github.com/Nick2603/golang/lesson_07/internal/resp"
+)
+
+func main() {
+	addr := flag.String("addr", ":6380", "address to listen on")
+	dumpFile := flag.String("dump", "", "dump file loaded on start and written on shutdown")
+	authFile := flag.String("auth", "", "JSON file with roles and API keys for AUTH; connections are unauthenticated if empty")
+	collections := make(map[string]string)
+	flag.Func("collection", "collection to create if missing, as name=primary_key (repeatable)", func(v string) error {
+		name, pk, ok := strings.Cut(v, "=")
+		if !ok || name == "" || pk == "" {
+			return fmt.Errorf("want name=primary_key, got %q", v)
+		}
+		collections[name] = pk
+		return nil
+	})
+	flag.Parse()
+
+	logger := slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{
+		Level: slog.LevelInfo,
+	}))
+	slog.SetDefault(logger)
+
+	store, err := daemon.OpenStore(*dumpFile, logger)
+	if err != nil {
+		logger.Error("failed to open store", "dump", *dumpFile, "error", err)
+		os.Exit(1)
+	}
+	for name, pk := range collections {
+		if _, err := store.GetCollection(name); err == nil {
+			continue
+		}
+		if _, err := store.CreateCollection(name, &documentstore.CollectionConfig{PrimaryKey: pk}); err != nil {
+			logger.Error("failed to create collection", "collection", name, "error", err)
+			os.Exit(1)
+		}
+	}
+
+	srv := resp.NewServer(store, logger)
+	if *authFile != "" {
+		authn, err := daemon.LoadAuth(*authFile)
+		if err != nil {
+			logger.Error("failed to load auth config", "auth", *authFile, "error", err)
+			os.Exit(1)
+		}
+		srv.SetAuthenticator(authn)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	go func() {
+		logger.Info("respd listening", "addr", *addr)
+		if err := srv.ListenAndServe(*addr); err != nil {
+			logger.Error("server failed", "error", err)
+			stop()
+		}
+	}()
+
+	<-ctx.Done()
+	logger.Info("shutting down")
+
+	if err := srv.Close(); err != nil {
+		logger.Error("failed to close server", "error", err)
+	}
+	if *dumpFile != "" {
+		if err := store.DumpToFile(*dumpFile); err != nil {
+			logger.Error("failed to dump store", "dump", *dumpFile, "error", err)
+		}
+	}
+	if err := store.Close(); err != nil {
+		logger.Error("failed to close store", "error", err)
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/Nick2603/golang/lesson_07/internal/documentstore"
+	"github.com/Nick2603/golang/lesson_07/internal/users"
+)
+
+func main() {
+	addr := flag.String("addr", ":8081", "address to listen on")
+	dataDir := flag.String("data", "users-data", "directory holding the users collection")
+	flag.Parse()
+
+	logger := slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{
+		Level: slog.LevelInfo,
+	}))
+	slog.SetDefault(logger)
+
+	store := documentstore.NewStoreWithLogger(logger)
+	usersColl, err := store.CreateCollection("users", &documentstore.CollectionConfig{
+		PrimaryKey: "id",
+		Engine:     documentstore.EngineBitcask,
+		DataDir:    *dataDir,
+		KeyGen:     documentstore.KeyGenUUIDv7,
+	})
+	if err != nil {
+		logger.Error("failed to open users collection", "data", *dataDir, "error", err)
+		os.Exit(1)
+	}
+
+	srv := &http.Server{
+		Addr:              *addr,
+		Handler:           users.NewHandler(users.NewService(usersColl), logger),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	go func() {
+		logger.Info("usersd listening", "addr", *addr, "data", *dataDir)
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Error("server failed", "error", err)
+			stop()
+		}
+	}()
+
+	<-ctx.Done()
+	logger.Info("shutting down")
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		logger.Error("failed to shut down server", "error", err)
+	}
+
+	if err := store.Close(); err != nil {
+		logger.Error("failed to close store", "error", err)
+	}
+}
@@ -0,0 +1,216 @@
+// Package auth authenticates callers by API key or bearer token and checks
+// their per-collection permissions, both for in-process use through Store
+// and for HTTP through Middleware.
+package auth
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/Nick2603/golang/lesson_07/internal/documentstore"
+)
+
+// AllCollections grants a permission on every collection. Store-wide
+// operations such as dumps require admin on AllCollections.
+const AllCollections = "*"
+
+// Permission is a level of access to a collection. Each level includes the
+// ones below it.
+type Permission int
+
+const (
+	PermNone Permission = iota
+	PermRead
+	PermWrite
+	PermAdmin
+)
+
+func (p Permission) String() string {
+	switch p {
+	case PermNone:
+		return "none"
+	case PermRead:
+		return "read"
+	case PermWrite:
+		return "write"
+	case PermAdmin:
+		return "admin"
+	default:
+		return fmt.Sprintf("Permission(%d)", int(p))
+	}
+}
+
+func (p Permission) MarshalText() ([]byte, error) {
+	if p < PermNone || p > PermAdmin {
+		return nil, fmt.Errorf("invalid permission %d", int(p))
+	}
+	return []byte(p.String()), nil
+}
+
+func (p *Permission) UnmarshalText(text []byte) error {
+	for perm := PermNone; perm <= PermAdmin; perm++ {
+		if perm.String() == string(text) {
+			*p = perm
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown permission %q", text)
+}
+
+// Role grants permissions per collection name, or on AllCollections.
+type Role struct {
+	Name   string                `json:"name"`
+	Grants map[string]Permission `json:"grants"`
+}
+
+// Principal is an authenticated caller.
+type Principal struct {
+	Name  string   `json:"name"`
+	Roles []string `json:"roles"`
+}
+
+// Config is the JSON form of an Authenticator, e.g.
+//
+//	{
+//	  "roles": [{"name": "users-rw", "grants": {"users": "write"}}],
+//	  "keys": [{"key": "secret", "principal": {"name": "svc", "roles": ["users-rw"]}}]
+//	}
+type Config struct {
+	Roles []Role      `json:"roles"`
+	Keys  []KeyConfig `json:"keys"`
+}
+
+type KeyConfig struct {
+	Key       string    `json:"key"`
+	Principal Principal `json:"principal"`
+}
+
+// PermissionError reports a denied operation. It matches
+// documentstore.ErrPermissionDenied with errors.Is.
+type PermissionError struct {
+	Principal  string
+	Collection string
+	Need       Permission
+}
+
+func (e *PermissionError) Error() string {
+	return fmt.Sprintf("%s: %s needs %s on %q", documentstore.ErrPermissionDenied, e.Principal, e.Need, e.Collection)
+}
+
+func (e *PermissionError) Unwrap() error {
+	return documentstore.ErrPermissionDenied
+}
+
+// Authenticator holds roles and the credentials that map to principals.
+// Only hashes of the credentials are kept.
+type Authenticator struct {
+	mu    sync.RWMutex
+	roles map[string]Role
+	keys  map[[sha256.Size]byte]Principal
+}
+
+func NewAuthenticator() *Authenticator {
+	return &Authenticator{
+		roles: make(map[string]Role),
+		keys:  make(map[[sha256.Size]byte]Principal),
+	}
+}
+
+// NewAuthenticatorFromConfig builds an Authenticator holding the roles and
+// keys of cfg.
+func NewAuthenticatorFromConfig(cfg Config) (*Authenticator, error) {
+	a := NewAuthenticator()
+	for _, role := range cfg.Roles {
+		if err := a.AddRole(role); err != nil {
+			return nil, err
+		}
+	}
+	for _, k := range cfg.Keys {
+		if err := a.AddKey(k.Key, k.Principal); err != nil {
+			return nil, err
+		}
+	}
+	return a, nil
+}
+
+// AddRole defines or replaces a role.
+func (a *Authenticator) AddRole(role Role) error {
+	if role.Name == "" {
+		return errors.New("role name is required")
+	}
+	for coll, perm := range role.Grants {
+		if perm < PermNone || perm > PermAdmin {
+			return fmt.Errorf("role %q: invalid permission %d on %q", role.Name, perm, coll)
+		}
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.roles[role.Name] = role
+	return nil
+}
+
+// AddKey registers an API key or bearer token for a principal. Every role
+// of the principal must already exist.
+func (a *Authenticator) AddKey(key string, p Principal) error {
+	if key == "" {
+		return errors.New("key is required")
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for _, name := range p.Roles {
+		if _, ok := a.roles[name]; !ok {
+			return fmt.Errorf("principal %q: unknown role %q", p.Name, name)
+		}
+	}
+	a.keys[sha256.Sum256([]byte(key))] = p
+	return nil
+}
+
+// RevokeKey forgets a key; requests using it are no longer authenticated.
+func (a *Authenticator) RevokeKey(key string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	delete(a.keys, sha256.Sum256([]byte(key)))
+}
+
+// Authenticate returns the principal a key belongs to.
+func (a *Authenticator) Authenticate(key string) (Principal, error) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	p, ok := a.keys[sha256.Sum256([]byte(key))]
+	if !ok || key == "" {
+		return Principal{}, documentstore.ErrUnauthenticated
+	}
+	return p, nil
+}
+
+// Permission returns the highest permission any role of p grants on the
+// collection.
+func (a *Authenticator) Permission(p Principal, collection string) Permission {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	best := PermNone
+	for _, name := range p.Roles {
+		role := a.roles[name]
+		best = max(best, role.Grants[collection], role.Grants[AllCollections])
+	}
+	return best
+}
+
+// Authorize returns a *PermissionError unless p has at least need on the
+// collection.
+func (a *Authenticator) Authorize(p Principal, collection string, need Permission) error {
+	if a.Permission(p, collection) >= need {
+		return nil
+	}
+	return &PermissionError{Principal: p.Name, Collection: collection, Need: need}
+}
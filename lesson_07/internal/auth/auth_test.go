@@ -0,0 +1,114 @@
+package auth
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/Nick2603/golang/lesson_07/internal/documentstore"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testConfig = `{
+	"roles": [
+		{"name": "admin", "grants": {"*": "admin"}},
+		{"name": "users-rw", "grants": {"users": "write"}},
+		{"name": "reader", "grants": {"*": "read"}}
+	],
+	"keys": [
+		{"key": "admin-key", "principal": {"name": "ops", "roles": ["admin"]}},
+		{"key": "svc-key", "principal": {"name": "users-svc", "roles": ["users-rw"]}},
+		{"key": "report-key", "principal": {"name": "reports", "roles": ["reader"]}},
+		{"key": "mixed-key", "principal": {"name": "mixed", "roles": ["reader", "users-rw"]}}
+	]
+}`
+
+func testAuthenticator(t *testing.T) *Authenticator {
+	t.Helper()
+
+	var cfg Config
+	require.NoError(t, json.Unmarshal([]byte(testConfig), &cfg))
+	a, err := NewAuthenticatorFromConfig(cfg)
+	require.NoError(t, err)
+	return a
+}
+
+func TestAuthenticator_Permission(t *testing.T) {
+	a := testAuthenticator(t)
+
+	tests := []struct {
+		key        string
+		collection string
+		want       Permission
+	}{
+		{key: "admin-key", collection: "users", want: PermAdmin},
+		{key: "admin-key", collection: AllCollections, want: PermAdmin},
+		{key: "svc-key", collection: "users", want: PermWrite},
+		{key: "svc-key", collection: "orders", want: PermNone},
+		{key: "report-key", collection: "orders", want: PermRead},
+		{key: "mixed-key", collection: "users", want: PermWrite},
+		{key: "mixed-key", collection: "orders", want: PermRead},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.key+"/"+tt.collection, func(t *testing.T) {
+			p, err := a.Authenticate(tt.key)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, a.Permission(p, tt.collection))
+		})
+	}
+}
+
+func TestAuthenticator_Authenticate(t *testing.T) {
+	a := testAuthenticator(t)
+
+	p, err := a.Authenticate("svc-key")
+	require.NoError(t, err)
+	assert.Equal(t, Principal{Name: "users-svc", Roles: []string{"users-rw"}}, p)
+
+	for _, key := range []string{"", "wrong"} {
+		_, err := a.Authenticate(key)
+		assert.ErrorIs(t, err, documentstore.ErrUnauthenticated)
+	}
+
+	a.RevokeKey("svc-key")
+	_, err = a.Authenticate("svc-key")
+	assert.ErrorIs(t, err, documentstore.ErrUnauthenticated)
+}
+
+func TestAuthenticator_Authorize(t *testing.T) {
+	a := testAuthenticator(t)
+	p, err := a.Authenticate("report-key")
+	require.NoError(t, err)
+
+	assert.NoError(t, a.Authorize(p, "users", PermRead))
+
+	err = a.Authorize(p, "users", PermWrite)
+	assert.ErrorIs(t, err, documentstore.ErrPermissionDenied)
+	var perr *PermissionError
+	require.ErrorAs(t, err, &perr)
+	assert.Equal(t, &PermissionError{Principal: "reports", Collection: "users", Need: PermWrite}, perr)
+	assert.Equal(t, `permission denied: reports needs write on "users"`, err.Error())
+}
+
+func TestAuthenticator_ConfigErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  Config
+	}{
+		{name: "unnamed role", cfg: Config{Roles: []Role{{Grants: map[string]Permission{"users": PermRead}}}}},
+		{name: "invalid permission", cfg: Config{Roles: []Role{{Name: "r", Grants: map[string]Permission{"users": 7}}}}},
+		{name: "unknown role", cfg: Config{Keys: []KeyConfig{{Key: "k", Principal: Principal{Name: "p", Roles: []string{"ghost"}}}}}},
+		{name: "empty key", cfg: Config{Keys: []KeyConfig{{Principal: Principal{Name: "p"}}}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewAuthenticatorFromConfig(tt.cfg)
+			assert.Error(t, err)
+		})
+	}
+
+	var cfg Config
+	assert.Error(t, json.Unmarshal([]byte(`{"roles": [{"name": "r", "grants": {"users": "owner"}}]}`), &cfg))
+}
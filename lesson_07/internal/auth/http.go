@@ -0,0 +1,87 @@
+package auth
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/Nick2603/golang/lesson_07/internal/httpapi"
+)
+
+type principalKey struct{}
+
+// PrincipalFromContext returns the principal Middleware authenticated.
+func PrincipalFromContext(ctx context.Context) (Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(Principal)
+	return p, ok
+}
+
+// Middleware authenticates requests to an httpapi.Server and checks the
+// permission each route needs, answering 401 or 403 in the server's error
+// format otherwise. Credentials are taken from "Authorization: Bearer
+// <token>" or "X-API-Key: <key>".
+func Middleware(a *Authenticator, logger *slog.Logger, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p, err := a.Authenticate(credential(r))
+		if err != nil {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="docstore"`)
+			httpapi.WriteError(w, err)
+			return
+		}
+
+		collection, need := requirement(r)
+		if err := a.Authorize(p, collection, need); err != nil {
+			logger.Warn("permission denied", "principal", p.Name, "method", r.Method, "path", r.URL.Path, "need", need.String())
+			httpapi.WriteError(w, err)
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), principalKey{}, p)))
+	})
+}
+
+func credential(r *http.Request) string {
+	if h := r.Header.Get("Authorization"); h != "" {
+		scheme, token, ok := strings.Cut(h, " ")
+		if ok && strings.EqualFold(scheme, "Bearer") {
+			return strings.TrimSpace(token)
+		}
+		return ""
+	}
+	return r.Header.Get("X-API-Key")
+}
+
+// requirement maps an httpapi route to the permission it needs. Listing
+// and creating collections are store-wide and checked on AllCollections;
+// unknown routes need admin.
+func requirement(r *http.Request) (string, Permission) {
+	parts := strings.Split(strings.Trim(r.URL.EscapedPath(), "/"), "/")
+	for i, part := range parts {
+		if unescaped, err := url.PathUnescape(part); err == nil {
+			parts[i] = unescaped
+		}
+	}
+	read := r.Method == http.MethodGet || r.Method == http.MethodHead
+
+	switch {
+	case len(parts) == 1 && parts[0] == "collections":
+		if read {
+			return AllCollections, PermRead
+		}
+		return AllCollections, PermAdmin
+	case len(parts) == 2 && parts[0] == "collections":
+		if read {
+			return parts[1], PermRead
+		}
+		return parts[1], PermAdmin
+	case len(parts) >= 3 && parts[0] == "collections" && parts[2] == "documents":
+		if read {
+			return parts[1], PermRead
+		}
+		return parts[1], PermWrite
+	default:
+		return AllCollections, PermAdmin
+	}
+}
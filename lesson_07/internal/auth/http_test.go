@@ -0,0 +1,105 @@
+package auth
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Nick2603/golang/lesson_07/internal/documentstore"
+	"github.com/Nick2603/golang/lesson_07/internal/documentstore/client"
+	"github.com/Nick2603/golang/lesson_07/internal/httpapi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupProtectedServer(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	store := documentstore.NewStoreWithLogger(discardLogger)
+	_, err := store.CreateCollection("users", &documentstore.CollectionConfig{PrimaryKey: "id"})
+	require.NoError(t, err)
+	_, err = store.CreateCollection("orders", &documentstore.CollectionConfig{PrimaryKey: "id"})
+	require.NoError(t, err)
+
+	srv := httptest.NewServer(Middleware(testAuthenticator(t), discardLogger, httpapi.NewServer(store, discardLogger)))
+	t.Cleanup(func() {
+		srv.Close()
+		store.Close()
+	})
+	return srv
+}
+
+func TestMiddleware(t *testing.T) {
+	srv := setupProtectedServer(t)
+
+	tests := []struct {
+		name       string
+		header     string
+		value      string
+		method     string
+		path       string
+		body       string
+		wantStatus int
+		wantCode   string
+	}{
+		{name: "no credentials", method: http.MethodGet, path: "/collections/users", wantStatus: http.StatusUnauthorized, wantCode: "unauthenticated"},
+		{name: "unknown key", header: "X-API-Key", value: "nope", method: http.MethodGet, path: "/collections/users", wantStatus: http.StatusUnauthorized, wantCode: "unauthenticated"},
+		{name: "wrong scheme", header: "Authorization", value: "Basic svc-key", method: http.MethodGet, path: "/collections/users", wantStatus: http.StatusUnauthorized, wantCode: "unauthenticated"},
+		{name: "bearer read", header: "Authorization", value: "Bearer svc-key", method: http.MethodGet, path: "/collections/users", wantStatus: http.StatusOK},
+		{name: "api key write", header: "X-API-Key", value: "svc-key", method: http.MethodPost, path: "/collections/users/documents", body: `{"id": "1"}`, wantStatus: http.StatusCreated},
+		{name: "other collection", header: "X-API-Key", value: "svc-key", method: http.MethodGet, path: "/collections/orders/documents", wantStatus: http.StatusForbidden, wantCode: "permission_denied"},
+		{name: "reader cannot write", header: "X-API-Key", value: "report-key", method: http.MethodDelete, path: "/collections/users/documents/1", wantStatus: http.StatusForbidden, wantCode: "permission_denied"},
+		{name: "writer cannot drop", header: "X-API-Key", value: "svc-key", method: http.MethodDelete, path: "/collections/users", wantStatus: http.StatusForbidden, wantCode: "permission_denied"},
+		{name: "writer cannot dump", header: "X-API-Key", value: "svc-key", method: http.MethodGet, path: "/dump", wantStatus: http.StatusForbidden, wantCode: "permission_denied"},
+		{name: "reader lists collections", header: "X-API-Key", value: "report-key", method: http.MethodGet, path: "/collections", wantStatus: http.StatusOK},
+		{name: "admin dumps", header: "X-API-Key", value: "admin-key", method: http.MethodGet, path: "/dump", wantStatus: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(tt.method, srv.URL+tt.path, strings.NewReader(tt.body))
+			require.NoError(t, err)
+			if tt.header != "" {
+				req.Header.Set(tt.header, tt.value)
+			}
+
+			resp, err := srv.Client().Do(req)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+			body, err := io.ReadAll(resp.Body)
+			require.NoError(t, err)
+
+			assert.Equal(t, tt.wantStatus, resp.StatusCode, string(body))
+			if tt.wantCode != "" {
+				var errResp httpapi.ErrorResponse
+				require.NoError(t, json.Unmarshal(body, &errResp))
+				assert.Equal(t, tt.wantCode, errResp.Code)
+			}
+		})
+	}
+}
+
+func TestMiddleware_Client(t *testing.T) {
+	srv := setupProtectedServer(t)
+
+	c, err := client.New(client.Config{BaseURL: srv.URL, Token: "report-key", Logger: discardLogger})
+	require.NoError(t, err)
+
+	key, err := c.Collection("users").Key("1")
+	require.NoError(t, err)
+	_, err = c.Collection("users").Get(key)
+	assert.ErrorIs(t, err, documentstore.ErrDocumentNotFound)
+
+	err = c.Collection("users").Put(documentstore.Document{Fields: map[string]documentstore.DocumentField{
+		"id": {Type: documentstore.DocumentFieldTypeString, Value: "1"},
+	}})
+	assert.ErrorIs(t, err, documentstore.ErrPermissionDenied)
+
+	anonymous, err := client.New(client.Config{BaseURL: srv.URL, Logger: discardLogger})
+	require.NoError(t, err)
+	_, err = anonymous.Collection("users").Get(key)
+	assert.ErrorIs(t, err, documentstore.ErrUnauthenticated)
+}
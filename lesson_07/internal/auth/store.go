@@ -0,0 +1,178 @@
+package auth
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/Nick2603/golang/lesson_07/internal/documentstore"
+)
+
+// Store is a *documentstore.Store seen by one principal. Every operation
+// checks the principal's permissions first:
+//
+//   - reading documents or collection info needs read on the collection;
+//   - writing or deleting documents needs write;
+//   - creating or deleting a collection needs admin on it;
+//   - dumping and restoring the whole store need admin on AllCollections.
+type Store struct {
+	store     *documentstore.Store
+	auth      *Authenticator
+	principal Principal
+	logger    *slog.Logger
+}
+
+func NewStore(store *documentstore.Store, a *Authenticator, p Principal, logger *slog.Logger) *Store {
+	return &Store{store: store, auth: a, principal: p, logger: logger}
+}
+
+func (s *Store) authorize(collection string, need Permission) error {
+	err := s.auth.Authorize(s.principal, collection, need)
+	if err != nil {
+		s.logger.Warn("permission denied", "principal", s.principal.Name, "collection", collection, "need", need.String())
+	}
+	return err
+}
+
+func (s *Store) CreateCollection(name string, cfg *documentstore.CollectionConfig) (*Collection, error) {
+	if err := s.authorize(name, PermAdmin); err != nil {
+		return nil, err
+	}
+	coll, err := s.store.CreateCollection(name, cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &Collection{coll: coll, name: name, store: s}, nil
+}
+
+func (s *Store) GetCollection(name string) (*Collection, error) {
+	if err := s.authorize(name, PermRead); err != nil {
+		return nil, err
+	}
+	coll, err := s.store.GetCollection(name)
+	if err != nil {
+		return nil, err
+	}
+	return &Collection{coll: coll, name: name, store: s}, nil
+}
+
+func (s *Store) DeleteCollection(name string) error {
+	if err := s.authorize(name, PermAdmin); err != nil {
+		return err
+	}
+	return s.store.DeleteCollection(name)
+}
+
+// Info describes the store, listing only the collections the principal
+// may read.
+func (s *Store) Info() documentstore.StoreInfo {
+	info := s.store.Info()
+	visible := info.Collections[:0]
+	for _, ci := range info.Collections {
+		if s.auth.Permission(s.principal, ci.Name) >= PermRead {
+			visible = append(visible, ci)
+		}
+	}
+	info.Collections = visible
+	return info
+}
+
+func (s *Store) Dump() ([]byte, error) {
+	if err := s.authorize(AllCollections, PermAdmin); err != nil {
+		return nil, err
+	}
+	return s.store.Dump()
+}
+
+func (s *Store) RestoreFromDump(dump []byte, opts documentstore.RestoreOptions) (*documentstore.RestoreReport, error) {
+	if err := s.authorize(AllCollections, PermAdmin); err != nil {
+		return nil, err
+	}
+	return s.store.RestoreFromDump(dump, opts)
+}
+
+// Watch streams changes of the collections in opts, all of which the
+// principal must be able to read; no collections means all of them and
+// needs read on AllCollections.
+func (s *Store) Watch(ctx context.Context, opts documentstore.WatchOptions) (*documentstore.ChangeStream, error) {
+	names := opts.Collections
+	if len(names) == 0 {
+		names = []string{AllCollections}
+	}
+	for _, name := range names {
+		if err := s.authorize(name, PermRead); err != nil {
+			return nil, err
+		}
+	}
+	return s.store.Watch(ctx, opts)
+}
+
+// Collection is a collection seen by the principal of the Store it came
+// from. It satisfies users.CollectionStore.
+type Collection struct {
+	coll  *documentstore.Collection
+	name  string
+	store *Store
+}
+
+func (c *Collection) Put(doc documentstore.Document) error {
+	if err := c.store.authorize(c.name, PermWrite); err != nil {
+		return err
+	}
+	return c.coll.Put(doc)
+}
+
+func (c *Collection) Insert(doc documentstore.Document, opts ...documentstore.PutOption) (string, error) {
+	if err := c.store.authorize(c.name, PermWrite); err != nil {
+		return "", err
+	}
+	return c.coll.Insert(doc, opts...)
+}
+
+// Key needs no permission: it only encodes values.
+func (c *Collection) Key(values ...any) (string, error) {
+	return c.coll.Key(values...)
+}
+
+func (c *Collection) Get(key string) (*documentstore.Document, error) {
+	if err := c.store.authorize(c.name, PermRead); err != nil {
+		return nil, err
+	}
+	return c.coll.Get(key)
+}
+
+// List returns no documents if the principal has lost read access since
+// the collection was opened.
+func (c *Collection) List() []documentstore.Document {
+	if err := c.store.authorize(c.name, PermRead); err != nil {
+		return []documentstore.Document{}
+	}
+	return c.coll.List()
+}
+
+// Scan needs read permission, which is checked once before the scan starts.
+func (c *Collection) Scan(fn func(key string, doc documentstore.Document) bool) error {
+	if err := c.store.authorize(c.name, PermRead); err != nil {
+		return err
+	}
+	return c.coll.Scan(fn)
+}
+
+// ScanFrom needs read permission, which is checked once before the scan
+// starts.
+func (c *Collection) ScanFrom(start string, fn func(key string, doc documentstore.Document) bool) error {
+	if err := c.store.authorize(c.name, PermRead); err != nil {
+		return err
+	}
+	return c.coll.ScanFrom(start, fn)
+}
+
+func (c *Collection) Delete(key string) error {
+	if err := c.store.authorize(c.name, PermWrite); err != nil {
+		return err
+	}
+	return c.coll.Delete(key)
+}
+
+func (c *Collection) Info() documentstore.CollectionInfo {
+	return c.coll.Info()
+}
@@ -0,0 +1,76 @@
+package auth
+
+import (
+	"io"
+	"log/slog"
+	"testing"
+
+	"github.com/Nick2603/golang/lesson_07/internal/documentstore"
+	"github.com/Nick2603/golang/lesson_07/internal/users"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var discardLogger = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+func storeAs(t *testing.T, a *Authenticator, store *documentstore.Store, key string) *Store {
+	t.Helper()
+
+	p, err := a.Authenticate(key)
+	require.NoError(t, err)
+	return NewStore(store, a, p, discardLogger)
+}
+
+func TestStore_Permissions(t *testing.T) {
+	a := testAuthenticator(t)
+	store := documentstore.NewStoreWithLogger(discardLogger)
+	defer store.Close()
+
+	admin := storeAs(t, a, store, "admin-key")
+	svc := storeAs(t, a, store, "svc-key")
+	reports := storeAs(t, a, store, "report-key")
+
+	_, err := svc.CreateCollection("users", &documentstore.CollectionConfig{PrimaryKey: "id"})
+	assert.ErrorIs(t, err, documentstore.ErrPermissionDenied)
+	_, err = admin.CreateCollection("users", &documentstore.CollectionConfig{PrimaryKey: "id"})
+	require.NoError(t, err)
+	_, err = admin.CreateCollection("orders", &documentstore.CollectionConfig{PrimaryKey: "id"})
+	require.NoError(t, err)
+
+	// users.Service runs unchanged on an authorized collection.
+	svcUsers, err := svc.GetCollection("users")
+	require.NoError(t, err)
+	userService := users.NewService(svcUsers)
+	_, err = userService.CreateUser("1", "Alice")
+	require.NoError(t, err)
+
+	_, err = svc.GetCollection("orders")
+	assert.ErrorIs(t, err, documentstore.ErrPermissionDenied)
+
+	reportUsers, err := reports.GetCollection("users")
+	require.NoError(t, err)
+	assert.Len(t, reportUsers.List(), 1)
+	key, err := reportUsers.Key("1")
+	require.NoError(t, err)
+	_, err = reportUsers.Get(key)
+	assert.NoError(t, err)
+	assert.ErrorIs(t, reportUsers.Delete(key), documentstore.ErrPermissionDenied)
+	_, err = users.NewService(reportUsers).CreateUser("2", "Bob")
+	assert.ErrorIs(t, err, documentstore.ErrPermissionDenied)
+
+	assert.ErrorIs(t, svc.DeleteCollection("users"), documentstore.ErrPermissionDenied)
+	_, err = svc.Dump()
+	assert.ErrorIs(t, err, documentstore.ErrPermissionDenied)
+	_, err = reports.RestoreFromDump([]byte(`{}`), documentstore.RestoreOptions{})
+	assert.ErrorIs(t, err, documentstore.ErrPermissionDenied)
+
+	var names []string
+	for _, ci := range svc.Info().Collections {
+		names = append(names, ci.Name)
+	}
+	assert.Equal(t, []string{"users"}, names)
+
+	_, err = admin.Dump()
+	assert.NoError(t, err)
+	assert.NoError(t, admin.DeleteCollection("orders"))
+}
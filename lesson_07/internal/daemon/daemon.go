@@ -0,0 +1,37 @@
+// Package daemon holds the start-up helpers shared by the store servers.
+package daemon
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"os"
+
+	"github.com/Nick2603/golang/lesson_07/internal/auth"
+	"github.com/Nick2603/golang/lesson_07/internal/documentstore"
+)
+
+// LoadAuth builds an authenticator from a JSON auth.Config file.
+func LoadAuth(filename string) (*auth.Authenticator, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	var cfg auth.Config
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, err
+	}
+	return auth.NewAuthenticatorFromConfig(cfg)
+}
+
+// OpenStore restores the store from dumpFile if it exists, or starts empty.
+func OpenStore(dumpFile string, logger *slog.Logger) (*documentstore.Store, error) {
+	if dumpFile == "" {
+		return documentstore.NewStoreWithLogger(logger), nil
+	}
+	if _, err := os.Stat(dumpFile); errors.Is(err, os.ErrNotExist) {
+		return documentstore.NewStoreWithLogger(logger), nil
+	}
+	return documentstore.NewStoreFromFile(dumpFile)
+}
@@ -0,0 +1,60 @@
+package daemon
+
+import (
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Nick2603/golang/lesson_07/internal/documentstore"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadAuth(t *testing.T) {
+	dir := t.TempDir()
+
+	valid := filepath.Join(dir, "auth.json")
+	require.NoError(t, os.WriteFile(valid, []byte(`{
+		"roles": [{"name": "reader", "grants": {"*": "read"}}],
+		"keys": [{"key": "secret", "principal": {"name": "alice", "roles": ["reader"]}}]
+	}`), 0o600))
+
+	authn, err := LoadAuth(valid)
+	require.NoError(t, err)
+	p, err := authn.Authenticate("secret")
+	require.NoError(t, err)
+	assert.Equal(t, "alice", p.Name)
+
+	malformed := filepath.Join(dir, "bad.json")
+	require.NoError(t, os.WriteFile(malformed, []byte(`{`), 0o600))
+	_, err = LoadAuth(malformed)
+	assert.Error(t, err)
+
+	_, err = LoadAuth(filepath.Join(dir, "missing.json"))
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestOpenStore(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	dumpFile := filepath.Join(t.TempDir(), "store.dump")
+
+	store, err := OpenStore(dumpFile, logger)
+	require.NoError(t, err)
+	_, err = store.CreateCollection("users", &documentstore.CollectionConfig{PrimaryKey: "id"})
+	require.NoError(t, err)
+	require.NoError(t, store.DumpToFile(dumpFile))
+	require.NoError(t, store.Close())
+
+	restored, err := OpenStore(dumpFile, logger)
+	require.NoError(t, err)
+	defer restored.Close()
+	_, err = restored.GetCollection("users")
+	assert.NoError(t, err)
+
+	empty, err := OpenStore("", logger)
+	require.NoError(t, err)
+	defer empty.Close()
+	assert.Empty(t, empty.Info().Collections)
+}
@@ -0,0 +1,361 @@
+package documentstore
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	bitcaskFileSuffix      = ".data"
+	defaultBitcaskFileSize = 64 << 20
+)
+
+// bitcaskEngine is an append-only log of documents split into data files,
+// with an in-memory directory pointing at the latest value of every key.
+type bitcaskEngine struct {
+	dir    string
+	unlock func() error
+	keydir map[string]bitcaskEntry
+	// keys caches the sorted keys of keydir; nil after the key set changes.
+	keys        []string
+	files       map[uint32]*os.File
+	active      *os.File
+	activeID    uint32
+	activeSize  int64
+	maxFileSize int64
+}
+
+type bitcaskEntry struct {
+	fileID uint32
+	offset int64
+	size   uint32
+}
+
+func openBitcaskEngine(dir string) (*bitcaskEngine, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	unlock, err := lockDataDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	ids, err := bitcaskFileIDs(dir)
+	if err != nil {
+		unlock()
+		return nil, err
+	}
+
+	e := &bitcaskEngine{
+		dir:         dir,
+		unlock:      unlock,
+		keydir:      make(map[string]bitcaskEntry),
+		files:       make(map[uint32]*os.File),
+		maxFileSize: defaultBitcaskFileSize,
+	}
+
+	for i, id := range ids {
+		last := i == len(ids)-1
+		if err := e.loadFile(id, last); err != nil {
+			e.Close()
+			return nil, err
+		}
+	}
+
+	if len(ids) == 0 {
+		if err := e.rotate(); err != nil {
+			e.Close()
+			return nil, err
+		}
+	}
+
+	return e, nil
+}
+
+func bitcaskFileIDs(dir string) ([]uint32, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var ids []uint32
+	for _, entry := range entries {
+		name := entry.Name()
+		if !strings.HasSuffix(name, bitcaskFileSuffix) {
+			continue
+		}
+		id, err := strconv.ParseUint(strings.TrimSuffix(name, bitcaskFileSuffix), 10, 32)
+		if err != nil {
+			continue
+		}
+		ids = append(ids, uint32(id))
+	}
+
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids, nil
+}
+
+func (e *bitcaskEngine) filePath(id uint32) string {
+	return filepath.Join(e.dir, fmt.Sprintf("%09d%s", id, bitcaskFileSuffix))
+}
+
+// loadFile replays one data file into the key directory. A damaged tail is
+// tolerated, and truncated, only in the last file, where a crash can leave
+// a partially written record.
+func (e *bitcaskEngine) loadFile(id uint32, last bool) error {
+	flag := os.O_RDONLY
+	if last {
+		flag = os.O_RDWR
+	}
+
+	file, err := os.OpenFile(e.filePath(id), flag, 0o644)
+	if err != nil {
+		return err
+	}
+	e.files[id] = file
+
+	var offset int64
+	for {
+		rec, size, err := readLogRecord(file, offset)
+		if errors.Is(err, io.EOF) || errors.Is(err, errTornRecord) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		if rec.tombstone() {
+			delete(e.keydir, rec.key)
+		} else {
+			e.keydir[rec.key] = bitcaskEntry{
+				fileID: id,
+				offset: offset + size - int64(len(rec.value)),
+				size:   uint32(len(rec.value)),
+			}
+		}
+
+		offset += size
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+
+	if offset != info.Size() {
+		if !last {
+			return fmt.Errorf("%w: %s", ErrCorruptDataFile, e.filePath(id))
+		}
+		if err := file.Truncate(offset); err != nil {
+			return err
+		}
+	}
+
+	if last {
+		e.active = file
+		e.activeID = id
+		e.activeSize = offset
+	}
+
+	return nil
+}
+
+// rotate seals the active file and starts a new, empty one.
+func (e *bitcaskEngine) rotate() error {
+	if e.active != nil {
+		if err := e.active.Sync(); err != nil {
+			return err
+		}
+	}
+
+	id := e.activeID + 1
+	file, err := os.OpenFile(e.filePath(id), os.O_CREATE|os.O_RDWR|os.O_EXCL, 0o644)
+	if err != nil {
+		return err
+	}
+
+	e.files[id] = file
+	e.active = file
+	e.activeID = id
+	e.activeSize = 0
+	return nil
+}
+
+func (e *bitcaskEngine) write(key string, value []byte, flags byte) (bitcaskEntry, error) {
+	record := encodeLogRecord(key, value, flags)
+	size := int64(len(record))
+	if e.activeSize > 0 && e.activeSize+size > e.maxFileSize {
+		if err := e.rotate(); err != nil {
+			return bitcaskEntry{}, err
+		}
+	}
+
+	if _, err := e.active.WriteAt(record, e.activeSize); err != nil {
+		return bitcaskEntry{}, err
+	}
+
+	entry := bitcaskEntry{
+		fileID: e.activeID,
+		offset: e.activeSize + logRecordHeaderSize + int64(len(key)),
+		size:   uint32(len(value)),
+	}
+	e.activeSize += size
+	return entry, nil
+}
+
+func (e *bitcaskEngine) read(entry bitcaskEntry) (Document, error) {
+	value := make([]byte, entry.size)
+	if _, err := e.files[entry.fileID].ReadAt(value, entry.offset); err != nil {
+		return Document{}, err
+	}
+	return decodeDocument(value)
+}
+
+func (e *bitcaskEngine) Get(key string) (Document, bool, error) {
+	entry, ok := e.keydir[key]
+	if !ok {
+		return Document{}, false, nil
+	}
+
+	doc, err := e.read(entry)
+	if err != nil {
+		return Document{}, false, err
+	}
+	return doc, true, nil
+}
+
+func (e *bitcaskEngine) Put(key string, doc Document) error {
+	value, err := encodeDocument(doc)
+	if err != nil {
+		return err
+	}
+
+	entry, err := e.write(key, value, 0)
+	if err != nil {
+		return err
+	}
+
+	if _, ok := e.keydir[key]; !ok {
+		e.keys = nil
+	}
+	e.keydir[key] = entry
+	return nil
+}
+
+func (e *bitcaskEngine) Delete(key string) error {
+	if _, ok := e.keydir[key]; !ok {
+		return nil
+	}
+
+	if _, err := e.write(key, nil, logRecordTombstone); err != nil {
+		return err
+	}
+
+	delete(e.keydir, key)
+	e.keys = nil
+	return nil
+}
+
+func (e *bitcaskEngine) Scan(fn func(key string, doc Document) bool) error {
+	return e.ScanFrom("", fn)
+}
+
+func (e *bitcaskEngine) ScanFrom(start string, fn func(key string, doc Document) bool) error {
+	if e.keys == nil {
+		e.keys = sortedKeys(e.keydir)
+	}
+
+	keys := e.keys
+	for _, key := range keys[sort.SearchStrings(keys, start):] {
+		entry, ok := e.keydir[key]
+		if !ok {
+			continue
+		}
+		doc, err := e.read(entry)
+		if err != nil {
+			return err
+		}
+		if !fn(key, doc) {
+			break
+		}
+	}
+	return nil
+}
+
+func (e *bitcaskEngine) Len() int {
+	return len(e.keydir)
+}
+
+// Compact rewrites the live documents into fresh data files and removes
+// the old ones. Old files are removed oldest first, so a crash part way
+// through never lets an older value outlive a tombstone.
+func (e *bitcaskEngine) Compact() error {
+	oldIDs := make([]uint32, 0, len(e.files))
+	for id := range e.files {
+		oldIDs = append(oldIDs, id)
+	}
+	sort.Slice(oldIDs, func(i, j int) bool { return oldIDs[i] < oldIDs[j] })
+
+	if err := e.rotate(); err != nil {
+		return err
+	}
+
+	keys := sortedKeys(e.keydir)
+
+	keydir := make(map[string]bitcaskEntry, len(e.keydir))
+	for _, key := range keys {
+		old := e.keydir[key]
+		value := make([]byte, old.size)
+		if _, err := e.files[old.fileID].ReadAt(value, old.offset); err != nil {
+			return err
+		}
+
+		entry, err := e.write(key, value, 0)
+		if err != nil {
+			return err
+		}
+		keydir[key] = entry
+	}
+
+	if err := e.active.Sync(); err != nil {
+		return err
+	}
+	e.keydir = keydir
+
+	for _, id := range oldIDs {
+		file := e.files[id]
+		delete(e.files, id)
+		if err := file.Close(); err != nil {
+			return err
+		}
+		if err := os.Remove(e.filePath(id)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (e *bitcaskEngine) Close() error {
+	var errs []error
+	if e.active != nil {
+		errs = append(errs, e.active.Sync())
+	}
+	for id, file := range e.files {
+		errs = append(errs, file.Close())
+		delete(e.files, id)
+	}
+	e.active = nil
+	if e.unlock != nil {
+		errs = append(errs, e.unlock())
+		e.unlock = nil
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,181 @@
+package documentstore
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBitcaskEngine_Persistence(t *testing.T) {
+	dir := t.TempDir()
+
+	engine, err := openBitcaskEngine(dir)
+	require.NoError(t, err)
+
+	doc := Document{
+		Fields: map[string]DocumentField{
+			"id":     {Type: DocumentFieldTypeString, Value: "user:1"},
+			"age":    {Type: DocumentFieldTypeNumber, Value: int64(25)},
+			"score":  {Type: DocumentFieldTypeNumber, Value: 9.5},
+			"active": {Type: DocumentFieldTypeBool, Value: true},
+		},
+	}
+	require.NoError(t, engine.Put("user:1", doc))
+	require.NoError(t, engine.Put("user:2", userDoc("user:2", "Bob")))
+	require.NoError(t, engine.Put("user:2", userDoc("user:2", "Bobby")))
+	require.NoError(t, engine.Put("user:3", userDoc("user:3", "Charlie")))
+	require.NoError(t, engine.Delete("user:3"))
+	require.NoError(t, engine.Close())
+
+	reopened, err := openBitcaskEngine(dir)
+	require.NoError(t, err)
+	defer reopened.Close()
+
+	assert.Equal(t, 2, reopened.Len())
+
+	got, ok, err := reopened.Get("user:1")
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, doc, got)
+
+	got, ok, err = reopened.Get("user:2")
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, "Bobby", got.Fields["name"].Value)
+
+	_, ok, err = reopened.Get("user:3")
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	var keys []string
+	require.NoError(t, reopened.Scan(func(key string, _ Document) bool {
+		keys = append(keys, key)
+		return true
+	}))
+	assert.Equal(t, []string{"user:1", "user:2"}, keys)
+}
+
+func TestBitcaskEngine_RotateAndCompact(t *testing.T) {
+	dir := t.TempDir()
+
+	engine, err := openBitcaskEngine(dir)
+	require.NoError(t, err)
+	engine.maxFileSize = 256
+
+	for i := 0; i < 20; i++ {
+		require.NoError(t, engine.Put("user:1", userDoc("user:1", "Alice")))
+		require.NoError(t, engine.Put("user:2", userDoc("user:2", "Bob")))
+	}
+	require.NoError(t, engine.Delete("user:2"))
+
+	before, err := bitcaskFileIDs(dir)
+	require.NoError(t, err)
+	assert.Greater(t, len(before), 2)
+
+	require.NoError(t, engine.Compact())
+
+	after, err := bitcaskFileIDs(dir)
+	require.NoError(t, err)
+	assert.Len(t, after, 1)
+	assert.Greater(t, after[0], before[len(before)-1])
+
+	require.NoError(t, engine.Close())
+
+	reopened, err := openBitcaskEngine(dir)
+	require.NoError(t, err)
+	defer reopened.Close()
+
+	assert.Equal(t, 1, reopened.Len())
+	got, ok, err := reopened.Get("user:1")
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, "Alice", got.Fields["name"].Value)
+}
+
+func TestBitcaskEngine_TornWrite(t *testing.T) {
+	dir := t.TempDir()
+
+	engine, err := openBitcaskEngine(dir)
+	require.NoError(t, err)
+	require.NoError(t, engine.Put("user:1", userDoc("user:1", "Alice")))
+	require.NoError(t, engine.Put("user:2", userDoc("user:2", "Bob")))
+	path := engine.filePath(engine.activeID)
+	require.NoError(t, engine.Close())
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	require.NoError(t, os.Truncate(path, info.Size()-3))
+
+	reopened, err := openBitcaskEngine(dir)
+	require.NoError(t, err)
+
+	assert.Equal(t, 1, reopened.Len())
+	require.NoError(t, reopened.Put("user:3", userDoc("user:3", "Charlie")))
+	require.NoError(t, reopened.Close())
+
+	again, err := openBitcaskEngine(dir)
+	require.NoError(t, err)
+	defer again.Close()
+
+	assert.Equal(t, 2, again.Len())
+	_, ok, err := again.Get("user:3")
+	require.NoError(t, err)
+	assert.True(t, ok)
+}
+
+func TestStore_BitcaskCollection(t *testing.T) {
+	dir := t.TempDir()
+	cfg := &CollectionConfig{PrimaryKey: "id", Engine: EngineBitcask, DataDir: dir}
+
+	store := NewStore()
+	coll, err := store.CreateCollection("users", cfg)
+	require.NoError(t, err)
+	require.NoError(t, coll.Put(userDoc("user:1", "Alice")))
+	require.NoError(t, coll.Put(userDoc("user:2", "Bob")))
+	require.NoError(t, coll.Delete("user:2"))
+	require.NoError(t, coll.Compact())
+	require.NoError(t, store.Close())
+
+	reopened := NewStore()
+	coll, err = reopened.CreateCollection("users", cfg)
+	require.NoError(t, err)
+	defer reopened.Close()
+
+	assert.Len(t, coll.List(), 1)
+	doc, err := coll.Get("user:1")
+	require.NoError(t, err)
+	assert.Equal(t, "Alice", doc.Fields["name"].Value)
+}
+
+func TestStore_CreateCollection_InvalidEngine(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  *CollectionConfig
+	}{
+		{
+			name: "unknown engine",
+			cfg:  &CollectionConfig{PrimaryKey: "id", Engine: "papyrus"},
+		},
+		{
+			name: "bitcask without data dir",
+			cfg:  &CollectionConfig{PrimaryKey: "id", Engine: EngineBitcask},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := NewStore()
+
+			coll, err := store.CreateCollection("users", tt.cfg)
+			assert.ErrorIs(t, err, ErrInvalidEngineConfig)
+			assert.Nil(t, coll)
+		})
+	}
+}
+
+func TestCollection_Compact_NotSupported(t *testing.T) {
+	coll := NewCollection(CollectionConfig{PrimaryKey: "id"})
+	assert.ErrorIs(t, coll.Compact(), ErrCompactionNotSupported)
+}
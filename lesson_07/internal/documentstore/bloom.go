@@ -0,0 +1,52 @@
+package documentstore
+
+import (
+	"hash/fnv"
+	"math"
+)
+
+const bloomBitsPerKey = 10
+
+// bloomFilter answers "definitely absent" or "maybe present" for keys of
+// an SSTable, so lookups can skip tables without touching their index.
+type bloomFilter struct {
+	bits []byte
+	k    uint32
+}
+
+func newBloomFilter(n int) *bloomFilter {
+	m := max(n*bloomBitsPerKey, 64)
+	k := uint32(math.Round(float64(bloomBitsPerKey) * math.Ln2))
+	return &bloomFilter{
+		bits: make([]byte, (m+7)/8),
+		k:    max(k, 1),
+	}
+}
+
+func bloomHashes(key string) (uint32, uint32) {
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	sum := h.Sum64()
+	return uint32(sum), uint32(sum >> 32)
+}
+
+func (b *bloomFilter) add(key string) {
+	h1, h2 := bloomHashes(key)
+	m := uint32(len(b.bits) * 8)
+	for i := uint32(0); i < b.k; i++ {
+		bit := (h1 + i*h2) % m
+		b.bits[bit/8] |= 1 << (bit % 8)
+	}
+}
+
+func (b *bloomFilter) mayContain(key string) bool {
+	h1, h2 := bloomHashes(key)
+	m := uint32(len(b.bits) * 8)
+	for i := uint32(0); i < b.k; i++ {
+		bit := (h1 + i*h2) % m
+		if b.bits[bit/8]&(1<<(bit%8)) == 0 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,174 @@
+// Package bulk imports documents into a collection from JSON Lines or CSV
+// and exports them back. Imports are streamed line by line; a bad line is
+// reported and skipped without aborting the rest.
+package bulk
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/Nick2603/golang/lesson_07/internal/documentstore"
+)
+
+// maxLineSize bounds a single JSON Lines record.
+const maxLineSize = 16 << 20
+
+type Format string
+
+const (
+	FormatJSONL Format = "jsonl"
+	FormatCSV   Format = "csv"
+)
+
+var ErrUnknownFormat = errors.New("unknown format")
+
+// ParseFormat accepts a format name, or returns ErrUnknownFormat.
+func ParseFormat(s string) (Format, error) {
+	switch f := Format(strings.ToLower(s)); f {
+	case FormatJSONL, FormatCSV:
+		return f, nil
+	case "ndjson":
+		return FormatJSONL, nil
+	default:
+		return "", fmt.Errorf("%w %q", ErrUnknownFormat, s)
+	}
+}
+
+// FormatFromPath picks the format matching the extension of filename.
+func FormatFromPath(filename string) (Format, error) {
+	return ParseFormat(strings.TrimPrefix(filepath.Ext(filename), "."))
+}
+
+// Collection is what import and export need of a collection. Both
+// *documentstore.Collection and *auth.Collection satisfy it.
+type Collection interface {
+	Insert(doc documentstore.Document, opts ...documentstore.PutOption) (string, error)
+	Scan(fn func(key string, doc documentstore.Document) bool) error
+	Info() documentstore.CollectionInfo
+}
+
+type ImportOptions struct {
+	Format Format
+	// ColumnTypes sets the type of CSV columns. Other columns are inferred
+	// per value: true and false become bools, numbers become numbers and
+	// anything else a string. Primary key columns are never inferred and
+	// stay strings unless listed here.
+	ColumnTypes map[string]documentstore.DocumentFieldType
+}
+
+// LineError is a line that could not be imported. Line counts from 1 and
+// includes the CSV header.
+type LineError struct {
+	Line int
+	Err  error
+}
+
+func (e *LineError) Error() string {
+	return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+}
+
+func (e *LineError) Unwrap() error {
+	return e.Err
+}
+
+type ImportReport struct {
+	Imported int
+	Errors   []*LineError
+}
+
+// Import reads documents from r and inserts them into coll. Lines that
+// cannot be parsed or stored are listed in the report; the returned error
+// is only set when r itself fails or, for CSV, the header is invalid.
+func Import(coll Collection, r io.Reader, opts ImportOptions) (*ImportReport, error) {
+	switch opts.Format {
+	case FormatJSONL:
+		return importJSONL(coll, r)
+	case FormatCSV:
+		return importCSV(coll, r, opts)
+	default:
+		return nil, fmt.Errorf("%w %q", ErrUnknownFormat, opts.Format)
+	}
+}
+
+func importJSONL(coll Collection, r io.Reader) (*ImportReport, error) {
+	report := &ImportReport{}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLineSize)
+
+	for line := 1; scanner.Scan(); line++ {
+		data := scanner.Bytes()
+		if len(strings.TrimSpace(string(data))) == 0 {
+			continue
+		}
+
+		doc, err := documentstore.DecodeDocument(data)
+		if err == nil {
+			_, err = coll.Insert(doc)
+		}
+		report.add(line, err)
+	}
+
+	return report, scanner.Err()
+}
+
+func (r *ImportReport) add(line int, err error) {
+	if err != nil {
+		r.Errors = append(r.Errors, &LineError{Line: line, Err: err})
+		return
+	}
+	r.Imported++
+}
+
+type ExportOptions struct {
+	Format Format
+	// Columns sets the CSV columns and their order. By default the primary
+	// key columns come first, followed by all other fields sorted by name.
+	Columns []string
+}
+
+// Export streams every document of coll to w in key order and returns how
+// many were written.
+func Export(coll Collection, w io.Writer, opts ExportOptions) (int, error) {
+	switch opts.Format {
+	case FormatJSONL:
+		return exportJSONL(coll, w)
+	case FormatCSV:
+		return exportCSV(coll, w, opts)
+	default:
+		return 0, fmt.Errorf("%w %q", ErrUnknownFormat, opts.Format)
+	}
+}
+
+func exportJSONL(coll Collection, w io.Writer) (int, error) {
+	bw := bufio.NewWriter(w)
+	enc := json.NewEncoder(bw)
+
+	n := 0
+	var writeErr error
+	err := coll.Scan(func(_ string, doc documentstore.Document) bool {
+		if writeErr = enc.Encode(documentstore.EncodeDocument(doc)); writeErr != nil {
+			return false
+		}
+		n++
+		return true
+	})
+	if err == nil {
+		err = writeErr
+	}
+	if err != nil {
+		return n, err
+	}
+	return n, bw.Flush()
+}
+
+func keyFields(cfg documentstore.CollectionConfig) []string {
+	if len(cfg.PrimaryKeys) > 0 {
+		return cfg.PrimaryKeys
+	}
+	return []string{cfg.PrimaryKey}
+}
@@ -0,0 +1,250 @@
+package bulk
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/Nick2603/golang/lesson_07/internal/documentstore"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newUsers(t *testing.T) *documentstore.Collection {
+	t.Helper()
+
+	coll, err := documentstore.OpenCollection(documentstore.CollectionConfig{PrimaryKey: "id"})
+	require.NoError(t, err)
+	t.Cleanup(func() { coll.Close() })
+	return coll
+}
+
+func field(typ documentstore.DocumentFieldType, value any) documentstore.DocumentField {
+	return documentstore.DocumentField{Type: typ, Value: value}
+}
+
+func lineNumbers(report *ImportReport) []int {
+	var lines []int
+	for _, e := range report.Errors {
+		lines = append(lines, e.Line)
+	}
+	return lines
+}
+
+func TestImport_JSONL(t *testing.T) {
+	coll := newUsers(t)
+
+	input := `{"id": "1", "name": "Alice", "age": 30}
+{"id": "2", "name": "Bob", "address": {"city": "Kyiv"}}
+
+not json
+{"name": "no key"}
+{"id": "3", "active": true}
+`
+	report, err := Import(coll, strings.NewReader(input), ImportOptions{Format: FormatJSONL})
+	require.NoError(t, err)
+
+	assert.Equal(t, 2, report.Imported)
+	assert.Equal(t, []int{2, 4, 5}, lineNumbers(report))
+	assert.ErrorIs(t, report.Errors[0], documentstore.ErrUnsupportedDocumentField)
+	assert.ErrorIs(t, report.Errors[2], documentstore.ErrInvalidPrimaryKey)
+
+	key, err := coll.Key("1")
+	require.NoError(t, err)
+	doc, err := coll.Get(key)
+	require.NoError(t, err)
+	assert.Equal(t, field(documentstore.DocumentFieldTypeNumber, int64(30)), doc.Fields["age"])
+}
+
+func TestImport_CSV(t *testing.T) {
+	tests := []struct {
+		name      string
+		input     string
+		types     map[string]documentstore.DocumentFieldType
+		wantDocs  map[string]map[string]documentstore.DocumentField
+		wantLines []int
+	}{
+		{
+			name:  "infers types but keeps keys as strings",
+			input: "id,name,age,score,active,zip\n007,Alice,30,4.5,true,01234\n",
+			wantDocs: map[string]map[string]documentstore.DocumentField{
+				"007": {
+					"id":     field(documentstore.DocumentFieldTypeString, "007"),
+					"name":   field(documentstore.DocumentFieldTypeString, "Alice"),
+					"age":    field(documentstore.DocumentFieldTypeNumber, int64(30)),
+					"score":  field(documentstore.DocumentFieldTypeNumber, 4.5),
+					"active": field(documentstore.DocumentFieldTypeBool, true),
+					"zip":    field(documentstore.DocumentFieldTypeNumber, int64(1234)),
+				},
+			},
+		},
+		{
+			name:  "explicit column types",
+			input: "id,zip,active\n1,01234,1\n",
+			types: map[string]documentstore.DocumentFieldType{
+				"zip":    documentstore.DocumentFieldTypeString,
+				"active": documentstore.DocumentFieldTypeBool,
+			},
+			wantDocs: map[string]map[string]documentstore.DocumentField{
+				"1": {
+					"id":     field(documentstore.DocumentFieldTypeString, "1"),
+					"zip":    field(documentstore.DocumentFieldTypeString, "01234"),
+					"active": field(documentstore.DocumentFieldTypeBool, true),
+				},
+			},
+		},
+		{
+			name:  "empty cells are left out",
+			input: "id,name,age\n1,,\n",
+			wantDocs: map[string]map[string]documentstore.DocumentField{
+				"1": {"id": field(documentstore.DocumentFieldTypeString, "1")},
+			},
+		},
+		{
+			name:  "bad lines are reported and skipped",
+			input: "id,age\n1,30\n2,thirty\n3\n,40\n4,\"unterminated\n",
+			types: map[string]documentstore.DocumentFieldType{"age": documentstore.DocumentFieldTypeNumber},
+			wantDocs: map[string]map[string]documentstore.DocumentField{
+				"1": {
+					"id":  field(documentstore.DocumentFieldTypeString, "1"),
+					"age": field(documentstore.DocumentFieldTypeNumber, int64(30)),
+				},
+			},
+			wantLines: []int{3, 4, 5, 6},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			coll := newUsers(t)
+
+			report, err := Import(coll, strings.NewReader(tt.input), ImportOptions{Format: FormatCSV, ColumnTypes: tt.types})
+			require.NoError(t, err)
+			assert.Equal(t, len(tt.wantDocs), report.Imported)
+			assert.Equal(t, tt.wantLines, lineNumbers(report))
+
+			for id, fields := range tt.wantDocs {
+				key, err := coll.Key(id)
+				require.NoError(t, err)
+				doc, err := coll.Get(key)
+				require.NoError(t, err)
+				assert.Equal(t, fields, doc.Fields)
+			}
+		})
+	}
+}
+
+func TestImport_CSVHeaderErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		types map[string]documentstore.DocumentFieldType
+	}{
+		{name: "duplicate column", input: "id,name,name\n"},
+		{name: "unnamed column", input: "id,,name\n"},
+		{name: "unknown column type", input: "id\n", types: map[string]documentstore.DocumentFieldType{"id": "date"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Import(newUsers(t), strings.NewReader(tt.input), ImportOptions{Format: FormatCSV, ColumnTypes: tt.types})
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestExport_RoundTrip(t *testing.T) {
+	src := newUsers(t)
+	for _, doc := range []documentstore.Document{
+		{Fields: map[string]documentstore.DocumentField{
+			"id":     field(documentstore.DocumentFieldTypeString, "1"),
+			"name":   field(documentstore.DocumentFieldTypeString, "Alice, Jr."),
+			"age":    field(documentstore.DocumentFieldTypeNumber, int64(30)),
+			"active": field(documentstore.DocumentFieldTypeBool, true),
+		}},
+		{Fields: map[string]documentstore.DocumentField{
+			"id":    field(documentstore.DocumentFieldTypeString, "2"),
+			"score": field(documentstore.DocumentFieldTypeNumber, 4.5),
+		}},
+	} {
+		require.NoError(t, src.Put(doc))
+	}
+
+	t.Run("csv columns", func(t *testing.T) {
+		var buf bytes.Buffer
+		n, err := Export(src, &buf, ExportOptions{Format: FormatCSV})
+		require.NoError(t, err)
+		assert.Equal(t, 2, n)
+		assert.Equal(t, "id,active,age,name,score\n1,true,30,\"Alice, Jr.\",\n2,,,,4.5\n", buf.String())
+
+		buf.Reset()
+		_, err = Export(src, &buf, ExportOptions{Format: FormatCSV, Columns: []string{"name", "id"}})
+		require.NoError(t, err)
+		assert.Equal(t, "name,id\n\"Alice, Jr.\",1\n,2\n", buf.String())
+	})
+
+	for _, format := range []Format{FormatJSONL, FormatCSV} {
+		t.Run(string(format), func(t *testing.T) {
+			var buf bytes.Buffer
+			_, err := Export(src, &buf, ExportOptions{Format: format})
+			require.NoError(t, err)
+
+			dst := newUsers(t)
+			report, err := Import(dst, &buf, ImportOptions{Format: format})
+			require.NoError(t, err)
+			assert.Empty(t, report.Errors)
+			assert.Equal(t, src.List(), dst.List())
+		})
+	}
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) {
+	return 0, errors.New("disk full")
+}
+
+func TestExport_StopsOnWriteError(t *testing.T) {
+	src := newUsers(t)
+	for i := 0; i < 1000; i++ {
+		require.NoError(t, src.Put(documentstore.Document{Fields: map[string]documentstore.DocumentField{
+			"id": field(documentstore.DocumentFieldTypeString, fmt.Sprintf("user-%04d", i)),
+		}}))
+	}
+
+	for _, format := range []Format{FormatJSONL, FormatCSV} {
+		t.Run(string(format), func(t *testing.T) {
+			n, err := Export(src, failingWriter{}, ExportOptions{Format: format})
+			assert.EqualError(t, err, "disk full")
+			assert.Less(t, n, 1000)
+		})
+	}
+}
+
+func TestFormatFromPath(t *testing.T) {
+	tests := []struct {
+		path    string
+		want    Format
+		wantErr bool
+	}{
+		{path: "users.csv", want: FormatCSV},
+		{path: "users.JSONL", want: FormatJSONL},
+		{path: "users.ndjson", want: FormatJSONL},
+		{path: "users.json", wantErr: true},
+		{path: "users", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			got, err := FormatFromPath(tt.path)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrUnknownFormat)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
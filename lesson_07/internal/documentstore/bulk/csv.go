@@ -0,0 +1,236 @@
+package bulk
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"slices"
+	"strconv"
+
+	"github.com/Nick2603/golang/lesson_07/internal/documentstore"
+)
+
+// importCSV reads a header row naming the fields, then one document per
+// record. Empty cells leave the field out of the document.
+func importCSV(coll Collection, r io.Reader, opts ImportOptions) (*ImportReport, error) {
+	for column, typ := range opts.ColumnTypes {
+		if !validType(typ) {
+			return nil, fmt.Errorf("column %q: %w: %q", column, documentstore.ErrUnsupportedDocumentField, typ)
+		}
+	}
+
+	cr := csv.NewReader(r)
+	header, err := cr.Read()
+	if errors.Is(err, io.EOF) {
+		return &ImportReport{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read header: %w", err)
+	}
+	if err := validHeader(header); err != nil {
+		return nil, err
+	}
+	cr.ReuseRecord = true
+
+	columns := make([]csvColumn, len(header))
+	keys := keyFields(coll.Info().Config)
+	for i, name := range header {
+		columns[i] = csvColumn{name: name, typ: opts.ColumnTypes[name], key: slices.Contains(keys, name)}
+	}
+
+	report := &ImportReport{}
+	for {
+		record, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		var perr *csv.ParseError
+		if errors.As(err, &perr) {
+			report.add(perr.StartLine, perr.Err)
+			continue
+		}
+		if err != nil {
+			return report, err
+		}
+
+		line, _ := cr.FieldPos(0)
+		doc, err := csvDocument(columns, record)
+		if err == nil {
+			_, err = coll.Insert(doc)
+		}
+		report.add(line, err)
+	}
+
+	return report, nil
+}
+
+type csvColumn struct {
+	name string
+	typ  documentstore.DocumentFieldType
+	key  bool
+}
+
+func validHeader(header []string) error {
+	seen := make(map[string]bool, len(header))
+	for i, name := range header {
+		if name == "" {
+			return fmt.Errorf("header: column %d has no name", i+1)
+		}
+		if seen[name] {
+			return fmt.Errorf("header: duplicate column %q", name)
+		}
+		seen[name] = true
+	}
+	return nil
+}
+
+func validType(typ documentstore.DocumentFieldType) bool {
+	switch typ {
+	case documentstore.DocumentFieldTypeString, documentstore.DocumentFieldTypeNumber, documentstore.DocumentFieldTypeBool:
+		return true
+	default:
+		return false
+	}
+}
+
+func csvDocument(columns []csvColumn, record []string) (documentstore.Document, error) {
+	doc := documentstore.Document{Fields: make(map[string]documentstore.DocumentField, len(record))}
+	for i, value := range record {
+		if value == "" {
+			continue
+		}
+
+		field, err := csvField(columns[i], value)
+		if err != nil {
+			return documentstore.Document{}, fmt.Errorf("column %q: %w", columns[i].name, err)
+		}
+		doc.Fields[columns[i].name] = field
+	}
+	return doc, nil
+}
+
+func csvField(col csvColumn, value string) (documentstore.DocumentField, error) {
+	typ := col.typ
+	if typ == "" {
+		typ = inferType(value)
+		if col.key {
+			typ = documentstore.DocumentFieldTypeString
+		}
+	}
+
+	switch typ {
+	case documentstore.DocumentFieldTypeNumber:
+		if i, err := strconv.ParseInt(value, 10, 64); err == nil {
+			return documentstore.DocumentField{Type: typ, Value: i}, nil
+		}
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil || math.IsInf(f, 0) || math.IsNaN(f) {
+			return documentstore.DocumentField{}, fmt.Errorf("%q is not a number", value)
+		}
+		return documentstore.DocumentField{Type: typ, Value: f}, nil
+	case documentstore.DocumentFieldTypeBool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return documentstore.DocumentField{}, fmt.Errorf("%q is not a bool", value)
+		}
+		return documentstore.DocumentField{Type: typ, Value: b}, nil
+	default:
+		return documentstore.DocumentField{Type: documentstore.DocumentFieldTypeString, Value: value}, nil
+	}
+}
+
+func inferType(value string) documentstore.DocumentFieldType {
+	if value == "true" || value == "false" {
+		return documentstore.DocumentFieldTypeBool
+	}
+	if f, err := strconv.ParseFloat(value, 64); err == nil && !math.IsInf(f, 0) && !math.IsNaN(f) {
+		return documentstore.DocumentFieldTypeNumber
+	}
+	return documentstore.DocumentFieldTypeString
+}
+
+// exportCSV scans coll twice when no columns are given: once to collect
+// the field names, once to write the rows.
+func exportCSV(coll Collection, w io.Writer, opts ExportOptions) (int, error) {
+	columns := opts.Columns
+	if len(columns) == 0 {
+		var err error
+		if columns, err = defaultColumns(coll); err != nil {
+			return 0, err
+		}
+	}
+
+	cw := csv.NewWriter(w)
+	if err := cw.Write(columns); err != nil {
+		return 0, err
+	}
+
+	n := 0
+	var writeErr error
+	record := make([]string, len(columns))
+	err := coll.Scan(func(_ string, doc documentstore.Document) bool {
+		for i, name := range columns {
+			record[i] = formatValue(doc.Fields[name].Value)
+		}
+		if writeErr = cw.Write(record); writeErr != nil {
+			return false
+		}
+		n++
+		return true
+	})
+	if err == nil {
+		err = writeErr
+	}
+	if err != nil {
+		return n, err
+	}
+
+	cw.Flush()
+	return n, cw.Error()
+}
+
+func defaultColumns(coll Collection) ([]string, error) {
+	keys := keyFields(coll.Info().Config)
+	seen := make(map[string]bool)
+	var rest []string
+	err := coll.Scan(func(_ string, doc documentstore.Document) bool {
+		for name := range doc.Fields {
+			if !seen[name] && !slices.Contains(keys, name) {
+				seen[name] = true
+				rest = append(rest, name)
+			}
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	slices.Sort(rest)
+	return append(slices.Clone(keys), rest...), nil
+}
+
+func formatValue(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case int:
+		return strconv.Itoa(v)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	case []any:
+		data, _ := json.Marshal(v)
+		return string(data)
+	default:
+		return fmt.Sprint(v)
+	}
+}
@@ -0,0 +1,247 @@
+package documentstore
+
+import (
+	"container/heap"
+	"container/list"
+	"fmt"
+)
+
+type EvictionPolicy string
+
+const (
+	EvictionLRU EvictionPolicy = "lru"
+	EvictionLFU EvictionPolicy = "lfu"
+)
+
+// CacheConfig turns a collection into a bounded cache. Zero limits are
+// ignored; when both are set, documents are evicted until both hold.
+type CacheConfig struct {
+	MaxDocuments int
+	// MaxBytes bounds the approximate encoded size of all documents.
+	MaxBytes int64
+	// Policy defaults to EvictionLRU.
+	Policy EvictionPolicy `json:",omitempty"`
+}
+
+type CacheStats struct {
+	Hits      uint64
+	Misses    uint64
+	Evictions uint64
+	Documents int
+	Bytes     int64
+}
+
+type cacheEntry struct {
+	key   string
+	size  int64
+	freq  uint64
+	tick  uint64
+	elem  *list.Element
+	index int
+}
+
+// cacheTracker keeps the eviction order of a collection's keys. LRU uses a
+// list ordered by recency; LFU uses a heap ordered by access count, with
+// the least recently used entry losing ties.
+type cacheTracker struct {
+	cfg     CacheConfig
+	entries map[string]*cacheEntry
+	recency *list.List
+	freq    cacheHeap
+	bytes   int64
+	tick    uint64
+	stats   CacheStats
+}
+
+func newCacheTracker(cfg CacheConfig) (*cacheTracker, error) {
+	if cfg.Policy == "" {
+		cfg.Policy = EvictionLRU
+	}
+	if cfg.Policy != EvictionLRU && cfg.Policy != EvictionLFU {
+		return nil, fmt.Errorf("%w: unknown eviction policy %q", ErrInvalidCacheConfig, cfg.Policy)
+	}
+	if cfg.MaxDocuments < 0 || cfg.MaxBytes < 0 {
+		return nil, fmt.Errorf("%w: negative limit", ErrInvalidCacheConfig)
+	}
+
+	return &cacheTracker{
+		cfg:     cfg,
+		entries: make(map[string]*cacheEntry),
+		recency: list.New(),
+	}, nil
+}
+
+func (t *cacheTracker) touch(key string) {
+	entry, ok := t.entries[key]
+	if !ok {
+		return
+	}
+
+	t.tick++
+	entry.tick = t.tick
+	entry.freq++
+
+	if t.cfg.Policy == EvictionLFU {
+		heap.Fix(&t.freq, entry.index)
+	} else {
+		t.recency.MoveToFront(entry.elem)
+	}
+}
+
+func (t *cacheTracker) add(key string, size int64) {
+	if entry, ok := t.entries[key]; ok {
+		t.bytes += size - entry.size
+		entry.size = size
+		t.touch(key)
+		return
+	}
+
+	t.tick++
+	entry := &cacheEntry{key: key, size: size, freq: 1, tick: t.tick}
+	t.entries[key] = entry
+	t.bytes += size
+
+	if t.cfg.Policy == EvictionLFU {
+		heap.Push(&t.freq, entry)
+	} else {
+		entry.elem = t.recency.PushFront(entry)
+	}
+}
+
+func (t *cacheTracker) remove(key string) {
+	entry, ok := t.entries[key]
+	if !ok {
+		return
+	}
+
+	delete(t.entries, key)
+	t.bytes -= entry.size
+
+	if t.cfg.Policy == EvictionLFU {
+		heap.Remove(&t.freq, entry.index)
+	} else {
+		t.recency.Remove(entry.elem)
+	}
+}
+
+func (t *cacheTracker) overLimit() bool {
+	if t.cfg.MaxDocuments > 0 && len(t.entries) > t.cfg.MaxDocuments {
+		return true
+	}
+	return t.cfg.MaxBytes > 0 && t.bytes > t.cfg.MaxBytes
+}
+
+// victim returns the next key to evict, never choosing keep.
+func (t *cacheTracker) victim(keep string) (string, bool) {
+	if t.cfg.Policy == EvictionLFU {
+		if len(t.freq) == 0 {
+			return "", false
+		}
+		if t.freq[0].key != keep {
+			return t.freq[0].key, true
+		}
+		// The protected key is the root; the next candidate is one of its
+		// children.
+		best := ""
+		for _, i := range []int{1, 2} {
+			if i < len(t.freq) && (best == "" || t.freq.less(t.freq[i], t.entries[best])) {
+				best = t.freq[i].key
+			}
+		}
+		return best, best != ""
+	}
+
+	for elem := t.recency.Back(); elem != nil; elem = elem.Prev() {
+		if key := elem.Value.(*cacheEntry).key; key != keep {
+			return key, true
+		}
+	}
+	return "", false
+}
+
+type cacheHeap []*cacheEntry
+
+func (h cacheHeap) less(a, b *cacheEntry) bool {
+	if a.freq != b.freq {
+		return a.freq < b.freq
+	}
+	return a.tick < b.tick
+}
+
+func (h cacheHeap) Len() int           { return len(h) }
+func (h cacheHeap) Less(i, j int) bool { return h.less(h[i], h[j]) }
+
+func (h cacheHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *cacheHeap) Push(x any) {
+	entry := x.(*cacheEntry)
+	entry.index = len(*h)
+	*h = append(*h, entry)
+}
+
+func (h *cacheHeap) Pop() any {
+	old := *h
+	entry := old[len(old)-1]
+	old[len(old)-1] = nil
+	*h = old[:len(old)-1]
+	return entry
+}
+
+func documentSize(doc Document) int64 {
+	data, err := encodeDocument(doc)
+	if err != nil {
+		return 0
+	}
+	return int64(len(data))
+}
+
+// OnEvict registers a callback invoked with every document evicted from a
+// cache-mode collection. Callbacks run while the collection is locked and
+// must not call back into it.
+func (c *Collection) OnEvict(fn func(key string, doc Document)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.onEvict = append(c.onEvict, fn)
+}
+
+// CacheStats reports hit, miss and eviction counters. It returns the zero
+// value for collections without a cache configuration.
+func (c *Collection) CacheStats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.cache == nil {
+		return CacheStats{}
+	}
+
+	stats := c.cache.stats
+	stats.Documents = len(c.cache.entries)
+	stats.Bytes = c.cache.bytes
+	return stats
+}
+
+func (c *Collection) evictOverflow(keep string) error {
+	for c.cache.overLimit() {
+		key, ok := c.cache.victim(keep)
+		if !ok {
+			return nil
+		}
+
+		doc, err := c.remove(key)
+		if err != nil {
+			return err
+		}
+		c.cache.stats.Evictions++
+		c.logger.Info("document evicted", "collection", c.name, "key", key, "policy", c.cache.cfg.Policy)
+
+		for _, fn := range c.onEvict {
+			fn(key, doc)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,164 @@
+package documentstore
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func keysOf(docs []Document) []string {
+	keys := make([]string, 0, len(docs))
+	for _, doc := range docs {
+		keys = append(keys, doc.Fields["id"].Value.(string))
+	}
+	return keys
+}
+
+func TestCollection_CacheEviction(t *testing.T) {
+	tests := []struct {
+		name        string
+		cfg         CacheConfig
+		ops         func(*testing.T, *Collection)
+		wantKeys    []string
+		wantEvicted []string
+	}{
+		{
+			name: "lru evicts least recently used",
+			cfg:  CacheConfig{MaxDocuments: 2},
+			ops: func(t *testing.T, c *Collection) {
+				require.NoError(t, c.Put(userDoc("a", "A")))
+				require.NoError(t, c.Put(userDoc("b", "B")))
+				_, err := c.Get("a")
+				require.NoError(t, err)
+				require.NoError(t, c.Put(userDoc("c", "C")))
+			},
+			wantKeys:    []string{"a", "c"},
+			wantEvicted: []string{"b"},
+		},
+		{
+			name: "lfu evicts least frequently used",
+			cfg:  CacheConfig{MaxDocuments: 2, Policy: EvictionLFU},
+			ops: func(t *testing.T, c *Collection) {
+				require.NoError(t, c.Put(userDoc("a", "A")))
+				require.NoError(t, c.Put(userDoc("b", "B")))
+				for i := 0; i < 3; i++ {
+					_, err := c.Get("a")
+					require.NoError(t, err)
+				}
+				_, err := c.Get("b")
+				require.NoError(t, err)
+				require.NoError(t, c.Put(userDoc("c", "C")))
+				require.NoError(t, c.Put(userDoc("d", "D")))
+			},
+			wantKeys:    []string{"a", "d"},
+			wantEvicted: []string{"b", "c"},
+		},
+		{
+			name: "lfu never evicts the document just written",
+			cfg:  CacheConfig{MaxDocuments: 1, Policy: EvictionLFU},
+			ops: func(t *testing.T, c *Collection) {
+				require.NoError(t, c.Put(userDoc("a", "A")))
+				_, err := c.Get("a")
+				require.NoError(t, err)
+				require.NoError(t, c.Put(userDoc("b", "B")))
+			},
+			wantKeys:    []string{"b"},
+			wantEvicted: []string{"a"},
+		},
+		{
+			name: "byte limit evicts until within budget",
+			cfg:  CacheConfig{MaxBytes: 2*documentSize(userDoc("a", "A")) + 1},
+			ops: func(t *testing.T, c *Collection) {
+				require.NoError(t, c.Put(userDoc("a", "A")))
+				require.NoError(t, c.Put(userDoc("b", "B")))
+				require.NoError(t, c.Put(userDoc("c", "C")))
+			},
+			wantKeys:    []string{"b", "c"},
+			wantEvicted: []string{"a"},
+		},
+		{
+			name: "deleted documents are not evicted",
+			cfg:  CacheConfig{MaxDocuments: 2},
+			ops: func(t *testing.T, c *Collection) {
+				require.NoError(t, c.Put(userDoc("a", "A")))
+				require.NoError(t, c.Put(userDoc("b", "B")))
+				require.NoError(t, c.Delete("a"))
+				require.NoError(t, c.Put(userDoc("c", "C")))
+			},
+			wantKeys:    []string{"b", "c"},
+			wantEvicted: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := tt.cfg
+			coll := NewCollection(CollectionConfig{PrimaryKey: "id", Cache: &cfg})
+
+			var evicted []string
+			coll.OnEvict(func(key string, doc Document) {
+				assert.Equal(t, key, doc.Fields["id"].Value)
+				evicted = append(evicted, key)
+			})
+
+			tt.ops(t, coll)
+
+			assert.Equal(t, tt.wantKeys, keysOf(coll.List()))
+			assert.Equal(t, tt.wantEvicted, evicted)
+			assert.Equal(t, uint64(len(tt.wantEvicted)), coll.CacheStats().Evictions)
+		})
+	}
+}
+
+func TestCollection_CacheStats(t *testing.T) {
+	coll := NewCollection(CollectionConfig{PrimaryKey: "id", Cache: &CacheConfig{MaxDocuments: 10}})
+
+	require.NoError(t, coll.Put(userDoc("a", "A")))
+	_, err := coll.Get("a")
+	require.NoError(t, err)
+	_, err = coll.Get("a")
+	require.NoError(t, err)
+	_, err = coll.Get("missing")
+	assert.ErrorIs(t, err, ErrDocumentNotFound)
+
+	stats := coll.CacheStats()
+	assert.Equal(t, uint64(2), stats.Hits)
+	assert.Equal(t, uint64(1), stats.Misses)
+	assert.Equal(t, uint64(0), stats.Evictions)
+	assert.Equal(t, 1, stats.Documents)
+	assert.Equal(t, documentSize(userDoc("a", "A")), stats.Bytes)
+
+	assert.Equal(t, CacheStats{}, NewCollection(CollectionConfig{PrimaryKey: "id"}).CacheStats())
+}
+
+func TestCollection_CacheOverPersistentEngine(t *testing.T) {
+	dir := t.TempDir()
+
+	unbounded := NewCollection(CollectionConfig{PrimaryKey: "id", Engine: EngineBitcask, DataDir: dir})
+	for _, key := range []string{"a", "b", "c"} {
+		require.NoError(t, unbounded.Put(userDoc(key, key)))
+	}
+	require.NoError(t, unbounded.Close())
+
+	bounded, err := OpenCollection(CollectionConfig{
+		PrimaryKey: "id",
+		Engine:     EngineBitcask,
+		DataDir:    dir,
+		Cache:      &CacheConfig{MaxDocuments: 2},
+	})
+	require.NoError(t, err)
+	defer bounded.Close()
+
+	assert.Equal(t, 2, bounded.Len())
+	assert.Equal(t, uint64(1), bounded.CacheStats().Evictions)
+}
+
+func TestOpenCollection_InvalidCacheConfig(t *testing.T) {
+	coll, err := OpenCollection(CollectionConfig{
+		PrimaryKey: "id",
+		Cache:      &CacheConfig{MaxDocuments: 1, Policy: "fifo"},
+	})
+	assert.ErrorIs(t, err, ErrInvalidCacheConfig)
+	assert.Nil(t, coll)
+}
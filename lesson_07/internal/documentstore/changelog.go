@@ -0,0 +1,513 @@
+package documentstore
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	changeLogFilename = "changes.log"
+	snapshotPrefix    = "snapshot-"
+	snapshotSuffix    = ".json"
+)
+
+type ChangeOp string
+
+const (
+	ChangeOpCreateCollection ChangeOp = "create_collection"
+	ChangeOpDeleteCollection ChangeOp = "delete_collection"
+	ChangeOpPut              ChangeOp = "put"
+	ChangeOpDelete           ChangeOp = "delete"
+	ChangeOpCreateNamespace  ChangeOp = "create_namespace"
+	ChangeOpDeleteNamespace  ChangeOp = "delete_namespace"
+)
+
+// ChangeRecord is a single entry of the timestamped change log. Namespace
+// is empty for changes to the root store.
+type ChangeRecord struct {
+	Seq        uint64            `json:"seq"`
+	Time       time.Time         `json:"time"`
+	Op         ChangeOp          `json:"op"`
+	Namespace  string            `json:"namespace,omitempty"`
+	Collection string            `json:"collection"`
+	Key        string            `json:"key,omitempty"`
+	Config     *CollectionConfig `json:"config,omitempty"`
+	Document   *Document         `json:"document,omitempty"`
+	ExpiresAt  *time.Time        `json:"expires_at,omitempty"`
+}
+
+type changeLog struct {
+	mu     sync.Mutex
+	dir    string
+	file   *os.File
+	writer *bufio.Writer
+	seq    uint64
+	now    func() time.Time
+}
+
+func openChangeLog(dir string) (*changeLog, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	records, size, err := scanChangeLog(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	path := filepath.Join(dir, changeLogFilename)
+	if err := truncateTornTail(path, size); err != nil {
+		return nil, err
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, err
+	}
+
+	cl := &changeLog{
+		dir:    dir,
+		file:   file,
+		writer: bufio.NewWriter(file),
+		now:    time.Now,
+	}
+	if len(records) > 0 {
+		cl.seq = records[len(records)-1].Seq
+	}
+
+	return cl, nil
+}
+
+func (cl *changeLog) append(rec ChangeRecord) error {
+	cl.mu.Lock()
+	defer cl.mu.Unlock()
+
+	cl.seq++
+	rec.Seq = cl.seq
+	rec.Time = cl.now()
+
+	data, err := json.Marshal(rec)
+	if err != nil {
+		cl.seq--
+		return err
+	}
+
+	if _, err := cl.writer.Write(append(data, '\n')); err != nil {
+		cl.seq--
+		return err
+	}
+
+	if err := cl.writer.Flush(); err != nil {
+		cl.seq--
+		return err
+	}
+
+	return cl.file.Sync()
+}
+
+func (cl *changeLog) close() error {
+	cl.mu.Lock()
+	defer cl.mu.Unlock()
+
+	if err := cl.writer.Flush(); err != nil {
+		cl.file.Close()
+		return err
+	}
+	return cl.file.Close()
+}
+
+func readChangeLog(dir string) ([]ChangeRecord, error) {
+	records, _, err := scanChangeLog(dir)
+	return records, err
+}
+
+// scanChangeLog returns the complete records of the log together with the
+// byte length they occupy.
+func scanChangeLog(dir string) ([]ChangeRecord, int64, error) {
+	file, err := os.Open(filepath.Join(dir, changeLogFilename))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, 0, nil
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	defer file.Close()
+
+	var (
+		records []ChangeRecord
+		size    int64
+	)
+	reader := bufio.NewReader(file)
+
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			// A partial trailing line is a write torn by a crash; ignore it.
+			break
+		}
+		if err != nil {
+			return nil, 0, err
+		}
+
+		var rec ChangeRecord
+		if err := json.Unmarshal(line, &rec); err != nil {
+			return nil, 0, fmt.Errorf("%w: %v", ErrCorruptChangeLog, err)
+		}
+		records = append(records, rec)
+		size += int64(len(line))
+	}
+
+	return records, size, nil
+}
+
+func truncateTornTail(path string, size int64) error {
+	info, err := os.Stat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if info.Size() == size {
+		return nil
+	}
+	return os.Truncate(path, size)
+}
+
+type snapshotFile struct {
+	name string
+	time time.Time
+	seq  uint64
+}
+
+func snapshotFilename(at time.Time, seq uint64) string {
+	return fmt.Sprintf("%s%020d-%020d%s", snapshotPrefix, at.UnixNano(), seq, snapshotSuffix)
+}
+
+func listSnapshots(dir string) ([]snapshotFile, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var snapshots []snapshotFile
+	for _, entry := range entries {
+		name := entry.Name()
+		if !strings.HasPrefix(name, snapshotPrefix) || !strings.HasSuffix(name, snapshotSuffix) {
+			continue
+		}
+
+		parts := strings.Split(strings.TrimSuffix(strings.TrimPrefix(name, snapshotPrefix), snapshotSuffix), "-")
+		if len(parts) != 2 {
+			continue
+		}
+
+		nanos, err := strconv.ParseInt(parts[0], 10, 64)
+		if err != nil {
+			continue
+		}
+		seq, err := strconv.ParseUint(parts[1], 10, 64)
+		if err != nil {
+			continue
+		}
+
+		snapshots = append(snapshots, snapshotFile{name: name, time: time.Unix(0, nanos), seq: seq})
+	}
+
+	sort.Slice(snapshots, func(i, j int) bool {
+		return snapshots[i].seq < snapshots[j].seq
+	})
+
+	return snapshots, nil
+}
+
+// EnableRecovery writes a base snapshot of the store into dir and starts
+// appending every subsequent mutation to a timestamped change log there,
+// so that RestoreAt can later rebuild the store as of any instant.
+// Namespaces share the change log of the root store, which is the only one
+// recovery can be enabled on.
+func (s *Store) EnableRecovery(dir string) error {
+	if s.namespace != "" {
+		s.logger.Error("failed to enable recovery: not the root store", "namespace", s.namespace)
+		return ErrInvalidNamespace
+	}
+	if s.changes != nil {
+		s.logger.Error("failed to enable recovery: already enabled", "dir", s.changes.dir)
+		return ErrRecoveryAlreadyEnabled
+	}
+
+	cl, err := openChangeLog(dir)
+	if err != nil {
+		s.logger.Error("failed to open change log", "dir", dir, "error", err)
+		return err
+	}
+
+	s.setChangeLog(cl)
+	if err := s.Snapshot(); err != nil {
+		s.setChangeLog(nil)
+		cl.close()
+		return err
+	}
+
+	s.logger.Info("recovery enabled", "dir", dir)
+	return nil
+}
+
+// setChangeLog points the store, its collections and its namespaces at cl.
+func (s *Store) setChangeLog(cl *changeLog) {
+	s.changes = cl
+	for name, coll := range s.collections {
+		coll.name = name
+		coll.changes = cl
+	}
+	for _, ns := range s.namespaces {
+		ns.setChangeLog(cl)
+	}
+}
+
+// Snapshot writes a new base snapshot into the recovery directory. Older
+// snapshots and log records are kept so earlier instants stay restorable.
+func (s *Store) Snapshot() error {
+	if s.namespace != "" {
+		s.logger.Error("failed to snapshot: not the root store", "namespace", s.namespace)
+		return ErrInvalidNamespace
+	}
+	if s.changes == nil {
+		s.logger.Error("failed to snapshot: recovery not enabled")
+		return ErrRecoveryNotEnabled
+	}
+
+	data, err := s.Dump()
+	if err != nil {
+		return err
+	}
+
+	name := snapshotFilename(s.changes.now(), s.changes.seq)
+	path := filepath.Join(s.changes.dir, name)
+	tmp := path + ".tmp"
+
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		s.logger.Error("failed to write snapshot", "filename", tmp, "error", err)
+		return err
+	}
+
+	if err := os.Rename(tmp, path); err != nil {
+		s.logger.Error("failed to rename snapshot", "filename", path, "error", err)
+		return err
+	}
+
+	s.logger.Info("snapshot written", "filename", path, "seq", s.changes.seq)
+	return nil
+}
+
+// RestoreAt rebuilds the store as it was at the given instant from the
+// latest base snapshot taken no later than at plus the change log records
+// that follow it. Documents are replayed straight into the engines, without
+// hooks, validation or quota checks, and the clock of the restored store
+// runs from at, so a document live at that instant is live in the result
+// and expires as it would have then. Collections on disk engines are
+// rebuilt in a temporary directory rather than their recorded DataDir,
+// which may hold newer data or be open in the live store; it is removed
+// when the store is closed.
+func RestoreAt(dir string, at time.Time) (*Store, error) {
+	snapshots, err := listSnapshots(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var base *snapshotFile
+	for i := range snapshots {
+		if !snapshots[i].time.After(at) {
+			base = &snapshots[i]
+		}
+	}
+
+	if base == nil {
+		return nil, fmt.Errorf("%w: %s", ErrNoSnapshot, at.Format(time.RFC3339Nano))
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, base.name))
+	if err != nil {
+		return nil, err
+	}
+
+	storeDump, err := decodeDump(data)
+	if err != nil {
+		return nil, err
+	}
+
+	scratch, err := os.MkdirTemp("", "documentstore-restore-")
+	if err != nil {
+		return nil, err
+	}
+
+	restoredAt := time.Now()
+	store := NewStore()
+	store.scratch = scratch
+	store.now = func() time.Time {
+		return at.Add(time.Since(restoredAt))
+	}
+	if err := store.load(storeDump, true); err != nil {
+		store.Close()
+		return nil, err
+	}
+
+	records, err := readChangeLog(dir)
+	if err != nil {
+		store.Close()
+		return nil, err
+	}
+
+	applied := 0
+	for _, rec := range records {
+		if rec.Seq <= base.seq {
+			continue
+		}
+		if rec.Time.After(at) {
+			break
+		}
+
+		if err := store.applyChange(rec); err != nil {
+			store.Close()
+			return nil, fmt.Errorf("replay change %d: %w", rec.Seq, err)
+		}
+		applied++
+	}
+
+	store.logger.Info("store restored to point in time",
+		"at", at,
+		"snapshot", base.name,
+		"changes_applied", applied,
+	)
+	return store, nil
+}
+
+func (s *Store) applyChange(rec ChangeRecord) error {
+	switch rec.Op {
+	case ChangeOpCreateNamespace:
+		_, err := s.Namespace(rec.Namespace)
+		return err
+	case ChangeOpDeleteNamespace:
+		return s.DeleteNamespace(rec.Namespace)
+	}
+
+	if rec.Namespace != "" {
+		ns, err := s.Namespace(rec.Namespace)
+		if err != nil {
+			return err
+		}
+		s = ns
+	}
+
+	switch rec.Op {
+	case ChangeOpCreateCollection:
+		if rec.Config == nil {
+			return ErrNilValue
+		}
+		cfg, err := s.rebuildConfig(*rec.Config)
+		if err != nil {
+			return err
+		}
+		_, err = s.CreateCollection(rec.Collection, &cfg)
+		return err
+	case ChangeOpDeleteCollection:
+		return s.DeleteCollection(rec.Collection)
+	case ChangeOpPut:
+		if rec.Document == nil {
+			return ErrNilValue
+		}
+		coll, err := s.GetCollection(rec.Collection)
+		if err != nil {
+			return err
+		}
+		var expiresAt time.Time
+		if rec.ExpiresAt != nil {
+			expiresAt = *rec.ExpiresAt
+		}
+		return coll.restoreDocument(*rec.Document, expiresAt)
+	case ChangeOpDelete:
+		coll, err := s.GetCollection(rec.Collection)
+		if err != nil {
+			return err
+		}
+		return coll.restoreDelete(rec.Key)
+	default:
+		return fmt.Errorf("%w: unknown op %q", ErrCorruptChangeLog, rec.Op)
+	}
+}
+
+// restoreDocument writes a document recovered from a snapshot or the
+// change log straight to the engine: no hooks, validation, quota checks or
+// change records, and the recorded deadline is kept as is. Usage and cache
+// tracking still follow the write.
+func (c *Collection) restoreDocument(doc Document, expiresAt time.Time) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key, err := c.primaryKey(doc)
+	if err != nil {
+		return err
+	}
+
+	before, stored, err := c.engine.Get(key)
+	if err != nil {
+		return err
+	}
+	if err := c.engine.Put(key, doc); err != nil {
+		return err
+	}
+
+	size := documentSize(doc)
+	delta := Usage{Documents: 1, Bytes: size}
+	if stored {
+		delta = Usage{Bytes: size - documentSize(before)}
+	}
+	c.quota.add(delta)
+	if c.storeQuota != nil {
+		c.storeQuota.add(delta)
+	}
+
+	c.observeKey(doc)
+	if expiresAt.IsZero() {
+		delete(c.expiries, key)
+	} else {
+		c.expiries[key] = expiresAt
+	}
+	if c.cache != nil {
+		c.cache.add(key, size)
+	}
+	return nil
+}
+
+// restoreDelete removes a document as recorded in the change log, straight
+// from the engine.
+func (c *Collection) restoreDelete(key string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	before, ok, err := c.engine.Get(key)
+	if err != nil || !ok {
+		return err
+	}
+	if err := c.engine.Delete(key); err != nil {
+		return err
+	}
+
+	c.releaseQuota(Usage{Documents: 1, Bytes: documentSize(before)})
+	delete(c.expiries, key)
+	if c.cache != nil {
+		c.cache.remove(key)
+	}
+	return nil
+}
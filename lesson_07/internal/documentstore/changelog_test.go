@@ -0,0 +1,363 @@
+package documentstore
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) time.Time {
+	c.now = c.now.Add(d)
+	return c.now
+}
+
+func userDoc(id, name string) Document {
+	return Document{
+		Fields: map[string]DocumentField{
+			"id":   {Type: DocumentFieldTypeString, Value: id},
+			"name": {Type: DocumentFieldTypeString, Value: name},
+		},
+	}
+}
+
+func TestRestoreAt(t *testing.T) {
+	dir := t.TempDir()
+	clock := &fakeClock{now: time.Now().Add(time.Minute)}
+
+	store := NewStore()
+	usersColl, err := store.CreateCollection("users", &CollectionConfig{PrimaryKey: "id"})
+	require.NoError(t, err)
+	require.NoError(t, usersColl.Put(userDoc("user:1", "Alice")))
+
+	require.NoError(t, store.EnableRecovery(dir))
+	store.changes.now = clock.Now
+	defer store.Close()
+
+	afterBase := clock.Advance(time.Minute)
+
+	clock.Advance(time.Minute)
+	require.NoError(t, usersColl.Put(userDoc("user:2", "Bob")))
+	afterBob := clock.Advance(time.Minute)
+
+	clock.Advance(time.Minute)
+	require.NoError(t, usersColl.Put(userDoc("user:1", "Alicia")))
+	_, err = store.CreateCollection("orders", &CollectionConfig{PrimaryKey: "id"})
+	require.NoError(t, err)
+	beforeMistake := clock.Advance(time.Minute)
+
+	clock.Advance(time.Minute)
+	require.NoError(t, usersColl.Delete("user:1"))
+	require.NoError(t, usersColl.Delete("user:2"))
+	require.NoError(t, store.DeleteCollection("orders"))
+
+	t.Run("restores base snapshot", func(t *testing.T) {
+		restored, err := RestoreAt(dir, afterBase)
+		require.NoError(t, err)
+
+		coll, err := restored.GetCollection("users")
+		require.NoError(t, err)
+		assert.Len(t, coll.List(), 1)
+	})
+
+	t.Run("replays changes up to the instant", func(t *testing.T) {
+		restored, err := RestoreAt(dir, afterBob)
+		require.NoError(t, err)
+
+		coll, err := restored.GetCollection("users")
+		require.NoError(t, err)
+		assert.Len(t, coll.List(), 2)
+
+		doc, err := coll.Get("user:1")
+		require.NoError(t, err)
+		assert.Equal(t, "Alice", doc.Fields["name"].Value)
+
+		_, err = restored.GetCollection("orders")
+		assert.ErrorIs(t, err, ErrCollectionNotFound)
+	})
+
+	t.Run("restores state right before deletion", func(t *testing.T) {
+		restored, err := RestoreAt(dir, beforeMistake)
+		require.NoError(t, err)
+
+		coll, err := restored.GetCollection("users")
+		require.NoError(t, err)
+		assert.Len(t, coll.List(), 2)
+
+		doc, err := coll.Get("user:1")
+		require.NoError(t, err)
+		assert.Equal(t, "Alicia", doc.Fields["name"].Value)
+
+		_, err = restored.GetCollection("orders")
+		assert.NoError(t, err)
+	})
+
+	t.Run("restores latest state", func(t *testing.T) {
+		restored, err := RestoreAt(dir, clock.Now())
+		require.NoError(t, err)
+
+		coll, err := restored.GetCollection("users")
+		require.NoError(t, err)
+		assert.Empty(t, coll.List())
+	})
+
+	t.Run("returns error before first snapshot", func(t *testing.T) {
+		restored, err := RestoreAt(dir, time.Now().Add(-time.Hour))
+		assert.ErrorIs(t, err, ErrNoSnapshot)
+		assert.Nil(t, restored)
+	})
+}
+
+func TestRestoreAt_ReplaysRawState(t *testing.T) {
+	dir := t.TempDir()
+	clock := &fakeClock{now: time.Now().Add(-time.Hour)}
+
+	store := NewStore()
+	coll, err := store.CreateCollection("sessions", &CollectionConfig{PrimaryKey: "id"})
+	require.NoError(t, err)
+	coll.now = clock.Now
+	require.NoError(t, coll.Put(userDoc("s1", "Alice")))
+	require.NoError(t, coll.Put(userDoc("s2", "Bob")))
+
+	require.NoError(t, store.EnableRecovery(dir))
+	store.changes.now = clock.Now
+	// The first snapshot is stamped with the wall clock; take one in the past.
+	require.NoError(t, store.Snapshot())
+	defer store.Close()
+
+	clock.Advance(time.Minute)
+	require.NoError(t, coll.PutWithOptions(userDoc("s3", "Carol"), WithTTL(time.Minute)))
+	expiresAt := clock.now.Add(time.Minute)
+	afterCarol := clock.Advance(10 * time.Second)
+
+	clock.Advance(10 * time.Second)
+	require.NoError(t, store.SetQuota(Quota{MaxDocuments: 1}))
+	require.NoError(t, store.Snapshot())
+	afterQuota := clock.Advance(10 * time.Second)
+
+	for name, at := range map[string]time.Time{"replayed from the log": afterCarol, "loaded over quota": afterQuota} {
+		t.Run(name, func(t *testing.T) {
+			restored, err := RestoreAt(dir, at)
+			require.NoError(t, err)
+			defer restored.Close()
+
+			restoredColl, err := restored.GetCollection("sessions")
+			require.NoError(t, err)
+			assert.Len(t, restoredColl.List(), 3, "documents live at the target are kept")
+
+			deadline, ok := restoredColl.ExpiresAt("s3")
+			require.True(t, ok)
+			assert.WithinDuration(t, expiresAt, deadline, 0)
+			assert.WithinDuration(t, at, restoredColl.now(), time.Second, "the restored clock runs from the target")
+		})
+	}
+}
+
+func TestRestoreAt_DiskEngine(t *testing.T) {
+	dir := t.TempDir()
+	dataDir := t.TempDir()
+	clock := &fakeClock{now: time.Now().Add(time.Minute)}
+
+	store := NewStore()
+	coll, err := store.CreateCollection("users", &CollectionConfig{PrimaryKey: "id", Engine: EngineBitcask, DataDir: dataDir})
+	require.NoError(t, err)
+	require.NoError(t, coll.Put(userDoc("user:1", "Alice")))
+
+	require.NoError(t, store.EnableRecovery(dir))
+	store.changes.now = clock.Now
+	defer store.Close()
+
+	target := clock.Advance(time.Minute)
+	clock.Advance(time.Minute)
+	require.NoError(t, coll.Put(userDoc("user:2", "Bob")))
+
+	restored, err := RestoreAt(dir, target)
+	require.NoError(t, err)
+
+	restoredColl, err := restored.GetCollection("users")
+	require.NoError(t, err)
+	assert.NotEqual(t, dataDir, restoredColl.cfg.DataDir, "the live data dir is never reopened")
+	_, err = restoredColl.Get("user:2")
+	assert.ErrorIs(t, err, ErrDocumentNotFound)
+	require.NoError(t, restoredColl.Put(userDoc("user:3", "Carol")))
+
+	scratch := restored.scratch
+	require.NoError(t, restored.Close())
+	assert.NoDirExists(t, scratch)
+
+	assert.Equal(t, 2, coll.Len())
+	_, err = coll.Get("user:3")
+	assert.ErrorIs(t, err, ErrDocumentNotFound)
+
+	data, err := store.Dump()
+	require.NoError(t, err)
+	_, err = NewStoreFromDump(data)
+	assert.ErrorIs(t, err, ErrDataDirLocked, "a dump cannot open a data dir in use")
+}
+
+func TestRestoreAt_Namespaces(t *testing.T) {
+	dir := t.TempDir()
+	clock := &fakeClock{now: time.Now().Add(time.Minute)}
+
+	store := NewStore()
+	acme, err := store.Namespace("acme")
+	require.NoError(t, err)
+	acmeUsers, err := acme.CreateCollection("users", &CollectionConfig{PrimaryKey: "id"})
+	require.NoError(t, err)
+	require.NoError(t, acmeUsers.Put(userDoc("user:1", "Alice")))
+
+	require.NoError(t, store.EnableRecovery(dir))
+	store.changes.now = clock.Now
+	defer store.Close()
+	assert.ErrorIs(t, acme.EnableRecovery(t.TempDir()), ErrInvalidNamespace)
+	assert.ErrorIs(t, acme.Snapshot(), ErrInvalidNamespace)
+
+	clock.Advance(time.Minute)
+	require.NoError(t, acmeUsers.Put(userDoc("user:2", "Bob")))
+	beta, err := store.Namespace("beta")
+	require.NoError(t, err)
+	betaOrders, err := beta.CreateCollection("orders", &CollectionConfig{PrimaryKey: "id"})
+	require.NoError(t, err)
+	require.NoError(t, betaOrders.Put(userDoc("order:1", "Widget")))
+	afterWrites := clock.Advance(time.Minute)
+
+	clock.Advance(time.Minute)
+	// Dropping a namespace must leave the shared change log open.
+	require.NoError(t, store.DeleteNamespace("beta"))
+	require.NoError(t, acmeUsers.Delete("user:1"))
+
+	t.Run("replays changes inside namespaces", func(t *testing.T) {
+		restored, err := RestoreAt(dir, afterWrites)
+		require.NoError(t, err)
+
+		_, err = restored.GetCollection("users")
+		assert.ErrorIs(t, err, ErrCollectionNotFound)
+
+		ns, err := restored.Namespace("acme")
+		require.NoError(t, err)
+		coll, err := ns.GetCollection("users")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"user:1", "user:2"}, keysOf(coll.List()))
+
+		ns, err = restored.Namespace("beta")
+		require.NoError(t, err)
+		coll, err = ns.GetCollection("orders")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"order:1"}, keysOf(coll.List()))
+	})
+
+	t.Run("replays namespace deletion", func(t *testing.T) {
+		restored, err := RestoreAt(dir, clock.Now())
+		require.NoError(t, err)
+
+		assert.Equal(t, []string{"acme"}, restored.Namespaces())
+		ns, err := restored.Namespace("acme")
+		require.NoError(t, err)
+		coll, err := ns.GetCollection("users")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"user:2"}, keysOf(coll.List()))
+	})
+}
+
+func TestRestoreAt_UsesLatestSnapshot(t *testing.T) {
+	dir := t.TempDir()
+	clock := &fakeClock{now: time.Now().Add(time.Minute)}
+
+	store := NewStore()
+	require.NoError(t, store.EnableRecovery(dir))
+	store.changes.now = clock.Now
+	defer store.Close()
+
+	coll, err := store.CreateCollection("users", &CollectionConfig{PrimaryKey: "id"})
+	require.NoError(t, err)
+	require.NoError(t, coll.Put(userDoc("user:1", "Alice")))
+
+	clock.Advance(time.Minute)
+	require.NoError(t, store.Snapshot())
+
+	clock.Advance(time.Minute)
+	require.NoError(t, coll.Put(userDoc("user:2", "Bob")))
+
+	snapshots, err := listSnapshots(dir)
+	require.NoError(t, err)
+	assert.Len(t, snapshots, 2)
+
+	restored, err := RestoreAt(dir, clock.Now())
+	require.NoError(t, err)
+
+	restoredColl, err := restored.GetCollection("users")
+	require.NoError(t, err)
+	assert.Len(t, restoredColl.List(), 2)
+}
+
+func TestStore_EnableRecovery(t *testing.T) {
+	t.Run("returns error when enabled twice", func(t *testing.T) {
+		store := NewStore()
+		require.NoError(t, store.EnableRecovery(t.TempDir()))
+		defer store.Close()
+
+		err := store.EnableRecovery(t.TempDir())
+		assert.ErrorIs(t, err, ErrRecoveryAlreadyEnabled)
+	})
+
+	t.Run("snapshot requires recovery", func(t *testing.T) {
+		store := NewStore()
+		assert.ErrorIs(t, store.Snapshot(), ErrRecoveryNotEnabled)
+	})
+
+	t.Run("continues sequence after reopen", func(t *testing.T) {
+		dir := t.TempDir()
+
+		store := NewStore()
+		require.NoError(t, store.EnableRecovery(dir))
+		_, err := store.CreateCollection("users", &CollectionConfig{PrimaryKey: "id"})
+		require.NoError(t, err)
+		require.NoError(t, store.Close())
+
+		reopened := NewStore()
+		require.NoError(t, reopened.EnableRecovery(dir))
+		defer reopened.Close()
+		assert.Equal(t, uint64(1), reopened.changes.seq)
+	})
+
+	t.Run("ignores torn trailing record", func(t *testing.T) {
+		dir := t.TempDir()
+
+		store := NewStore()
+		require.NoError(t, store.EnableRecovery(dir))
+		_, err := store.CreateCollection("users", &CollectionConfig{PrimaryKey: "id"})
+		require.NoError(t, err)
+		require.NoError(t, store.Close())
+
+		f, err := os.OpenFile(filepath.Join(dir, changeLogFilename), os.O_APPEND|os.O_WRONLY, 0o644)
+		require.NoError(t, err)
+		_, err = f.WriteString(`{"seq":2,"op":"put"`)
+		require.NoError(t, err)
+		require.NoError(t, f.Close())
+
+		records, err := readChangeLog(dir)
+		require.NoError(t, err)
+		assert.Len(t, records, 1)
+
+		reopened := NewStore()
+		require.NoError(t, reopened.EnableRecovery(dir))
+		_, err = reopened.CreateCollection("orders", &CollectionConfig{PrimaryKey: "id"})
+		require.NoError(t, err)
+		require.NoError(t, reopened.Close())
+
+		records, err = readChangeLog(dir)
+		require.NoError(t, err)
+		assert.Len(t, records, 2)
+	})
+}
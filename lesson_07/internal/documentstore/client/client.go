@@ -0,0 +1,193 @@
+// Package client talks to a docstored server. Its Collection has the same
+// Put, Get, List and Delete methods as documentstore.Collection, so code
+// written against a local collection works unchanged against a remote one.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/Nick2603/golang/lesson_07/internal/documentstore"
+	"github.com/Nick2603/golang/lesson_07/internal/httpapi"
+)
+
+const (
+	defaultTimeout      = 5 * time.Second
+	defaultMaxRetries   = 3
+	defaultRetryBackoff = 100 * time.Millisecond
+	listPageSize        = 1000
+)
+
+type Config struct {
+	// BaseURL is the server address, e.g. "http://localhost:8080".
+	BaseURL string
+	// HTTPClient defaults to a new http.Client.
+	HTTPClient *http.Client
+	// Timeout bounds each attempt of a request. Defaults to 5s.
+	Timeout time.Duration
+	// MaxRetries is the number of retries after a failed attempt. Only
+	// transport errors and 429, 502, 503 and 504 responses are retried.
+	// Defaults to 3; a negative value disables retries.
+	MaxRetries int
+	// RetryBackoff is the wait before the first retry, doubled for each
+	// later one. Defaults to 100ms.
+	RetryBackoff time.Duration
+	// Token, when set, is sent as a bearer token.
+	Token  string
+	Logger *slog.Logger
+}
+
+type Client struct {
+	baseURL *url.URL
+	http    *http.Client
+	cfg     Config
+	logger  *slog.Logger
+}
+
+// Error is a failure reported by the server. It unwraps to the matching
+// documentstore error, so errors.Is(err, documentstore.ErrDocumentNotFound)
+// works as it does locally.
+type Error struct {
+	StatusCode int
+	Code       string
+	Message    string
+	Violations []documentstore.Violation
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("server returned %d: %s", e.StatusCode, e.Message)
+}
+
+func (e *Error) Unwrap() error {
+	return httpapi.ErrorForCode(e.Code)
+}
+
+func New(cfg Config) (*Client, error) {
+	base, err := url.Parse(cfg.BaseURL)
+	if err != nil || base.Scheme == "" || base.Host == "" {
+		return nil, fmt.Errorf("invalid base URL %q", cfg.BaseURL)
+	}
+
+	if cfg.HTTPClient == nil {
+		cfg.HTTPClient = &http.Client{}
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = defaultTimeout
+	}
+	if cfg.MaxRetries == 0 {
+		cfg.MaxRetries = defaultMaxRetries
+	}
+	if cfg.MaxRetries < 0 {
+		cfg.MaxRetries = 0
+	}
+	if cfg.RetryBackoff <= 0 {
+		cfg.RetryBackoff = defaultRetryBackoff
+	}
+	if cfg.Logger == nil {
+		cfg.Logger = slog.Default()
+	}
+
+	return &Client{baseURL: base, http: cfg.HTTPClient, cfg: cfg, logger: cfg.Logger}, nil
+}
+
+// Collection returns a handle to the named remote collection. The
+// collection is not checked until the first request.
+func (c *Client) Collection(name string) *Collection {
+	return &Collection{client: c, name: name}
+}
+
+// do sends a request, retrying transient failures, and decodes a JSON
+// response into out unless out is nil.
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, body []byte, out any) error {
+	u := c.baseURL.JoinPath(path)
+	u.RawQuery = query.Encode()
+
+	var err error
+	for attempt := 0; ; attempt++ {
+		var retry bool
+		retry, err = c.attempt(ctx, method, u.String(), body, out)
+		if err == nil || !retry || attempt >= c.cfg.MaxRetries {
+			break
+		}
+
+		wait := c.cfg.RetryBackoff << attempt
+		c.logger.Warn("request failed, retrying", "method", method, "path", path, "attempt", attempt+1, "wait", wait, "error", err)
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%s %s: %w", method, path, ctx.Err())
+		case <-time.After(wait):
+		}
+	}
+
+	if err != nil {
+		return fmt.Errorf("%s %s: %w", method, path, err)
+	}
+	return nil
+}
+
+func (c *Client) attempt(ctx context.Context, method, target string, body []byte, out any) (retry bool, err error) {
+	ctx, cancel := context.WithTimeout(ctx, c.cfg.Timeout)
+	defer cancel()
+
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, target, reader)
+	if err != nil {
+		return false, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.cfg.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.cfg.Token)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return true, err
+	}
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		return retryable(resp.StatusCode), decodeError(resp.StatusCode, data)
+	}
+	if out == nil || resp.StatusCode == http.StatusNoContent {
+		return false, nil
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return false, fmt.Errorf("decode response: %w", err)
+	}
+	return false, nil
+}
+
+func retryable(status int) bool {
+	switch status {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	default:
+		return false
+	}
+}
+
+func decodeError(status int, data []byte) error {
+	var resp httpapi.ErrorResponse
+	if err := json.Unmarshal(data, &resp); err != nil || resp.Error == "" {
+		return &Error{StatusCode: status, Message: strings.TrimSpace(string(data))}
+	}
+	return &Error{StatusCode: status, Code: resp.Code, Message: resp.Error, Violations: resp.Violations}
+}
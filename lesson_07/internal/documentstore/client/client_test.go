@@ -0,0 +1,221 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Nick2603/golang/lesson_07/internal/documentstore"
+	"github.com/Nick2603/golang/lesson_07/internal/httpapi"
+	"github.com/Nick2603/golang/lesson_07/internal/users"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var discardLogger = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+// setupRemote starts a server with a "users" collection and returns it
+// with a client whose retries are fast enough for tests.
+func setupRemote(t *testing.T, wrap func(http.Handler) http.Handler) (*httptest.Server, *Client) {
+	t.Helper()
+
+	store := documentstore.NewStoreWithLogger(discardLogger)
+	_, err := store.CreateCollection("users", &documentstore.CollectionConfig{PrimaryKey: "id"})
+	require.NoError(t, err)
+	_, err = store.CreateCollection("members", &documentstore.CollectionConfig{PrimaryKey: "id", KeyGen: documentstore.KeyGenULID})
+	require.NoError(t, err)
+
+	var handler http.Handler = httpapi.NewServer(store, discardLogger)
+	if wrap != nil {
+		handler = wrap(handler)
+	}
+	srv := httptest.NewServer(handler)
+	t.Cleanup(func() {
+		srv.Close()
+		store.Close()
+	})
+
+	c, err := New(Config{BaseURL: srv.URL, RetryBackoff: time.Millisecond, Timeout: time.Second, Logger: discardLogger})
+	require.NoError(t, err)
+	return srv, c
+}
+
+func TestCollection_UsersServiceOverNetwork(t *testing.T) {
+	_, c := setupRemote(t, nil)
+	var coll users.CollectionStore = c.Collection("users")
+	svc := users.NewService(coll)
+
+	_, err := svc.CreateUser("2", "Bob")
+	require.NoError(t, err)
+	_, err = svc.CreateUser("1", "Alice")
+	require.NoError(t, err)
+	_, err = svc.CreateUser("1", "Mallory")
+	assert.ErrorIs(t, err, users.ErrUserAlreadyExists)
+
+	user, err := svc.GetUser("1")
+	require.NoError(t, err)
+	assert.Equal(t, &users.User{ID: "1", Name: "Alice"}, user)
+
+	list, err := svc.ListUsers()
+	require.NoError(t, err)
+	assert.Equal(t, []users.User{{ID: "1", Name: "Alice"}, {ID: "2", Name: "Bob"}}, list)
+
+	require.NoError(t, svc.DeleteUser("1"))
+	_, err = svc.GetUser("1")
+	assert.ErrorIs(t, err, users.ErrUserNotFound)
+}
+
+func TestCollection_UsersServiceGeneratesIDsOverNetwork(t *testing.T) {
+	_, c := setupRemote(t, nil)
+	svc := users.NewService(c.Collection("members"))
+
+	user, err := svc.CreateUser("", "Carol")
+	require.NoError(t, err)
+	assert.NotEmpty(t, user.ID)
+
+	stored, err := svc.GetUser(user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, user, stored)
+}
+
+func TestCollection_InsertWithExpiry(t *testing.T) {
+	_, c := setupRemote(t, nil)
+	coll := c.Collection("users")
+
+	doc := func(id string) documentstore.Document {
+		return documentstore.Document{Fields: map[string]documentstore.DocumentField{
+			"id": {Type: documentstore.DocumentFieldTypeString, Value: id},
+		}}
+	}
+
+	expired, err := coll.Insert(doc("expired"), documentstore.WithExpiry(time.Now().Add(-time.Second)))
+	require.NoError(t, err)
+	_, err = coll.Get(expired)
+	assert.ErrorIs(t, err, documentstore.ErrDocumentNotFound)
+
+	live, err := coll.Insert(doc("live"), documentstore.WithTTL(time.Hour))
+	require.NoError(t, err)
+	_, err = coll.Get(live)
+	assert.NoError(t, err)
+}
+
+func TestCollection_Errors(t *testing.T) {
+	_, c := setupRemote(t, nil)
+	coll := c.Collection("users")
+
+	missing, err := coll.Key("missing")
+	require.NoError(t, err)
+	_, err = coll.Get(missing)
+	assert.ErrorIs(t, err, documentstore.ErrDocumentNotFound)
+
+	assert.ErrorIs(t, coll.Delete(missing), documentstore.ErrDocumentNotFound)
+
+	err = coll.Put(documentstore.Document{Fields: map[string]documentstore.DocumentField{
+		"name": {Type: documentstore.DocumentFieldTypeString, Value: "Alice"},
+	}})
+	assert.ErrorIs(t, err, documentstore.ErrInvalidPrimaryKey)
+
+	assert.ErrorIs(t, coll.Put(documentstore.Document{}), documentstore.ErrNilValue)
+
+	_, err = c.Collection("orders").Get(missing)
+	assert.ErrorIs(t, err, documentstore.ErrCollectionNotFound)
+
+	var serr *Error
+	require.ErrorAs(t, err, &serr)
+	assert.Equal(t, http.StatusNotFound, serr.StatusCode)
+}
+
+func TestCollection_ListPages(t *testing.T) {
+	_, c := setupRemote(t, nil)
+	coll := c.Collection("users")
+
+	for i := 0; i < listPageSize+5; i++ {
+		require.NoError(t, coll.Put(documentstore.Document{Fields: map[string]documentstore.DocumentField{
+			"id": {Type: documentstore.DocumentFieldTypeString, Value: fmt.Sprintf("%05d", i)},
+		}}))
+	}
+
+	assert.Len(t, coll.List(), listPageSize+5)
+	assert.Empty(t, c.Collection("orders").List())
+}
+
+func TestClient_RetriesTransientFailures(t *testing.T) {
+	var calls atomic.Int32
+	flaky := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if calls.Add(1) <= 2 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+	_, c := setupRemote(t, flaky)
+
+	missing, err := c.Collection("users").Key("missing")
+	require.NoError(t, err)
+	_, err = c.Collection("users").Get(missing)
+	assert.ErrorIs(t, err, documentstore.ErrDocumentNotFound)
+	assert.Equal(t, int32(3), calls.Load())
+
+	// Client errors are final.
+	calls.Store(10)
+	_, err = c.Collection("users").Get(missing)
+	assert.ErrorIs(t, err, documentstore.ErrDocumentNotFound)
+	assert.Equal(t, int32(11), calls.Load())
+}
+
+func TestClient_GivesUpAfterMaxRetries(t *testing.T) {
+	var calls atomic.Int32
+	down := func(http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls.Add(1)
+			http.Error(w, "maintenance", http.StatusServiceUnavailable)
+		})
+	}
+	_, c := setupRemote(t, down)
+
+	key, err := c.Collection("users").Key("1")
+	require.NoError(t, err)
+	_, err = c.Collection("users").Get(key)
+	var serr *Error
+	require.ErrorAs(t, err, &serr)
+	assert.Equal(t, http.StatusServiceUnavailable, serr.StatusCode)
+	assert.Equal(t, "maintenance", serr.Message)
+	assert.Equal(t, int32(defaultMaxRetries+1), calls.Load())
+}
+
+func TestClient_Timeout(t *testing.T) {
+	release := make(chan struct{})
+	slow := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			select {
+			case <-release:
+			case <-r.Context().Done():
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+	srv, _ := setupRemote(t, slow)
+	t.Cleanup(func() { close(release) })
+
+	c, err := New(Config{BaseURL: srv.URL, Timeout: 20 * time.Millisecond, MaxRetries: -1, Logger: discardLogger})
+	require.NoError(t, err)
+
+	key, err := c.Collection("users").Key("1")
+	require.NoError(t, err)
+	_, err = c.Collection("users").Get(key)
+	assert.True(t, errors.Is(err, context.DeadlineExceeded), "got %v", err)
+}
+
+func TestNew_InvalidBaseURL(t *testing.T) {
+	_, err := New(Config{BaseURL: "localhost:8080"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,166 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/Nick2603/golang/lesson_07/internal/documentstore"
+	"github.com/Nick2603/golang/lesson_07/internal/httpapi"
+)
+
+// Collection is a remote documentstore collection.
+type Collection struct {
+	client *Client
+	name   string
+}
+
+func (c *Collection) Name() string {
+	return c.name
+}
+
+// Put inserts or replaces a document.
+func (c *Collection) Put(doc documentstore.Document) error {
+	return c.PutContext(context.Background(), doc)
+}
+
+func (c *Collection) PutContext(ctx context.Context, doc documentstore.Document) error {
+	_, err := c.InsertContext(ctx, doc)
+	return err
+}
+
+// Insert writes doc and returns its primary key, which the server
+// generates when the collection has a key generator and doc has no key.
+// Put options are sent along and applied by the server.
+func (c *Collection) Insert(doc documentstore.Document, opts ...documentstore.PutOption) (string, error) {
+	return c.InsertContext(context.Background(), doc, opts...)
+}
+
+func (c *Collection) InsertContext(ctx context.Context, doc documentstore.Document, opts ...documentstore.PutOption) (string, error) {
+	if doc.Fields == nil {
+		return "", documentstore.ErrNilValue
+	}
+
+	query := url.Values{}
+	settings := documentstore.ResolvePutOptions(opts...)
+	if !settings.ExpiresAt.IsZero() {
+		query.Set("expires_at", settings.ExpiresAt.Format(time.RFC3339Nano))
+	}
+	if settings.TTL > 0 {
+		query.Set("ttl", settings.TTL.String())
+	}
+	if settings.IfAbsent {
+		query.Set("if_absent", "true")
+	}
+	if settings.IfExists {
+		query.Set("if_exists", "true")
+	}
+
+	body, err := json.Marshal(documentstore.EncodeDocument(doc))
+	if err != nil {
+		return "", err
+	}
+
+	var resp httpapi.InsertResponse
+	if err := c.client.do(ctx, http.MethodPost, c.documentsPath(), query, body, &resp); err != nil {
+		c.client.logger.Error("failed to put remote document", "collection", c.name, "error", err)
+		return "", err
+	}
+	return documentstore.EncodeKey(resp.Key...)
+}
+
+// Key returns the key under which a document with the given primary key
+// values is stored, for use with Get and Delete.
+func (c *Collection) Key(values ...any) (string, error) {
+	return documentstore.EncodeKey(values...)
+}
+
+func (c *Collection) Get(key string) (*documentstore.Document, error) {
+	return c.GetContext(context.Background(), key)
+}
+
+func (c *Collection) GetContext(ctx context.Context, key string) (*documentstore.Document, error) {
+	path, err := c.documentPath(key)
+	if err != nil {
+		return nil, err
+	}
+
+	var raw json.RawMessage
+	if err := c.client.do(ctx, http.MethodGet, path, nil, nil, &raw); err != nil {
+		return nil, err
+	}
+
+	doc, err := documentstore.DecodeDocument(raw)
+	if err != nil {
+		return nil, err
+	}
+	return &doc, nil
+}
+
+// List returns every document in key order. Like documentstore.Collection,
+// it has no error result: failures are logged and an empty list returned.
+func (c *Collection) List() []documentstore.Document {
+	docs, err := c.ListContext(context.Background())
+	if err != nil {
+		c.client.logger.Error("failed to list remote documents", "collection", c.name, "error", err)
+		return []documentstore.Document{}
+	}
+	return docs
+}
+
+// ListContext fetches every document in key order, one page at a time.
+func (c *Collection) ListContext(ctx context.Context) ([]documentstore.Document, error) {
+	docs := []documentstore.Document{}
+	query := url.Values{"limit": {strconv.Itoa(listPageSize)}}
+	for {
+		var page struct {
+			Documents []json.RawMessage `json:"documents"`
+			Next      string            `json:"next"`
+		}
+		if err := c.client.do(ctx, http.MethodGet, c.documentsPath(), query, nil, &page); err != nil {
+			return nil, err
+		}
+
+		for _, raw := range page.Documents {
+			doc, err := documentstore.DecodeDocument(raw)
+			if err != nil {
+				return nil, err
+			}
+			docs = append(docs, doc)
+		}
+
+		if page.Next == "" {
+			return docs, nil
+		}
+		query.Set("after", page.Next)
+	}
+}
+
+func (c *Collection) Delete(key string) error {
+	return c.DeleteContext(context.Background(), key)
+}
+
+func (c *Collection) DeleteContext(ctx context.Context, key string) error {
+	path, err := c.documentPath(key)
+	if err != nil {
+		return err
+	}
+	return c.client.do(ctx, http.MethodDelete, path, nil, nil, nil)
+}
+
+func (c *Collection) documentsPath() string {
+	return "collections/" + url.PathEscape(c.name) + "/documents"
+}
+
+// documentPath addresses the document stored under key by its primary
+// key values.
+func (c *Collection) documentPath(key string) (string, error) {
+	values, err := documentstore.DecodeKey(key)
+	if err != nil {
+		return "", err
+	}
+	return c.documentsPath() + "/" + httpapi.KeyPath(values), nil
+}
@@ -0,0 +1,128 @@
+package documentstore
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// encodeDocument serializes a document for disk-backed engines.
+func encodeDocument(doc Document) ([]byte, error) {
+	return json.Marshal(doc)
+}
+
+// decodeDocument reverses encodeDocument. Unlike a plain json.Unmarshal it
+// keeps integral numbers as int64, so documents read back from disk look
+// the same as the ones that were written.
+func decodeDocument(data []byte) (Document, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	var doc Document
+	if err := dec.Decode(&doc); err != nil {
+		return Document{}, err
+	}
+
+	for name, field := range doc.Fields {
+		var err error
+		if field.Value, err = decodeNumbers(field.Value); err != nil {
+			return Document{}, fmt.Errorf("%w: %s", ErrUnsupportedDocumentField, name)
+		}
+		doc.Fields[name] = field
+	}
+
+	return doc, nil
+}
+
+// decodeNumbers converts the json.Numbers in a field value, including
+// array elements, to int64 or float64.
+func decodeNumbers(v any) (any, error) {
+	switch v := v.(type) {
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i, nil
+		}
+		return v.Float64()
+	case []any:
+		for i, elem := range v {
+			var err error
+			if v[i], err = decodeNumbers(elem); err != nil {
+				return nil, err
+			}
+		}
+		return v, nil
+	default:
+		return v, nil
+	}
+}
+
+// EncodeDocument converts a document into the plain JSON object used on the
+// wire and in exports, e.g. {"id": "1", "age": 30, "active": true}. Unlike
+// encodeDocument it drops the field types, which DecodeDocument infers.
+func EncodeDocument(doc Document) map[string]any {
+	out := make(map[string]any, len(doc.Fields))
+	for name, field := range doc.Fields {
+		out[name] = field.Value
+	}
+	return out
+}
+
+// DecodeDocument parses a plain JSON object into a document. Integral
+// numbers become int64 and other numbers float64; arrays may hold strings,
+// numbers and booleans. Nulls, nested arrays and objects are rejected
+// because documents cannot hold them.
+func DecodeDocument(data []byte) (Document, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	var raw map[string]any
+	if err := dec.Decode(&raw); err != nil {
+		return Document{}, fmt.Errorf("%w: %v", ErrMalformedDocument, err)
+	}
+	if raw == nil {
+		return Document{}, fmt.Errorf("%w: document must be a JSON object", ErrMalformedDocument)
+	}
+
+	doc := Document{Fields: make(map[string]DocumentField, len(raw))}
+	for name, value := range raw {
+		field, err := decodeWireField(value)
+		if err != nil {
+			return Document{}, fmt.Errorf("%w: %s: %v", ErrUnsupportedDocumentField, name, err)
+		}
+		doc.Fields[name] = field
+	}
+	return doc, nil
+}
+
+func decodeWireField(value any) (DocumentField, error) {
+	switch v := value.(type) {
+	case string:
+		return DocumentField{Type: DocumentFieldTypeString, Value: v}, nil
+	case bool:
+		return DocumentField{Type: DocumentFieldTypeBool, Value: v}, nil
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return DocumentField{Type: DocumentFieldTypeNumber, Value: i}, nil
+		}
+		f, err := v.Float64()
+		if err != nil {
+			return DocumentField{}, err
+		}
+		return DocumentField{Type: DocumentFieldTypeNumber, Value: f}, nil
+	case []any:
+		elems := make([]any, len(v))
+		for i, elem := range v {
+			field, err := decodeWireField(elem)
+			if err != nil {
+				return DocumentField{}, err
+			}
+			if field.Type == DocumentFieldTypeArray {
+				return DocumentField{}, fmt.Errorf("nested arrays are not supported")
+			}
+			elems[i] = field.Value
+		}
+		return DocumentField{Type: DocumentFieldTypeArray, Value: elems}, nil
+	default:
+		return DocumentField{}, fmt.Errorf("unsupported JSON value %T", value)
+	}
+}
@@ -0,0 +1,47 @@
+package documentstore
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecodeDocument(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    Document
+		wantErr error
+	}{
+		{
+			name:  "infers field types",
+			input: `{"id": "1", "age": 30, "score": 9.5, "active": true, "tags": ["a", 1]}`,
+			want: Document{Fields: map[string]DocumentField{
+				"id":     {Type: DocumentFieldTypeString, Value: "1"},
+				"age":    {Type: DocumentFieldTypeNumber, Value: int64(30)},
+				"score":  {Type: DocumentFieldTypeNumber, Value: 9.5},
+				"active": {Type: DocumentFieldTypeBool, Value: true},
+				"tags":   {Type: DocumentFieldTypeArray, Value: []any{"a", int64(1)}},
+			}},
+		},
+		{name: "malformed json", input: `{"id":`, wantErr: ErrMalformedDocument},
+		{name: "not an object", input: `null`, wantErr: ErrMalformedDocument},
+		{name: "null field", input: `{"id": null}`, wantErr: ErrUnsupportedDocumentField},
+		{name: "nested object", input: `{"address": {"city": "Kyiv"}}`, wantErr: ErrUnsupportedDocumentField},
+		{name: "nested array", input: `{"tags": [["a"]]}`, wantErr: ErrUnsupportedDocumentField},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := DecodeDocument([]byte(tt.input))
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, doc)
+			assert.Equal(t, map[string]any{"id": "1", "age": int64(30), "score": 9.5, "active": true, "tags": []any{"a", int64(1)}}, EncodeDocument(doc))
+		})
+	}
+}
@@ -1,79 +1,556 @@
 package documentstore
 
-import "log/slog"
+import (
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+)
 
 type Collection struct {
+	mu        sync.Mutex
+	name      string
+	namespace string
 	cfg       CollectionConfig
-	documents map[string]Document
+	engine    Engine
 	logger    *slog.Logger
+	changes   *changeLog
+	createdAt time.Time
+	options   map[string]string
+	cache     *cacheTracker
+	onEvict   []func(key string, doc Document)
+	hooks     collectionHooks
+	schema    *compiledSchema
+	expiries  map[string]time.Time
+	now       func() time.Time
+	reaper    *reaper
+	events    *eventHub
+	quota     *quotaTracker
+	// storeQuota is shared by all collections of a store or namespace.
+	storeQuota *quotaTracker
+	// ownsEvents is false once a store shares its event hub with the
+	// collection.
+	ownsEvents bool
+
+	migrations    []Migration
+	schemaVersion int
+	migration     *MigrationState
+	migrating     bool
+
+	sequence uint64
 }
 
 type CollectionConfig struct {
 	PrimaryKey string
+	// PrimaryKeys names several key fields, in order, for a composite key.
+	// When set, PrimaryKey must be empty or equal to its first element.
+	// Key fields may hold strings or numbers; see EncodeKey.
+	PrimaryKeys []string `json:",omitempty"`
+	// Engine selects the storage engine; defaults to EngineMemory.
+	Engine EngineKind `json:",omitempty"`
+	// DataDir is the directory used by disk-backed engines.
+	DataDir string `json:",omitempty"`
+	// Cache, when set, bounds the collection and evicts documents.
+	Cache *CacheConfig `json:",omitempty"`
+	// Expiry, when set, enables document expiry and the background reaper.
+	Expiry *ExpiryConfig `json:",omitempty"`
+	// Schema, when set, is checked by every Put.
+	Schema *Schema `json:",omitempty"`
+	// KeyGen fills in missing primary keys on Put and Insert. UUIDs and
+	// ULIDs are strings; KeyGenSequence issues the numbers 1, 2, 3...
+	KeyGen KeyGenerator `json:",omitempty"`
+	// Quota, when set, limits what Put may store in the collection.
+	Quota *Quota `json:",omitempty"`
 }
 
+// NewCollection creates a collection backed by the engine named in cfg.
+// It panics if the engine cannot be opened; use OpenCollection to handle
+// that error.
 func NewCollection(cfg CollectionConfig) *Collection {
-	return &Collection{
-		cfg:       cfg,
-		documents: make(map[string]Document),
-		logger:    slog.Default(),
+	coll, err := OpenCollection(cfg)
+	if err != nil {
+		panic(err)
+	}
+	return coll
+}
+
+// validConfig runs the checks OpenCollection makes before opening an
+// engine.
+func validConfig(cfg CollectionConfig) error {
+	if !validKeyGenerator(cfg.KeyGen) {
+		return fmt.Errorf("%w: %q", ErrInvalidKeyGenerator, cfg.KeyGen)
+	}
+	if err := validKeyFields(cfg); err != nil {
+		return err
+	}
+	if cfg.Quota != nil {
+		if err := validQuota(*cfg.Quota); err != nil {
+			return err
+		}
+	}
+	if cfg.Cache != nil {
+		if _, err := newCacheTracker(*cfg.Cache); err != nil {
+			return err
+		}
+	}
+	if cfg.Schema != nil {
+		if _, err := compileSchema(*cfg.Schema); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func OpenCollection(cfg CollectionConfig) (*Collection, error) {
+	if err := validConfig(cfg); err != nil {
+		return nil, err
+	}
+
+	var quota Quota
+	if cfg.Quota != nil {
+		quota = *cfg.Quota
+	}
+
+	var schema *compiledSchema
+	if cfg.Schema != nil {
+		var err error
+		if schema, err = compileSchema(*cfg.Schema); err != nil {
+			return nil, err
+		}
+	}
+
+	engine, err := newEngine(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	coll := &Collection{
+		cfg:        cfg,
+		engine:     engine,
+		logger:     slog.Default(),
+		createdAt:  time.Now(),
+		options:    make(map[string]string),
+		expiries:   make(map[string]time.Time),
+		now:        time.Now,
+		events:     newEventHub(),
+		ownsEvents: true,
+		schema:     schema,
+		quota:      &quotaTracker{quota: quota},
+	}
+
+	if err := coll.initUsage(); err != nil {
+		engine.Close()
+		return nil, err
+	}
+
+	if cfg.KeyGen == KeyGenSequence {
+		if err := coll.initSequence(); err != nil {
+			engine.Close()
+			return nil, err
+		}
+	}
+
+	if cfg.Cache != nil {
+		if err := coll.initCache(*cfg.Cache); err != nil {
+			engine.Close()
+			return nil, err
+		}
+	}
+
+	if cfg.Expiry != nil {
+		if err := coll.initExpiry(*cfg.Expiry); err != nil {
+			engine.Close()
+			return nil, err
+		}
+	}
+
+	return coll, nil
+}
+
+// initCache starts tracking documents already held by a persistent engine.
+func (c *Collection) initCache(cfg CacheConfig) error {
+	cache, err := newCacheTracker(cfg)
+	if err != nil {
+		return err
+	}
+
+	err = c.engine.Scan(func(key string, doc Document) bool {
+		cache.add(key, documentSize(doc))
+		return true
+	})
+	if err != nil {
+		return err
 	}
+
+	c.cache = cache
+	return c.evictOverflow("")
 }
 
 func (c *Collection) Put(doc Document) error {
+	return c.PutWithOptions(doc)
+}
+
+func (c *Collection) PutWithOptions(doc Document, opts ...PutOption) error {
+	var o putOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	defer c.events.wait(c.name)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if doc.Fields != nil {
+		var err error
+		if doc, err = c.fillKey(doc); err != nil {
+			return err
+		}
+	}
+	return c.put(doc, o)
+}
+
+func (c *Collection) put(doc Document, opts putOptions) error {
 	if doc.Fields == nil {
 		c.logger.Error("failed to put document: nil fields")
 		return ErrNilValue
 	}
 
-	field, ok := doc.Fields[c.cfg.PrimaryKey]
-	if !ok || field.Type != DocumentFieldTypeString {
-		c.logger.Error("failed to put document: invalid primary key", "primary_key", c.cfg.PrimaryKey)
-		return ErrInvalidPrimaryKey
+	key, err := c.primaryKey(doc)
+	if err != nil {
+		return err
 	}
 
-	key, ok := field.Value.(string)
-	if !ok || key == "" {
-		c.logger.Error("failed to put document: empty primary key value")
-		return ErrInvalidPrimaryKey
+	before, exists, err := c.engine.Get(key)
+	if err != nil {
+		c.logger.Error("failed to put document: engine read failed", "key", key, "error", err)
+		return err
+	}
+	stored := exists
+	if exists && c.expired(key) {
+		exists = false
+	}
+	if exists && opts.ifAbsent {
+		return fmt.Errorf("%w: %s", ErrDocumentAlreadyExists, FormatKey(key))
+	}
+	if !exists && opts.ifExists {
+		return fmt.Errorf("%w: %s", ErrDocumentNotFound, FormatKey(key))
 	}
 
-	_, exists := c.documents[key]
-	c.documents[key] = doc
+	if len(c.hooks.beforePut) > 0 {
+		doc = *cloneDocument(&doc)
+		if err := c.runBeforePut(key, &doc, before, exists); err != nil {
+			return err
+		}
+	}
+
+	if err := c.Validate(doc); err != nil {
+		c.logger.Warn("failed to put document: validation failed", "key", key, "error", err)
+		return err
+	}
+
+	expiresAt, err := c.expiryFor(doc, opts)
+	if err != nil {
+		c.logger.Error("failed to put document: invalid expiry", "key", key, "error", err)
+		return err
+	}
+
+	delta, err := c.reserveQuota(key, doc, before, stored)
+	if err != nil {
+		return err
+	}
+
+	rec := ChangeRecord{Op: ChangeOpPut, Key: key, Document: &doc}
+	if !expiresAt.IsZero() {
+		rec.ExpiresAt = &expiresAt
+	}
+	if err := c.record(rec); err != nil {
+		c.releaseQuota(delta)
+		c.logger.Error("failed to put document: change log write failed", "key", key, "error", err)
+		return err
+	}
+
+	if err := c.engine.Put(key, doc); err != nil {
+		c.releaseQuota(delta)
+		c.logger.Error("failed to put document: engine write failed", "key", key, "error", err)
+		return err
+	}
+
+	if err := c.runAfterPut(key, doc, before, exists); err != nil {
+		c.releaseQuota(delta)
+		return err
+	}
+
+	c.observeKey(doc)
+
+	if expiresAt.IsZero() {
+		delete(c.expiries, key)
+	} else {
+		c.expiries[key] = expiresAt
+	}
 
 	if exists {
+		c.publish(EventUpdate, key, &before, &doc)
 		c.logger.Info("document updated", "key", key)
 	} else {
+		c.publish(EventInsert, key, nil, &doc)
 		c.logger.Info("document created", "key", key)
 	}
 
+	if c.cache != nil {
+		c.cache.add(key, documentSize(doc))
+		if err := c.evictOverflow(key); err != nil {
+			c.logger.Error("failed to evict documents", "collection", c.name, "error", err)
+			return err
+		}
+	}
+
 	return nil
 }
 
+func (c *Collection) primaryKey(doc Document) (string, error) {
+	key, err := documentKey(c.cfg.keyFields(), doc)
+	if err != nil {
+		c.logger.Error("failed to put document: invalid primary key", "primary_key", c.cfg.keyFields(), "error", err)
+		return "", err
+	}
+	return key, nil
+}
+
 func (c *Collection) Get(key string) (*Document, error) {
-	doc, ok := c.documents[key]
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	doc, ok, err := c.engine.Get(key)
+	if err != nil {
+		c.logger.Error("failed to get document: engine read failed", "key", key, "error", err)
+		return nil, err
+	}
+
+	if ok && c.expired(key) {
+		if err := c.expire(key); err != nil {
+			return nil, err
+		}
+		ok = false
+	}
+
 	if !ok {
+		if c.cache != nil {
+			c.cache.stats.Misses++
+		}
 		c.logger.Warn("document not found", "key", key)
 		return nil, ErrDocumentNotFound
 	}
+
+	if c.cache != nil {
+		c.cache.stats.Hits++
+		c.cache.touch(key)
+	}
 	return &doc, nil
 }
 
+// lookup reads a live document without touching cache statistics.
+func (c *Collection) lookup(key string) (Document, bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	doc, ok, err := c.engine.Get(key)
+	if err != nil || !ok || c.expired(key) {
+		return Document{}, false, err
+	}
+	return doc, true, nil
+}
+
 func (c *Collection) Delete(key string) error {
-	if _, ok := c.documents[key]; !ok {
+	defer c.events.wait(c.name)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	doc, ok, err := c.engine.Get(key)
+	if err != nil {
+		c.logger.Error("failed to delete document: engine read failed", "key", key, "error", err)
+		return err
+	}
+
+	if ok && c.expired(key) {
+		if err := c.expire(key); err != nil {
+			return err
+		}
+		ok = false
+	}
+
+	if !ok {
 		c.logger.Warn("failed to delete document: not found", "key", key)
 		return ErrDocumentNotFound
 	}
 
-	delete(c.documents, key)
+	if err := c.runBeforeDelete(key, doc); err != nil {
+		return err
+	}
+
+	if err := c.deleteStored(key); err != nil {
+		c.logger.Error("failed to delete document", "key", key, "error", err)
+		return err
+	}
+
+	if err := c.runAfterDelete(key, doc); err != nil {
+		return err
+	}
+
+	c.forget(key, doc)
 	c.logger.Info("document deleted", "key", key)
 	return nil
 }
 
+// remove deletes a document that is known to exist, forgets any state
+// kept about it and returns the removed document.
+func (c *Collection) remove(key string) (Document, error) {
+	before, _, err := c.engine.Get(key)
+	if err != nil {
+		return Document{}, err
+	}
+
+	if err := c.deleteStored(key); err != nil {
+		return Document{}, err
+	}
+
+	c.forget(key, before)
+	return before, nil
+}
+
+func (c *Collection) deleteStored(key string) error {
+	if err := c.record(ChangeRecord{Op: ChangeOpDelete, Key: key}); err != nil {
+		return err
+	}
+	return c.engine.Delete(key)
+}
+
+func (c *Collection) forget(key string, before Document) {
+	c.releaseQuota(Usage{Documents: 1, Bytes: documentSize(before)})
+	delete(c.expiries, key)
+	if c.cache != nil {
+		c.cache.remove(key)
+	}
+
+	c.publish(EventDelete, key, &before, nil)
+}
+
+// List returns all live documents in ascending key order.
 func (c *Collection) List() []Document {
-	result := make([]Document, 0, len(c.documents))
-	for _, d := range c.documents {
-		result = append(result, d)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.list()
+}
+
+func (c *Collection) list() []Document {
+	result := make([]Document, 0, c.engine.Len())
+	err := c.engine.Scan(func(key string, doc Document) bool {
+		if !c.expired(key) {
+			result = append(result, doc)
+		}
+		return true
+	})
+	if err != nil {
+		c.logger.Error("failed to list documents: engine scan failed", "error", err)
 	}
 	return result
 }
+
+// scanBatchSize is how many documents Scan reads per lock.
+const scanBatchSize = 256
+
+// Scan calls fn for every live document in key order until fn returns
+// false. Documents are read in batches and the collection is unlocked while
+// fn runs, so fn may be slow or write to the collection; changes made
+// during the scan may or may not be seen.
+func (c *Collection) Scan(fn func(key string, doc Document) bool) error {
+	return c.ScanFrom("", fn)
+}
+
+// ScanFrom is Scan starting at the first key at or after start. To resume
+// after a key, pass the key followed by "\x00".
+func (c *Collection) ScanFrom(start string, fn func(key string, doc Document) bool) error {
+	type entry struct {
+		key string
+		doc Document
+	}
+
+	for {
+		batch := make([]entry, 0, scanBatchSize)
+		full := false
+		c.mu.Lock()
+		err := c.engine.ScanFrom(start, func(key string, doc Document) bool {
+			start = key + "\x00"
+			if !c.expired(key) {
+				batch = append(batch, entry{key: key, doc: doc})
+			}
+			full = len(batch) == scanBatchSize
+			return !full
+		})
+		c.mu.Unlock()
+		if err != nil {
+			c.logger.Error("failed to scan documents: engine scan failed", "error", err)
+			return err
+		}
+
+		for _, e := range batch {
+			if !fn(e.key, e.doc) {
+				return nil
+			}
+		}
+		if !full {
+			return nil
+		}
+	}
+}
+
+func (c *Collection) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.engine.Len()
+}
+
+// Compact reclaims space held by overwritten and deleted documents in
+// engines that support it.
+func (c *Collection) Compact() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	compactor, ok := c.engine.(interface{ Compact() error })
+	if !ok {
+		return ErrCompactionNotSupported
+	}
+
+	if err := compactor.Compact(); err != nil {
+		c.logger.Error("failed to compact collection", "collection", c.name, "error", err)
+		return err
+	}
+
+	c.logger.Info("collection compacted", "collection", c.name, "documents", c.engine.Len())
+	return nil
+}
+
+// Close stops the expiry reaper, ends the change streams of a standalone
+// collection and releases the storage engine.
+func (c *Collection) Close() error {
+	if c.reaper != nil {
+		c.reaper.stop()
+	}
+	if c.ownsEvents {
+		c.events.close()
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.engine.Close()
+}
+
+func (c *Collection) record(rec ChangeRecord) error {
+	if c.changes == nil {
+		return nil
+	}
+	rec.Namespace, rec.Collection = c.namespace, c.name
+	return c.changes.append(rec)
+}
@@ -1,7 +1,9 @@
 package documentstore
 
 import (
+	"fmt"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -40,10 +42,10 @@ func TestCollection_Put(t *testing.T) {
 			wantErr: ErrInvalidPrimaryKey,
 		},
 		{
-			name: "returns error when primary key is not string type",
+			name: "returns error when primary key is not string or number type",
 			doc: Document{
 				Fields: map[string]DocumentField{
-					"id": {Type: DocumentFieldTypeNumber, Value: 123},
+					"id": {Type: DocumentFieldTypeBool, Value: true},
 				},
 			},
 			wantErr: ErrInvalidPrimaryKey,
@@ -232,3 +234,61 @@ func TestCollection_List(t *testing.T) {
 		})
 	}
 }
+
+func TestCollection_Scan(t *testing.T) {
+	coll, clock := expiringCollection(t, ExpiryConfig{})
+
+	total := 2*scanBatchSize + 3
+	var want []string
+	for i := 0; i < total; i++ {
+		id := fmt.Sprintf("%04d", i)
+		if i%100 == 7 {
+			require.NoError(t, coll.PutWithOptions(userDoc(id, "x"), WithTTL(time.Minute)))
+			continue
+		}
+		require.NoError(t, coll.Put(userDoc(id, "x")))
+		want = append(want, id)
+	}
+	clock.Advance(time.Minute)
+
+	t.Run("visits live documents in key order", func(t *testing.T) {
+		var got []string
+		err := coll.Scan(func(key string, doc Document) bool {
+			assert.Equal(t, doc.Fields["id"].Value, key)
+			got = append(got, doc.Fields["id"].Value.(string))
+			return true
+		})
+		require.NoError(t, err)
+		assert.Equal(t, want, got)
+	})
+
+	t.Run("stops when fn returns false", func(t *testing.T) {
+		n := 0
+		require.NoError(t, coll.Scan(func(string, Document) bool {
+			n++
+			return n < 5
+		}))
+		assert.Equal(t, 5, n)
+	})
+
+	t.Run("resumes after a key", func(t *testing.T) {
+		var got []string
+		require.NoError(t, coll.ScanFrom(want[300]+"\x00", func(key string, _ Document) bool {
+			got = append(got, key)
+			return true
+		}))
+		assert.Equal(t, want[301:], got)
+	})
+
+	t.Run("fn may write to the collection", func(t *testing.T) {
+		require.NoError(t, coll.Scan(func(key string, doc Document) bool {
+			doc = *cloneDocument(&doc)
+			doc.Fields["name"] = DocumentField{Type: DocumentFieldTypeString, Value: "y"}
+			require.NoError(t, coll.Put(doc))
+			return true
+		}))
+		for _, doc := range coll.List() {
+			assert.Equal(t, "y", doc.Fields["name"].Value)
+		}
+	})
+}
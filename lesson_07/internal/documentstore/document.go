@@ -6,6 +6,8 @@ const (
 	DocumentFieldTypeString DocumentFieldType = "string"
 	DocumentFieldTypeNumber DocumentFieldType = "number"
 	DocumentFieldTypeBool   DocumentFieldType = "bool"
+	// DocumentFieldTypeArray holds a []any of strings, numbers and bools.
+	DocumentFieldTypeArray DocumentFieldType = "array"
 )
 
 type DocumentField struct {
@@ -16,3 +18,18 @@ type DocumentField struct {
 type Document struct {
 	Fields map[string]DocumentField
 }
+
+// arrayElement wraps an array element in a DocumentField of its type. It
+// reports false for values arrays cannot hold.
+func arrayElement(v any) (DocumentField, bool) {
+	switch v.(type) {
+	case string:
+		return DocumentField{Type: DocumentFieldTypeString, Value: v}, true
+	case int, int64, float64:
+		return DocumentField{Type: DocumentFieldTypeNumber, Value: v}, true
+	case bool:
+		return DocumentField{Type: DocumentFieldTypeBool, Value: v}, true
+	default:
+		return DocumentField{}, false
+	}
+}
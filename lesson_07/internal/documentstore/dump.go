@@ -5,64 +5,177 @@ import (
 	"encoding/json"
 	"io"
 	"os"
+	"time"
 )
 
 type StoreDump struct {
+	Version     int                       `json:"version"`
+	Metadata    StoreMetadata             `json:"metadata"`
 	Collections map[string]CollectionDump `json:"collections"`
+	Namespaces  map[string]StoreDump      `json:"namespaces,omitempty"`
 }
 
 type CollectionDump struct {
-	Config    CollectionConfig `json:"config"`
-	Documents []Document       `json:"documents"`
+	Config    CollectionConfig     `json:"config"`
+	Metadata  CollectionMetadata   `json:"metadata"`
+	Documents []Document           `json:"documents"`
+	Expiries  map[string]time.Time `json:"expiries,omitempty"`
 }
 
 func (s *Store) Dump() ([]byte, error) {
 	s.logger.Info("starting store dump")
 
+	dumpedAt := time.Now()
+	dump := s.dump(dumpedAt)
+
+	data, err := json.Marshal(dump)
+	if err != nil {
+		s.logger.Error("failed to marshal store dump", "error", err)
+		return nil, err
+	}
+
+	s.logger.Info("store dump completed", "collections_count", len(s.collections), "namespaces_count", len(s.namespaces))
+	return data, nil
+}
+
+// dump captures the store and its namespaces and records dumpedAt as
+// their last dump time.
+func (s *Store) dump(dumpedAt time.Time) StoreDump {
+	metadata := s.metadata()
+	metadata.LastDumpAt = dumpedAt
+
 	dump := StoreDump{
+		Version:     DumpFormatVersion,
+		Metadata:    metadata,
 		Collections: make(map[string]CollectionDump),
 	}
 
 	for name, coll := range s.collections {
-		dump.Collections[name] = CollectionDump{
-			Config:    coll.cfg,
-			Documents: coll.List(),
-		}
+		dump.Collections[name] = coll.dump()
 	}
 
-	data, err := json.Marshal(dump)
-	if err != nil {
-		s.logger.Error("failed to marshal store dump", "error", err)
-		return nil, err
+	for name, ns := range s.namespaces {
+		if dump.Namespaces == nil {
+			dump.Namespaces = make(map[string]StoreDump)
+		}
+		dump.Namespaces[name] = ns.dump(dumpedAt)
 	}
 
-	s.logger.Info("store dump completed", "collections_count", len(s.collections))
-	return data, nil
+	s.lastDumpAt = dumpedAt
+	return dump
 }
 
 func NewStoreFromDump(dump []byte) (*Store, error) {
-	var storeDump StoreDump
-	if err := json.Unmarshal(dump, &storeDump); err != nil {
+	storeDump, err := decodeDump(dump)
+	if err != nil {
 		return nil, err
 	}
 
 	store := NewStore()
+	if err := store.load(storeDump, false); err != nil {
+		store.Close()
+		return nil, err
+	}
+
+	store.logger.Info("store loaded from dump",
+		"collections_count", len(storeDump.Collections),
+		"namespaces_count", len(storeDump.Namespaces),
+		"version", storeDump.Version,
+	)
+	return store, nil
+}
+
+// load fills an empty store, and its namespaces, from a dump. With raw,
+// documents go straight to the engines as RestoreAt replays them;
+// otherwise they are written with PutWithOptions.
+func (s *Store) load(storeDump *StoreDump, raw bool) error {
+	s.restoreMetadata(storeDump.Metadata)
 
 	for name, collDump := range storeDump.Collections {
-		coll, err := store.CreateCollection(name, &collDump.Config)
+		cfg, err := s.rebuildConfig(collDump.Config)
+		if err != nil {
+			return err
+		}
+		coll, err := s.CreateCollection(name, &cfg)
 		if err != nil {
-			return nil, err
+			return err
 		}
+		coll.restoreMetadata(collDump.Metadata)
 
 		for _, doc := range collDump.Documents {
-			if err := coll.Put(doc); err != nil {
-				return nil, err
+			if raw {
+				err = coll.restoreDocument(doc, collDump.expiresAt(doc))
+			} else {
+				err = coll.PutWithOptions(doc, collDump.expiryOptions(doc)...)
 			}
+			if err != nil {
+				return err
+			}
+		}
+
+		if len(collDump.Documents) != collDump.Metadata.DocumentCount {
+			s.logger.Warn("document count differs from dump metadata",
+				"collection", name,
+				"documents", len(collDump.Documents),
+				"document_count", collDump.Metadata.DocumentCount,
+			)
 		}
 	}
 
-	store.logger.Info("store loaded from dump", "collections_count", len(storeDump.Collections))
-	return store, nil
+	for name, nsDump := range storeDump.Namespaces {
+		ns, err := s.Namespace(name)
+		if err != nil {
+			return err
+		}
+		if err := ns.load(&nsDump, raw); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (c *Collection) dump() CollectionDump {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	docs := c.list()
+	dump := CollectionDump{
+		Config:    c.cfg,
+		Metadata:  c.metadata(len(docs)),
+		Documents: docs,
+	}
+
+	for key, at := range c.expiries {
+		if c.expired(key) {
+			continue
+		}
+		if dump.Expiries == nil {
+			dump.Expiries = make(map[string]time.Time)
+		}
+		dump.Expiries[key] = at
+	}
+
+	return dump
+}
+
+// expiryOptions restores the deadline a document had when it was dumped.
+func (d CollectionDump) expiryOptions(doc Document) []PutOption {
+	at := d.expiresAt(doc)
+	if at.IsZero() {
+		return nil
+	}
+	return []PutOption{WithExpiry(at)}
+}
+
+// expiresAt returns the deadline a document had when it was dumped, or
+// the zero time.
+func (d CollectionDump) expiresAt(doc Document) time.Time {
+	key, err := documentKey(d.Config.keyFields(), doc)
+	if err != nil {
+		return time.Time{}
+	}
+	return d.Expiries[key]
 }
 
 func (s *Store) DumpToFile(filename string) error {
@@ -0,0 +1,125 @@
+package documentstore
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Engine stores the documents of a single collection by primary key.
+// Collection performs validation and logging; engines only persist.
+type Engine interface {
+	Get(key string) (Document, bool, error)
+	Put(key string, doc Document) error
+	Delete(key string) error
+	// Scan calls fn for every document in ascending key order until fn
+	// returns false.
+	Scan(fn func(key string, doc Document) bool) error
+	// ScanFrom is Scan starting at the first key at or after start.
+	ScanFrom(start string, fn func(key string, doc Document) bool) error
+	Len() int
+	Close() error
+}
+
+type EngineKind string
+
+const (
+	EngineMemory  EngineKind = "memory"
+	EngineBitcask EngineKind = "bitcask"
+	EngineLSM     EngineKind = "lsm"
+)
+
+// dataDirLockFilename is the file disk engines lock, so that only one
+// engine at a time opens a DataDir.
+const dataDirLockFilename = "LOCK"
+
+// onDisk reports whether the engine keeps its documents in DataDir.
+func (k EngineKind) onDisk() bool {
+	return k == EngineBitcask || k == EngineLSM
+}
+
+func newEngine(cfg CollectionConfig) (Engine, error) {
+	switch cfg.Engine {
+	case "", EngineMemory:
+		return newMemoryEngine(), nil
+	case EngineBitcask, EngineLSM:
+		if cfg.DataDir == "" {
+			return nil, fmt.Errorf("%w: %s engine requires DataDir", ErrInvalidEngineConfig, cfg.Engine)
+		}
+		if cfg.Engine == EngineLSM {
+			return openLSMEngine(cfg.DataDir)
+		}
+		return openBitcaskEngine(cfg.DataDir)
+	default:
+		return nil, fmt.Errorf("%w: unknown engine %q", ErrInvalidEngineConfig, cfg.Engine)
+	}
+}
+
+type memoryEngine struct {
+	documents map[string]Document
+	// keys caches the sorted keys; nil after the key set changes.
+	keys []string
+}
+
+func newMemoryEngine() *memoryEngine {
+	return &memoryEngine{documents: make(map[string]Document)}
+}
+
+func (e *memoryEngine) Get(key string) (Document, bool, error) {
+	doc, ok := e.documents[key]
+	return doc, ok, nil
+}
+
+func (e *memoryEngine) Put(key string, doc Document) error {
+	if _, ok := e.documents[key]; !ok {
+		e.keys = nil
+	}
+	e.documents[key] = doc
+	return nil
+}
+
+func (e *memoryEngine) Delete(key string) error {
+	if _, ok := e.documents[key]; ok {
+		e.keys = nil
+	}
+	delete(e.documents, key)
+	return nil
+}
+
+func (e *memoryEngine) Scan(fn func(key string, doc Document) bool) error {
+	return e.ScanFrom("", fn)
+}
+
+func (e *memoryEngine) ScanFrom(start string, fn func(key string, doc Document) bool) error {
+	if e.keys == nil {
+		e.keys = sortedKeys(e.documents)
+	}
+
+	keys := e.keys
+	for _, key := range keys[sort.SearchStrings(keys, start):] {
+		doc, ok := e.documents[key]
+		if !ok {
+			continue
+		}
+		if !fn(key, doc) {
+			break
+		}
+	}
+	return nil
+}
+
+func (e *memoryEngine) Len() int {
+	return len(e.documents)
+}
+
+func (e *memoryEngine) Close() error {
+	return nil
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,83 @@
+package documentstore
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEngine_ScanFrom(t *testing.T) {
+	tests := []struct {
+		name string
+		open func(t *testing.T) Engine
+	}{
+		{name: "memory", open: func(t *testing.T) Engine { return newMemoryEngine() }},
+		{name: "bitcask", open: func(t *testing.T) Engine {
+			engine, err := openBitcaskEngine(t.TempDir())
+			require.NoError(t, err)
+			return engine
+		}},
+		{name: "lsm", open: func(t *testing.T) Engine { return openSmallLSM(t, t.TempDir()) }},
+	}
+
+	const n = 100
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			engine := tt.open(t)
+			defer engine.Close()
+
+			for i := 0; i < n; i++ {
+				require.NoError(t, engine.Put(lsmKey(i), lsmDoc(i, 1)))
+			}
+			for i := 0; i < n; i += 2 {
+				require.NoError(t, engine.Delete(lsmKey(i)))
+			}
+
+			scan := func(start string, limit int) []string {
+				var keys []string
+				require.NoError(t, engine.ScanFrom(start, func(key string, _ Document) bool {
+					keys = append(keys, key)
+					return len(keys) < limit
+				}))
+				return keys
+			}
+
+			assert.Equal(t, []string{lsmKey(1), lsmKey(3)}, scan("", 2))
+			assert.Equal(t, []string{lsmKey(51), lsmKey(53)}, scan(lsmKey(50), 2))
+			assert.Equal(t, []string{lsmKey(53)}, scan(lsmKey(51)+"\x00", 1))
+			assert.Equal(t, []string{lsmKey(99)}, scan(lsmKey(99), n))
+			assert.Empty(t, scan(lsmKey(99)+"\x00", n))
+
+			// A new key after a scan shows up in the next one.
+			require.NoError(t, engine.Put(lsmKey(50), lsmDoc(50, 2)))
+			assert.Equal(t, []string{lsmKey(50), lsmKey(51)}, scan(lsmKey(49)+"\x00", 2))
+		})
+	}
+}
+
+func TestEngine_LocksDataDir(t *testing.T) {
+	tests := []struct {
+		name string
+		open func(dir string) (Engine, error)
+	}{
+		{name: "bitcask", open: func(dir string) (Engine, error) { return openBitcaskEngine(dir) }},
+		{name: "lsm", open: func(dir string) (Engine, error) { return openLSMEngine(dir) }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			engine, err := tt.open(dir)
+			require.NoError(t, err)
+
+			_, err = tt.open(dir)
+			assert.ErrorIs(t, err, ErrDataDirLocked)
+
+			require.NoError(t, engine.Close())
+			reopened, err := tt.open(dir)
+			require.NoError(t, err)
+			require.NoError(t, reopened.Close())
+		})
+	}
+}
@@ -4,9 +4,41 @@ import "errors"
 
 var (
 	ErrDocumentNotFound         = errors.New("document not found")
+	ErrDocumentAlreadyExists    = errors.New("document already exists")
 	ErrCollectionAlreadyExists  = errors.New("collection already exists")
 	ErrCollectionNotFound       = errors.New("collection not found")
 	ErrUnsupportedDocumentField = errors.New("unsupported document field")
+	ErrMalformedDocument        = errors.New("malformed document")
 	ErrInvalidPrimaryKey        = errors.New("invalid primary key")
 	ErrNilValue                 = errors.New("got nil instead of value")
+	ErrRecoveryAlreadyEnabled   = errors.New("recovery already enabled")
+	ErrRecoveryNotEnabled       = errors.New("recovery not enabled")
+	ErrNoSnapshot               = errors.New("no snapshot at or before requested time")
+	ErrCorruptChangeLog         = errors.New("corrupt change log")
+	ErrUnknownConflictPolicy    = errors.New("unknown conflict policy")
+	ErrConfigMismatch           = errors.New("collection config mismatch")
+	ErrRestoreConflict          = errors.New("restore conflict")
+	ErrUnsupportedDumpVersion   = errors.New("unsupported dump version")
+	ErrInvalidEngineConfig      = errors.New("invalid engine config")
+	ErrCorruptDataFile          = errors.New("corrupt data file")
+	ErrDataDirLocked            = errors.New("data dir in use by another engine")
+	ErrCompactionNotSupported   = errors.New("compaction not supported by engine")
+	ErrInvalidCacheConfig       = errors.New("invalid cache config")
+	ErrInvalidExpiry            = errors.New("invalid expiry")
+	ErrInvalidWatchOptions      = errors.New("invalid watch options")
+	ErrInvalidResumeToken       = errors.New("invalid resume token")
+	ErrSlowConsumer             = errors.New("change stream consumer too slow")
+	ErrStoreClosed              = errors.New("store closed")
+	ErrValidationFailed         = errors.New("document validation failed")
+	ErrInvalidSchema            = errors.New("invalid schema")
+	ErrInvalidMigration         = errors.New("invalid migration")
+	ErrMigrationFailed          = errors.New("migration failed")
+	ErrMigrationInProgress      = errors.New("migration already in progress")
+	ErrInvalidKeyGenerator      = errors.New("invalid key generator")
+	ErrUnauthenticated          = errors.New("unauthenticated")
+	ErrPermissionDenied         = errors.New("permission denied")
+	ErrNamespaceNotFound        = errors.New("namespace not found")
+	ErrInvalidNamespace         = errors.New("invalid namespace")
+	ErrQuotaExceeded            = errors.New("quota exceeded")
+	ErrInvalidQuota             = errors.New("invalid quota")
 )
@@ -0,0 +1,242 @@
+package documentstore
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+const defaultReapInterval = time.Second
+
+// ExpiryConfig enables per-document expiry on a collection. A document's
+// deadline comes from, in order: WithExpiry/WithTTL passed to
+// PutWithOptions, the timestamp in Field, and DefaultTTL.
+type ExpiryConfig struct {
+	// Field names a document field holding the expiry time, either as Unix
+	// seconds (number) or as an RFC 3339 string.
+	Field string `json:",omitempty"`
+	// DefaultTTL applies to documents without any other deadline.
+	DefaultTTL time.Duration `json:",omitempty"`
+	// ReapInterval is how often expired documents are removed in the
+	// background. Defaults to one second; negative disables the reaper and
+	// leaves expiry to reads.
+	ReapInterval time.Duration `json:",omitempty"`
+}
+
+type PutOption func(*putOptions)
+
+type putOptions struct {
+	expiresAt time.Time
+	ttl       time.Duration
+	ifAbsent  bool
+	ifExists  bool
+}
+
+// WithExpiry makes the document expire at the given time.
+func WithExpiry(at time.Time) PutOption {
+	return func(o *putOptions) {
+		o.expiresAt = at
+	}
+}
+
+// WithTTL makes the document expire after d.
+func WithTTL(d time.Duration) PutOption {
+	return func(o *putOptions) {
+		o.ttl = d
+	}
+}
+
+// IfAbsent makes the write fail with ErrDocumentAlreadyExists when a live
+// document already has the key. The check and the write are atomic.
+func IfAbsent() PutOption {
+	return func(o *putOptions) {
+		o.ifAbsent = true
+	}
+}
+
+// IfExists makes the write fail with ErrDocumentNotFound unless a live
+// document already has the key. The check and the write are atomic.
+func IfExists() PutOption {
+	return func(o *putOptions) {
+		o.ifExists = true
+	}
+}
+
+// PutSettings is what a list of put options asks for, for callers that
+// forward the options elsewhere, such as a remote client.
+type PutSettings struct {
+	ExpiresAt time.Time
+	TTL       time.Duration
+	IfAbsent  bool
+	IfExists  bool
+}
+
+func ResolvePutOptions(opts ...PutOption) PutSettings {
+	var o putOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return PutSettings{ExpiresAt: o.expiresAt, TTL: o.ttl, IfAbsent: o.ifAbsent, IfExists: o.ifExists}
+}
+
+func (c *Collection) expiryFor(doc Document, opts putOptions) (time.Time, error) {
+	switch {
+	case !opts.expiresAt.IsZero():
+		return opts.expiresAt, nil
+	case opts.ttl > 0:
+		return c.now().Add(opts.ttl), nil
+	case c.cfg.Expiry == nil:
+		return time.Time{}, nil
+	}
+
+	if c.cfg.Expiry.Field != "" {
+		if field, ok := doc.Fields[c.cfg.Expiry.Field]; ok {
+			return parseExpiryField(field)
+		}
+	}
+
+	if c.cfg.Expiry.DefaultTTL > 0 {
+		return c.now().Add(c.cfg.Expiry.DefaultTTL), nil
+	}
+
+	return time.Time{}, nil
+}
+
+func parseExpiryField(field DocumentField) (time.Time, error) {
+	switch field.Type {
+	case DocumentFieldTypeNumber:
+		seconds, ok := numberValue(field.Value)
+		if !ok {
+			return time.Time{}, fmt.Errorf("%w: %v", ErrInvalidExpiry, field.Value)
+		}
+		whole, frac := math.Modf(seconds)
+		return time.Unix(int64(whole), int64(frac*float64(time.Second))), nil
+	case DocumentFieldTypeString:
+		s, _ := field.Value.(string)
+		at, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("%w: %v", ErrInvalidExpiry, err)
+		}
+		return at, nil
+	default:
+		return time.Time{}, fmt.Errorf("%w: field type %s", ErrInvalidExpiry, field.Type)
+	}
+}
+
+// initExpiry rebuilds field-based deadlines for documents already held by
+// a persistent engine and starts the reaper.
+func (c *Collection) initExpiry(cfg ExpiryConfig) error {
+	if cfg.Field != "" {
+		err := c.engine.Scan(func(key string, doc Document) bool {
+			field, ok := doc.Fields[cfg.Field]
+			if !ok {
+				return true
+			}
+			if at, err := parseExpiryField(field); err == nil {
+				c.expiries[key] = at
+			}
+			return true
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	interval := cfg.ReapInterval
+	if interval == 0 {
+		interval = defaultReapInterval
+	}
+	if interval > 0 {
+		c.reaper = startReaper(c, interval)
+	}
+
+	return nil
+}
+
+func (c *Collection) expired(key string) bool {
+	at, ok := c.expiries[key]
+	return ok && !at.After(c.now())
+}
+
+func (c *Collection) expire(key string) error {
+	if _, err := c.remove(key); err != nil {
+		c.logger.Error("failed to expire document", "collection", c.name, "key", key, "error", err)
+		return err
+	}
+
+	c.logger.Info("document expired", "collection", c.name, "key", key)
+	return nil
+}
+
+// ExpiresAt reports the deadline of a document, if it has one.
+func (c *Collection) ExpiresAt(key string) (time.Time, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	at, ok := c.expiries[key]
+	return at, ok
+}
+
+// ReapExpired removes every expired document and returns how many were
+// removed. The background reaper calls it periodically.
+func (c *Collection) ReapExpired() int {
+	defer c.events.wait(c.name)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	reaped := 0
+	for key := range c.expiries {
+		if !c.expired(key) {
+			continue
+		}
+		if err := c.expire(key); err != nil {
+			break
+		}
+		reaped++
+	}
+
+	if reaped > 0 {
+		c.logger.Info("expired documents reaped", "collection", c.name, "count", reaped)
+	}
+	return reaped
+}
+
+type reaper struct {
+	done     chan struct{}
+	stopped  chan struct{}
+	stopOnce sync.Once
+}
+
+func startReaper(c *Collection, interval time.Duration) *reaper {
+	r := &reaper{
+		done:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+
+	go func() {
+		defer close(r.stopped)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-r.done:
+				return
+			case <-ticker.C:
+				c.ReapExpired()
+			}
+		}
+	}()
+
+	return r
+}
+
+// stop signals the reaper and waits for it to exit.
+func (r *reaper) stop() {
+	r.stopOnce.Do(func() {
+		close(r.done)
+	})
+	<-r.stopped
+}
@@ -0,0 +1,266 @@
+package documentstore
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func expiringCollection(t *testing.T, cfg ExpiryConfig) (*Collection, *fakeClock) {
+	t.Helper()
+
+	cfg.ReapInterval = -1
+	coll := NewCollection(CollectionConfig{PrimaryKey: "id", Expiry: &cfg})
+	clock := &fakeClock{now: time.Unix(1_700_000_000, 0)}
+	coll.now = clock.Now
+	t.Cleanup(func() { coll.Close() })
+	return coll, clock
+}
+
+func TestCollection_Expiry(t *testing.T) {
+	base := time.Unix(1_700_000_000, 0)
+
+	withField := func(doc Document, field DocumentField) Document {
+		doc.Fields["expires"] = field
+		return doc
+	}
+
+	tests := []struct {
+		name    string
+		cfg     ExpiryConfig
+		doc     Document
+		opts    []PutOption
+		wantAt  time.Time
+		wantErr error
+	}{
+		{
+			name:   "ttl option",
+			doc:    userDoc("a", "A"),
+			opts:   []PutOption{WithTTL(time.Minute)},
+			wantAt: base.Add(time.Minute),
+		},
+		{
+			name:   "absolute option",
+			doc:    userDoc("a", "A"),
+			opts:   []PutOption{WithExpiry(base.Add(time.Hour))},
+			wantAt: base.Add(time.Hour),
+		},
+		{
+			name:   "default ttl",
+			cfg:    ExpiryConfig{DefaultTTL: 10 * time.Second},
+			doc:    userDoc("a", "A"),
+			wantAt: base.Add(10 * time.Second),
+		},
+		{
+			name:   "unix seconds field",
+			cfg:    ExpiryConfig{Field: "expires", DefaultTTL: time.Second},
+			doc:    withField(userDoc("a", "A"), DocumentField{Type: DocumentFieldTypeNumber, Value: float64(base.Unix() + 30)}),
+			wantAt: base.Add(30 * time.Second),
+		},
+		{
+			name:   "rfc3339 field",
+			cfg:    ExpiryConfig{Field: "expires"},
+			doc:    withField(userDoc("a", "A"), DocumentField{Type: DocumentFieldTypeString, Value: base.Add(time.Minute).Format(time.RFC3339)}),
+			wantAt: base.Add(time.Minute),
+		},
+		{
+			name:   "option wins over field",
+			cfg:    ExpiryConfig{Field: "expires"},
+			doc:    withField(userDoc("a", "A"), DocumentField{Type: DocumentFieldTypeNumber, Value: base.Unix() + 30}),
+			opts:   []PutOption{WithTTL(time.Second)},
+			wantAt: base.Add(time.Second),
+		},
+		{
+			name:    "invalid field",
+			cfg:     ExpiryConfig{Field: "expires"},
+			doc:     withField(userDoc("a", "A"), DocumentField{Type: DocumentFieldTypeString, Value: "tomorrow"}),
+			wantErr: ErrInvalidExpiry,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			coll, clock := expiringCollection(t, tt.cfg)
+
+			err := coll.PutWithOptions(tt.doc, tt.opts...)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				assert.Equal(t, 0, coll.Len())
+				return
+			}
+			require.NoError(t, err)
+
+			at, ok := coll.ExpiresAt("a")
+			require.True(t, ok)
+			assert.True(t, tt.wantAt.Equal(at), "expires at %v, want %v", at, tt.wantAt)
+
+			clock.now = tt.wantAt.Add(-time.Nanosecond)
+			_, err = coll.Get("a")
+			require.NoError(t, err)
+
+			clock.now = tt.wantAt
+			_, err = coll.Get("a")
+			assert.ErrorIs(t, err, ErrDocumentNotFound)
+			assert.Equal(t, 0, coll.Len())
+		})
+	}
+}
+
+func TestCollection_ExpiryListAndDelete(t *testing.T) {
+	coll, clock := expiringCollection(t, ExpiryConfig{})
+
+	require.NoError(t, coll.PutWithOptions(userDoc("a", "A"), WithTTL(time.Minute)))
+	require.NoError(t, coll.Put(userDoc("b", "B")))
+	require.NoError(t, coll.PutWithOptions(userDoc("c", "C"), WithTTL(time.Hour)))
+
+	clock.Advance(time.Minute)
+	assert.Equal(t, []string{"b", "c"}, keysOf(coll.List()))
+	assert.ErrorIs(t, coll.Delete("a"), ErrDocumentNotFound)
+
+	// Overwriting without an expiry clears the deadline.
+	require.NoError(t, coll.Put(userDoc("c", "C2")))
+	_, ok := coll.ExpiresAt("c")
+	assert.False(t, ok)
+}
+
+func TestCollection_PutIfAbsent(t *testing.T) {
+	coll, clock := expiringCollection(t, ExpiryConfig{})
+
+	require.NoError(t, coll.PutWithOptions(userDoc("a", "A"), IfAbsent(), WithTTL(time.Minute)))
+	err := coll.PutWithOptions(userDoc("a", "A2"), IfAbsent())
+	assert.ErrorIs(t, err, ErrDocumentAlreadyExists)
+	_, err = coll.Insert(userDoc("a", "A3"), IfAbsent())
+	assert.ErrorIs(t, err, ErrDocumentAlreadyExists)
+
+	doc, err := coll.Get("a")
+	require.NoError(t, err)
+	assert.Equal(t, "A", doc.Fields["name"].Value)
+
+	// An expired document no longer holds its key.
+	clock.Advance(time.Minute)
+	require.NoError(t, coll.PutWithOptions(userDoc("a", "A4"), IfAbsent()))
+}
+
+func TestCollection_PutIfExists(t *testing.T) {
+	coll, clock := expiringCollection(t, ExpiryConfig{})
+
+	err := coll.PutWithOptions(userDoc("a", "A"), IfExists())
+	assert.ErrorIs(t, err, ErrDocumentNotFound)
+	assert.Equal(t, 0, coll.Len())
+
+	require.NoError(t, coll.PutWithOptions(userDoc("a", "A"), WithTTL(time.Minute)))
+	_, err = coll.Insert(userDoc("a", "A2"), IfExists())
+	require.NoError(t, err)
+
+	doc, err := coll.Get("a")
+	require.NoError(t, err)
+	assert.Equal(t, "A2", doc.Fields["name"].Value)
+
+	// An expired document no longer holds its key.
+	require.NoError(t, coll.PutWithOptions(userDoc("a", "A3"), WithTTL(time.Minute)))
+	clock.Advance(time.Minute)
+	err = coll.PutWithOptions(userDoc("a", "A4"), IfExists())
+	assert.ErrorIs(t, err, ErrDocumentNotFound)
+}
+
+func TestCollection_ReapExpired(t *testing.T) {
+	coll, clock := expiringCollection(t, ExpiryConfig{DefaultTTL: time.Minute})
+
+	require.NoError(t, coll.Put(userDoc("a", "A")))
+	require.NoError(t, coll.Put(userDoc("b", "B")))
+	clock.Advance(30 * time.Second)
+	require.NoError(t, coll.Put(userDoc("c", "C")))
+
+	clock.Advance(30 * time.Second)
+	assert.Equal(t, 2, coll.ReapExpired())
+	assert.Equal(t, 1, coll.Len())
+	assert.Equal(t, 0, coll.ReapExpired())
+}
+
+func TestCollection_BackgroundReaper(t *testing.T) {
+	store := NewStore()
+	coll, err := store.CreateCollection("sessions", &CollectionConfig{
+		PrimaryKey: "id",
+		Expiry:     &ExpiryConfig{ReapInterval: time.Millisecond},
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, coll.PutWithOptions(userDoc("a", "A"), WithTTL(time.Millisecond)))
+	require.NoError(t, coll.Put(userDoc("b", "B")))
+
+	assert.Eventually(t, func() bool { return coll.Len() == 1 }, time.Second, time.Millisecond)
+
+	require.NoError(t, store.Close())
+	select {
+	case <-coll.reaper.stopped:
+	default:
+		t.Fatal("reaper still running after Store.Close")
+	}
+}
+
+func TestStore_DumpPreservesExpiry(t *testing.T) {
+	store := NewStore()
+	cfg := &CollectionConfig{PrimaryKey: "id", Expiry: &ExpiryConfig{ReapInterval: -1}}
+	coll, err := store.CreateCollection("sessions", cfg)
+	require.NoError(t, err)
+
+	at := time.Now().Add(time.Hour).Truncate(time.Second)
+	require.NoError(t, coll.PutWithOptions(userDoc("a", "A"), WithExpiry(at)))
+	require.NoError(t, coll.Put(userDoc("b", "B")))
+
+	data, err := store.Dump()
+	require.NoError(t, err)
+
+	restored, err := NewStoreFromDump(data)
+	require.NoError(t, err)
+	defer restored.Close()
+
+	restoredColl, err := restored.GetCollection("sessions")
+	require.NoError(t, err)
+
+	got, ok := restoredColl.ExpiresAt("a")
+	require.True(t, ok)
+	assert.True(t, at.Equal(got))
+	_, ok = restoredColl.ExpiresAt("b")
+	assert.False(t, ok)
+}
+
+func TestStore_RestoreFromDumpPreservesExpiry(t *testing.T) {
+	at := time.Now().Add(time.Hour).Truncate(time.Second)
+	dump := StoreDump{
+		Version: DumpFormatVersion,
+		Collections: map[string]CollectionDump{
+			"sessions": {
+				Config:    CollectionConfig{PrimaryKey: "id", Expiry: &ExpiryConfig{ReapInterval: -1}},
+				Documents: []Document{userDoc("a", "A"), userDoc("b", "B"), userDoc("c", "C")},
+				Expiries: map[string]time.Time{
+					"a": at,
+					"c": time.Now().Add(-time.Minute),
+				},
+			},
+		},
+	}
+	data, err := json.Marshal(dump)
+	require.NoError(t, err)
+
+	store := NewStore()
+	defer store.Close()
+	report, err := store.RestoreFromDump(data, RestoreOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, map[string][]string{"sessions": {"a", "b"}}, report.Restored)
+	assert.Equal(t, map[string][]string{"sessions": {"c"}}, report.Skipped)
+
+	coll, err := store.GetCollection("sessions")
+	require.NoError(t, err)
+
+	got, ok := coll.ExpiresAt("a")
+	require.True(t, ok)
+	assert.True(t, at.Equal(got))
+	_, ok = coll.ExpiresAt("b")
+	assert.False(t, ok)
+	_, err = coll.Get("c")
+	assert.ErrorIs(t, err, ErrDocumentNotFound)
+}
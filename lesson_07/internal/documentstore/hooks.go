@@ -0,0 +1,148 @@
+package documentstore
+
+import "errors"
+
+// BeforePutHook may change doc before it is written, or abort the write by
+// returning an error. existing is nil when the document is new.
+type BeforePutHook func(doc *Document, existing *Document) error
+
+// AfterPutHook runs once doc has been written. An error undoes the write.
+type AfterPutHook func(doc Document, existing *Document) error
+
+// BeforeDeleteHook may abort a Delete by returning an error.
+type BeforeDeleteHook func(doc Document) error
+
+// AfterDeleteHook runs once doc has been deleted. An error undoes the
+// delete.
+type AfterDeleteHook func(doc Document) error
+
+// collectionHooks are run in registration order while the collection is
+// locked, so hooks must not call back into the collection. Delete hooks
+// only run for Delete, not for expiry or cache eviction.
+type collectionHooks struct {
+	beforePut    []BeforePutHook
+	afterPut     []AfterPutHook
+	beforeDelete []BeforeDeleteHook
+	afterDelete  []AfterDeleteHook
+}
+
+func (c *Collection) OnBeforePut(fn BeforePutHook) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.hooks.beforePut = append(c.hooks.beforePut, fn)
+}
+
+func (c *Collection) OnAfterPut(fn AfterPutHook) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.hooks.afterPut = append(c.hooks.afterPut, fn)
+}
+
+func (c *Collection) OnBeforeDelete(fn BeforeDeleteHook) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.hooks.beforeDelete = append(c.hooks.beforeDelete, fn)
+}
+
+func (c *Collection) OnAfterDelete(fn AfterDeleteHook) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.hooks.afterDelete = append(c.hooks.afterDelete, fn)
+}
+
+func (c *Collection) runBeforePut(key string, doc *Document, before Document, exists bool) error {
+	for _, fn := range c.hooks.beforePut {
+		if err := fn(doc, existingDocument(before, exists)); err != nil {
+			c.logger.Warn("put aborted by hook", "collection", c.name, "key", key, "error", err)
+			return err
+		}
+		if doc.Fields == nil {
+			c.logger.Error("failed to put document: hook cleared fields", "collection", c.name, "key", key)
+			return ErrNilValue
+		}
+	}
+
+	newKey, err := c.primaryKey(*doc)
+	if err != nil {
+		return err
+	}
+	if newKey != key {
+		c.logger.Error("failed to put document: hook changed primary key", "collection", c.name, "key", key, "new_key", newKey)
+		return ErrInvalidPrimaryKey
+	}
+	return nil
+}
+
+func (c *Collection) runAfterPut(key string, doc Document, before Document, exists bool) error {
+	for _, fn := range c.hooks.afterPut {
+		if err := fn(doc, existingDocument(before, exists)); err != nil {
+			c.logger.Warn("put undone by hook", "collection", c.name, "key", key, "error", err)
+			if undoErr := c.restoreStored(key, before, exists); undoErr != nil {
+				return errors.Join(err, undoErr)
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *Collection) runBeforeDelete(key string, doc Document) error {
+	for _, fn := range c.hooks.beforeDelete {
+		if err := fn(doc); err != nil {
+			c.logger.Warn("delete aborted by hook", "collection", c.name, "key", key, "error", err)
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *Collection) runAfterDelete(key string, doc Document) error {
+	for _, fn := range c.hooks.afterDelete {
+		if err := fn(doc); err != nil {
+			c.logger.Warn("delete undone by hook", "collection", c.name, "key", key, "error", err)
+			if undoErr := c.restoreStored(key, doc, true); undoErr != nil {
+				return errors.Join(err, undoErr)
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// restoreStored puts back the stored state of key after a hook rejected a
+// write, recording the reversal so that change-log replay matches.
+func (c *Collection) restoreStored(key string, doc Document, exists bool) error {
+	if !exists {
+		delete(c.expiries, key)
+		err := c.deleteStored(key)
+		if err != nil {
+			c.logger.Error("failed to undo put", "collection", c.name, "key", key, "error", err)
+		}
+		return err
+	}
+
+	rec := ChangeRecord{Op: ChangeOpPut, Key: key, Document: &doc}
+	if at, ok := c.expiries[key]; ok {
+		rec.ExpiresAt = &at
+	}
+
+	err := c.record(rec)
+	if err == nil {
+		err = c.engine.Put(key, doc)
+	}
+	if err != nil {
+		c.logger.Error("failed to undo write", "collection", c.name, "key", key, "error", err)
+	}
+	return err
+}
+
+func existingDocument(doc Document, exists bool) *Document {
+	if !exists {
+		return nil
+	}
+	return cloneDocument(&doc)
+}
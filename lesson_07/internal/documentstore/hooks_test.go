@@ -0,0 +1,280 @@
+package documentstore
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var errVetoed = errors.New("vetoed")
+
+func TestCollection_PutHooks(t *testing.T) {
+	tests := []struct {
+		name      string
+		register  func(c *Collection, calls *[]string)
+		doc       Document
+		wantErr   error
+		wantName  any
+		wantCalls []string
+	}{
+		{
+			name: "before hooks run in order and mutate the document",
+			register: func(c *Collection, calls *[]string) {
+				c.OnBeforePut(func(doc *Document, existing *Document) error {
+					*calls = append(*calls, "trim")
+					name := doc.Fields["name"]
+					name.Value = strings.TrimSpace(name.Value.(string))
+					doc.Fields["name"] = name
+					return nil
+				})
+				c.OnBeforePut(func(doc *Document, existing *Document) error {
+					*calls = append(*calls, "lower")
+					name := doc.Fields["name"]
+					name.Value = strings.ToLower(name.Value.(string))
+					doc.Fields["name"] = name
+					return nil
+				})
+				c.OnAfterPut(func(doc Document, existing *Document) error {
+					*calls = append(*calls, "after:"+doc.Fields["name"].Value.(string))
+					return nil
+				})
+			},
+			doc:       userDoc("u1", "  ALICE "),
+			wantName:  "alice",
+			wantCalls: []string{"trim", "lower", "after:alice"},
+		},
+		{
+			name: "before hook error aborts the chain",
+			register: func(c *Collection, calls *[]string) {
+				c.OnBeforePut(func(*Document, *Document) error {
+					*calls = append(*calls, "first")
+					return errVetoed
+				})
+				c.OnBeforePut(func(*Document, *Document) error {
+					*calls = append(*calls, "second")
+					return nil
+				})
+				c.OnAfterPut(func(Document, *Document) error {
+					*calls = append(*calls, "after")
+					return nil
+				})
+			},
+			doc:       userDoc("u1", "Alice"),
+			wantErr:   errVetoed,
+			wantCalls: []string{"first"},
+		},
+		{
+			name: "before hook may not change the primary key",
+			register: func(c *Collection, calls *[]string) {
+				c.OnBeforePut(func(doc *Document, _ *Document) error {
+					doc.Fields["id"] = DocumentField{Type: DocumentFieldTypeString, Value: "other"}
+					return nil
+				})
+			},
+			doc:     userDoc("u1", "Alice"),
+			wantErr: ErrInvalidPrimaryKey,
+		},
+		{
+			name: "after hook error undoes the insert",
+			register: func(c *Collection, calls *[]string) {
+				c.OnAfterPut(func(Document, *Document) error {
+					*calls = append(*calls, "after")
+					return errVetoed
+				})
+			},
+			doc:       userDoc("u1", "Alice"),
+			wantErr:   errVetoed,
+			wantCalls: []string{"after"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			coll := NewCollection(CollectionConfig{PrimaryKey: "id"})
+			defer coll.Close()
+
+			var calls []string
+			tt.register(coll, &calls)
+
+			stream, err := coll.Watch(context.Background(), WatchOptions{})
+			require.NoError(t, err)
+
+			err = coll.Put(tt.doc)
+			assert.Equal(t, tt.wantCalls, calls)
+
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				assert.Equal(t, 0, coll.Len())
+				assert.Empty(t, stream.Events())
+				return
+			}
+			require.NoError(t, err)
+
+			got, err := coll.Get("u1")
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantName, got.Fields["name"].Value)
+			assert.Equal(t, tt.wantName, next(t, stream).After.Fields["name"].Value)
+		})
+	}
+}
+
+func TestCollection_PutHooksSeeExistingDocument(t *testing.T) {
+	coll := NewCollection(CollectionConfig{PrimaryKey: "id"})
+	defer coll.Close()
+
+	var existing []any
+	coll.OnBeforePut(func(doc *Document, old *Document) error {
+		if old == nil {
+			existing = append(existing, nil)
+		} else {
+			existing = append(existing, old.Fields["name"].Value)
+		}
+		return nil
+	})
+
+	doc := userDoc("u1", "Alice")
+	require.NoError(t, coll.Put(doc))
+	require.NoError(t, coll.Put(userDoc("u1", "Alicia")))
+	assert.Equal(t, []any{nil, "Alice"}, existing)
+}
+
+func TestCollection_AfterPutHookRestoresPreviousVersion(t *testing.T) {
+	coll := NewCollection(CollectionConfig{PrimaryKey: "id"})
+	defer coll.Close()
+
+	require.NoError(t, coll.Put(userDoc("u1", "Alice")))
+
+	coll.OnAfterPut(func(doc Document, existing *Document) error {
+		require.NotNil(t, existing)
+		return errVetoed
+	})
+
+	assert.ErrorIs(t, coll.Put(userDoc("u1", "Mallory")), errVetoed)
+
+	got, err := coll.Get("u1")
+	require.NoError(t, err)
+	assert.Equal(t, "Alice", got.Fields["name"].Value)
+}
+
+func TestCollection_DeleteHooks(t *testing.T) {
+	tests := []struct {
+		name      string
+		register  func(c *Collection, calls *[]string)
+		wantErr   error
+		wantKept  bool
+		wantCalls []string
+	}{
+		{
+			name: "hooks run in order",
+			register: func(c *Collection, calls *[]string) {
+				c.OnBeforeDelete(func(Document) error {
+					*calls = append(*calls, "before")
+					return nil
+				})
+				c.OnAfterDelete(func(doc Document) error {
+					*calls = append(*calls, "after:"+doc.Fields["id"].Value.(string))
+					return nil
+				})
+			},
+			wantCalls: []string{"before", "after:u1"},
+		},
+		{
+			name: "before hook vetoes the delete",
+			register: func(c *Collection, calls *[]string) {
+				c.OnBeforeDelete(func(Document) error {
+					*calls = append(*calls, "before")
+					return errVetoed
+				})
+				c.OnAfterDelete(func(Document) error {
+					*calls = append(*calls, "after")
+					return nil
+				})
+			},
+			wantErr:   errVetoed,
+			wantKept:  true,
+			wantCalls: []string{"before"},
+		},
+		{
+			name: "after hook error undoes the delete",
+			register: func(c *Collection, calls *[]string) {
+				c.OnAfterDelete(func(Document) error {
+					*calls = append(*calls, "after")
+					return errVetoed
+				})
+			},
+			wantErr:   errVetoed,
+			wantKept:  true,
+			wantCalls: []string{"after"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			coll := NewCollection(CollectionConfig{PrimaryKey: "id"})
+			defer coll.Close()
+			require.NoError(t, coll.Put(userDoc("u1", "Alice")))
+
+			var calls []string
+			tt.register(coll, &calls)
+
+			err := coll.Delete("u1")
+			assert.ErrorIs(t, err, tt.wantErr)
+			assert.Equal(t, tt.wantCalls, calls)
+
+			_, err = coll.Get("u1")
+			if tt.wantKept {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, ErrDocumentNotFound)
+			}
+		})
+	}
+}
+
+func TestCollection_HooksDoNotMutateCallerDocument(t *testing.T) {
+	coll := NewCollection(CollectionConfig{PrimaryKey: "id"})
+	defer coll.Close()
+
+	coll.OnBeforePut(func(doc *Document, _ *Document) error {
+		doc.Fields["updated_at"] = DocumentField{Type: DocumentFieldTypeNumber, Value: 1}
+		return nil
+	})
+
+	doc := userDoc("u1", "Alice")
+	require.NoError(t, coll.Put(doc))
+	assert.NotContains(t, doc.Fields, "updated_at")
+
+	got, err := coll.Get("u1")
+	require.NoError(t, err)
+	assert.Contains(t, got.Fields, "updated_at")
+}
+
+func TestCollection_UndoneWritesReplayFromChangeLog(t *testing.T) {
+	dir := t.TempDir()
+
+	store := NewStore()
+	coll, err := store.CreateCollection("users", &CollectionConfig{PrimaryKey: "id"})
+	require.NoError(t, err)
+	require.NoError(t, store.EnableRecovery(dir))
+	require.NoError(t, coll.Put(userDoc("u1", "Alice")))
+
+	coll.OnAfterPut(func(Document, *Document) error { return errVetoed })
+	coll.OnAfterDelete(func(Document) error { return errVetoed })
+	assert.ErrorIs(t, coll.Put(userDoc("u1", "Mallory")), errVetoed)
+	assert.ErrorIs(t, coll.Put(userDoc("u2", "Bob")), errVetoed)
+	assert.ErrorIs(t, coll.Delete("u1"), errVetoed)
+	require.NoError(t, store.Close())
+
+	restored, err := RestoreAt(dir, coll.now().Add(time.Hour))
+	require.NoError(t, err)
+	defer restored.Close()
+
+	restoredColl, err := restored.GetCollection("users")
+	require.NoError(t, err)
+	assert.Equal(t, []Document{userDoc("u1", "Alice")}, restoredColl.List())
+}
@@ -0,0 +1,395 @@
+package documentstore
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"math"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+)
+
+// DumpIssue is a problem VerifyDump found in a dump. Document is the
+// 1-based position of the document in its collection, or 0 when the issue
+// concerns the whole collection. Key is in its text form, see FormatKey.
+type DumpIssue struct {
+	Namespace  string `json:"namespace,omitempty"`
+	Collection string `json:"collection,omitempty"`
+	Document   int    `json:"document,omitempty"`
+	Key        string `json:"key,omitempty"`
+	Message    string `json:"message"`
+}
+
+func (i DumpIssue) String() string {
+	var b strings.Builder
+	if i.Namespace != "" {
+		b.WriteString(i.Namespace + "/")
+	}
+	b.WriteString(i.Collection)
+	if i.Document > 0 {
+		fmt.Fprintf(&b, " document %d", i.Document)
+	}
+	if i.Key != "" {
+		fmt.Fprintf(&b, " (key %q)", i.Key)
+	}
+	return b.String() + ": " + i.Message
+}
+
+type VerifyReport struct {
+	// Version is the format version the dump was written with.
+	Version     int         `json:"version"`
+	Collections int         `json:"collections"`
+	Documents   int         `json:"documents"`
+	Issues      []DumpIssue `json:"issues,omitempty"`
+}
+
+func (r *VerifyReport) OK() bool {
+	return len(r.Issues) == 0
+}
+
+// VerifyDump checks a dump without loading it into a store: collection
+// configs, the primary key and field types of every document, duplicate
+// keys, schemas and the recorded metadata. An error means the dump could
+// not be read at all; everything else is listed as an issue.
+func VerifyDump(data []byte) (*VerifyReport, error) {
+	version, dump, err := readDump(data)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &VerifyReport{Version: version}
+	report.verifyStore("", dump)
+	for _, name := range slices.Sorted(maps.Keys(dump.Namespaces)) {
+		ns := dump.Namespaces[name]
+		report.verifyStore(name, &ns)
+	}
+	return report, nil
+}
+
+// readDump decodes a dump and returns the version it was written with.
+func readDump(data []byte) (int, *StoreDump, error) {
+	var raw map[string]any
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return 0, nil, err
+	}
+	version, err := dumpVersion(raw)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	dump, err := decodeDump(data)
+	if err != nil {
+		return 0, nil, err
+	}
+	return version, dump, nil
+}
+
+func (r *VerifyReport) verifyStore(namespace string, dump *StoreDump) {
+	for _, name := range slices.Sorted(maps.Keys(dump.Collections)) {
+		coll := dump.Collections[name]
+		r.Collections++
+		r.Documents += len(coll.Documents)
+		r.verifyCollection(DumpIssue{Namespace: namespace, Collection: name}, &coll)
+	}
+}
+
+func (r *VerifyReport) verifyCollection(at DumpIssue, coll *CollectionDump) {
+	issue := func(doc int, key, format string, args ...any) {
+		i := at
+		i.Document, i.Message = doc, fmt.Sprintf(format, args...)
+		if key != "" {
+			i.Key = FormatKey(key)
+		}
+		r.Issues = append(r.Issues, i)
+	}
+
+	cfg := coll.Config
+	if err := validConfig(cfg); err != nil {
+		issue(0, "", "invalid config: %v", err)
+	}
+	var schema *compiledSchema
+	if cfg.Schema != nil {
+		schema, _ = compileSchema(*cfg.Schema)
+	}
+
+	seen := make(map[string]int, len(coll.Documents))
+	for i, doc := range coll.Documents {
+		pos := i + 1
+		if doc.Fields == nil {
+			issue(pos, "", "document has no fields")
+			continue
+		}
+
+		key, err := documentKey(cfg.keyFields(), doc)
+		if err != nil {
+			issue(pos, "", "%v", err)
+		} else if prev, ok := seen[key]; ok {
+			issue(pos, key, "duplicate key, also document %d", prev)
+		} else {
+			seen[key] = pos
+		}
+
+		for _, name := range slices.Sorted(maps.Keys(doc.Fields)) {
+			if err := checkField(doc.Fields[name]); err != nil {
+				issue(pos, key, "field %q: %v", name, err)
+			}
+		}
+
+		if schema != nil {
+			if err := schema.validate(doc); err != nil {
+				issue(pos, key, "%v", err)
+			}
+		}
+	}
+
+	if coll.Metadata.DocumentCount != len(coll.Documents) {
+		issue(0, "", "metadata counts %d documents, dump holds %d", coll.Metadata.DocumentCount, len(coll.Documents))
+	}
+	for _, key := range slices.Sorted(maps.Keys(coll.Expiries)) {
+		if _, ok := seen[key]; !ok {
+			issue(0, key, "expiry for a document that is not in the dump")
+		}
+	}
+}
+
+func checkField(f DocumentField) error {
+	var ok bool
+	switch f.Type {
+	case DocumentFieldTypeString:
+		_, ok = f.Value.(string)
+	case DocumentFieldTypeNumber:
+		var n float64
+		n, ok = numberValue(f.Value)
+		ok = ok && !math.IsInf(n, 0) && !math.IsNaN(n)
+	case DocumentFieldTypeBool:
+		_, ok = f.Value.(bool)
+	case DocumentFieldTypeArray:
+		var elems []any
+		if elems, ok = f.Value.([]any); ok {
+			for _, v := range elems {
+				elem, scalar := arrayElement(v)
+				if !scalar {
+					return fmt.Errorf("%w: array element %v", ErrUnsupportedDocumentField, v)
+				}
+				if err := checkField(elem); err != nil {
+					return err
+				}
+			}
+		}
+	default:
+		return fmt.Errorf("%w: type %q", ErrUnsupportedDocumentField, f.Type)
+	}
+
+	if !ok {
+		return fmt.Errorf("%w: value %v is not a %s", ErrUnsupportedDocumentField, f.Value, f.Type)
+	}
+	return nil
+}
+
+// DumpSummary describes the contents of a dump.
+type DumpSummary struct {
+	Version     int                     `json:"version"`
+	CreatedAt   time.Time               `json:"created_at"`
+	LastDumpAt  time.Time               `json:"last_dump_at,omitzero"`
+	Collections []CollectionSummary     `json:"collections"`
+	Namespaces  map[string]*DumpSummary `json:"namespaces,omitempty"`
+}
+
+type CollectionSummary struct {
+	Name       string     `json:"name"`
+	PrimaryKey []string   `json:"primary_key"`
+	Engine     EngineKind `json:"engine,omitempty"`
+	Documents  int        `json:"documents"`
+	// Bytes is the approximate encoded size of all documents.
+	Bytes    int64 `json:"bytes"`
+	Expiring int   `json:"expiring,omitempty"`
+	// Fields counts, per field name, the documents holding it by type.
+	Fields map[string]map[DocumentFieldType]int `json:"fields"`
+}
+
+// SummarizeDump reports what each collection of a dump holds without
+// loading it into a store.
+func SummarizeDump(data []byte) (*DumpSummary, error) {
+	version, dump, err := readDump(data)
+	if err != nil {
+		return nil, err
+	}
+
+	summary := summarize(dump)
+	summary.Version = version
+	return summary, nil
+}
+
+func summarize(dump *StoreDump) *DumpSummary {
+	summary := &DumpSummary{
+		Version:     dump.Version,
+		CreatedAt:   dump.Metadata.CreatedAt,
+		LastDumpAt:  dump.Metadata.LastDumpAt,
+		Collections: make([]CollectionSummary, 0, len(dump.Collections)),
+	}
+
+	for _, name := range slices.Sorted(maps.Keys(dump.Collections)) {
+		coll := dump.Collections[name]
+		cs := CollectionSummary{
+			Name:       name,
+			PrimaryKey: coll.Config.keyFields(),
+			Engine:     coll.Config.Engine,
+			Documents:  len(coll.Documents),
+			Expiring:   len(coll.Expiries),
+			Fields:     make(map[string]map[DocumentFieldType]int),
+		}
+		for _, doc := range coll.Documents {
+			cs.Bytes += documentSize(doc)
+			for fieldName, f := range doc.Fields {
+				if cs.Fields[fieldName] == nil {
+					cs.Fields[fieldName] = make(map[DocumentFieldType]int)
+				}
+				cs.Fields[fieldName][f.Type]++
+			}
+		}
+		summary.Collections = append(summary.Collections, cs)
+	}
+
+	for name, ns := range dump.Namespaces {
+		if summary.Namespaces == nil {
+			summary.Namespaces = make(map[string]*DumpSummary)
+		}
+		summary.Namespaces[name] = summarize(&ns)
+	}
+	return summary
+}
+
+// DumpDiff lists what changed between two dumps. Collections holds only
+// collections with differences; those added or removed as a whole list all
+// of their documents as added or removed.
+type DumpDiff struct {
+	AddedCollections   []string                   `json:"added_collections,omitempty"`
+	RemovedCollections []string                   `json:"removed_collections,omitempty"`
+	Collections        map[string]*CollectionDiff `json:"collections,omitempty"`
+	Namespaces         map[string]*DumpDiff       `json:"namespaces,omitempty"`
+}
+
+// CollectionDiff lists document keys in their text form, see FormatKey, in
+// key order.
+type CollectionDiff struct {
+	ConfigChanged bool     `json:"config_changed,omitempty"`
+	Added         []string `json:"added,omitempty"`
+	Removed       []string `json:"removed,omitempty"`
+	Changed       []string `json:"changed,omitempty"`
+}
+
+// Empty reports whether the dumps hold the same collections and documents.
+func (d *DumpDiff) Empty() bool {
+	return len(d.AddedCollections) == 0 && len(d.RemovedCollections) == 0 &&
+		len(d.Collections) == 0 && len(d.Namespaces) == 0
+}
+
+// DiffDumps compares two dumps document by document, matching documents
+// by primary key. Metadata such as dump times is ignored.
+func DiffDumps(oldData, newData []byte) (*DumpDiff, error) {
+	_, oldDump, err := readDump(oldData)
+	if err != nil {
+		return nil, fmt.Errorf("old dump: %w", err)
+	}
+	_, newDump, err := readDump(newData)
+	if err != nil {
+		return nil, fmt.Errorf("new dump: %w", err)
+	}
+	return diffStores(oldDump, newDump), nil
+}
+
+func diffStores(oldDump, newDump *StoreDump) *DumpDiff {
+	diff := &DumpDiff{}
+
+	names := slices.Sorted(maps.Keys(oldDump.Collections))
+	for name := range newDump.Collections {
+		if _, ok := oldDump.Collections[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+
+	for _, name := range names {
+		oldColl, inOld := oldDump.Collections[name]
+		newColl, inNew := newDump.Collections[name]
+		switch {
+		case !inOld:
+			diff.AddedCollections = append(diff.AddedCollections, name)
+		case !inNew:
+			diff.RemovedCollections = append(diff.RemovedCollections, name)
+		}
+
+		cd := diffCollections(oldColl, newColl, inOld && inNew)
+		if cd.ConfigChanged || len(cd.Added)+len(cd.Removed)+len(cd.Changed) > 0 {
+			if diff.Collections == nil {
+				diff.Collections = make(map[string]*CollectionDiff)
+			}
+			diff.Collections[name] = cd
+		}
+	}
+
+	nsNames := slices.Collect(maps.Keys(oldDump.Namespaces))
+	for name := range newDump.Namespaces {
+		if _, ok := oldDump.Namespaces[name]; !ok {
+			nsNames = append(nsNames, name)
+		}
+	}
+	for _, name := range nsNames {
+		oldNS, newNS := oldDump.Namespaces[name], newDump.Namespaces[name]
+		if nsDiff := diffStores(&oldNS, &newNS); !nsDiff.Empty() {
+			if diff.Namespaces == nil {
+				diff.Namespaces = make(map[string]*DumpDiff)
+			}
+			diff.Namespaces[name] = nsDiff
+		}
+	}
+
+	return diff
+}
+
+func diffCollections(oldColl, newColl CollectionDump, both bool) *CollectionDiff {
+	cd := &CollectionDiff{ConfigChanged: both && !reflect.DeepEqual(oldColl.Config, newColl.Config)}
+
+	oldDocs := documentsByKey(oldColl)
+	newDocs := documentsByKey(newColl)
+	for key, doc := range newDocs {
+		before, ok := oldDocs[key]
+		switch {
+		case !ok:
+			cd.Added = append(cd.Added, key)
+		case !reflect.DeepEqual(before.Fields, doc.Fields):
+			cd.Changed = append(cd.Changed, key)
+		}
+	}
+	for key := range oldDocs {
+		if _, ok := newDocs[key]; !ok {
+			cd.Removed = append(cd.Removed, key)
+		}
+	}
+
+	for _, keys := range [][]string{cd.Added, cd.Removed, cd.Changed} {
+		slices.Sort(keys)
+		for i, key := range keys {
+			if !strings.HasPrefix(key, "#") {
+				keys[i] = FormatKey(key)
+			}
+		}
+	}
+	return cd
+}
+
+// documentsByKey indexes the documents of a collection dump. Documents
+// without a valid key are indexed by their position, e.g. "#3".
+func documentsByKey(coll CollectionDump) map[string]Document {
+	docs := make(map[string]Document, len(coll.Documents))
+	for i, doc := range coll.Documents {
+		key, err := documentKey(coll.Config.keyFields(), doc)
+		if err != nil {
+			key = fmt.Sprintf("#%d", i+1)
+		}
+		docs[key] = doc
+	}
+	return docs
+}
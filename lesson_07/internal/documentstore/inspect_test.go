@@ -0,0 +1,193 @@
+package documentstore
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func inspectStore(t *testing.T, names ...string) *Store {
+	t.Helper()
+
+	store := NewStore()
+	t.Cleanup(func() { store.Close() })
+	coll, err := store.CreateCollection("users", &CollectionConfig{PrimaryKey: "id"})
+	require.NoError(t, err)
+	for _, name := range names {
+		require.NoError(t, coll.Put(Document{Fields: map[string]DocumentField{
+			"id":   {Type: DocumentFieldTypeString, Value: name},
+			"name": {Type: DocumentFieldTypeString, Value: name},
+		}}))
+	}
+	return store
+}
+
+func TestVerifyDump(t *testing.T) {
+	tests := []struct {
+		name       string
+		dump       string
+		wantIssues []DumpIssue
+		wantErr    error
+	}{
+		{
+			name: "clean dump",
+			dump: `{"version":4,"collections":{"users":{"config":{"PrimaryKey":"id"},"metadata":{"document_count":1},"documents":[` +
+				`{"Fields":{"id":{"Type":"string","Value":"1"},"age":{"Type":"number","Value":30}}}]}}}`,
+		},
+		{
+			name: "document problems",
+			dump: `{"version":4,"collections":{"users":{"config":{"PrimaryKey":"id"},"metadata":{"document_count":4},"documents":[` +
+				`{"Fields":{"id":{"Type":"string","Value":"1"},"age":{"Type":"number","Value":"thirty"}}},` +
+				`{"Fields":{"name":{"Type":"string","Value":"no key"}}},` +
+				`{"Fields":{"id":{"Type":"string","Value":"1"},"tags":{"Type":"list","Value":[]}}},` +
+				`{}]}}}`,
+			wantIssues: []DumpIssue{
+				{Collection: "users", Document: 1, Key: "1", Message: `field "age": unsupported document field: value thirty is not a number`},
+				{Collection: "users", Document: 2, Message: `invalid primary key: missing field "id"`},
+				{Collection: "users", Document: 3, Key: "1", Message: "duplicate key, also document 1"},
+				{Collection: "users", Document: 3, Key: "1", Message: `field "tags": unsupported document field: type "list"`},
+				{Collection: "users", Document: 4, Message: "document has no fields"},
+			},
+		},
+		{
+			name: "collection problems in a namespace",
+			dump: `{"version":4,"collections":{},"namespaces":{"acme":{"version":4,"collections":{"users":{` +
+				`"config":{"PrimaryKey":"id","KeyGen":"random"},"metadata":{"document_count":2},"documents":[],` +
+				`"expiries":{"9":"2030-01-01T00:00:00Z"}}}}}}`,
+			wantIssues: []DumpIssue{
+				{Namespace: "acme", Collection: "users", Message: `invalid config: invalid key generator: "random"`},
+				{Namespace: "acme", Collection: "users", Message: "metadata counts 2 documents, dump holds 0"},
+				{Namespace: "acme", Collection: "users", Key: "9", Message: "expiry for a document that is not in the dump"},
+			},
+		},
+		{
+			name: "schema violations",
+			dump: `{"version":4,"collections":{"users":{"config":{"PrimaryKey":"id","Schema":{"Fields":{"id":{"Type":"string"},"name":{"Type":"string","Required":true}}}},` +
+				`"metadata":{"document_count":1},"documents":[{"Fields":{"id":{"Type":"string","Value":"1"}}}]}}}`,
+			wantIssues: []DumpIssue{
+				{Collection: "users", Document: 1, Key: "1", Message: "document validation failed: name: is required"},
+			},
+		},
+		{
+			name:    "unreadable dump",
+			dump:    `{"version":99}`,
+			wantErr: ErrUnsupportedDumpVersion,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report, err := VerifyDump([]byte(tt.dump))
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantIssues, report.Issues)
+			assert.Equal(t, len(tt.wantIssues) == 0, report.OK())
+		})
+	}
+}
+
+func TestVerifyDump_StoreDump(t *testing.T) {
+	store := inspectStore(t, "alice", "bob")
+	ns, err := store.Namespace("acme")
+	require.NoError(t, err)
+	_, err = ns.CreateCollection("orders", &CollectionConfig{PrimaryKey: "id"})
+	require.NoError(t, err)
+
+	data, err := store.Dump()
+	require.NoError(t, err)
+
+	report, err := VerifyDump(data)
+	require.NoError(t, err)
+	assert.Equal(t, &VerifyReport{Version: DumpFormatVersion, Collections: 2, Documents: 2}, report)
+}
+
+func TestSummarizeDump(t *testing.T) {
+	store := inspectStore(t, "alice", "bob")
+	users, err := store.GetCollection("users")
+	require.NoError(t, err)
+	require.NoError(t, users.Put(Document{Fields: map[string]DocumentField{
+		"id":   {Type: DocumentFieldTypeString, Value: "carol"},
+		"name": {Type: DocumentFieldTypeNumber, Value: 7},
+	}}))
+
+	data, err := store.Dump()
+	require.NoError(t, err)
+
+	summary, err := SummarizeDump(data)
+	require.NoError(t, err)
+	require.Len(t, summary.Collections, 1)
+
+	cs := summary.Collections[0]
+	assert.Equal(t, "users", cs.Name)
+	assert.Equal(t, []string{"id"}, cs.PrimaryKey)
+	assert.Equal(t, 3, cs.Documents)
+	assert.Equal(t, users.QuotaStats().Usage.Bytes, cs.Bytes)
+	assert.Equal(t, map[string]map[DocumentFieldType]int{
+		"id":   {DocumentFieldTypeString: 3},
+		"name": {DocumentFieldTypeString: 2, DocumentFieldTypeNumber: 1},
+	}, cs.Fields)
+}
+
+func TestDiffDumps(t *testing.T) {
+	store := inspectStore(t, "alice", "bob", "carol")
+	before, err := store.Dump()
+	require.NoError(t, err)
+
+	users, err := store.GetCollection("users")
+	require.NoError(t, err)
+	require.NoError(t, users.Delete("bob"))
+	require.NoError(t, users.Put(Document{Fields: map[string]DocumentField{
+		"id":   {Type: DocumentFieldTypeString, Value: "carol"},
+		"name": {Type: DocumentFieldTypeString, Value: "Carol"},
+	}}))
+	require.NoError(t, users.Put(Document{Fields: map[string]DocumentField{
+		"id": {Type: DocumentFieldTypeString, Value: "dave"},
+	}}))
+	_, err = store.CreateCollection("orders", &CollectionConfig{PrimaryKey: "id"})
+	require.NoError(t, err)
+	ns, err := store.Namespace("acme")
+	require.NoError(t, err)
+	tenantUsers, err := ns.CreateCollection("users", &CollectionConfig{PrimaryKey: "id"})
+	require.NoError(t, err)
+	require.NoError(t, tenantUsers.Put(Document{Fields: map[string]DocumentField{
+		"id": {Type: DocumentFieldTypeString, Value: "erin"},
+	}}))
+
+	after, err := store.Dump()
+	require.NoError(t, err)
+
+	diff, err := DiffDumps(before, after)
+	require.NoError(t, err)
+	assert.Equal(t, &DumpDiff{
+		AddedCollections: []string{"orders"},
+		Collections: map[string]*CollectionDiff{
+			"users": {Added: []string{"dave"}, Removed: []string{"bob"}, Changed: []string{"carol"}},
+		},
+		Namespaces: map[string]*DumpDiff{
+			"acme": {
+				AddedCollections: []string{"users"},
+				Collections:      map[string]*CollectionDiff{"users": {Added: []string{"erin"}}},
+			},
+		},
+	}, diff)
+
+	lines, err := store.CreateCollection("lines", &CollectionConfig{PrimaryKeys: []string{"order_id", "line_no"}})
+	require.NoError(t, err)
+	require.NoError(t, lines.Put(orderLine("o1", 2)))
+	withLines, err := store.Dump()
+	require.NoError(t, err)
+	diff, err = DiffDumps(after, withLines)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"o1/2"}, diff.Collections["lines"].Added)
+
+	same, err := DiffDumps(after, after)
+	require.NoError(t, err)
+	assert.True(t, same.Empty())
+
+	_, err = DiffDumps(before, []byte("not json"))
+	assert.Error(t, err)
+}
@@ -0,0 +1,216 @@
+package documentstore
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sort"
+)
+
+const jsonSchemaDialect = "https://json-schema.org/draft/2020-12/schema"
+
+// jsonSchema is the subset of JSON Schema 2020-12 that maps onto Schema.
+// Other keywords are ignored on import.
+type jsonSchema struct {
+	Schema               string                 `json:"$schema,omitempty"`
+	Type                 jsonSchemaType         `json:"type,omitempty"`
+	Properties           map[string]*jsonSchema `json:"properties,omitempty"`
+	Required             []string               `json:"required,omitempty"`
+	AdditionalProperties *bool                  `json:"additionalProperties,omitempty"`
+	Items                *jsonSchema            `json:"items,omitempty"`
+	Enum                 []any                  `json:"enum,omitempty"`
+	Minimum              *float64               `json:"minimum,omitempty"`
+	Maximum              *float64               `json:"maximum,omitempty"`
+	MinLength            *int                   `json:"minLength,omitempty"`
+	MaxLength            *int                   `json:"maxLength,omitempty"`
+	Pattern              string                 `json:"pattern,omitempty"`
+}
+
+// jsonSchemaType holds "type", which may be a single name or a list.
+type jsonSchemaType []string
+
+func (t *jsonSchemaType) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*t = jsonSchemaType{single}
+		return nil
+	}
+
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return fmt.Errorf("type must be a string or a list of strings: %w", err)
+	}
+	*t = list
+	return nil
+}
+
+func (t jsonSchemaType) MarshalJSON() ([]byte, error) {
+	if len(t) == 1 {
+		return json.Marshal(t[0])
+	}
+	return json.Marshal([]string(t))
+}
+
+// SchemaFromJSONSchema converts a JSON Schema describing a document into
+// collection rules. Supported keywords are type, properties, required,
+// additionalProperties, enum, minimum, maximum, minLength, maxLength,
+// pattern and items. Array elements must be strings, numbers or booleans,
+// so nested arrays and object properties are rejected.
+func SchemaFromJSONSchema(data []byte) (*Schema, error) {
+	var root jsonSchema
+	if err := json.Unmarshal(data, &root); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidSchema, err)
+	}
+
+	if len(root.Type) > 0 && !slices.Equal(root.Type, jsonSchemaType{"object"}) {
+		return nil, fmt.Errorf("%w: root type must be object, got %v", ErrInvalidSchema, []string(root.Type))
+	}
+
+	schema := &Schema{
+		Fields:             make(map[string]FieldRule),
+		AllowUnknownFields: root.AdditionalProperties == nil || *root.AdditionalProperties,
+	}
+
+	for name, prop := range root.Properties {
+		rule, err := fieldRuleFromJSONSchema(prop)
+		if err != nil {
+			return nil, fmt.Errorf("%w: property %q: %v", ErrInvalidSchema, name, err)
+		}
+		schema.Fields[name] = rule
+	}
+
+	for _, name := range root.Required {
+		rule := schema.Fields[name]
+		rule.Required = true
+		schema.Fields[name] = rule
+	}
+
+	if _, err := compileSchema(*schema); err != nil {
+		return nil, err
+	}
+	return schema, nil
+}
+
+func fieldRuleFromJSONSchema(prop *jsonSchema) (FieldRule, error) {
+	if prop == nil {
+		return FieldRule{}, nil
+	}
+
+	rule := FieldRule{
+		Enum:      prop.Enum,
+		Min:       prop.Minimum,
+		Max:       prop.Maximum,
+		MinLength: prop.MinLength,
+		MaxLength: prop.MaxLength,
+		Pattern:   prop.Pattern,
+	}
+
+	for _, typ := range prop.Type {
+		var fieldType DocumentFieldType
+		switch typ {
+		case "string":
+			fieldType = DocumentFieldTypeString
+		case "number", "integer":
+			fieldType = DocumentFieldTypeNumber
+		case "boolean":
+			fieldType = DocumentFieldTypeBool
+		case "array":
+			fieldType = DocumentFieldTypeArray
+		default:
+			return FieldRule{}, fmt.Errorf("type %q is not supported", typ)
+		}
+		if !slices.Contains(rule.Types, fieldType) {
+			rule.Types = append(rule.Types, fieldType)
+		}
+	}
+	rule.Integer = slices.Contains(prop.Type, "integer") && !slices.Contains(prop.Type, "number")
+
+	if prop.Items != nil {
+		if prop.Items.Items != nil || slices.Contains(prop.Items.Type, "array") {
+			return FieldRule{}, fmt.Errorf("items: nested arrays are not supported")
+		}
+		items, err := fieldRuleFromJSONSchema(prop.Items)
+		if err != nil {
+			return FieldRule{}, fmt.Errorf("items: %v", err)
+		}
+		rule.Items = &items
+	}
+
+	return rule, nil
+}
+
+// JSONSchema exports the schema as a JSON Schema 2020-12 document.
+func (s *Schema) JSONSchema() ([]byte, error) {
+	root := jsonSchema{
+		Schema:     jsonSchemaDialect,
+		Type:       jsonSchemaType{"object"},
+		Properties: make(map[string]*jsonSchema),
+	}
+	if !s.AllowUnknownFields {
+		root.AdditionalProperties = new(bool)
+	}
+
+	for name, rule := range s.Fields {
+		prop := jsonSchemaFromFieldRule(rule)
+		if rule.Items != nil {
+			prop.Items = jsonSchemaFromFieldRule(*rule.Items)
+		}
+
+		root.Properties[name] = prop
+		if rule.Required {
+			root.Required = append(root.Required, name)
+		}
+	}
+	sort.Strings(root.Required)
+
+	return json.MarshalIndent(root, "", "  ")
+}
+
+func jsonSchemaFromFieldRule(rule FieldRule) *jsonSchema {
+	prop := &jsonSchema{
+		Enum:      rule.Enum,
+		Minimum:   rule.Min,
+		Maximum:   rule.Max,
+		MinLength: rule.MinLength,
+		MaxLength: rule.MaxLength,
+		Pattern:   rule.Pattern,
+	}
+	for _, typ := range rule.Types {
+		switch {
+		case typ == DocumentFieldTypeNumber && rule.Integer:
+			prop.Type = append(prop.Type, "integer")
+		case typ == DocumentFieldTypeBool:
+			prop.Type = append(prop.Type, "boolean")
+		default:
+			prop.Type = append(prop.Type, string(typ))
+		}
+	}
+	return prop
+}
+
+// JSONSchema exports the rules Put enforces on this collection, including
+// the required primary key fields, as a JSON Schema document.
+func (c *Collection) JSONSchema() ([]byte, error) {
+	schema := Schema{AllowUnknownFields: true, Fields: make(map[string]FieldRule)}
+	if c.cfg.Schema != nil {
+		schema.AllowUnknownFields = c.cfg.Schema.AllowUnknownFields
+		for name, rule := range c.cfg.Schema.Fields {
+			schema.Fields[name] = rule
+		}
+	}
+
+	for _, name := range c.cfg.keyFields() {
+		pk := schema.Fields[name]
+		pk.Required = true
+		if len(pk.Types) == 0 {
+			pk.Types = []DocumentFieldType{DocumentFieldTypeString, DocumentFieldTypeNumber}
+		}
+		if pk.MinLength == nil || *pk.MinLength < 1 {
+			one := 1
+			pk.MinLength = &one
+		}
+		schema.Fields[name] = pk
+	}
+
+	return schema.JSONSchema()
+}
@@ -0,0 +1,191 @@
+package documentstore
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const userJSONSchema = `{
+  "$schema": "https://json-schema.org/draft/2020-12/schema",
+  "title": "User",
+  "type": "object",
+  "properties": {
+    "id": {"type": ["string", "number"], "minLength": 1},
+    "email": {"type": "string", "pattern": "^[^@]+@[^@]+$", "maxLength": 64},
+    "age": {"type": "integer", "minimum": 0, "maximum": 150},
+    "role": {"type": "string", "enum": ["admin", "member"]},
+    "active": {"type": "boolean"},
+    "score": {"type": ["number", "string"]},
+    "tags": {"type": "array", "items": {"type": "string", "maxLength": 8}}
+  },
+  "required": ["id", "email"],
+  "additionalProperties": false
+}`
+
+func TestSchemaFromJSONSchema(t *testing.T) {
+	schema, err := SchemaFromJSONSchema([]byte(userJSONSchema))
+	require.NoError(t, err)
+
+	assert.False(t, schema.AllowUnknownFields)
+	assert.Equal(t, FieldRule{Types: []DocumentFieldType{DocumentFieldTypeNumber}, Integer: true, Min: ptr(0.0), Max: ptr(150.0)}, schema.Fields["age"])
+	assert.Equal(t, FieldRule{Types: []DocumentFieldType{DocumentFieldTypeString}, Enum: []any{"admin", "member"}}, schema.Fields["role"])
+	assert.Equal(t, []DocumentFieldType{DocumentFieldTypeNumber, DocumentFieldTypeString}, schema.Fields["score"].Types)
+	assert.True(t, schema.Fields["email"].Required)
+	assert.Equal(t, FieldRule{
+		Types: []DocumentFieldType{DocumentFieldTypeArray},
+		Items: &FieldRule{Types: []DocumentFieldType{DocumentFieldTypeString}, MaxLength: ptr(8)},
+	}, schema.Fields["tags"])
+
+	coll, err := OpenCollection(CollectionConfig{PrimaryKey: "id", Schema: schema})
+	require.NoError(t, err)
+	defer coll.Close()
+
+	str := func(s string) DocumentField { return DocumentField{Type: DocumentFieldTypeString, Value: s} }
+	num := func(n float64) DocumentField { return DocumentField{Type: DocumentFieldTypeNumber, Value: n} }
+	arr := func(v ...any) DocumentField { return DocumentField{Type: DocumentFieldTypeArray, Value: v} }
+
+	tests := []struct {
+		name      string
+		fields    map[string]DocumentField
+		wantRules []string
+	}{
+		{
+			name:   "valid",
+			fields: map[string]DocumentField{"email": str("a@b.c"), "age": num(30), "role": str("admin"), "score": str("high"), "tags": arr("go", "db")},
+		},
+		{
+			name:      "missing required",
+			fields:    map[string]DocumentField{},
+			wantRules: []string{"required"},
+		},
+		{
+			name:      "not an integer",
+			fields:    map[string]DocumentField{"email": str("a@b.c"), "age": num(30.5)},
+			wantRules: []string{"integer"},
+		},
+		{
+			name:      "not in enum",
+			fields:    map[string]DocumentField{"email": str("a@b.c"), "role": str("owner")},
+			wantRules: []string{"enum"},
+		},
+		{
+			name:      "not an array",
+			fields:    map[string]DocumentField{"email": str("a@b.c"), "tags": str("go")},
+			wantRules: []string{"type"},
+		},
+		{
+			name:      "bad array items",
+			fields:    map[string]DocumentField{"email": str("a@b.c"), "tags": arr("go", int64(1), "databases")},
+			wantRules: []string{"type", "max_length"},
+		},
+		{
+			name:      "additional property",
+			fields:    map[string]DocumentField{"email": str("a@b.c"), "nickname": str("al")},
+			wantRules: []string{"unknown"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.fields["id"] = str("u1")
+			err := coll.Validate(Document{Fields: tt.fields})
+			if tt.wantRules == nil {
+				assert.NoError(t, err)
+				return
+			}
+
+			var verr *ValidationError
+			require.ErrorAs(t, err, &verr)
+			var rules []string
+			for _, v := range verr.Violations {
+				rules = append(rules, v.Rule)
+			}
+			assert.Equal(t, tt.wantRules, rules)
+		})
+	}
+}
+
+func TestSchemaFromJSONSchema_Errors(t *testing.T) {
+	tests := []struct {
+		name   string
+		schema string
+	}{
+		{name: "malformed", schema: `{"type":`},
+		{name: "root not an object", schema: `{"type": "string"}`},
+		{name: "nested array", schema: `{"properties": {"tags": {"type": "array", "items": {"type": "array"}}}}`},
+		{name: "bad items", schema: `{"properties": {"tags": {"type": "array", "items": {"type": "object"}}}}`},
+		{name: "nested object", schema: `{"properties": {"address": {"type": "object"}}}`},
+		{name: "bad pattern", schema: `{"properties": {"code": {"type": "string", "pattern": "("}}}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := SchemaFromJSONSchema([]byte(tt.schema))
+			assert.ErrorIs(t, err, ErrInvalidSchema)
+		})
+	}
+}
+
+func TestSchema_JSONSchemaRoundTrip(t *testing.T) {
+	schema, err := SchemaFromJSONSchema([]byte(userJSONSchema))
+	require.NoError(t, err)
+
+	exported, err := schema.JSONSchema()
+	require.NoError(t, err)
+
+	reimported, err := SchemaFromJSONSchema(exported)
+	require.NoError(t, err)
+	assert.Equal(t, schema, reimported)
+}
+
+func TestCollection_JSONSchema(t *testing.T) {
+	tests := []struct {
+		name   string
+		schema *Schema
+		want   string
+	}{
+		{
+			name: "no schema",
+			want: `{
+				"$schema": "https://json-schema.org/draft/2020-12/schema",
+				"type": "object",
+				"properties": {"id": {"type": ["string", "number"], "minLength": 1}},
+				"required": ["id"]
+			}`,
+		},
+		{
+			name: "schema merged with primary key",
+			schema: &Schema{Fields: map[string]FieldRule{
+				"name": {Required: true, Types: []DocumentFieldType{DocumentFieldTypeString}, MaxLength: ptr(10)},
+				"age":  {Types: []DocumentFieldType{DocumentFieldTypeNumber}, Integer: true, Min: ptr(0.0)},
+			}},
+			want: `{
+				"$schema": "https://json-schema.org/draft/2020-12/schema",
+				"type": "object",
+				"properties": {
+					"id": {"type": ["string", "number"], "minLength": 1},
+					"name": {"type": "string", "maxLength": 10},
+					"age": {"type": "integer", "minimum": 0}
+				},
+				"required": ["id", "name"],
+				"additionalProperties": false
+			}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			coll, err := OpenCollection(CollectionConfig{PrimaryKey: "id", Schema: tt.schema})
+			require.NoError(t, err)
+			defer coll.Close()
+
+			got, err := coll.JSONSchema()
+			require.NoError(t, err)
+			assert.JSONEq(t, tt.want, string(got))
+			assert.True(t, json.Valid(got))
+		})
+	}
+}
@@ -0,0 +1,333 @@
+package documentstore
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Encoded keys start with a NUL, which a single string key may not, so
+// they never collide with one and sort before it. Each component then
+// starts with a tag so that numbers sort before strings. Numbers are 16 hex
+// digits of an order-preserving float64 image; strings escape NUL and end
+// with a NUL pair, so a prefix sorts first.
+const (
+	keyEncodedPrefix = '\x00'
+
+	keyTagNumber = 'n'
+	keyTagString = 's'
+
+	keyStringEscape     = "\x00\x01"
+	keyStringTerminator = "\x00\x00"
+
+	// maxExactInt is the largest integer float64 holds exactly, 2^53.
+	maxExactInt = 1 << 53
+)
+
+// keyFields returns the fields that form the primary key, in order.
+func (cfg CollectionConfig) keyFields() []string {
+	if len(cfg.PrimaryKeys) > 0 {
+		return cfg.PrimaryKeys
+	}
+	return []string{cfg.PrimaryKey}
+}
+
+// EncodeKey builds the canonical key of a document whose primary key
+// fields hold values, in PrimaryKeys order. A single string value is its
+// own key, as it always was, and may not start with NUL; any other
+// combination of strings and numbers is encoded so that byte order matches
+// value order, field by field. Numbers are compared as float64; integers
+// beyond ±2^53, which float64 cannot hold exactly, are rejected.
+func EncodeKey(values ...any) (string, error) {
+	if len(values) == 0 {
+		return "", fmt.Errorf("%w: no key values", ErrInvalidPrimaryKey)
+	}
+	if len(values) == 1 {
+		if s, ok := values[0].(string); ok {
+			if s == "" {
+				return "", fmt.Errorf("%w: empty string", ErrInvalidPrimaryKey)
+			}
+			if s[0] == keyEncodedPrefix {
+				return "", fmt.Errorf("%w: string starts with NUL", ErrInvalidPrimaryKey)
+			}
+			return s, nil
+		}
+	}
+
+	var b strings.Builder
+	b.WriteByte(keyEncodedPrefix)
+	for _, v := range values {
+		if err := appendKeyComponent(&b, v); err != nil {
+			return "", err
+		}
+	}
+	return b.String(), nil
+}
+
+func appendKeyComponent(b *strings.Builder, v any) error {
+	if s, ok := v.(string); ok {
+		if s == "" {
+			return fmt.Errorf("%w: empty string", ErrInvalidPrimaryKey)
+		}
+		b.WriteByte(keyTagString)
+		b.WriteString(strings.ReplaceAll(s, "\x00", keyStringEscape))
+		b.WriteString(keyStringTerminator)
+		return nil
+	}
+
+	n, ok := numberValue(v)
+	if !ok {
+		return fmt.Errorf("%w: unsupported key value %T", ErrInvalidPrimaryKey, v)
+	}
+	if math.IsNaN(n) {
+		return fmt.Errorf("%w: NaN", ErrInvalidPrimaryKey)
+	}
+	if !exactKeyNumber(v) {
+		return fmt.Errorf("%w: integer %v is beyond ±2^53", ErrInvalidPrimaryKey, v)
+	}
+	if n == 0 {
+		n = 0 // -0 and 0 are the same key
+	}
+
+	bits := math.Float64bits(n)
+	if bits&(1<<63) != 0 {
+		bits = ^bits
+	} else {
+		bits |= 1 << 63
+	}
+
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], bits)
+	b.WriteByte(keyTagNumber)
+	b.WriteString(hex.EncodeToString(buf[:]))
+	return nil
+}
+
+// exactKeyNumber reports whether v converts to float64 without rounding.
+func exactKeyNumber(v any) bool {
+	var i int64
+	switch n := v.(type) {
+	case int:
+		i = int64(n)
+	case int64:
+		i = n
+	default:
+		return true
+	}
+	return i >= -maxExactInt && i <= maxExactInt
+}
+
+// DecodeKey returns the primary key values of a key: strings, and numbers
+// as int64 when they are whole and float64 otherwise.
+func DecodeKey(key string) ([]any, error) {
+	if key == "" {
+		return nil, fmt.Errorf("%w: empty key", ErrInvalidPrimaryKey)
+	}
+	if key[0] != keyEncodedPrefix {
+		return []any{key}, nil
+	}
+
+	var values []any
+	for rest := key[1:]; rest != ""; {
+		switch rest[0] {
+		case keyTagString:
+			end := strings.Index(rest, keyStringTerminator)
+			if end < 0 {
+				return nil, fmt.Errorf("%w: unterminated string in %q", ErrInvalidPrimaryKey, key)
+			}
+			values = append(values, strings.ReplaceAll(rest[1:end], keyStringEscape, "\x00"))
+			rest = rest[end+len(keyStringTerminator):]
+		case keyTagNumber:
+			if len(rest) < 17 {
+				return nil, fmt.Errorf("%w: short number in %q", ErrInvalidPrimaryKey, key)
+			}
+			buf, err := hex.DecodeString(rest[1:17])
+			if err != nil {
+				return nil, fmt.Errorf("%w: bad number in %q", ErrInvalidPrimaryKey, key)
+			}
+			bits := binary.BigEndian.Uint64(buf)
+			if bits&(1<<63) != 0 {
+				bits &^= 1 << 63
+			} else {
+				bits = ^bits
+			}
+			n := math.Float64frombits(bits)
+			if n == math.Trunc(n) && math.Abs(n) <= maxExactInt {
+				values = append(values, int64(n))
+			} else {
+				values = append(values, n)
+			}
+			rest = rest[17:]
+		default:
+			return nil, fmt.Errorf("%w: unknown tag in %q", ErrInvalidPrimaryKey, key)
+		}
+	}
+	if len(values) == 0 {
+		return nil, fmt.Errorf("%w: empty key", ErrInvalidPrimaryKey)
+	}
+	return values, nil
+}
+
+// FormatKey returns the text form of a key: its values joined by "/", the
+// form ParseKey reads back. Keys that do not decode are returned quoted.
+func FormatKey(key string) string {
+	values, err := DecodeKey(key)
+	if err != nil {
+		return strconv.Quote(key)
+	}
+
+	parts := make([]string, len(values))
+	for i, v := range values {
+		switch v := v.(type) {
+		case int64:
+			parts[i] = strconv.FormatInt(v, 10)
+		case float64:
+			parts[i] = strconv.FormatFloat(v, 'f', -1, 64)
+		default:
+			parts[i] = v.(string)
+		}
+	}
+	return strings.Join(parts, "/")
+}
+
+// ParseKey resolves the text form of a primary key, one part per key
+// field, to the key it is stored under. A part is read as a string unless
+// the schema only allows numbers for its field; when the schema allows
+// both, a part that also reads as a number is taken as one only if no
+// document is stored under the string reading.
+func (c *Collection) ParseKey(parts ...string) (string, error) {
+	fields := c.cfg.keyFields()
+	if len(parts) != len(fields) {
+		return "", fmt.Errorf("%w: got %d values for key fields %v", ErrInvalidPrimaryKey, len(parts), fields)
+	}
+
+	readings := make([][]any, len(parts))
+	for i, part := range parts {
+		var err error
+		if readings[i], err = c.keyReadings(fields[i], part); err != nil {
+			return "", err
+		}
+	}
+
+	var first string
+	for _, values := range keyCombinations(readings) {
+		key, err := EncodeKey(values...)
+		if err != nil {
+			continue
+		}
+		if first == "" {
+			first = key
+		}
+		if _, ok, err := c.lookup(key); err != nil {
+			return "", err
+		} else if ok {
+			return key, nil
+		}
+	}
+	if first == "" {
+		return "", fmt.Errorf("%w: %q", ErrInvalidPrimaryKey, strings.Join(parts, "/"))
+	}
+	return first, nil
+}
+
+// keyReadings lists the values part may stand for in the key field name,
+// most likely first.
+func (c *Collection) keyReadings(name, part string) ([]any, error) {
+	var types []DocumentFieldType
+	if c.schema != nil {
+		types = c.schema.Fields[name].Types
+	}
+	allowString := len(types) == 0 || slices.Contains(types, DocumentFieldTypeString)
+	allowNumber := len(types) == 0 || slices.Contains(types, DocumentFieldTypeNumber)
+
+	var readings []any
+	if allowString {
+		readings = append(readings, part)
+	}
+	if allowNumber {
+		if i, err := strconv.ParseInt(part, 10, 64); err == nil {
+			readings = append(readings, i)
+		} else if f, err := strconv.ParseFloat(part, 64); err == nil {
+			readings = append(readings, f)
+		}
+	}
+	if len(readings) == 0 {
+		return nil, fmt.Errorf("%w: field %q: %q is not a number", ErrInvalidPrimaryKey, name, part)
+	}
+	return readings, nil
+}
+
+// keyCombinations lists every choice of one reading per part, varying the
+// last part fastest.
+func keyCombinations(readings [][]any) [][]any {
+	combos := [][]any{{}}
+	for _, options := range readings {
+		next := make([][]any, 0, len(combos)*len(options))
+		for _, combo := range combos {
+			for _, v := range options {
+				next = append(next, append(slices.Clone(combo), v))
+			}
+		}
+		combos = next
+	}
+	return combos
+}
+
+// documentKey extracts and encodes the primary key of doc.
+func documentKey(fields []string, doc Document) (string, error) {
+	values := make([]any, 0, len(fields))
+	for _, name := range fields {
+		field, ok := doc.Fields[name]
+		if !ok {
+			return "", fmt.Errorf("%w: missing field %q", ErrInvalidPrimaryKey, name)
+		}
+		switch field.Type {
+		case DocumentFieldTypeString, DocumentFieldTypeNumber:
+		default:
+			return "", fmt.Errorf("%w: field %q has type %s", ErrInvalidPrimaryKey, name, field.Type)
+		}
+		if field.Type == DocumentFieldTypeString {
+			if _, ok := field.Value.(string); !ok {
+				return "", fmt.Errorf("%w: field %q is not a string", ErrInvalidPrimaryKey, name)
+			}
+		}
+		values = append(values, field.Value)
+	}
+
+	return EncodeKey(values...)
+}
+
+// Key returns the key under which a document with the given primary key
+// values is stored, for use with Get and Delete.
+func (c *Collection) Key(values ...any) (string, error) {
+	fields := c.cfg.keyFields()
+	if len(values) != len(fields) {
+		return "", fmt.Errorf("%w: got %d values for key fields %v", ErrInvalidPrimaryKey, len(values), fields)
+	}
+	return EncodeKey(values...)
+}
+
+// DocumentKey returns the key under which doc is stored.
+func (c *Collection) DocumentKey(doc Document) (string, error) {
+	return documentKey(c.cfg.keyFields(), doc)
+}
+
+func validKeyFields(cfg CollectionConfig) error {
+	fields := cfg.keyFields()
+	if cfg.PrimaryKey != "" && len(cfg.PrimaryKeys) > 0 && cfg.PrimaryKey != cfg.PrimaryKeys[0] {
+		return fmt.Errorf("%w: PrimaryKey %q conflicts with PrimaryKeys %v", ErrInvalidPrimaryKey, cfg.PrimaryKey, cfg.PrimaryKeys)
+	}
+	for i, name := range fields {
+		if name == "" || slices.Contains(fields[:i], name) {
+			return fmt.Errorf("%w: key fields %v", ErrInvalidPrimaryKey, fields)
+		}
+	}
+	if cfg.KeyGen != "" && len(fields) > 1 {
+		return fmt.Errorf("%w: cannot generate composite keys", ErrInvalidKeyGenerator)
+	}
+	return nil
+}
@@ -0,0 +1,273 @@
+package documentstore
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func orderLine(orderID string, lineNo any) Document {
+	return Document{Fields: map[string]DocumentField{
+		"order_id": {Type: DocumentFieldTypeString, Value: orderID},
+		"line_no":  {Type: DocumentFieldTypeNumber, Value: lineNo},
+	}}
+}
+
+func TestEncodeKey_PreservesOrder(t *testing.T) {
+	tests := []struct {
+		name   string
+		values [][]any
+	}{
+		{
+			name:   "numbers",
+			values: [][]any{{math.Inf(-1)}, {-1e9}, {-2.5}, {-1}, {0}, {0.5}, {1}, {2}, {10}, {1e9}, {math.Inf(1)}},
+		},
+		{
+			name:   "string prefixes",
+			values: [][]any{{"a", 1}, {"a\x00", 1}, {"ab", 1}, {"b", 1}},
+		},
+		{
+			name:   "composite",
+			values: [][]any{{"o1", 2}, {"o1", 10}, {"o2", -1}, {"o2", 1}},
+		},
+		{
+			name:   "numbers before strings",
+			values: [][]any{{1e9, "a"}, {"0", 1}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var keys []string
+			for _, values := range tt.values {
+				key, err := EncodeKey(values...)
+				require.NoError(t, err)
+				keys = append(keys, key)
+			}
+			assert.IsIncreasing(t, keys)
+		})
+	}
+}
+
+func TestEncodeKey(t *testing.T) {
+	key, err := EncodeKey("alice")
+	require.NoError(t, err)
+	assert.Equal(t, "alice", key, "single string keys are stored as is")
+
+	str, err := EncodeKey("1")
+	require.NoError(t, err)
+	num, err := EncodeKey(1)
+	require.NoError(t, err)
+	assert.NotEqual(t, str, num, "a string never shares a key with a number")
+	composite, err := EncodeKey("a", "b")
+	require.NoError(t, err)
+	assert.Less(t, num, str, "encoded keys sort before single string keys")
+	assert.Less(t, composite, str)
+
+	exact, err := EncodeKey(1 << 53)
+	require.NoError(t, err)
+	assert.Equal(t, testKey(float64(1<<53)), exact)
+
+	intKey, err := EncodeKey(7)
+	require.NoError(t, err)
+	floatKey, err := EncodeKey(7.0)
+	require.NoError(t, err)
+	assert.Equal(t, intKey, floatKey)
+
+	zero, err := EncodeKey(0.0)
+	require.NoError(t, err)
+	negZero, err := EncodeKey(math.Copysign(0, -1))
+	require.NoError(t, err)
+	assert.Equal(t, zero, negZero)
+
+	for _, values := range [][]any{{}, {""}, {"a", ""}, {true}, {math.NaN()}, {"a", []string{"b"}}, {1<<53 + 1}, {int64(-1<<53 - 1)}, {num}, {"\x00a"}} {
+		_, err := EncodeKey(values...)
+		assert.ErrorIs(t, err, ErrInvalidPrimaryKey, "values %v", values)
+	}
+}
+
+func TestDecodeKey(t *testing.T) {
+	for _, values := range [][]any{
+		{"alice"},
+		{"a\x00b", "\x00"},
+		{int64(7)},
+		{"o1", int64(-2)},
+		{2.5, "x"},
+		{math.Inf(-1)},
+	} {
+		got, err := DecodeKey(testKey(values...))
+		require.NoError(t, err)
+		assert.Equal(t, values, got)
+	}
+
+	for _, key := range []string{"", "\x00", "\x00sabc", "\x00n12", "\x00nzzzzzzzzzzzzzzzz", "\x00x"} {
+		_, err := DecodeKey(key)
+		assert.ErrorIs(t, err, ErrInvalidPrimaryKey, "key %q", key)
+	}
+}
+
+func TestFormatKey(t *testing.T) {
+	assert.Equal(t, "alice", FormatKey("alice"))
+	assert.Equal(t, "o1/2", FormatKey(testKey("o1", 2)))
+	assert.Equal(t, "0.5", FormatKey(testKey(0.5)))
+	assert.Equal(t, `"\x00x"`, FormatKey("\x00x"))
+}
+
+func TestCollection_ParseKey(t *testing.T) {
+	coll := NewCollection(CollectionConfig{PrimaryKeys: []string{"order_id", "line_no"}})
+	defer coll.Close()
+	require.NoError(t, coll.Put(orderLine("o1", 2)))
+	require.NoError(t, coll.Put(orderLine("7", 3)))
+
+	tests := []struct {
+		parts   []string
+		want    string
+		wantErr error
+	}{
+		{parts: []string{"o1", "2"}, want: testKey("o1", 2)},
+		{parts: []string{"7", "3"}, want: testKey("7", 3)},
+		{parts: []string{"o9", "1"}, want: testKey("o9", "1")},
+		{parts: []string{"o1"}, wantErr: ErrInvalidPrimaryKey},
+	}
+
+	for _, tt := range tests {
+		got, err := coll.ParseKey(tt.parts...)
+		if tt.wantErr != nil {
+			assert.ErrorIs(t, err, tt.wantErr)
+			continue
+		}
+		require.NoError(t, err)
+		assert.Equal(t, tt.want, got, "parts %v", tt.parts)
+	}
+
+	typed := NewCollection(CollectionConfig{PrimaryKey: "n", Schema: &Schema{Fields: map[string]FieldRule{
+		"n": {Types: []DocumentFieldType{DocumentFieldTypeNumber}},
+	}}})
+	defer typed.Close()
+	key, err := typed.ParseKey("5")
+	require.NoError(t, err)
+	assert.Equal(t, testKey(5), key)
+	_, err = typed.ParseKey("five")
+	assert.ErrorIs(t, err, ErrInvalidPrimaryKey)
+}
+
+func TestCollection_CompositeKey(t *testing.T) {
+	coll, err := OpenCollection(CollectionConfig{PrimaryKeys: []string{"order_id", "line_no"}})
+	require.NoError(t, err)
+	defer coll.Close()
+
+	for _, doc := range []Document{orderLine("o2", 1), orderLine("o1", 10), orderLine("o1", 2)} {
+		require.NoError(t, coll.Put(doc))
+	}
+
+	key, err := coll.Key("o1", 2)
+	require.NoError(t, err)
+	doc, err := coll.Get(key)
+	require.NoError(t, err)
+	assert.Equal(t, "o1", doc.Fields["order_id"].Value)
+	assert.Equal(t, 2, doc.Fields["line_no"].Value)
+
+	var order []string
+	for _, doc := range coll.List() {
+		order = append(order, doc.Fields["order_id"].Value.(string))
+	}
+	assert.Equal(t, []string{"o1", "o1", "o2"}, order)
+	assert.Equal(t, 2, coll.List()[0].Fields["line_no"].Value)
+
+	require.NoError(t, coll.Delete(key))
+	_, err = coll.Get(key)
+	assert.ErrorIs(t, err, ErrDocumentNotFound)
+
+	_, err = coll.Key("o1")
+	assert.ErrorIs(t, err, ErrInvalidPrimaryKey)
+
+	err = coll.Put(Document{Fields: map[string]DocumentField{
+		"order_id": {Type: DocumentFieldTypeString, Value: "o3"},
+	}})
+	assert.ErrorIs(t, err, ErrInvalidPrimaryKey)
+}
+
+func TestCollection_NumericKeyListsInOrder(t *testing.T) {
+	coll := NewCollection(CollectionConfig{PrimaryKey: "n"})
+	defer coll.Close()
+
+	for _, n := range []float64{10, -3, 2, 0.5} {
+		require.NoError(t, coll.Put(Document{Fields: map[string]DocumentField{
+			"n": {Type: DocumentFieldTypeNumber, Value: n},
+		}}))
+	}
+
+	var got []float64
+	for _, doc := range coll.List() {
+		got = append(got, doc.Fields["n"].Value.(float64))
+	}
+	assert.Equal(t, []float64{-3, 0.5, 2, 10}, got)
+
+	key, err := coll.Key(2)
+	require.NoError(t, err)
+	_, err = coll.Get(key)
+	assert.NoError(t, err)
+}
+
+func TestOpenCollection_InvalidKeyFields(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     CollectionConfig
+		wantErr error
+	}{
+		{name: "no key", cfg: CollectionConfig{}, wantErr: ErrInvalidPrimaryKey},
+		{name: "empty field", cfg: CollectionConfig{PrimaryKeys: []string{"a", ""}}, wantErr: ErrInvalidPrimaryKey},
+		{name: "duplicate field", cfg: CollectionConfig{PrimaryKeys: []string{"a", "a"}}, wantErr: ErrInvalidPrimaryKey},
+		{
+			name:    "conflicting primary key",
+			cfg:     CollectionConfig{PrimaryKey: "b", PrimaryKeys: []string{"a", "b"}},
+			wantErr: ErrInvalidPrimaryKey,
+		},
+		{
+			name:    "generated composite key",
+			cfg:     CollectionConfig{PrimaryKeys: []string{"a", "b"}, KeyGen: KeyGenULID},
+			wantErr: ErrInvalidKeyGenerator,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			coll, err := OpenCollection(tt.cfg)
+			assert.ErrorIs(t, err, tt.wantErr)
+			assert.Nil(t, coll)
+		})
+	}
+}
+
+func TestStore_DumpCompositeKeys(t *testing.T) {
+	store := NewStore()
+	coll, err := store.CreateCollection("lines", &CollectionConfig{PrimaryKeys: []string{"order_id", "line_no"}})
+	require.NoError(t, err)
+	require.NoError(t, coll.Put(orderLine("o1", 1)))
+	require.NoError(t, coll.Put(orderLine("o1", 2)))
+
+	data, err := store.Dump()
+	require.NoError(t, err)
+	restored, err := NewStoreFromDump(data)
+	require.NoError(t, err)
+	restoredColl, err := restored.GetCollection("lines")
+	require.NoError(t, err)
+
+	key, err := restoredColl.Key("o1", 2)
+	require.NoError(t, err)
+	doc, err := restoredColl.Get(key)
+	require.NoError(t, err)
+	assert.Equal(t, float64(2), doc.Fields["line_no"].Value)
+	assert.Equal(t, 2, restoredColl.Len())
+}
+
+// testKey encodes a key for tests, which only use valid values.
+func testKey(values ...any) string {
+	key, err := EncodeKey(values...)
+	if err != nil {
+		panic(err)
+	}
+	return key
+}
@@ -0,0 +1,228 @@
+package documentstore
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"strings"
+	"time"
+)
+
+type KeyGenerator string
+
+const (
+	KeyGenUUIDv4   KeyGenerator = "uuid_v4"
+	KeyGenUUIDv7   KeyGenerator = "uuid_v7"
+	KeyGenULID     KeyGenerator = "ulid"
+	KeyGenSequence KeyGenerator = "sequence"
+)
+
+const crockfordAlphabet = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+func validKeyGenerator(gen KeyGenerator) bool {
+	switch gen {
+	case "", KeyGenUUIDv4, KeyGenUUIDv7, KeyGenULID, KeyGenSequence:
+		return true
+	default:
+		return false
+	}
+}
+
+// Insert writes doc like PutWithOptions and returns its primary key. When
+// the collection has a key generator and doc has no primary key, or an
+// empty one, a new key is generated and stored in the document.
+func (c *Collection) Insert(doc Document, opts ...PutOption) (string, error) {
+	var o putOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	defer c.events.wait(c.name)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if doc.Fields == nil {
+		c.logger.Error("failed to insert document: nil fields")
+		return "", ErrNilValue
+	}
+
+	doc, err := c.fillKey(doc)
+	if err != nil {
+		return "", err
+	}
+
+	key, err := c.primaryKey(doc)
+	if err != nil {
+		return "", err
+	}
+	return key, c.put(doc, o)
+}
+
+// fillKey returns doc with a generated primary key if it lacks one. The
+// caller's field map is left untouched.
+func (c *Collection) fillKey(doc Document) (Document, error) {
+	if c.cfg.KeyGen == "" {
+		return doc, nil
+	}
+	field := c.cfg.keyFields()[0]
+	if value, ok := doc.Fields[field]; ok && value.Value != "" {
+		return doc, nil
+	}
+
+	value, err := c.generateKey()
+	if err != nil {
+		c.logger.Error("failed to generate primary key", "collection", c.name, "generator", c.cfg.KeyGen, "error", err)
+		return doc, err
+	}
+
+	doc = *cloneDocument(&doc)
+	switch value := value.(type) {
+	case int64:
+		doc.Fields[field] = DocumentField{Type: DocumentFieldTypeNumber, Value: value}
+	default:
+		doc.Fields[field] = DocumentField{Type: DocumentFieldTypeString, Value: value}
+	}
+	return doc, nil
+}
+
+// generateKey returns a new primary key value: an int64 for
+// KeyGenSequence and a string otherwise.
+func (c *Collection) generateKey() (any, error) {
+	switch c.cfg.KeyGen {
+	case KeyGenSequence:
+		// Skip keys taken by documents written with explicit keys.
+		for {
+			c.sequence++
+			value := int64(c.sequence)
+			encoded, err := EncodeKey(value)
+			if err != nil {
+				return nil, err
+			}
+			_, exists, err := c.engine.Get(encoded)
+			if err != nil {
+				return nil, err
+			}
+			if !exists {
+				return value, nil
+			}
+		}
+	case KeyGenUUIDv4:
+		return newUUIDv4()
+	case KeyGenUUIDv7:
+		return newUUIDv7(c.now())
+	case KeyGenULID:
+		return newULID(c.now())
+	default:
+		return nil, fmt.Errorf("%w: unknown key generator %q", ErrInvalidKeyGenerator, c.cfg.KeyGen)
+	}
+}
+
+// observeKey advances the sequence past explicit number keys so that
+// generated keys never collide with them.
+func (c *Collection) observeKey(doc Document) {
+	if c.cfg.KeyGen != KeyGenSequence {
+		return
+	}
+	n, ok := numberValue(doc.Fields[c.cfg.keyFields()[0]].Value)
+	if ok && n >= 1 && n <= maxExactInt {
+		c.advanceSequence(uint64(n))
+	}
+}
+
+// advanceSequence makes the next generated key greater than n.
+func (c *Collection) advanceSequence(n uint64) {
+	c.sequence = max(c.sequence, n)
+}
+
+// numberKeyPrefix starts every key whose first value is a number.
+const numberKeyPrefix = string(keyEncodedPrefix) + string(keyTagNumber)
+
+// initSequence resumes the sequence of a persistent engine from its
+// largest number key. Number keys sort in numeric order, so it is found
+// with a binary search of seeks rather than by reading every document.
+func (c *Collection) initSequence() error {
+	// atLeast reports whether some number key is n or greater.
+	atLeast := func(n uint64) (bool, error) {
+		start, err := EncodeKey(int64(n))
+		if err != nil {
+			return false, err
+		}
+		found := false
+		err = c.engine.ScanFrom(start, func(key string, _ Document) bool {
+			found = strings.HasPrefix(key, numberKeyPrefix)
+			return false
+		})
+		return found, err
+	}
+
+	found, err := atLeast(1)
+	if err != nil || !found {
+		return err
+	}
+
+	lo, hi := uint64(1), uint64(maxExactInt)
+	for lo < hi {
+		mid := lo + (hi-lo+1)/2
+		found, err := atLeast(mid)
+		if err != nil {
+			return err
+		}
+		if found {
+			lo = mid
+		} else {
+			hi = mid - 1
+		}
+	}
+	c.advanceSequence(lo)
+	return nil
+}
+
+func newUUIDv4() (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", err
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return formatUUID(b), nil
+}
+
+// newUUIDv7 builds an RFC 9562 version 7 UUID: a millisecond timestamp
+// followed by random bits, so keys sort by creation time.
+func newUUIDv7(now time.Time) (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[6:]); err != nil {
+		return "", err
+	}
+	ms := uint64(now.UnixMilli())
+	b[0], b[1], b[2] = byte(ms>>40), byte(ms>>32), byte(ms>>24)
+	b[3], b[4], b[5] = byte(ms>>16), byte(ms>>8), byte(ms)
+	b[6] = b[6]&0x0f | 0x70
+	b[8] = b[8]&0x3f | 0x80
+	return formatUUID(b), nil
+}
+
+func formatUUID(b [16]byte) string {
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
+
+// newULID builds a ULID: a 48-bit millisecond timestamp and 80 random
+// bits in Crockford base32, 26 characters that sort by creation time.
+func newULID(now time.Time) (string, error) {
+	var b [16]byte
+	binary.BigEndian.PutUint64(b[:8], uint64(now.UnixMilli())<<16)
+	if _, err := rand.Read(b[6:]); err != nil {
+		return "", err
+	}
+
+	hi := binary.BigEndian.Uint64(b[:8])
+	lo := binary.BigEndian.Uint64(b[8:])
+
+	var out [26]byte
+	for i := 25; i >= 0; i-- {
+		out[i] = crockfordAlphabet[lo&0x1f]
+		lo = lo>>5 | hi<<59
+		hi >>= 5
+	}
+	return string(out[:]), nil
+}
@@ -0,0 +1,226 @@
+package documentstore
+
+import (
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func seqKey(n int) string {
+	key, err := EncodeKey(int64(n))
+	if err != nil {
+		panic(err)
+	}
+	return key
+}
+
+func namedDoc(name string) Document {
+	return Document{Fields: map[string]DocumentField{
+		"name": {Type: DocumentFieldTypeString, Value: name},
+	}}
+}
+
+func TestCollection_InsertGeneratesKeys(t *testing.T) {
+	tests := []struct {
+		name    string
+		gen     KeyGenerator
+		pattern string
+	}{
+		{name: "uuid v4", gen: KeyGenUUIDv4, pattern: `^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`},
+		{name: "uuid v7", gen: KeyGenUUIDv7, pattern: `^[0-9a-f]{8}-[0-9a-f]{4}-7[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`},
+		{name: "ulid", gen: KeyGenULID, pattern: `^[0-7][0-9A-HJKMNP-TV-Z]{25}$`},
+		{name: "sequence", gen: KeyGenSequence, pattern: `^[1-9][0-9]*$`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			coll := NewCollection(CollectionConfig{PrimaryKey: "id", KeyGen: tt.gen})
+			defer coll.Close()
+
+			seen := make(map[string]bool)
+			for i := 0; i < 50; i++ {
+				doc := namedDoc("Alice")
+				key, err := coll.Insert(doc)
+				require.NoError(t, err)
+				assert.Regexp(t, regexp.MustCompile(tt.pattern), FormatKey(key))
+				assert.False(t, seen[key], "duplicate key %s", key)
+				seen[key] = true
+				assert.NotContains(t, doc.Fields, "id")
+
+				stored, err := coll.Get(key)
+				require.NoError(t, err)
+				storedKey, err := coll.DocumentKey(*stored)
+				require.NoError(t, err)
+				assert.Equal(t, key, storedKey)
+			}
+		})
+	}
+}
+
+func TestCollection_TimeOrderedKeys(t *testing.T) {
+	for _, gen := range []KeyGenerator{KeyGenUUIDv7, KeyGenULID, KeyGenSequence} {
+		t.Run(string(gen), func(t *testing.T) {
+			coll := NewCollection(CollectionConfig{PrimaryKey: "id", KeyGen: gen})
+			defer coll.Close()
+			clock := &fakeClock{now: time.UnixMilli(1_700_000_000_000)}
+			coll.now = clock.Now
+
+			var keys []string
+			for i := 0; i < 12; i++ {
+				key, err := coll.Insert(namedDoc("x"))
+				require.NoError(t, err)
+				keys = append(keys, key)
+				clock.Advance(time.Millisecond)
+			}
+			assert.IsIncreasing(t, keys)
+		})
+	}
+}
+
+func TestCollection_InsertKeepsExplicitKey(t *testing.T) {
+	coll := NewCollection(CollectionConfig{PrimaryKey: "id", KeyGen: KeyGenSequence})
+	defer coll.Close()
+
+	key, err := coll.Insert(userDoc("5", "Eve"))
+	require.NoError(t, err)
+	assert.Equal(t, "5", key)
+
+	// A string key never collides with a generated number key.
+	key, err = coll.Insert(namedDoc("Frank"))
+	require.NoError(t, err)
+	assert.Equal(t, seqKey(1), key)
+
+	eve := namedDoc("Eve")
+	eve.Fields["id"] = DocumentField{Type: DocumentFieldTypeNumber, Value: int64(5)}
+	key, err = coll.Insert(eve)
+	require.NoError(t, err)
+	assert.Equal(t, seqKey(5), key)
+
+	// The sequence continues past explicit number keys.
+	key, err = coll.Insert(namedDoc("Grace"))
+	require.NoError(t, err)
+	assert.Equal(t, seqKey(6), key)
+
+	require.NoError(t, coll.Put(namedDoc("Heidi")))
+	assert.Equal(t, 5, coll.Len())
+}
+
+func TestCollection_InsertWithoutKeyGenerator(t *testing.T) {
+	coll := NewCollection(CollectionConfig{PrimaryKey: "id"})
+	defer coll.Close()
+
+	_, err := coll.Insert(namedDoc("Alice"))
+	assert.ErrorIs(t, err, ErrInvalidPrimaryKey)
+}
+
+func TestOpenCollection_InvalidKeyGenerator(t *testing.T) {
+	coll, err := OpenCollection(CollectionConfig{PrimaryKey: "id", KeyGen: "snowflake"})
+	assert.ErrorIs(t, err, ErrInvalidKeyGenerator)
+	assert.Nil(t, coll)
+}
+
+func TestSequence_SurvivesDumpAndReopen(t *testing.T) {
+	store := NewStore()
+	coll, err := store.CreateCollection("users", &CollectionConfig{PrimaryKey: "id", KeyGen: KeyGenSequence})
+	require.NoError(t, err)
+
+	for i := 0; i < 3; i++ {
+		_, err := coll.Insert(namedDoc("x"))
+		require.NoError(t, err)
+	}
+	// Deleting the newest document must not let its key be reissued.
+	require.NoError(t, coll.Delete(seqKey(3)))
+
+	data, err := store.Dump()
+	require.NoError(t, err)
+	restored, err := NewStoreFromDump(data)
+	require.NoError(t, err)
+	restoredColl, err := restored.GetCollection("users")
+	require.NoError(t, err)
+
+	key, err := restoredColl.Insert(namedDoc("y"))
+	require.NoError(t, err)
+	assert.Equal(t, seqKey(4), key)
+
+	t.Run("persistent engine", func(t *testing.T) {
+		dir := t.TempDir()
+		cfg := CollectionConfig{PrimaryKey: "id", KeyGen: KeyGenSequence, Engine: EngineBitcask, DataDir: dir}
+
+		first := NewCollection(cfg)
+		for i := 0; i < 2; i++ {
+			_, err := first.Insert(namedDoc("x"))
+			require.NoError(t, err)
+		}
+		require.NoError(t, first.Close())
+
+		reopened, err := OpenCollection(cfg)
+		require.NoError(t, err)
+		defer reopened.Close()
+
+		key, err := reopened.Insert(namedDoc("y"))
+		require.NoError(t, err)
+		assert.Equal(t, seqKey(3), key)
+	})
+
+	t.Run("merge restore", func(t *testing.T) {
+		live := NewStore()
+		defer live.Close()
+		liveColl, err := live.CreateCollection("users", &CollectionConfig{PrimaryKey: "id", KeyGen: KeyGenSequence})
+		require.NoError(t, err)
+		_, err = liveColl.Insert(namedDoc("z"))
+		require.NoError(t, err)
+
+		_, err = live.RestoreFromDump(data, RestoreOptions{Conflict: ConflictOverwrite})
+		require.NoError(t, err)
+
+		key, err := liveColl.Insert(namedDoc("y"))
+		require.NoError(t, err)
+		assert.Equal(t, seqKey(4), key)
+	})
+}
+
+func TestSequence_ResumesFromLargestNumberKey(t *testing.T) {
+	for _, engine := range []EngineKind{EngineBitcask, EngineLSM} {
+		t.Run(string(engine), func(t *testing.T) {
+			cfg := CollectionConfig{PrimaryKey: "id", KeyGen: KeyGenSequence, Engine: engine, DataDir: t.TempDir()}
+
+			first, err := OpenCollection(cfg)
+			require.NoError(t, err)
+			for _, id := range []any{int64(-5), int64(7), 2.5, int64(1_000_003), 999.5} {
+				doc := namedDoc("x")
+				doc.Fields["id"] = DocumentField{Type: DocumentFieldTypeNumber, Value: id}
+				require.NoError(t, first.Put(doc))
+			}
+			require.NoError(t, first.Put(userDoc("zzz", "x")))
+			require.NoError(t, first.Close())
+
+			reopened, err := OpenCollection(cfg)
+			require.NoError(t, err)
+			defer reopened.Close()
+
+			key, err := reopened.Insert(namedDoc("y"))
+			require.NoError(t, err)
+			assert.Equal(t, seqKey(1_000_004), key)
+		})
+	}
+
+	t.Run("no number keys", func(t *testing.T) {
+		cfg := CollectionConfig{PrimaryKey: "id", KeyGen: KeyGenSequence, Engine: EngineBitcask, DataDir: t.TempDir()}
+
+		first, err := OpenCollection(cfg)
+		require.NoError(t, err)
+		require.NoError(t, first.Put(userDoc("a", "x")))
+		require.NoError(t, first.Close())
+
+		reopened, err := OpenCollection(cfg)
+		require.NoError(t, err)
+		defer reopened.Close()
+
+		key, err := reopened.Insert(namedDoc("y"))
+		require.NoError(t, err)
+		assert.Equal(t, seqKey(1), key)
+	})
+}
@@ -0,0 +1,28 @@
+//go:build !unix
+
+package documentstore
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// lockDataDir claims dir by creating its LOCK file, which unlock removes.
+// Without flock a crash leaves the file behind; delete it by hand once no
+// process uses the directory.
+func lockDataDir(dir string) (unlock func() error, err error) {
+	path := filepath.Join(dir, dataDirLockFilename)
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_RDWR, 0o644)
+	if errors.Is(err, os.ErrExist) {
+		return nil, fmt.Errorf("%w: %s", ErrDataDirLocked, dir)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return func() error {
+		return errors.Join(f.Close(), os.Remove(path))
+	}, nil
+}
@@ -0,0 +1,30 @@
+//go:build unix
+
+package documentstore
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+// lockDataDir takes an exclusive lock on the LOCK file of dir, so that two
+// engines never write the same files. The kernel drops the lock if the
+// process dies, so a crash leaves nothing stale behind.
+func lockDataDir(dir string) (unlock func() error, err error) {
+	f, err := os.OpenFile(filepath.Join(dir, dataDirLockFilename), os.O_CREATE|os.O_RDWR, 0o644)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		f.Close()
+		if errors.Is(err, syscall.EWOULDBLOCK) {
+			return nil, fmt.Errorf("%w: %s", ErrDataDirLocked, dir)
+		}
+		return nil, err
+	}
+	return f.Close, nil
+}
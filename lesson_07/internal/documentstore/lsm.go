@@ -0,0 +1,773 @@
+package documentstore
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	lsmWALFilename      = "wal.log"
+	lsmManifestFilename = "MANIFEST"
+	lsmTableSuffix      = ".sst"
+	lsmMaxLevels        = 7
+	lsmLevelMultiplier  = 10
+
+	defaultMemtableSize        = 4 << 20
+	defaultL0CompactionTrigger = 4
+	defaultLevelBaseSize       = 10 * defaultMemtableSize
+	defaultTableTargetSize     = 2 << 20
+)
+
+// Crash points reported to lsmCrashHook. A flush or compaction is only
+// committed once the manifest naming its output has been renamed into
+// place; everything before that is discarded on reopen.
+const (
+	lsmPointFlushWritten        = "flush-written"
+	lsmPointFlushCommitted      = "flush-committed"
+	lsmPointCompactionWritten   = "compaction-written"
+	lsmPointCompactionCommitted = "compaction-committed"
+)
+
+// lsmCrashHook is called at each crash point; tests use it to kill the
+// process in the middle of a flush or compaction.
+var lsmCrashHook func(point string)
+
+func lsmCrashPoint(point string) {
+	if lsmCrashHook != nil {
+		lsmCrashHook(point)
+	}
+}
+
+type lsmManifest struct {
+	NextID uint64     `json:"next_id"`
+	Levels [][]uint64 `json:"levels"`
+}
+
+// lsmEngine is a log-structured merge tree. Writes go to a write-ahead log
+// and an in-memory memtable, which is flushed to an immutable SSTable in
+// level 0 when it grows too large. Level 0 tables may overlap; tables of
+// deeper levels never do. Compaction merges a level into the next one when
+// it exceeds its size budget.
+type lsmEngine struct {
+	dir      string
+	unlock   func() error
+	wal      *os.File
+	walSize  int64
+	memtable map[string]lsmItem
+	memSize  int
+	levels   [][]*sstable
+	nextID   uint64
+	count    int
+
+	memtableSize    int
+	l0Trigger       int
+	levelBaseSize   int64
+	tableTargetSize int64
+}
+
+func openLSMEngine(dir string) (*lsmEngine, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	unlock, err := lockDataDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	e := &lsmEngine{
+		dir:             dir,
+		unlock:          unlock,
+		memtable:        make(map[string]lsmItem),
+		levels:          make([][]*sstable, lsmMaxLevels),
+		nextID:          1,
+		memtableSize:    defaultMemtableSize,
+		l0Trigger:       defaultL0CompactionTrigger,
+		levelBaseSize:   defaultLevelBaseSize,
+		tableTargetSize: defaultTableTargetSize,
+	}
+
+	if err := e.loadManifest(); err != nil {
+		e.Close()
+		return nil, err
+	}
+
+	if err := e.removeOrphans(); err != nil {
+		e.Close()
+		return nil, err
+	}
+
+	if err := e.replayWAL(); err != nil {
+		e.Close()
+		return nil, err
+	}
+
+	err = e.merge(e.cursors(), func(ref lsmRef) bool {
+		if !ref.tombstone {
+			e.count++
+		}
+		return true
+	})
+	if err != nil {
+		e.Close()
+		return nil, err
+	}
+
+	return e, nil
+}
+
+func (e *lsmEngine) tablePath(id uint64) string {
+	return filepath.Join(e.dir, fmt.Sprintf("%09d%s", id, lsmTableSuffix))
+}
+
+func (e *lsmEngine) loadManifest() error {
+	data, err := os.ReadFile(filepath.Join(e.dir, lsmManifestFilename))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var manifest lsmManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return fmt.Errorf("%w: manifest: %v", ErrCorruptDataFile, err)
+	}
+
+	e.nextID = manifest.NextID
+	for level, ids := range manifest.Levels {
+		if level >= lsmMaxLevels {
+			return fmt.Errorf("%w: manifest has %d levels", ErrCorruptDataFile, len(manifest.Levels))
+		}
+		for _, id := range ids {
+			table, err := openSSTable(e.tablePath(id), id)
+			if err != nil {
+				return err
+			}
+			e.levels[level] = append(e.levels[level], table)
+		}
+	}
+
+	return nil
+}
+
+func (e *lsmEngine) writeManifest() error {
+	manifest := lsmManifest{
+		NextID: e.nextID,
+		Levels: make([][]uint64, len(e.levels)),
+	}
+	for level, tables := range e.levels {
+		manifest.Levels[level] = make([]uint64, 0, len(tables))
+		for _, table := range tables {
+			manifest.Levels[level] = append(manifest.Levels[level], table.id)
+		}
+	}
+
+	data, err := json.Marshal(manifest)
+	if err != nil {
+		return err
+	}
+
+	path := filepath.Join(e.dir, lsmManifestFilename)
+	tmp := path + ".tmp"
+
+	file, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, path)
+}
+
+// removeOrphans deletes table files left behind by a flush or compaction
+// that crashed before committing, or after committing but before removing
+// its inputs.
+func (e *lsmEngine) removeOrphans() error {
+	live := make(map[uint64]bool)
+	for _, tables := range e.levels {
+		for _, table := range tables {
+			live[table.id] = true
+		}
+	}
+
+	entries, err := os.ReadDir(e.dir)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		name := entry.Name()
+		if strings.HasSuffix(name, ".tmp") {
+			if err := os.Remove(filepath.Join(e.dir, name)); err != nil {
+				return err
+			}
+			continue
+		}
+		if !strings.HasSuffix(name, lsmTableSuffix) {
+			continue
+		}
+
+		id, err := strconv.ParseUint(strings.TrimSuffix(name, lsmTableSuffix), 10, 64)
+		if err != nil || live[id] {
+			continue
+		}
+		if err := os.Remove(filepath.Join(e.dir, name)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (e *lsmEngine) replayWAL() error {
+	file, err := os.OpenFile(filepath.Join(e.dir, lsmWALFilename), os.O_CREATE|os.O_RDWR, 0o644)
+	if err != nil {
+		return err
+	}
+	e.wal = file
+
+	var offset int64
+	for {
+		rec, size, err := readLogRecord(file, offset)
+		if errors.Is(err, io.EOF) || errors.Is(err, errTornRecord) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		e.applyMemtable(lsmItem{key: rec.key, value: rec.value, tombstone: rec.tombstone()})
+		offset += size
+	}
+
+	if err := file.Truncate(offset); err != nil {
+		return err
+	}
+	e.walSize = offset
+	return nil
+}
+
+func (e *lsmEngine) applyMemtable(item lsmItem) {
+	if old, ok := e.memtable[item.key]; ok {
+		e.memSize -= len(old.key) + len(old.value)
+	}
+	e.memtable[item.key] = item
+	e.memSize += len(item.key) + len(item.value)
+}
+
+func (e *lsmEngine) write(item lsmItem) error {
+	var flags byte
+	if item.tombstone {
+		flags = logRecordTombstone
+	}
+
+	record := encodeLogRecord(item.key, item.value, flags)
+	if _, err := e.wal.WriteAt(record, e.walSize); err != nil {
+		return err
+	}
+	e.walSize += int64(len(record))
+
+	e.applyMemtable(item)
+
+	if e.memSize < e.memtableSize {
+		return nil
+	}
+
+	if err := e.flush(); err != nil {
+		return err
+	}
+	return e.maybeCompact()
+}
+
+func (e *lsmEngine) flush() error {
+	if len(e.memtable) == 0 {
+		return nil
+	}
+
+	items := make([]lsmItem, 0, len(e.memtable))
+	for _, item := range e.memtable {
+		items = append(items, item)
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].key < items[j].key })
+
+	id := e.nextID
+	table, err := writeSSTable(e.tablePath(id), id, items)
+	if err != nil {
+		return err
+	}
+	lsmCrashPoint(lsmPointFlushWritten)
+
+	e.nextID++
+	e.levels[0] = append(e.levels[0], table)
+	if err := e.writeManifest(); err != nil {
+		e.levels[0] = e.levels[0][:len(e.levels[0])-1]
+		e.nextID--
+		table.close()
+		os.Remove(table.path)
+		return err
+	}
+	lsmCrashPoint(lsmPointFlushCommitted)
+
+	if err := e.wal.Truncate(0); err != nil {
+		return err
+	}
+	e.walSize = 0
+	e.memtable = make(map[string]lsmItem)
+	e.memSize = 0
+	return nil
+}
+
+func (e *lsmEngine) levelSize(level int) int64 {
+	var size int64
+	for _, table := range e.levels[level] {
+		size += table.size
+	}
+	return size
+}
+
+func (e *lsmEngine) levelBudget(level int) int64 {
+	budget := e.levelBaseSize
+	for i := 1; i < level; i++ {
+		budget *= lsmLevelMultiplier
+	}
+	return budget
+}
+
+func (e *lsmEngine) maybeCompact() error {
+	for {
+		switch {
+		case len(e.levels[0]) >= e.l0Trigger:
+			if err := e.compact(0); err != nil {
+				return err
+			}
+		default:
+			compacted := false
+			for level := 1; level < lsmMaxLevels-1; level++ {
+				if e.levelSize(level) > e.levelBudget(level) {
+					if err := e.compact(level); err != nil {
+						return err
+					}
+					compacted = true
+					break
+				}
+			}
+			if !compacted {
+				return nil
+			}
+		}
+	}
+}
+
+// compact merges tables of level into level+1. All of level 0 is merged at
+// once because its tables overlap; deeper levels move one table at a time.
+func (e *lsmEngine) compact(level int) error {
+	var inputs []*sstable
+	if level == 0 {
+		inputs = e.levels[0]
+	} else {
+		inputs = e.levels[level][:1]
+	}
+
+	lo, hi := inputs[0].minKey(), inputs[0].maxKey()
+	for _, table := range inputs[1:] {
+		lo, hi = min(lo, table.minKey()), max(hi, table.maxKey())
+	}
+
+	var overlaps, kept []*sstable
+	for _, table := range e.levels[level+1] {
+		if table.maxKey() < lo || table.minKey() > hi {
+			kept = append(kept, table)
+		} else {
+			overlaps = append(overlaps, table)
+		}
+	}
+
+	// Newest data first: later level-0 tables shadow earlier ones, and any
+	// level shadows the one below it.
+	var sources []*lsmCursor
+	for i := len(inputs) - 1; i >= 0; i-- {
+		sources = append(sources, tableCursor(inputs[i]))
+	}
+	for _, table := range overlaps {
+		sources = append(sources, tableCursor(table))
+	}
+
+	bottom := true
+	for deeper := level + 2; deeper < lsmMaxLevels; deeper++ {
+		if len(e.levels[deeper]) > 0 {
+			bottom = false
+		}
+	}
+
+	var (
+		outputs []*sstable
+		batch   []lsmItem
+		size    int64
+		nextID  = e.nextID
+	)
+	flushBatch := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		table, err := writeSSTable(e.tablePath(nextID), nextID, batch)
+		if err != nil {
+			return err
+		}
+		nextID++
+		outputs = append(outputs, table)
+		batch, size = nil, 0
+		return nil
+	}
+
+	var writeErr error
+	err := e.merge(sources, func(ref lsmRef) bool {
+		if ref.tombstone && bottom {
+			return true
+		}
+
+		value, err := ref.read()
+		if err != nil {
+			writeErr = err
+			return false
+		}
+
+		batch = append(batch, lsmItem{key: ref.key, value: value, tombstone: ref.tombstone})
+		size += int64(len(ref.key) + len(value))
+		if size >= e.tableTargetSize {
+			if err := flushBatch(); err != nil {
+				writeErr = err
+				return false
+			}
+		}
+		return true
+	})
+	if err == nil {
+		err = writeErr
+	}
+	if err == nil {
+		err = flushBatch()
+	}
+	if err != nil {
+		for _, table := range outputs {
+			table.close()
+			os.Remove(table.path)
+		}
+		return err
+	}
+	lsmCrashPoint(lsmPointCompactionWritten)
+
+	prevLevel, prevNext, prevID := e.levels[level], e.levels[level+1], e.nextID
+
+	next := append(kept, outputs...)
+	sort.Slice(next, func(i, j int) bool { return next[i].minKey() < next[j].minKey() })
+
+	if level == 0 {
+		e.levels[0] = nil
+	} else {
+		e.levels[level] = e.levels[level][1:]
+	}
+	e.levels[level+1] = next
+	e.nextID = nextID
+
+	if err := e.writeManifest(); err != nil {
+		e.levels[level], e.levels[level+1], e.nextID = prevLevel, prevNext, prevID
+		for _, table := range outputs {
+			table.close()
+			os.Remove(table.path)
+		}
+		return err
+	}
+	lsmCrashPoint(lsmPointCompactionCommitted)
+
+	for _, table := range slices.Concat(inputs, overlaps) {
+		table.close()
+		if err := os.Remove(table.path); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// lsmRef points at the newest version of a key found during a merge.
+type lsmRef struct {
+	key       string
+	tombstone bool
+	value     []byte
+	table     *sstable
+	entry     sstableEntry
+}
+
+func (r lsmRef) read() ([]byte, error) {
+	if r.table == nil {
+		return r.value, nil
+	}
+	return r.table.readValue(r.entry)
+}
+
+// lsmCursor walks one source of the tree in key order: either the sorted
+// memtable refs or a run of non-overlapping tables.
+type lsmCursor struct {
+	refs   []lsmRef
+	tables []*sstable
+	pos    int
+}
+
+func tableCursor(tables ...*sstable) *lsmCursor {
+	return &lsmCursor{tables: tables}
+}
+
+// current returns the ref under the cursor, skipping exhausted tables.
+func (c *lsmCursor) current() (lsmRef, bool) {
+	if c.tables == nil {
+		if c.pos < len(c.refs) {
+			return c.refs[c.pos], true
+		}
+		return lsmRef{}, false
+	}
+
+	for len(c.tables) > 0 && c.pos >= len(c.tables[0].entries) {
+		c.tables, c.pos = c.tables[1:], 0
+	}
+	if len(c.tables) == 0 {
+		return lsmRef{}, false
+	}
+	table := c.tables[0]
+	entry := table.entries[c.pos]
+	return lsmRef{key: entry.key, tombstone: entry.tombstone, table: table, entry: entry}, true
+}
+
+// seek moves the cursor to the first key at or after start.
+func (c *lsmCursor) seek(start string) {
+	if c.tables == nil {
+		c.pos = sort.Search(len(c.refs), func(i int) bool { return c.refs[i].key >= start })
+		return
+	}
+
+	for len(c.tables) > 0 && c.tables[0].maxKey() < start {
+		c.tables = c.tables[1:]
+	}
+	c.pos = 0
+	if len(c.tables) > 0 {
+		entries := c.tables[0].entries
+		c.pos = sort.Search(len(entries), func(i int) bool { return entries[i].key >= start })
+	}
+}
+
+func (e *lsmEngine) memtableCursor() *lsmCursor {
+	refs := make([]lsmRef, 0, len(e.memtable))
+	for _, item := range e.memtable {
+		refs = append(refs, lsmRef{key: item.key, tombstone: item.tombstone, value: item.value})
+	}
+	sort.Slice(refs, func(i, j int) bool { return refs[i].key < refs[j].key })
+	return &lsmCursor{refs: refs}
+}
+
+// cursors returns every source of the tree, newest first.
+func (e *lsmEngine) cursors() []*lsmCursor {
+	sources := []*lsmCursor{e.memtableCursor()}
+	for i := len(e.levels[0]) - 1; i >= 0; i-- {
+		sources = append(sources, tableCursor(e.levels[0][i]))
+	}
+	for _, tables := range e.levels[1:] {
+		sources = append(sources, tableCursor(tables...))
+	}
+	return sources
+}
+
+// merge walks the sources in ascending key order and calls fn with the
+// version of each key from the earliest (newest) source that has it.
+func (e *lsmEngine) merge(sources []*lsmCursor, fn func(ref lsmRef) bool) error {
+	for {
+		var (
+			ref   lsmRef
+			found bool
+		)
+		for _, src := range sources {
+			if cur, ok := src.current(); ok && (!found || cur.key < ref.key) {
+				ref, found = cur, true
+			}
+		}
+		if !found {
+			return nil
+		}
+
+		for _, src := range sources {
+			if cur, ok := src.current(); ok && cur.key == ref.key {
+				src.pos++
+			}
+		}
+
+		if !fn(ref) {
+			return nil
+		}
+	}
+}
+
+func (e *lsmEngine) find(key string) (lsmRef, bool) {
+	if item, ok := e.memtable[key]; ok {
+		return lsmRef{key: key, tombstone: item.tombstone, value: item.value}, true
+	}
+
+	for i := len(e.levels[0]) - 1; i >= 0; i-- {
+		table := e.levels[0][i]
+		if entry, ok := table.lookup(key); ok {
+			return lsmRef{key: key, tombstone: entry.tombstone, table: table, entry: entry}, true
+		}
+	}
+
+	for _, tables := range e.levels[1:] {
+		i := sort.Search(len(tables), func(i int) bool { return tables[i].maxKey() >= key })
+		if i == len(tables) || tables[i].minKey() > key {
+			continue
+		}
+		if entry, ok := tables[i].lookup(key); ok {
+			return lsmRef{key: key, tombstone: entry.tombstone, table: tables[i], entry: entry}, true
+		}
+	}
+
+	return lsmRef{}, false
+}
+
+func (e *lsmEngine) has(key string) bool {
+	ref, ok := e.find(key)
+	return ok && !ref.tombstone
+}
+
+func (e *lsmEngine) Get(key string) (Document, bool, error) {
+	ref, ok := e.find(key)
+	if !ok || ref.tombstone {
+		return Document{}, false, nil
+	}
+
+	value, err := ref.read()
+	if err != nil {
+		return Document{}, false, err
+	}
+
+	doc, err := decodeDocument(value)
+	if err != nil {
+		return Document{}, false, err
+	}
+	return doc, true, nil
+}
+
+func (e *lsmEngine) Put(key string, doc Document) error {
+	value, err := encodeDocument(doc)
+	if err != nil {
+		return err
+	}
+
+	existed := e.has(key)
+	if err := e.write(lsmItem{key: key, value: value}); err != nil {
+		return err
+	}
+
+	if !existed {
+		e.count++
+	}
+	return nil
+}
+
+func (e *lsmEngine) Delete(key string) error {
+	if !e.has(key) {
+		return nil
+	}
+
+	if err := e.write(lsmItem{key: key, tombstone: true}); err != nil {
+		return err
+	}
+
+	e.count--
+	return nil
+}
+
+func (e *lsmEngine) Scan(fn func(key string, doc Document) bool) error {
+	return e.ScanFrom("", fn)
+}
+
+func (e *lsmEngine) ScanFrom(start string, fn func(key string, doc Document) bool) error {
+	sources := e.cursors()
+	for _, src := range sources {
+		src.seek(start)
+	}
+
+	var scanErr error
+	err := e.merge(sources, func(ref lsmRef) bool {
+		if ref.tombstone {
+			return true
+		}
+
+		value, err := ref.read()
+		if err != nil {
+			scanErr = err
+			return false
+		}
+
+		doc, err := decodeDocument(value)
+		if err != nil {
+			scanErr = err
+			return false
+		}
+		return fn(ref.key, doc)
+	})
+	if err != nil {
+		return err
+	}
+	return scanErr
+}
+
+func (e *lsmEngine) Len() int {
+	return e.count
+}
+
+// Compact flushes the memtable and merges every level down until no level
+// exceeds its budget.
+func (e *lsmEngine) Compact() error {
+	if err := e.flush(); err != nil {
+		return err
+	}
+	if len(e.levels[0]) > 0 {
+		if err := e.compact(0); err != nil {
+			return err
+		}
+	}
+	return e.maybeCompact()
+}
+
+func (e *lsmEngine) Close() error {
+	var errs []error
+	if e.wal != nil {
+		errs = append(errs, e.wal.Sync(), e.wal.Close())
+		e.wal = nil
+	}
+	for _, tables := range e.levels {
+		for _, table := range tables {
+			errs = append(errs, table.close())
+		}
+	}
+	if e.unlock != nil {
+		errs = append(errs, e.unlock())
+		e.unlock = nil
+	}
+	return errors.Join(errs...)
+}
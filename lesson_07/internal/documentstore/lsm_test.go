@@ -0,0 +1,357 @@
+package documentstore
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// openSmallLSM opens an engine with tiny budgets so that a few hundred
+// writes exercise flushes and compactions across several levels.
+func openSmallLSM(t *testing.T, dir string) *lsmEngine {
+	t.Helper()
+
+	engine, err := openLSMEngine(dir)
+	require.NoError(t, err)
+	engine.memtableSize = 512
+	engine.l0Trigger = 2
+	engine.levelBaseSize = 2048
+	engine.tableTargetSize = 512
+	return engine
+}
+
+func lsmKey(i int) string {
+	return fmt.Sprintf("event:%04d", i)
+}
+
+func lsmDoc(i, version int) Document {
+	return Document{
+		Fields: map[string]DocumentField{
+			"id":      {Type: DocumentFieldTypeString, Value: lsmKey(i)},
+			"version": {Type: DocumentFieldTypeNumber, Value: int64(version)},
+		},
+	}
+}
+
+func TestBloomFilter(t *testing.T) {
+	bloom := newBloomFilter(100)
+	for i := 0; i < 100; i++ {
+		bloom.add(lsmKey(i))
+	}
+
+	for i := 0; i < 100; i++ {
+		assert.True(t, bloom.mayContain(lsmKey(i)))
+	}
+
+	falsePositives := 0
+	for i := 100; i < 1100; i++ {
+		if bloom.mayContain(lsmKey(i)) {
+			falsePositives++
+		}
+	}
+	assert.Less(t, falsePositives, 50)
+}
+
+func TestSSTable(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "000000001.sst")
+
+	items := []lsmItem{
+		{key: "a", value: []byte("1")},
+		{key: "b", tombstone: true},
+		{key: "c", value: []byte("333")},
+	}
+
+	table, err := writeSSTable(path, 1, items)
+	require.NoError(t, err)
+	defer table.close()
+
+	assert.Equal(t, "a", table.minKey())
+	assert.Equal(t, "c", table.maxKey())
+
+	entry, ok := table.lookup("c")
+	require.True(t, ok)
+	value, err := table.readValue(entry)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("333"), value)
+
+	entry, ok = table.lookup("b")
+	require.True(t, ok)
+	assert.True(t, entry.tombstone)
+
+	_, ok = table.lookup("d")
+	assert.False(t, ok)
+
+	t.Run("detects corruption", func(t *testing.T) {
+		data, err := os.ReadFile(path)
+		require.NoError(t, err)
+		data[0] ^= 0xff
+
+		corrupt := filepath.Join(t.TempDir(), "000000002.sst")
+		require.NoError(t, os.WriteFile(corrupt, data, 0o644))
+
+		_, err = openSSTable(corrupt, 2)
+		assert.ErrorIs(t, err, ErrCorruptDataFile)
+	})
+}
+
+func TestLSMEngine_FlushAndCompact(t *testing.T) {
+	dir := t.TempDir()
+	engine := openSmallLSM(t, dir)
+
+	const n = 300
+	for i := 0; i < n; i++ {
+		require.NoError(t, engine.Put(lsmKey(i), lsmDoc(i, 1)))
+	}
+	for i := 0; i < n; i += 3 {
+		require.NoError(t, engine.Put(lsmKey(i), lsmDoc(i, 2)))
+	}
+	for i := 1; i < n; i += 3 {
+		require.NoError(t, engine.Delete(lsmKey(i)))
+	}
+
+	deeper := 0
+	for _, tables := range engine.levels[1:] {
+		deeper += len(tables)
+	}
+	assert.Positive(t, deeper, "expected tables below level 0")
+	assert.Equal(t, n-n/3, engine.Len())
+
+	check := func(t *testing.T, engine *lsmEngine) {
+		t.Helper()
+
+		for i := 0; i < n; i++ {
+			doc, ok, err := engine.Get(lsmKey(i))
+			require.NoError(t, err)
+			switch i % 3 {
+			case 0:
+				require.True(t, ok, lsmKey(i))
+				assert.Equal(t, int64(2), doc.Fields["version"].Value)
+			case 1:
+				assert.False(t, ok, lsmKey(i))
+			default:
+				require.True(t, ok, lsmKey(i))
+				assert.Equal(t, int64(1), doc.Fields["version"].Value)
+			}
+		}
+
+		var keys []string
+		require.NoError(t, engine.Scan(func(key string, _ Document) bool {
+			keys = append(keys, key)
+			return true
+		}))
+		assert.Len(t, keys, n-n/3)
+		assert.IsIncreasing(t, keys)
+	}
+
+	check(t, engine)
+
+	require.NoError(t, engine.Compact())
+	assert.Empty(t, engine.levels[0])
+	check(t, engine)
+
+	require.NoError(t, engine.Close())
+
+	reopened := openSmallLSM(t, dir)
+	defer reopened.Close()
+
+	assert.Equal(t, n-n/3, reopened.Len())
+	check(t, reopened)
+}
+
+func TestLSMEngine_RecoversFromWAL(t *testing.T) {
+	dir := t.TempDir()
+
+	engine, err := openLSMEngine(dir)
+	require.NoError(t, err)
+	require.NoError(t, engine.Put("a", lsmDoc(1, 1)))
+	require.NoError(t, engine.Put("b", lsmDoc(2, 1)))
+	require.NoError(t, engine.Delete("a"))
+	require.NoError(t, engine.Close())
+
+	wal := filepath.Join(dir, lsmWALFilename)
+	f, err := os.OpenFile(wal, os.O_APPEND|os.O_WRONLY, 0o644)
+	require.NoError(t, err)
+	_, err = f.Write(encodeLogRecord("c", []byte("{}"), 0)[:7])
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	reopened, err := openLSMEngine(dir)
+	require.NoError(t, err)
+	defer reopened.Close()
+
+	assert.Equal(t, 1, reopened.Len())
+	_, ok, err := reopened.Get("a")
+	require.NoError(t, err)
+	assert.False(t, ok)
+	_, ok, err = reopened.Get("b")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	_, ok, err = reopened.Get("c")
+	require.NoError(t, err)
+	assert.False(t, ok)
+}
+
+func TestStore_LSMCollection(t *testing.T) {
+	cfg := &CollectionConfig{PrimaryKey: "id", Engine: EngineLSM, DataDir: t.TempDir()}
+
+	store := NewStore()
+	coll, err := store.CreateCollection("events", cfg)
+	require.NoError(t, err)
+	require.NoError(t, coll.Put(lsmDoc(1, 1)))
+	require.NoError(t, coll.Put(lsmDoc(2, 1)))
+	require.NoError(t, coll.Delete(lsmKey(1)))
+	require.NoError(t, coll.Compact())
+	require.NoError(t, store.Close())
+
+	reopened := NewStore()
+	coll, err = reopened.CreateCollection("events", cfg)
+	require.NoError(t, err)
+	defer reopened.Close()
+
+	docs := coll.List()
+	require.Len(t, docs, 1)
+	assert.Equal(t, lsmKey(2), docs[0].Fields["id"].Value)
+}
+
+const (
+	lsmCrashDirEnv   = "DOCUMENTSTORE_LSM_CRASH_DIR"
+	lsmCrashPointEnv = "DOCUMENTSTORE_LSM_CRASH_POINT"
+	lsmCrashExitCode = 3
+	lsmCrashOps      = 400
+)
+
+// lsmCrashOp describes the i-th operation of the crash workload: every
+// fourth operation deletes a key written shortly before, the rest put.
+func lsmCrashOp(i int) (key string, del bool) {
+	if i%4 == 3 {
+		return lsmKey(i - 2), true
+	}
+	return lsmKey(i), false
+}
+
+// TestLSMCrashHelper is the child process of TestLSMEngine_CrashRecovery.
+// It runs the workload, acknowledging every completed operation on
+// stdout, and exits abruptly when the engine reaches the crash point.
+func TestLSMCrashHelper(t *testing.T) {
+	dir := os.Getenv(lsmCrashDirEnv)
+	point := os.Getenv(lsmCrashPointEnv)
+	if dir == "" || point == "" {
+		t.Skip("crash helper only runs as a child process")
+	}
+
+	lsmCrashHook = func(p string) {
+		if p == point {
+			os.Exit(lsmCrashExitCode)
+		}
+	}
+
+	engine := openSmallLSM(t, dir)
+	for i := 0; i < lsmCrashOps; i++ {
+		key, del := lsmCrashOp(i)
+		var err error
+		if del {
+			err = engine.Delete(key)
+		} else {
+			err = engine.Put(key, lsmDoc(i, i))
+		}
+		if err != nil {
+			fmt.Printf("error %d %v\n", i, err)
+			os.Exit(1)
+		}
+		fmt.Printf("ack %d\n", i)
+	}
+	os.Exit(0)
+}
+
+func TestLSMEngine_CrashRecovery(t *testing.T) {
+	if testing.Short() {
+		t.Skip("spawns child processes")
+	}
+
+	points := []string{
+		lsmPointFlushWritten,
+		lsmPointFlushCommitted,
+		lsmPointCompactionWritten,
+		lsmPointCompactionCommitted,
+	}
+
+	for _, point := range points {
+		t.Run(point, func(t *testing.T) {
+			dir := t.TempDir()
+
+			cmd := exec.Command(os.Args[0], "-test.run=^TestLSMCrashHelper$")
+			cmd.Env = append(os.Environ(), lsmCrashDirEnv+"="+dir, lsmCrashPointEnv+"="+point)
+			var stdout bytes.Buffer
+			cmd.Stdout = &stdout
+
+			err := cmd.Run()
+			var exitErr *exec.ExitError
+			require.ErrorAs(t, err, &exitErr, stdout.String())
+			require.Equal(t, lsmCrashExitCode, exitErr.ExitCode(), stdout.String())
+
+			acked := -1
+			scanner := bufio.NewScanner(&stdout)
+			for scanner.Scan() {
+				if n, ok := strings.CutPrefix(scanner.Text(), "ack "); ok {
+					acked, err = strconv.Atoi(n)
+					require.NoError(t, err)
+				}
+			}
+			require.Less(t, acked, lsmCrashOps-1, "crash point was never reached")
+
+			// The operation in flight was already in the WAL when the engine
+			// started flushing, so it must survive as well.
+			want := make(map[string]int)
+			for i := 0; i <= acked+1; i++ {
+				key, del := lsmCrashOp(i)
+				if del {
+					delete(want, key)
+				} else {
+					want[key] = i
+				}
+			}
+
+			engine := openSmallLSM(t, dir)
+			defer engine.Close()
+
+			assert.Equal(t, len(want), engine.Len())
+
+			got := make(map[string]int)
+			require.NoError(t, engine.Scan(func(key string, doc Document) bool {
+				got[key] = int(doc.Fields["version"].Value.(int64))
+				return true
+			}))
+			assert.Equal(t, want, got)
+
+			entries, err := os.ReadDir(dir)
+			require.NoError(t, err)
+			live := 0
+			for _, tables := range engine.levels {
+				live += len(tables)
+			}
+			tables := 0
+			for _, entry := range entries {
+				assert.False(t, strings.HasSuffix(entry.Name(), ".tmp"), entry.Name())
+				if strings.HasSuffix(entry.Name(), lsmTableSuffix) {
+					tables++
+				}
+			}
+			assert.Equal(t, live, tables, "orphaned tables left behind")
+
+			require.NoError(t, engine.Put(lsmKey(9999), lsmDoc(9999, 1)))
+			require.NoError(t, engine.Compact())
+			_, ok, err := engine.Get(lsmKey(9999))
+			require.NoError(t, err)
+			assert.True(t, ok)
+		})
+	}
+}
@@ -5,6 +5,10 @@ import (
 	"reflect"
 )
 
+// MarshalDocument converts a struct to a document. Fields are named by their
+// json tag, ignoring options such as omitempty, or by their Go name if they
+// have none; unexported fields and fields tagged "-" are skipped, here and in
+// UnmarshalDocument.
 func MarshalDocument(input any) (*Document, error) {
 	v := reflect.ValueOf(input)
 	if v.Kind() == reflect.Pointer {
@@ -25,9 +29,9 @@ func MarshalDocument(input any) (*Document, error) {
 		fieldValue := v.Field(i)
 		fieldType := t.Field(i)
 
-		name := fieldType.Tag.Get("json")
-		if name == "" {
-			name = fieldType.Name
+		name, _, skip := documentFieldName(fieldType)
+		if skip {
+			continue
 		}
 
 		switch fieldValue.Kind() {
@@ -65,9 +69,9 @@ func UnmarshalDocument(doc *Document, output any) error {
 
 	for i := 0; i < v.NumField(); i++ {
 		fieldType := t.Field(i)
-		name := fieldType.Tag.Get("json")
-		if name == "" {
-			name = fieldType.Name
+		name, _, skip := documentFieldName(fieldType)
+		if skip {
+			continue
 		}
 
 		docField, exists := doc.Fields[name]
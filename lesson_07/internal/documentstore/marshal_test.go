@@ -14,6 +14,17 @@ type TestStruct struct {
 	Active bool   `json:"active"`
 }
 
+// TaggedStruct covers how fields are named: by the name part of the json
+// tag, by the Go name without one, and skipped when tagged "-" or
+// unexported.
+type TaggedStruct struct {
+	ID      string `json:"id"`
+	Nick    string `json:"nick,omitempty"`
+	Note    string `json:"-"`
+	Plain   bool
+	private string
+}
+
 func TestMarshalDocument(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -50,6 +61,17 @@ func TestMarshalDocument(t *testing.T) {
 				assert.Equal(t, "user:2", doc.Fields["id"].Value)
 			},
 		},
+		{
+			name:  "names fields by json tag and skips hidden ones",
+			input: TaggedStruct{ID: "user:3", Nick: "al", Note: "n", Plain: true, private: "p"},
+			check: func(t *testing.T, doc *Document) {
+				assert.Equal(t, map[string]DocumentField{
+					"id":    {Type: DocumentFieldTypeString, Value: "user:3"},
+					"nick":  {Type: DocumentFieldTypeString, Value: "al"},
+					"Plain": {Type: DocumentFieldTypeBool, Value: true},
+				}, doc.Fields)
+			},
+		},
 		{
 			name:    "returns error for non-struct input",
 			input:   "not a struct",
@@ -104,6 +126,23 @@ func TestUnmarshalDocument(t *testing.T) {
 				assert.Equal(t, true, result.Active)
 			},
 		},
+		{
+			name: "names fields by json tag and skips hidden ones",
+			doc: &Document{
+				Fields: map[string]DocumentField{
+					"id":      {Type: DocumentFieldTypeString, Value: "user:3"},
+					"nick":    {Type: DocumentFieldTypeString, Value: "al"},
+					"Plain":   {Type: DocumentFieldTypeBool, Value: true},
+					"-":       {Type: DocumentFieldTypeString, Value: "n"},
+					"Note":    {Type: DocumentFieldTypeString, Value: "n"},
+					"private": {Type: DocumentFieldTypeString, Value: "p"},
+				},
+			},
+			output: &TaggedStruct{},
+			check: func(t *testing.T, output any) {
+				assert.Equal(t, &TaggedStruct{ID: "user:3", Nick: "al", Plain: true}, output)
+			},
+		},
 		{
 			name:    "returns error when output is not a pointer",
 			doc:     &Document{Fields: map[string]DocumentField{}},
@@ -0,0 +1,184 @@
+package documentstore
+
+import (
+	"maps"
+	"sort"
+	"time"
+)
+
+type StoreMetadata struct {
+	CreatedAt  time.Time         `json:"created_at"`
+	LastDumpAt time.Time         `json:"last_dump_at,omitzero"`
+	Labels     map[string]string `json:"labels,omitempty"`
+	Quota      *Quota            `json:"quota,omitempty"`
+}
+
+// CollectionMetadata is the extensible per-collection section of a dump.
+// New knowledge a collection gains should be added here rather than to
+// CollectionConfig, which only describes how the collection behaves.
+type CollectionMetadata struct {
+	CreatedAt     time.Time         `json:"created_at"`
+	DocumentCount int               `json:"document_count"`
+	Options       map[string]string `json:"options,omitempty"`
+	// SchemaVersion is the last migration fully applied.
+	SchemaVersion int `json:"schema_version,omitempty"`
+	// Migration records an interrupted migration so it can be resumed.
+	Migration *MigrationState `json:"migration,omitempty"`
+	// Sequence is the last key issued by KeyGenSequence.
+	Sequence uint64 `json:"sequence,omitempty"`
+}
+
+type StoreInfo struct {
+	CreatedAt   time.Time
+	LastDumpAt  time.Time
+	Labels      map[string]string
+	Collections []CollectionInfo
+}
+
+type CollectionInfo struct {
+	Name          string
+	Config        CollectionConfig
+	CreatedAt     time.Time
+	DocumentCount int
+	Options       map[string]string
+	SchemaVersion int
+	Usage         Usage
+}
+
+func (s *Store) Info() StoreInfo {
+	info := StoreInfo{
+		CreatedAt:   s.createdAt,
+		LastDumpAt:  s.lastDumpAt,
+		Labels:      maps.Clone(s.labels),
+		Collections: make([]CollectionInfo, 0, len(s.collections)),
+	}
+
+	for name, coll := range s.collections {
+		info.Collections = append(info.Collections, coll.info(name))
+	}
+
+	sort.Slice(info.Collections, func(i, j int) bool {
+		return info.Collections[i].Name < info.Collections[j].Name
+	})
+
+	return info
+}
+
+func (s *Store) SetLabel(key, value string) {
+	s.labels[key] = value
+	s.logger.Info("store label set", "label", key, "value", value)
+}
+
+func (s *Store) DeleteLabel(key string) {
+	delete(s.labels, key)
+	s.logger.Info("store label deleted", "label", key)
+}
+
+func (s *Store) metadata() StoreMetadata {
+	md := StoreMetadata{
+		CreatedAt:  s.createdAt,
+		LastDumpAt: s.lastDumpAt,
+		Labels:     maps.Clone(s.labels),
+	}
+	if q := s.quota.stats().Quota; q != (Quota{}) {
+		md.Quota = &q
+	}
+	return md
+}
+
+func (s *Store) restoreMetadata(md StoreMetadata) {
+	if !md.CreatedAt.IsZero() {
+		s.createdAt = md.CreatedAt
+	}
+	s.lastDumpAt = md.LastDumpAt
+	if md.Labels != nil {
+		s.labels = maps.Clone(md.Labels)
+	}
+	if md.Quota != nil {
+		s.quota.setQuota(*md.Quota)
+	}
+}
+
+func (c *Collection) Info() CollectionInfo {
+	return c.info(c.name)
+}
+
+func (c *Collection) info(name string) CollectionInfo {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return CollectionInfo{
+		Name:          name,
+		Config:        c.cfg,
+		CreatedAt:     c.createdAt,
+		DocumentCount: c.engine.Len(),
+		Options:       maps.Clone(c.options),
+		SchemaVersion: c.schemaVersion,
+		Usage:         c.quota.stats().Usage,
+	}
+}
+
+// SetOption stores an application-defined option that is persisted in
+// dumps alongside the collection.
+func (c *Collection) SetOption(key, value string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.options[key] = value
+	c.logger.Info("collection option set", "collection", c.name, "option", key, "value", value)
+}
+
+func (c *Collection) Option(key string) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	value, ok := c.options[key]
+	return value, ok
+}
+
+func (c *Collection) metadata(documentCount int) CollectionMetadata {
+	return CollectionMetadata{
+		CreatedAt:     c.createdAt,
+		DocumentCount: documentCount,
+		Options:       maps.Clone(c.options),
+		SchemaVersion: c.schemaVersion,
+		Migration:     c.migration.clone(),
+		Sequence:      c.sequence,
+	}
+}
+
+func (c *Collection) restoreMetadata(md CollectionMetadata) {
+	if !md.CreatedAt.IsZero() {
+		c.createdAt = md.CreatedAt
+	}
+	if md.Options != nil {
+		c.options = maps.Clone(md.Options)
+	}
+	c.schemaVersion = md.SchemaVersion
+	c.migration = md.Migration.clone()
+	c.advanceSequence(md.Sequence)
+}
+
+// upgradeDumpV1 adds the metadata sections introduced in version 2.
+// Creation times are unknown for older dumps and stay zero.
+func upgradeDumpV1(dump map[string]any) error {
+	if _, ok := dump["metadata"]; !ok {
+		dump["metadata"] = map[string]any{}
+	}
+
+	collections, _ := dump["collections"].(map[string]any)
+	for _, c := range collections {
+		coll, ok := c.(map[string]any)
+		if !ok {
+			continue
+		}
+		if _, ok := coll["metadata"]; ok {
+			continue
+		}
+
+		docs, _ := coll["documents"].([]any)
+		coll["metadata"] = map[string]any{"document_count": len(docs)}
+	}
+
+	return nil
+}
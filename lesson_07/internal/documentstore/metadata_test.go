@@ -0,0 +1,89 @@
+package documentstore
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStore_Info(t *testing.T) {
+	store := NewStore()
+	store.SetLabel("env", "test")
+	store.SetLabel("owner", "team-a")
+	store.DeleteLabel("owner")
+
+	usersColl, err := store.CreateCollection("users", &CollectionConfig{PrimaryKey: "id"})
+	require.NoError(t, err)
+	require.NoError(t, usersColl.Put(userDoc("user:1", "Alice")))
+	usersColl.SetOption("retention", "30d")
+
+	_, err = store.CreateCollection("audit", &CollectionConfig{PrimaryKey: "id"})
+	require.NoError(t, err)
+
+	info := store.Info()
+
+	assert.False(t, info.CreatedAt.IsZero())
+	assert.True(t, info.LastDumpAt.IsZero())
+	assert.Equal(t, map[string]string{"env": "test"}, info.Labels)
+	require.Len(t, info.Collections, 2)
+	assert.Equal(t, "audit", info.Collections[0].Name)
+	assert.Equal(t, "users", info.Collections[1].Name)
+	assert.Equal(t, 1, info.Collections[1].DocumentCount)
+	assert.Equal(t, map[string]string{"retention": "30d"}, info.Collections[1].Options)
+	assert.Equal(t, "id", info.Collections[1].Config.PrimaryKey)
+}
+
+func TestStore_Info_SurvivesDump(t *testing.T) {
+	store := NewStore()
+	store.createdAt = time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	store.SetLabel("env", "prod")
+
+	coll, err := store.CreateCollection("users", &CollectionConfig{PrimaryKey: "id"})
+	require.NoError(t, err)
+	coll.createdAt = time.Date(2024, 2, 3, 4, 5, 6, 0, time.UTC)
+	coll.SetOption("owner", "billing")
+	require.NoError(t, coll.Put(userDoc("user:1", "Alice")))
+
+	data, err := store.Dump()
+	require.NoError(t, err)
+	assert.False(t, store.Info().LastDumpAt.IsZero())
+
+	restored, err := NewStoreFromDump(data)
+	require.NoError(t, err)
+
+	info := restored.Info()
+	assert.True(t, store.createdAt.Equal(info.CreatedAt))
+	assert.True(t, store.lastDumpAt.Equal(info.LastDumpAt))
+	assert.Equal(t, map[string]string{"env": "prod"}, info.Labels)
+
+	require.Len(t, info.Collections, 1)
+	assert.True(t, coll.createdAt.Equal(info.Collections[0].CreatedAt))
+	assert.Equal(t, 1, info.Collections[0].DocumentCount)
+
+	restoredColl, err := restored.GetCollection("users")
+	require.NoError(t, err)
+	owner, ok := restoredColl.Option("owner")
+	assert.True(t, ok)
+	assert.Equal(t, "billing", owner)
+}
+
+func TestNewStoreFromDump_UpgradesVersion1Metadata(t *testing.T) {
+	dump := `{"version":1,"collections":{"users":{"config":{"PrimaryKey":"id"},"documents":[` +
+		`{"Fields":{"id":{"Type":"string","Value":"user:1"}}},` +
+		`{"Fields":{"id":{"Type":"string","Value":"user:2"}}}]}}}`
+
+	storeDump, err := decodeDump([]byte(dump))
+	require.NoError(t, err)
+	assert.Equal(t, DumpFormatVersion, storeDump.Version)
+	assert.Equal(t, 2, storeDump.Collections["users"].Metadata.DocumentCount)
+
+	store, err := NewStoreFromDump([]byte(dump))
+	require.NoError(t, err)
+
+	info := store.Info()
+	require.Len(t, info.Collections, 1)
+	assert.Equal(t, 2, info.Collections[0].DocumentCount)
+	assert.Empty(t, info.Labels)
+}
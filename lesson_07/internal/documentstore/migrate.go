@@ -0,0 +1,225 @@
+package documentstore
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"slices"
+)
+
+const defaultMigrationBatchSize = 100
+
+// Migration upgrades documents to Version. Up may change any field except
+// the primary key; documents it leaves unchanged are not rewritten.
+type Migration struct {
+	Version int
+	Name    string
+	Up      func(doc *Document) error
+}
+
+// MigrationState is the position of an unfinished migration.
+type MigrationState struct {
+	Version   int    `json:"version"`
+	LastKey   string `json:"last_key"`
+	Processed int    `json:"processed"`
+}
+
+func (s *MigrationState) clone() *MigrationState {
+	if s == nil {
+		return nil
+	}
+	clone := *s
+	return &clone
+}
+
+type MigrationProgress struct {
+	Version   int
+	Name      string
+	Processed int
+	Total     int
+}
+
+type MigrateOptions struct {
+	// BatchSize is the number of documents migrated per lock acquisition.
+	// Defaults to 100.
+	BatchSize int
+	// TargetVersion stops after this version; zero applies every
+	// registered migration.
+	TargetVersion int
+	// Progress is called after every batch.
+	Progress func(MigrationProgress)
+}
+
+type MigrationReport struct {
+	FromVersion int
+	ToVersion   int
+	Scanned     int
+	Migrated    int
+}
+
+// RegisterMigration adds a migration. Versions must be positive and
+// unique; migrations at or below the applied version are never run.
+func (c *Collection) RegisterMigration(m Migration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if m.Version <= 0 || m.Up == nil {
+		return fmt.Errorf("%w: version %d", ErrInvalidMigration, m.Version)
+	}
+	if slices.ContainsFunc(c.migrations, func(other Migration) bool { return other.Version == m.Version }) {
+		return fmt.Errorf("%w: duplicate version %d", ErrInvalidMigration, m.Version)
+	}
+
+	c.migrations = append(c.migrations, m)
+	slices.SortFunc(c.migrations, func(a, b Migration) int { return a.Version - b.Version })
+	return nil
+}
+
+func (c *Collection) SchemaVersion() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.schemaVersion
+}
+
+// Migrate runs pending migrations in version order. Documents are migrated
+// in key order, one batch at a time, so the collection stays usable in
+// between. If Migrate stops early, because of ctx or a failing document,
+// the next call resumes after the last migrated document.
+func (c *Collection) Migrate(ctx context.Context, opts MigrateOptions) (*MigrationReport, error) {
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = defaultMigrationBatchSize
+	}
+
+	c.mu.Lock()
+	if c.migrating {
+		c.mu.Unlock()
+		return nil, ErrMigrationInProgress
+	}
+	c.migrating = true
+
+	report := &MigrationReport{FromVersion: c.schemaVersion, ToVersion: c.schemaVersion}
+	var pending []Migration
+	for _, m := range c.migrations {
+		if m.Version > c.schemaVersion && (opts.TargetVersion == 0 || m.Version <= opts.TargetVersion) {
+			pending = append(pending, m)
+		}
+	}
+	c.mu.Unlock()
+
+	defer func() {
+		c.mu.Lock()
+		c.migrating = false
+		c.mu.Unlock()
+	}()
+
+	for _, m := range pending {
+		c.logger.Info("migration started", "collection", c.name, "version", m.Version, "name", m.Name)
+		if err := c.runMigration(ctx, m, opts, report); err != nil {
+			c.logger.Error("migration stopped", "collection", c.name, "version", m.Version, "error", err)
+			return report, err
+		}
+		report.ToVersion = m.Version
+		c.logger.Info("migration applied", "collection", c.name, "version", m.Version, "name", m.Name)
+	}
+
+	return report, nil
+}
+
+func (c *Collection) runMigration(ctx context.Context, m Migration, opts MigrateOptions, report *MigrationReport) error {
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		progress, done, err := c.migrateBatch(m, opts.BatchSize, report)
+		if err != nil {
+			return err
+		}
+		if opts.Progress != nil {
+			opts.Progress(progress)
+		}
+		if done {
+			return nil
+		}
+	}
+}
+
+func (c *Collection) migrateBatch(m Migration, batchSize int, report *MigrationReport) (MigrationProgress, bool, error) {
+	defer c.events.wait(c.name)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.migration == nil || c.migration.Version != m.Version {
+		c.migration = &MigrationState{Version: m.Version}
+	}
+	state := c.migration
+
+	type entry struct {
+		key string
+		doc Document
+	}
+	// Resume at the smallest key after LastKey.
+	start := ""
+	if state.LastKey != "" {
+		start = state.LastKey + "\x00"
+	}
+
+	batch := make([]entry, 0, batchSize)
+	err := c.engine.ScanFrom(start, func(key string, doc Document) bool {
+		batch = append(batch, entry{key: key, doc: doc})
+		return len(batch) < batchSize
+	})
+	if err != nil {
+		return MigrationProgress{}, false, err
+	}
+
+	for _, e := range batch {
+		if !c.expired(e.key) {
+			changed, err := c.migrateDocument(m, e.key, e.doc)
+			if err != nil {
+				return MigrationProgress{}, false, fmt.Errorf("%w: version %d, key %q: %w", ErrMigrationFailed, m.Version, e.key, err)
+			}
+			if changed {
+				report.Migrated++
+			}
+		}
+		report.Scanned++
+		state.LastKey = e.key
+		state.Processed++
+	}
+
+	progress := MigrationProgress{
+		Version:   m.Version,
+		Name:      m.Name,
+		Processed: state.Processed,
+		Total:     c.engine.Len(),
+	}
+
+	done := len(batch) < batchSize
+	if done {
+		c.schemaVersion = m.Version
+		c.migration = nil
+	}
+	return progress, done, nil
+}
+
+func (c *Collection) migrateDocument(m Migration, key string, doc Document) (bool, error) {
+	migrated := cloneDocument(&doc)
+	if err := m.Up(migrated); err != nil {
+		return false, err
+	}
+	if reflect.DeepEqual(doc, *migrated) {
+		return false, nil
+	}
+
+	if newKey, err := c.primaryKey(*migrated); err != nil || newKey != key {
+		return false, ErrInvalidPrimaryKey
+	}
+
+	opts := putOptions{}
+	if at, ok := c.expiries[key]; ok {
+		opts.expiresAt = at
+	}
+	return true, c.put(*migrated, opts)
+}
@@ -0,0 +1,214 @@
+package documentstore
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func addFieldMigration(version int, field string, value any, seen *[]string) Migration {
+	return Migration{
+		Version: version,
+		Name:    "add " + field,
+		Up: func(doc *Document) error {
+			if seen != nil {
+				*seen = append(*seen, doc.Fields["id"].Value.(string))
+			}
+			if _, ok := doc.Fields[field]; !ok {
+				doc.Fields[field] = DocumentField{Type: DocumentFieldTypeString, Value: value}
+			}
+			return nil
+		},
+	}
+}
+
+func migrationCollection(t *testing.T, keys ...string) *Collection {
+	t.Helper()
+
+	coll := NewCollection(CollectionConfig{PrimaryKey: "id"})
+	t.Cleanup(func() { coll.Close() })
+	for _, key := range keys {
+		require.NoError(t, coll.Put(userDoc(key, key)))
+	}
+	return coll
+}
+
+func TestCollection_Migrate(t *testing.T) {
+	coll := migrationCollection(t, "a", "b", "c", "d", "e")
+	require.NoError(t, coll.Put(Document{Fields: map[string]DocumentField{
+		"id":   {Type: DocumentFieldTypeString, Value: "f"},
+		"role": {Type: DocumentFieldTypeString, Value: "admin"},
+	}}))
+
+	require.NoError(t, coll.RegisterMigration(addFieldMigration(2, "email", "", nil)))
+	require.NoError(t, coll.RegisterMigration(addFieldMigration(1, "role", "member", nil)))
+
+	var progress []MigrationProgress
+	report, err := coll.Migrate(context.Background(), MigrateOptions{
+		BatchSize: 4,
+		Progress:  func(p MigrationProgress) { progress = append(progress, p) },
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, &MigrationReport{FromVersion: 0, ToVersion: 2, Scanned: 12, Migrated: 11}, report)
+	assert.Equal(t, []MigrationProgress{
+		{Version: 1, Name: "add role", Processed: 4, Total: 6},
+		{Version: 1, Name: "add role", Processed: 6, Total: 6},
+		{Version: 2, Name: "add email", Processed: 4, Total: 6},
+		{Version: 2, Name: "add email", Processed: 6, Total: 6},
+	}, progress)
+	assert.Equal(t, 2, coll.SchemaVersion())
+	assert.Equal(t, 2, coll.Info().SchemaVersion)
+
+	for _, doc := range coll.List() {
+		assert.Contains(t, doc.Fields, "email")
+	}
+	f, err := coll.Get("f")
+	require.NoError(t, err)
+	assert.Equal(t, "admin", f.Fields["role"].Value)
+
+	// Nothing is pending once every migration has been applied.
+	report, err = coll.Migrate(context.Background(), MigrateOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, &MigrationReport{FromVersion: 2, ToVersion: 2}, report)
+}
+
+func TestCollection_MigrateTargetVersion(t *testing.T) {
+	coll := migrationCollection(t, "a")
+	require.NoError(t, coll.RegisterMigration(addFieldMigration(1, "role", "member", nil)))
+	require.NoError(t, coll.RegisterMigration(addFieldMigration(2, "email", "", nil)))
+
+	_, err := coll.Migrate(context.Background(), MigrateOptions{TargetVersion: 1})
+	require.NoError(t, err)
+	assert.Equal(t, 1, coll.SchemaVersion())
+
+	doc, err := coll.Get("a")
+	require.NoError(t, err)
+	assert.NotContains(t, doc.Fields, "email")
+}
+
+func TestCollection_MigrateResumesAfterFailure(t *testing.T) {
+	coll := migrationCollection(t, "a", "b", "c", "d")
+
+	errBadDocument := errors.New("bad document")
+	broken := true
+	var seen []string
+	require.NoError(t, coll.RegisterMigration(Migration{
+		Version: 1,
+		Up: func(doc *Document) error {
+			key := doc.Fields["id"].Value.(string)
+			seen = append(seen, key)
+			if key == "c" && broken {
+				return errBadDocument
+			}
+			doc.Fields["migrated"] = DocumentField{Type: DocumentFieldTypeBool, Value: true}
+			return nil
+		},
+	}))
+
+	_, err := coll.Migrate(context.Background(), MigrateOptions{BatchSize: 1})
+	assert.ErrorIs(t, err, ErrMigrationFailed)
+	assert.ErrorIs(t, err, errBadDocument)
+	assert.Equal(t, 0, coll.SchemaVersion())
+	assert.Equal(t, &MigrationState{Version: 1, LastKey: "b", Processed: 2}, coll.metadata(0).Migration)
+
+	broken = false
+	report, err := coll.Migrate(context.Background(), MigrateOptions{BatchSize: 1})
+	require.NoError(t, err)
+	assert.Equal(t, 2, report.Migrated)
+	assert.Equal(t, []string{"a", "b", "c", "c", "d"}, seen)
+	assert.Equal(t, 1, coll.SchemaVersion())
+	assert.Nil(t, coll.metadata(0).Migration)
+}
+
+func TestCollection_MigrateCancelled(t *testing.T) {
+	coll := migrationCollection(t, "a", "b", "c")
+	require.NoError(t, coll.RegisterMigration(addFieldMigration(1, "role", "member", nil)))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	_, err := coll.Migrate(ctx, MigrateOptions{
+		BatchSize: 2,
+		Progress:  func(MigrationProgress) { cancel() },
+	})
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, 0, coll.SchemaVersion())
+
+	var seen []string
+	coll.migrations[0] = addFieldMigration(1, "role", "member", &seen)
+	_, err = coll.Migrate(context.Background(), MigrateOptions{BatchSize: 2})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"c"}, seen)
+}
+
+func TestCollection_MigrateMayNotChangePrimaryKey(t *testing.T) {
+	coll := migrationCollection(t, "a")
+	require.NoError(t, coll.RegisterMigration(Migration{
+		Version: 1,
+		Up: func(doc *Document) error {
+			doc.Fields["id"] = DocumentField{Type: DocumentFieldTypeString, Value: "z"}
+			return nil
+		},
+	}))
+
+	_, err := coll.Migrate(context.Background(), MigrateOptions{})
+	assert.ErrorIs(t, err, ErrInvalidPrimaryKey)
+	assert.Equal(t, []string{"a"}, keysOf(coll.List()))
+}
+
+func TestCollection_RegisterMigrationErrors(t *testing.T) {
+	coll := migrationCollection(t)
+	require.NoError(t, coll.RegisterMigration(addFieldMigration(1, "role", "member", nil)))
+
+	tests := []struct {
+		name string
+		m    Migration
+	}{
+		{name: "zero version", m: addFieldMigration(0, "role", "member", nil)},
+		{name: "duplicate version", m: addFieldMigration(1, "email", "", nil)},
+		{name: "missing func", m: Migration{Version: 2}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.ErrorIs(t, coll.RegisterMigration(tt.m), ErrInvalidMigration)
+		})
+	}
+}
+
+func TestStore_DumpKeepsMigrationState(t *testing.T) {
+	store := NewStore()
+	coll, err := store.CreateCollection("users", &CollectionConfig{PrimaryKey: "id"})
+	require.NoError(t, err)
+	for _, key := range []string{"a", "b", "c"} {
+		require.NoError(t, coll.Put(userDoc(key, key)))
+	}
+
+	require.NoError(t, coll.RegisterMigration(addFieldMigration(1, "role", "member", nil)))
+	_, err = coll.Migrate(context.Background(), MigrateOptions{})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	require.NoError(t, coll.RegisterMigration(addFieldMigration(2, "email", "", nil)))
+	_, err = coll.Migrate(ctx, MigrateOptions{BatchSize: 1, Progress: func(MigrationProgress) { cancel() }})
+	require.ErrorIs(t, err, context.Canceled)
+
+	data, err := store.Dump()
+	require.NoError(t, err)
+
+	restored, err := NewStoreFromDump(data)
+	require.NoError(t, err)
+	restoredColl, err := restored.GetCollection("users")
+	require.NoError(t, err)
+
+	assert.Equal(t, 1, restoredColl.SchemaVersion())
+
+	var seen []string
+	require.NoError(t, restoredColl.RegisterMigration(addFieldMigration(2, "email", "", &seen)))
+	_, err = restoredColl.Migrate(context.Background(), MigrateOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"b", "c"}, seen)
+	assert.Equal(t, 2, restoredColl.SchemaVersion())
+}
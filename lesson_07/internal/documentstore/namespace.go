@@ -0,0 +1,68 @@
+package documentstore
+
+import (
+	"maps"
+	"slices"
+)
+
+// Namespace returns the store scoped to the named namespace, creating it on
+// first use. A namespace has its own collections, change stream and dumps,
+// so callers holding it can never see collections of the root store or of
+// another namespace. Namespaces cannot be nested.
+func (s *Store) Namespace(name string) (*Store, error) {
+	if name == "" || s.namespace != "" {
+		s.logger.Warn("invalid namespace", "namespace", name, "parent", s.namespace)
+		return nil, ErrInvalidNamespace
+	}
+
+	if ns, ok := s.namespaces[name]; ok {
+		return ns, nil
+	}
+
+	if s.changes != nil {
+		if err := s.changes.append(ChangeRecord{Op: ChangeOpCreateNamespace, Namespace: name}); err != nil {
+			s.logger.Error("failed to create namespace: change log write failed", "namespace", name, "error", err)
+			return nil, err
+		}
+	}
+
+	ns := NewStoreWithLogger(s.logger.With("namespace", name))
+	ns.namespace = name
+	ns.quota.scope = name
+	ns.changes = s.changes
+	ns.scratch = s.scratch
+	ns.now = s.now
+	s.namespaces[name] = ns
+
+	s.logger.Info("namespace created", "namespace", name)
+	return ns, nil
+}
+
+// Namespaces returns the names of all namespaces, sorted.
+func (s *Store) Namespaces() []string {
+	return slices.Sorted(maps.Keys(s.namespaces))
+}
+
+// DeleteNamespace drops a namespace together with all of its collections.
+func (s *Store) DeleteNamespace(name string) error {
+	ns, ok := s.namespaces[name]
+	if !ok {
+		s.logger.Warn("failed to delete namespace: not found", "namespace", name)
+		return ErrNamespaceNotFound
+	}
+
+	if s.changes != nil {
+		if err := s.changes.append(ChangeRecord{Op: ChangeOpDeleteNamespace, Namespace: name}); err != nil {
+			s.logger.Error("failed to delete namespace: change log write failed", "namespace", name, "error", err)
+			return err
+		}
+	}
+
+	delete(s.namespaces, name)
+	if err := ns.Close(); err != nil {
+		s.logger.Error("failed to close deleted namespace", "namespace", name, "error", err)
+	}
+
+	s.logger.Info("namespace deleted", "namespace", name)
+	return nil
+}
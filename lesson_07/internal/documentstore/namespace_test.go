@@ -0,0 +1,150 @@
+package documentstore
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func tenantStore(t *testing.T, root *Store, name, userID string) *Store {
+	t.Helper()
+
+	ns, err := root.Namespace(name)
+	require.NoError(t, err)
+	coll, err := ns.CreateCollection("users", &CollectionConfig{PrimaryKey: "id"})
+	require.NoError(t, err)
+	require.NoError(t, coll.Put(Document{Fields: map[string]DocumentField{
+		"id": {Type: DocumentFieldTypeString, Value: userID},
+	}}))
+	return ns
+}
+
+func collectionNames(s *Store) []string {
+	var names []string
+	for _, ci := range s.Info().Collections {
+		names = append(names, ci.Name)
+	}
+	return names
+}
+
+func TestStore_Namespace_Isolation(t *testing.T) {
+	root := NewStore()
+	defer root.Close()
+
+	acme := tenantStore(t, root, "acme", "alice")
+	globex := tenantStore(t, root, "globex", "bob")
+	_, err := globex.CreateCollection("orders", &CollectionConfig{PrimaryKey: "id"})
+	require.NoError(t, err)
+
+	same, err := root.Namespace("acme")
+	require.NoError(t, err)
+	assert.Same(t, acme, same)
+
+	assert.Equal(t, []string{"users"}, collectionNames(acme))
+	assert.Equal(t, []string{"orders", "users"}, collectionNames(globex))
+	assert.Empty(t, collectionNames(root))
+	assert.Equal(t, []string{"acme", "globex"}, root.Namespaces())
+
+	_, err = acme.GetCollection("orders")
+	assert.ErrorIs(t, err, ErrCollectionNotFound)
+	_, err = root.GetCollection("users")
+	assert.ErrorIs(t, err, ErrCollectionNotFound)
+
+	acmeUsers, err := acme.GetCollection("users")
+	require.NoError(t, err)
+	_, err = acmeUsers.Get("bob")
+	assert.ErrorIs(t, err, ErrDocumentNotFound)
+	_, err = acmeUsers.Get("alice")
+	assert.NoError(t, err)
+}
+
+func TestStore_Namespace_Errors(t *testing.T) {
+	root := NewStore()
+	defer root.Close()
+
+	_, err := root.Namespace("")
+	assert.ErrorIs(t, err, ErrInvalidNamespace)
+
+	ns, err := root.Namespace("acme")
+	require.NoError(t, err)
+	_, err = ns.Namespace("nested")
+	assert.ErrorIs(t, err, ErrInvalidNamespace)
+
+	assert.ErrorIs(t, root.DeleteNamespace("globex"), ErrNamespaceNotFound)
+}
+
+func TestStore_DeleteNamespace(t *testing.T) {
+	root := NewStore()
+	defer root.Close()
+
+	tenantStore(t, root, "acme", "alice")
+	tenantStore(t, root, "globex", "bob")
+
+	require.NoError(t, root.DeleteNamespace("acme"))
+	assert.Equal(t, []string{"globex"}, root.Namespaces())
+
+	acme, err := root.Namespace("acme")
+	require.NoError(t, err)
+	assert.Empty(t, collectionNames(acme))
+}
+
+func TestStore_Namespace_DumpAndRestore(t *testing.T) {
+	root := NewStore()
+	defer root.Close()
+
+	acme := tenantStore(t, root, "acme", "alice")
+	tenantStore(t, root, "globex", "bob")
+	_, err := root.CreateCollection("settings", &CollectionConfig{PrimaryKey: "id"})
+	require.NoError(t, err)
+
+	t.Run("namespace dump holds only its collections", func(t *testing.T) {
+		data, err := acme.Dump()
+		require.NoError(t, err)
+
+		restored, err := NewStoreFromDump(data)
+		require.NoError(t, err)
+		defer restored.Close()
+
+		assert.Equal(t, []string{"users"}, collectionNames(restored))
+		assert.Empty(t, restored.Namespaces())
+	})
+
+	t.Run("restores into a namespace", func(t *testing.T) {
+		data, err := acme.Dump()
+		require.NoError(t, err)
+
+		fresh := NewStore()
+		defer fresh.Close()
+		ns, err := fresh.Namespace("acme-copy")
+		require.NoError(t, err)
+		_, err = ns.CreateCollection("users", &CollectionConfig{PrimaryKey: "id"})
+		require.NoError(t, err)
+
+		report, err := ns.RestoreFromDump(data, RestoreOptions{})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"alice"}, report.Restored["users"])
+		assert.Empty(t, collectionNames(fresh))
+	})
+
+	t.Run("root dump round trips namespaces", func(t *testing.T) {
+		data, err := root.Dump()
+		require.NoError(t, err)
+
+		restored, err := NewStoreFromDump(data)
+		require.NoError(t, err)
+		defer restored.Close()
+
+		assert.Equal(t, []string{"settings"}, collectionNames(restored))
+		assert.Equal(t, []string{"acme", "globex"}, restored.Namespaces())
+
+		globex, err := restored.Namespace("globex")
+		require.NoError(t, err)
+		users, err := globex.GetCollection("users")
+		require.NoError(t, err)
+		_, err = users.Get("bob")
+		assert.NoError(t, err)
+		_, err = users.Get("alice")
+		assert.ErrorIs(t, err, ErrDocumentNotFound)
+	})
+}
@@ -0,0 +1,186 @@
+package documentstore
+
+import (
+	"fmt"
+	"sync"
+)
+
+// Quota limits what a collection, or all collections of a store or
+// namespace together, may hold. Zero limits are ignored.
+type Quota struct {
+	MaxDocuments int `json:",omitempty"`
+	// MaxBytes bounds the approximate encoded size of all documents.
+	MaxBytes         int64 `json:",omitempty"`
+	MaxDocumentBytes int64 `json:",omitempty"`
+	MaxFields        int   `json:",omitempty"`
+}
+
+// Usage is what is currently counted against a quota.
+type Usage struct {
+	Documents int   `json:"documents"`
+	Bytes     int64 `json:"bytes"`
+}
+
+func (u Usage) negate() Usage {
+	return Usage{Documents: -u.Documents, Bytes: -u.Bytes}
+}
+
+type QuotaStats struct {
+	Quota Quota `json:"quota"`
+	Usage Usage `json:"usage"`
+}
+
+// QuotaError reports the limit a write would have exceeded. It matches
+// ErrQuotaExceeded with errors.Is.
+type QuotaError struct {
+	// Scope is the collection or namespace the quota belongs to; it is
+	// empty for the root store.
+	Scope string
+	Limit string
+	Max   int64
+	Got   int64
+}
+
+func (e *QuotaError) Error() string {
+	scope := "store"
+	if e.Scope != "" {
+		scope = fmt.Sprintf("%q", e.Scope)
+	}
+	return fmt.Sprintf("%s: %s %s %d exceeds %d", ErrQuotaExceeded, scope, e.Limit, e.Got, e.Max)
+}
+
+func (e *QuotaError) Unwrap() error {
+	return ErrQuotaExceeded
+}
+
+func validQuota(q Quota) error {
+	if q.MaxDocuments < 0 || q.MaxBytes < 0 || q.MaxDocumentBytes < 0 || q.MaxFields < 0 {
+		return fmt.Errorf("%w: negative limit", ErrInvalidQuota)
+	}
+	return nil
+}
+
+// quotaTracker counts usage against a quota. A store shares its tracker
+// with all of its collections, so it has its own lock.
+type quotaTracker struct {
+	mu    sync.Mutex
+	scope string
+	quota Quota
+	usage Usage
+}
+
+// reserve adds delta to the usage of t unless that, or the document being
+// written, breaks the quota. Shrinking usage is always allowed.
+func (t *quotaTracker) reserve(doc Document, size int64, delta Usage) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	q := t.quota
+	switch {
+	case q.MaxFields > 0 && len(doc.Fields) > q.MaxFields:
+		return &QuotaError{Scope: t.scope, Limit: "fields", Max: int64(q.MaxFields), Got: int64(len(doc.Fields))}
+	case q.MaxDocumentBytes > 0 && size > q.MaxDocumentBytes:
+		return &QuotaError{Scope: t.scope, Limit: "document bytes", Max: q.MaxDocumentBytes, Got: size}
+	case q.MaxDocuments > 0 && delta.Documents > 0 && t.usage.Documents+delta.Documents > q.MaxDocuments:
+		return &QuotaError{Scope: t.scope, Limit: "documents", Max: int64(q.MaxDocuments), Got: int64(t.usage.Documents + delta.Documents)}
+	case q.MaxBytes > 0 && delta.Bytes > 0 && t.usage.Bytes+delta.Bytes > q.MaxBytes:
+		return &QuotaError{Scope: t.scope, Limit: "bytes", Max: q.MaxBytes, Got: t.usage.Bytes + delta.Bytes}
+	}
+
+	t.usage.Documents += delta.Documents
+	t.usage.Bytes += delta.Bytes
+	return nil
+}
+
+func (t *quotaTracker) add(delta Usage) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.usage.Documents += delta.Documents
+	t.usage.Bytes += delta.Bytes
+}
+
+func (t *quotaTracker) stats() QuotaStats {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return QuotaStats{Quota: t.quota, Usage: t.usage}
+}
+
+func (t *quotaTracker) setQuota(q Quota) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.quota = q
+}
+
+// initUsage counts the documents already held by a persistent engine.
+func (c *Collection) initUsage() error {
+	return c.engine.Scan(func(key string, doc Document) bool {
+		c.quota.usage.Documents++
+		c.quota.usage.Bytes += documentSize(doc)
+		return true
+	})
+}
+
+// reserveQuota charges the write of doc over before, if stored, to the
+// collection and store quotas, returning the usage delta to release if the
+// write does not happen.
+func (c *Collection) reserveQuota(key string, doc, before Document, stored bool) (Usage, error) {
+	size := documentSize(doc)
+	delta := Usage{Documents: 1, Bytes: size}
+	if stored {
+		delta = Usage{Bytes: size - documentSize(before)}
+	}
+
+	if err := c.quota.reserve(doc, size, delta); err != nil {
+		c.logger.Warn("failed to put document: quota exceeded", "key", key, "error", err)
+		return Usage{}, err
+	}
+	if c.storeQuota != nil {
+		if err := c.storeQuota.reserve(doc, size, delta); err != nil {
+			c.quota.add(delta.negate())
+			c.logger.Warn("failed to put document: quota exceeded", "key", key, "error", err)
+			return Usage{}, err
+		}
+	}
+	return delta, nil
+}
+
+func (c *Collection) releaseQuota(delta Usage) {
+	c.quota.add(delta.negate())
+	if c.storeQuota != nil {
+		c.storeQuota.add(delta.negate())
+	}
+}
+
+// QuotaStats reports the quota of the collection and its current usage.
+// Bytes are approximate encoded sizes and include expired documents that
+// have not been removed yet.
+func (c *Collection) QuotaStats() QuotaStats {
+	return c.quota.stats()
+}
+
+// SetQuota limits all collections of the store, or of the namespace,
+// together. Documents already stored are kept even if they exceed it.
+func (s *Store) SetQuota(q Quota) error {
+	if err := validQuota(q); err != nil {
+		s.logger.Error("failed to set quota", "error", err)
+		return err
+	}
+
+	s.quota.setQuota(q)
+	s.logger.Info("store quota set",
+		"max_documents", q.MaxDocuments,
+		"max_bytes", q.MaxBytes,
+		"max_document_bytes", q.MaxDocumentBytes,
+		"max_fields", q.MaxFields,
+	)
+	return nil
+}
+
+// QuotaStats reports the store quota and the usage of all of its
+// collections. Namespaces are counted separately.
+func (s *Store) QuotaStats() QuotaStats {
+	return s.quota.stats()
+}
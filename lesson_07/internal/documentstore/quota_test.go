@@ -0,0 +1,170 @@
+package documentstore
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func quotaDoc(id string, fields int, value string) Document {
+	doc := Document{Fields: map[string]DocumentField{
+		"id": {Type: DocumentFieldTypeString, Value: id},
+	}}
+	for i := 1; i < fields; i++ {
+		doc.Fields[string(rune('a'+i))] = DocumentField{Type: DocumentFieldTypeString, Value: value}
+	}
+	return doc
+}
+
+func TestCollection_Quota(t *testing.T) {
+	small := documentSize(quotaDoc("1", 2, "x"))
+
+	tests := []struct {
+		name      string
+		quota     Quota
+		docs      []Document
+		wantLimit string
+	}{
+		{
+			name:      "max documents",
+			quota:     Quota{MaxDocuments: 2},
+			docs:      []Document{quotaDoc("1", 1, ""), quotaDoc("2", 1, ""), quotaDoc("3", 1, "")},
+			wantLimit: "documents",
+		},
+		{
+			name:  "update does not count as a new document",
+			quota: Quota{MaxDocuments: 1},
+			docs:  []Document{quotaDoc("1", 1, ""), quotaDoc("1", 2, "x")},
+		},
+		{
+			name:      "max bytes",
+			quota:     Quota{MaxBytes: 2*small + 1},
+			docs:      []Document{quotaDoc("1", 2, "x"), quotaDoc("2", 2, "x"), quotaDoc("3", 2, "x")},
+			wantLimit: "bytes",
+		},
+		{
+			name:  "shrinking update is allowed at the byte limit",
+			quota: Quota{MaxBytes: documentSize(quotaDoc("1", 2, "xxxx"))},
+			docs:  []Document{quotaDoc("1", 2, "xxxx"), quotaDoc("1", 2, "x")},
+		},
+		{
+			name:      "max document bytes",
+			quota:     Quota{MaxDocumentBytes: small},
+			docs:      []Document{quotaDoc("1", 2, "x"), quotaDoc("2", 2, strings.Repeat("x", 100))},
+			wantLimit: "document bytes",
+		},
+		{
+			name:      "max fields",
+			quota:     Quota{MaxFields: 3},
+			docs:      []Document{quotaDoc("1", 3, "x"), quotaDoc("2", 4, "x")},
+			wantLimit: "fields",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := NewStore()
+			defer store.Close()
+			coll, err := store.CreateCollection("users", &CollectionConfig{PrimaryKey: "id", Quota: &tt.quota})
+			require.NoError(t, err)
+
+			last := len(tt.docs) - 1
+			for _, doc := range tt.docs[:last] {
+				require.NoError(t, coll.Put(doc))
+			}
+			before := coll.QuotaStats().Usage
+
+			err = coll.Put(tt.docs[last])
+			if tt.wantLimit == "" {
+				assert.NoError(t, err)
+				return
+			}
+
+			assert.ErrorIs(t, err, ErrQuotaExceeded)
+			var qerr *QuotaError
+			require.ErrorAs(t, err, &qerr)
+			assert.Equal(t, "users", qerr.Scope)
+			assert.Equal(t, tt.wantLimit, qerr.Limit)
+			assert.Equal(t, before, coll.QuotaStats().Usage)
+			assert.Equal(t, last, coll.Len())
+		})
+	}
+}
+
+func TestCollection_QuotaUsage(t *testing.T) {
+	store := NewStore()
+	defer store.Close()
+	coll, err := store.CreateCollection("users", &CollectionConfig{PrimaryKey: "id", Quota: &Quota{MaxDocuments: 1}})
+	require.NoError(t, err)
+
+	doc := quotaDoc("1", 2, "x")
+	require.NoError(t, coll.Put(doc))
+	assert.Equal(t, QuotaStats{Quota: Quota{MaxDocuments: 1}, Usage: Usage{Documents: 1, Bytes: documentSize(doc)}}, coll.QuotaStats())
+	assert.Equal(t, coll.QuotaStats().Usage, coll.Info().Usage)
+	assert.Equal(t, coll.QuotaStats().Usage, store.QuotaStats().Usage)
+
+	require.NoError(t, coll.Delete("1"))
+	assert.Equal(t, Usage{}, coll.QuotaStats().Usage)
+	assert.Equal(t, Usage{}, store.QuotaStats().Usage)
+	assert.NoError(t, coll.Put(quotaDoc("2", 1, "")))
+}
+
+func TestStore_Quota(t *testing.T) {
+	root := NewStore()
+	defer root.Close()
+
+	acme, err := root.Namespace("acme")
+	require.NoError(t, err)
+	require.NoError(t, acme.SetQuota(Quota{MaxDocuments: 2}))
+
+	users, err := acme.CreateCollection("users", &CollectionConfig{PrimaryKey: "id"})
+	require.NoError(t, err)
+	orders, err := acme.CreateCollection("orders", &CollectionConfig{PrimaryKey: "id"})
+	require.NoError(t, err)
+	require.NoError(t, users.Put(quotaDoc("1", 1, "")))
+	require.NoError(t, orders.Put(quotaDoc("1", 1, "")))
+
+	err = users.Put(quotaDoc("2", 1, ""))
+	assert.ErrorIs(t, err, ErrQuotaExceeded)
+	var qerr *QuotaError
+	require.ErrorAs(t, err, &qerr)
+	assert.Equal(t, "acme", qerr.Scope)
+	assert.Equal(t, Usage{Documents: 1, Bytes: documentSize(quotaDoc("1", 1, ""))}, users.QuotaStats().Usage)
+	assert.Equal(t, 2, acme.QuotaStats().Usage.Documents)
+
+	// Other tenants are not affected.
+	globex, err := root.Namespace("globex")
+	require.NoError(t, err)
+	globexUsers, err := globex.CreateCollection("users", &CollectionConfig{PrimaryKey: "id"})
+	require.NoError(t, err)
+	assert.NoError(t, globexUsers.Put(quotaDoc("1", 1, "")))
+	assert.NoError(t, globexUsers.Put(quotaDoc("2", 1, "")))
+	assert.NoError(t, globexUsers.Put(quotaDoc("3", 1, "")))
+
+	require.NoError(t, acme.DeleteCollection("orders"))
+	assert.Equal(t, 1, acme.QuotaStats().Usage.Documents)
+	assert.NoError(t, users.Put(quotaDoc("2", 1, "")))
+
+	t.Run("survives dump", func(t *testing.T) {
+		data, err := root.Dump()
+		require.NoError(t, err)
+		restored, err := NewStoreFromDump(data)
+		require.NoError(t, err)
+		defer restored.Close()
+
+		ns, err := restored.Namespace("acme")
+		require.NoError(t, err)
+		assert.Equal(t, QuotaStats{Quota: Quota{MaxDocuments: 2}, Usage: acme.QuotaStats().Usage}, ns.QuotaStats())
+	})
+}
+
+func TestQuota_Invalid(t *testing.T) {
+	store := NewStore()
+	defer store.Close()
+
+	assert.ErrorIs(t, store.SetQuota(Quota{MaxBytes: -1}), ErrInvalidQuota)
+	_, err := store.CreateCollection("users", &CollectionConfig{PrimaryKey: "id", Quota: &Quota{MaxFields: -1}})
+	assert.ErrorIs(t, err, ErrInvalidQuota)
+}
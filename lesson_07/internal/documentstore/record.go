@@ -0,0 +1,81 @@
+package documentstore
+
+import (
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+)
+
+// Log records are shared by the Bitcask data files and the LSM write-ahead
+// log. Layout: crc32 | flags | key length | value length | key | value.
+// The checksum covers everything after itself.
+const (
+	logRecordHeaderSize = 13
+	logRecordTombstone  = 1
+)
+
+var errTornRecord = errors.New("torn log record")
+
+type logRecord struct {
+	key   string
+	value []byte
+	flags byte
+}
+
+func (r logRecord) tombstone() bool {
+	return r.flags&logRecordTombstone != 0
+}
+
+func encodeLogRecord(key string, value []byte, flags byte) []byte {
+	record := make([]byte, logRecordHeaderSize+len(key)+len(value))
+	record[4] = flags
+	binary.BigEndian.PutUint32(record[5:9], uint32(len(key)))
+	binary.BigEndian.PutUint32(record[9:13], uint32(len(value)))
+	copy(record[logRecordHeaderSize:], key)
+	copy(record[logRecordHeaderSize+len(key):], value)
+	binary.BigEndian.PutUint32(record[:4], crc32.ChecksumIEEE(record[4:]))
+	return record
+}
+
+// readLogRecord reads the record starting at offset and returns it with its
+// encoded size. It returns io.EOF at a clean end of data and errTornRecord
+// when the record is incomplete or fails its checksum.
+func readLogRecord(r io.ReaderAt, offset int64) (logRecord, int64, error) {
+	header := make([]byte, logRecordHeaderSize)
+	n, err := r.ReadAt(header, offset)
+	if n == 0 && errors.Is(err, io.EOF) {
+		return logRecord{}, 0, io.EOF
+	}
+	if n < len(header) {
+		if err == nil || errors.Is(err, io.EOF) {
+			return logRecord{}, 0, errTornRecord
+		}
+		return logRecord{}, 0, err
+	}
+
+	keyLen := binary.BigEndian.Uint32(header[5:9])
+	valLen := binary.BigEndian.Uint32(header[9:13])
+
+	body := make([]byte, int(keyLen)+int(valLen))
+	if n, err := r.ReadAt(body, offset+logRecordHeaderSize); n < len(body) {
+		if err == nil || errors.Is(err, io.EOF) {
+			return logRecord{}, 0, errTornRecord
+		}
+		return logRecord{}, 0, err
+	}
+
+	crc := crc32.NewIEEE()
+	crc.Write(header[4:])
+	crc.Write(body)
+	if crc.Sum32() != binary.BigEndian.Uint32(header[:4]) {
+		return logRecord{}, 0, errTornRecord
+	}
+
+	rec := logRecord{
+		key:   string(body[:keyLen]),
+		value: body[keyLen:],
+		flags: header[4],
+	}
+	return rec, logRecordHeaderSize + int64(len(body)), nil
+}
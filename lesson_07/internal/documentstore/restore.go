@@ -0,0 +1,282 @@
+package documentstore
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+)
+
+type ConflictPolicy string
+
+const (
+	ConflictSkip      ConflictPolicy = "skip"
+	ConflictOverwrite ConflictPolicy = "overwrite"
+	ConflictFail      ConflictPolicy = "fail"
+	ConflictKeepNewer ConflictPolicy = "keep_newer"
+)
+
+const defaultVersionField = "version"
+
+// RestoreOptions selects what a merge-restore loads into an existing store.
+type RestoreOptions struct {
+	// Collections limits the restore to the named collections; empty means all.
+	Collections []string
+	// KeyFilter, when set, restores only documents for which it returns true.
+	KeyFilter func(collection, key string) bool
+	// Conflict decides what happens to documents whose key already exists.
+	// Defaults to ConflictSkip.
+	Conflict ConflictPolicy
+	// VersionField names the numeric field compared by ConflictKeepNewer.
+	// Defaults to "version".
+	VersionField string
+	// Config, when set, vets the dumped config of every collection the
+	// restore creates and returns the config to create it with. An error
+	// aborts the restore before anything is written.
+	Config func(collection string, cfg CollectionConfig) (CollectionConfig, error)
+}
+
+// RestoreReport lists the keys handled by a merge-restore, per collection.
+type RestoreReport struct {
+	CreatedCollections []string
+	Restored           map[string][]string
+	Skipped            map[string][]string
+	Conflicts          map[string][]string
+}
+
+type restoreOp struct {
+	collection string
+	doc        Document
+	opts       []PutOption
+}
+
+// RestoreFromDump merges the documents of a dump into the store. The whole
+// dump is planned before anything is written, so ConflictFail leaves the
+// store untouched.
+// Only the collections of s are restored; namespaces in the dump are
+// ignored, restore them through their own Namespace handle.
+func (s *Store) RestoreFromDump(dump []byte, opts RestoreOptions) (*RestoreReport, error) {
+	storeDump, err := decodeDump(dump)
+	if err != nil {
+		s.logger.Error("failed to decode dump for restore", "error", err)
+		return nil, err
+	}
+
+	if opts.Conflict == "" {
+		opts.Conflict = ConflictSkip
+	}
+	if opts.VersionField == "" {
+		opts.VersionField = defaultVersionField
+	}
+
+	switch opts.Conflict {
+	case ConflictSkip, ConflictOverwrite, ConflictFail, ConflictKeepNewer:
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnknownConflictPolicy, opts.Conflict)
+	}
+
+	names := opts.Collections
+	if len(names) == 0 {
+		for name := range storeDump.Collections {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+	}
+
+	report := &RestoreReport{
+		Restored:  make(map[string][]string),
+		Skipped:   make(map[string][]string),
+		Conflicts: make(map[string][]string),
+	}
+
+	var (
+		missing []string
+		configs = make(map[string]CollectionConfig)
+		planned []*Collection
+		ops     []restoreOp
+	)
+	defer func() {
+		for _, coll := range planned {
+			coll.Close()
+		}
+	}()
+
+	for _, name := range names {
+		collDump, ok := storeDump.Collections[name]
+		if !ok {
+			s.logger.Error("failed to restore collection: not in dump", "collection", name)
+			return nil, fmt.Errorf("%w: %s", ErrCollectionNotFound, name)
+		}
+
+		coll, exists := s.collections[name]
+		if exists {
+			if !slices.Equal(coll.cfg.keyFields(), collDump.Config.keyFields()) {
+				s.logger.Error("failed to restore collection: primary key mismatch",
+					"collection", name,
+					"primary_key", coll.cfg.keyFields(),
+					"dump_primary_key", collDump.Config.keyFields(),
+				)
+				return nil, fmt.Errorf("%w: %s", ErrConfigMismatch, name)
+			}
+		} else {
+			cfg := collDump.Config
+			if opts.Config != nil {
+				if cfg, err = opts.Config(name, cfg); err != nil {
+					s.logger.Error("failed to restore collection: config rejected", "collection", name, "error", err)
+					return nil, fmt.Errorf("collection %s: %w", name, err)
+				}
+			}
+			configs[name] = cfg
+
+			// Planned against an empty in-memory collection; the real one is
+			// created once planning succeeds.
+			planCfg := cfg
+			planCfg.Engine, planCfg.DataDir = EngineMemory, ""
+			if coll, err = OpenCollection(planCfg); err != nil {
+				s.logger.Error("failed to restore collection: invalid config", "collection", name, "error", err)
+				return nil, fmt.Errorf("collection %s: %w", name, err)
+			}
+			coll.logger = s.logger
+			planned = append(planned, coll)
+			missing = append(missing, name)
+		}
+
+		for _, doc := range collDump.Documents {
+			key, err := coll.primaryKey(doc)
+			if err != nil {
+				return nil, err
+			}
+
+			if opts.KeyFilter != nil && !opts.KeyFilter(name, key) {
+				report.Skipped[name] = append(report.Skipped[name], key)
+				continue
+			}
+
+			// Documents keep the deadline they had when dumped; those that
+			// have expired since are not restored.
+			var putOpts []PutOption
+			if at, ok := collDump.Expiries[key]; ok {
+				if !at.After(coll.now()) {
+					report.Skipped[name] = append(report.Skipped[name], key)
+					continue
+				}
+				putOpts = []PutOption{WithExpiry(at)}
+			}
+
+			existing, found, err := coll.engine.Get(key)
+			if err != nil {
+				return nil, err
+			}
+			if !found {
+				ops = append(ops, restoreOp{collection: name, doc: doc, opts: putOpts})
+				report.Restored[name] = append(report.Restored[name], key)
+				continue
+			}
+
+			report.Conflicts[name] = append(report.Conflicts[name], key)
+
+			switch opts.Conflict {
+			case ConflictFail:
+				s.logger.Error("restore aborted on conflict", "collection", name, "key", key)
+				return nil, fmt.Errorf("%w: %s/%s", ErrRestoreConflict, name, key)
+			case ConflictOverwrite:
+				ops = append(ops, restoreOp{collection: name, doc: doc, opts: putOpts})
+				report.Restored[name] = append(report.Restored[name], key)
+			case ConflictKeepNewer:
+				if documentVersion(doc, opts.VersionField) > documentVersion(existing, opts.VersionField) {
+					ops = append(ops, restoreOp{collection: name, doc: doc, opts: putOpts})
+					report.Restored[name] = append(report.Restored[name], key)
+				} else {
+					report.Skipped[name] = append(report.Skipped[name], key)
+				}
+			default:
+				report.Skipped[name] = append(report.Skipped[name], key)
+			}
+		}
+	}
+
+	for _, name := range missing {
+		cfg := configs[name]
+		if _, err := s.CreateCollection(name, &cfg); err != nil {
+			return nil, err
+		}
+		report.CreatedCollections = append(report.CreatedCollections, name)
+	}
+
+	for _, op := range ops {
+		if err := s.collections[op.collection].PutWithOptions(op.doc, op.opts...); err != nil {
+			return nil, err
+		}
+	}
+
+	// Keys the dumped sequence handed out must not be generated again, even
+	// for documents that were deleted before the dump.
+	for _, name := range names {
+		coll := s.collections[name]
+		coll.mu.Lock()
+		coll.advanceSequence(storeDump.Collections[name].Metadata.Sequence)
+		coll.mu.Unlock()
+	}
+
+	for _, m := range []map[string][]string{report.Restored, report.Skipped, report.Conflicts} {
+		for _, keys := range m {
+			sort.Strings(keys)
+		}
+	}
+
+	s.logger.Info("store restored from dump",
+		"collections", names,
+		"created_collections", len(report.CreatedCollections),
+		"documents_restored", countKeys(report.Restored),
+		"documents_skipped", countKeys(report.Skipped),
+		"conflicts", countKeys(report.Conflicts),
+	)
+	return report, nil
+}
+
+func (s *Store) RestoreFromFile(filename string, opts RestoreOptions) (*RestoreReport, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		s.logger.Error("failed to read dump file", "filename", filename, "error", err)
+		return nil, err
+	}
+	return s.RestoreFromDump(data, opts)
+}
+
+// KeysFilter returns a RestoreOptions.KeyFilter that accepts only the
+// given keys, regardless of collection.
+func KeysFilter(keys ...string) func(collection, key string) bool {
+	return func(_, key string) bool {
+		return slices.Contains(keys, key)
+	}
+}
+
+func documentVersion(doc Document, field string) float64 {
+	f, ok := doc.Fields[field]
+	if !ok || f.Type != DocumentFieldTypeNumber {
+		return 0
+	}
+	v, _ := numberValue(f.Value)
+	return v
+}
+
+func numberValue(v any) (float64, bool) {
+	switch n := v.(type) {
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case float64:
+		return n, true
+	default:
+		return 0, false
+	}
+}
+
+func countKeys(m map[string][]string) int {
+	total := 0
+	for _, keys := range m {
+		total += len(keys)
+	}
+	return total
+}
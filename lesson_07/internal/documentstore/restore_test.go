@@ -0,0 +1,220 @@
+package documentstore
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func versionedDoc(id, name string, version int64) Document {
+	doc := userDoc(id, name)
+	doc.Fields["version"] = DocumentField{Type: DocumentFieldTypeNumber, Value: version}
+	return doc
+}
+
+func backupDump(t *testing.T) []byte {
+	t.Helper()
+
+	store := NewStore()
+	usersColl, err := store.CreateCollection("users", &CollectionConfig{PrimaryKey: "id"})
+	require.NoError(t, err)
+	require.NoError(t, usersColl.Put(versionedDoc("user:1", "Alice", 2)))
+	require.NoError(t, usersColl.Put(versionedDoc("user:2", "Bob", 1)))
+	require.NoError(t, usersColl.Put(versionedDoc("user:3", "Charlie", 1)))
+
+	productsColl, err := store.CreateCollection("products", &CollectionConfig{PrimaryKey: "sku"})
+	require.NoError(t, err)
+	require.NoError(t, productsColl.Put(Document{
+		Fields: map[string]DocumentField{
+			"sku": {Type: DocumentFieldTypeString, Value: "prod:1"},
+		},
+	}))
+
+	data, err := store.Dump()
+	require.NoError(t, err)
+	return data
+}
+
+func liveStore(t *testing.T) *Store {
+	t.Helper()
+
+	store := NewStore()
+	coll, err := store.CreateCollection("users", &CollectionConfig{PrimaryKey: "id"})
+	require.NoError(t, err)
+	require.NoError(t, coll.Put(versionedDoc("user:1", "Alice (edited)", 1)))
+	require.NoError(t, coll.Put(versionedDoc("user:2", "Bob (edited)", 5)))
+	return store
+}
+
+func TestStore_RestoreFromDump(t *testing.T) {
+	tests := []struct {
+		name          string
+		opts          RestoreOptions
+		wantErr       error
+		wantRestored  map[string][]string
+		wantSkipped   map[string][]string
+		wantConflicts map[string][]string
+		wantCreated   []string
+		wantNames     map[string]string
+	}{
+		{
+			name:          "skips conflicting documents by default",
+			opts:          RestoreOptions{Collections: []string{"users"}},
+			wantRestored:  map[string][]string{"users": {"user:3"}},
+			wantSkipped:   map[string][]string{"users": {"user:1", "user:2"}},
+			wantConflicts: map[string][]string{"users": {"user:1", "user:2"}},
+			wantNames:     map[string]string{"user:1": "Alice (edited)", "user:2": "Bob (edited)", "user:3": "Charlie"},
+		},
+		{
+			name:          "overwrites conflicting documents",
+			opts:          RestoreOptions{Collections: []string{"users"}, Conflict: ConflictOverwrite},
+			wantRestored:  map[string][]string{"users": {"user:1", "user:2", "user:3"}},
+			wantSkipped:   map[string][]string{},
+			wantConflicts: map[string][]string{"users": {"user:1", "user:2"}},
+			wantNames:     map[string]string{"user:1": "Alice", "user:2": "Bob", "user:3": "Charlie"},
+		},
+		{
+			name:          "keeps newer version",
+			opts:          RestoreOptions{Collections: []string{"users"}, Conflict: ConflictKeepNewer},
+			wantRestored:  map[string][]string{"users": {"user:1", "user:3"}},
+			wantSkipped:   map[string][]string{"users": {"user:2"}},
+			wantConflicts: map[string][]string{"users": {"user:1", "user:2"}},
+			wantNames:     map[string]string{"user:1": "Alice", "user:2": "Bob (edited)", "user:3": "Charlie"},
+		},
+		{
+			name:      "fails on conflict without writing",
+			opts:      RestoreOptions{Collections: []string{"users"}, Conflict: ConflictFail},
+			wantErr:   ErrRestoreConflict,
+			wantNames: map[string]string{"user:1": "Alice (edited)", "user:2": "Bob (edited)"},
+		},
+		{
+			name: "restores only filtered keys",
+			opts: RestoreOptions{
+				Collections: []string{"users"},
+				KeyFilter:   KeysFilter("user:1"),
+				Conflict:    ConflictOverwrite,
+			},
+			wantRestored:  map[string][]string{"users": {"user:1"}},
+			wantSkipped:   map[string][]string{"users": {"user:2", "user:3"}},
+			wantConflicts: map[string][]string{"users": {"user:1"}},
+			wantNames:     map[string]string{"user:1": "Alice", "user:2": "Bob (edited)"},
+		},
+		{
+			name:          "creates missing collections",
+			opts:          RestoreOptions{Collections: []string{"products"}},
+			wantRestored:  map[string][]string{"products": {"prod:1"}},
+			wantSkipped:   map[string][]string{},
+			wantConflicts: map[string][]string{},
+			wantCreated:   []string{"products"},
+			wantNames:     map[string]string{"user:1": "Alice (edited)", "user:2": "Bob (edited)"},
+		},
+		{
+			name:    "returns error for collection missing from dump",
+			opts:    RestoreOptions{Collections: []string{"orders"}},
+			wantErr: ErrCollectionNotFound,
+		},
+		{
+			name:    "returns error for unknown policy",
+			opts:    RestoreOptions{Conflict: "merge"},
+			wantErr: ErrUnknownConflictPolicy,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := liveStore(t)
+
+			report, err := store.RestoreFromDump(backupDump(t), tt.opts)
+
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				assert.Nil(t, report)
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, tt.wantRestored, report.Restored)
+				assert.Equal(t, tt.wantSkipped, report.Skipped)
+				assert.Equal(t, tt.wantConflicts, report.Conflicts)
+				assert.Equal(t, tt.wantCreated, report.CreatedCollections)
+			}
+
+			coll, err := store.GetCollection("users")
+			require.NoError(t, err)
+			for key, name := range tt.wantNames {
+				doc, err := coll.Get(key)
+				require.NoError(t, err)
+				assert.Equal(t, name, doc.Fields["name"].Value)
+			}
+			if tt.wantNames != nil {
+				assert.Len(t, coll.List(), len(tt.wantNames))
+			}
+		})
+	}
+}
+
+func TestStore_RestoreFromDump_ConfigMismatch(t *testing.T) {
+	store := NewStore()
+	_, err := store.CreateCollection("users", &CollectionConfig{PrimaryKey: "email"})
+	require.NoError(t, err)
+
+	report, err := store.RestoreFromDump(backupDump(t), RestoreOptions{Collections: []string{"users"}})
+	assert.ErrorIs(t, err, ErrConfigMismatch)
+	assert.Nil(t, report)
+}
+
+func TestStore_RestoreFromDump_InvalidConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  string
+		wantErr error
+	}{
+		{name: "key generator", config: `{"PrimaryKey":"id","KeyGen":"bogus"}`, wantErr: ErrInvalidKeyGenerator},
+		{name: "schema", config: `{"PrimaryKey":"id","Schema":{"fields":{"id":{"types":["list"]}}}}`, wantErr: ErrInvalidSchema},
+		{name: "quota", config: `{"PrimaryKey":"id","Quota":{"MaxDocuments":-1}}`, wantErr: ErrInvalidQuota},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := NewStore()
+			dump := `{"version":4,"collections":{"users":{"config":` + tt.config + `,"documents":[]}}}`
+
+			report, err := store.RestoreFromDump([]byte(dump), RestoreOptions{})
+			assert.ErrorIs(t, err, tt.wantErr)
+			assert.Nil(t, report)
+			assert.Empty(t, store.Info().Collections)
+		})
+	}
+}
+
+func TestStore_RestoreFromDump_ConfigHook(t *testing.T) {
+	store := NewStore()
+	defer store.Close()
+
+	var seen []string
+	report, err := store.RestoreFromDump(backupDump(t), RestoreOptions{
+		Config: func(collection string, cfg CollectionConfig) (CollectionConfig, error) {
+			seen = append(seen, collection)
+			if collection == "products" {
+				return cfg, ErrInvalidEngineConfig
+			}
+			return cfg, nil
+		},
+	})
+	assert.ErrorIs(t, err, ErrInvalidEngineConfig)
+	assert.Nil(t, report)
+	assert.Equal(t, []string{"products"}, seen)
+	assert.Empty(t, store.Info().Collections)
+
+	report, err = store.RestoreFromDump(backupDump(t), RestoreOptions{
+		Collections: []string{"users"},
+		Config: func(_ string, cfg CollectionConfig) (CollectionConfig, error) {
+			cfg.Quota = &Quota{MaxDocuments: 10}
+			return cfg, nil
+		},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"users"}, report.CreatedCollections)
+	coll, err := store.GetCollection("users")
+	require.NoError(t, err)
+	assert.Equal(t, &Quota{MaxDocuments: 10}, coll.Info().Config.Quota)
+}
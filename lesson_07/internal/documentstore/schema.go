@@ -0,0 +1,340 @@
+package documentstore
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Schema constrains the documents of a collection. Put rejects documents
+// that break any rule with a *ValidationError.
+type Schema struct {
+	Fields map[string]FieldRule `json:"fields"`
+	// AllowUnknownFields permits fields that have no rule.
+	AllowUnknownFields bool `json:"allow_unknown_fields,omitempty"`
+}
+
+// FieldRule describes one field. Nil limits are not checked.
+type FieldRule struct {
+	Required bool `json:"required,omitempty"`
+	// Types lists the allowed field types; empty allows any type.
+	Types     []DocumentFieldType `json:"types,omitempty"`
+	MinLength *int                `json:"min_length,omitempty"`
+	MaxLength *int                `json:"max_length,omitempty"`
+	// Pattern is a regular expression string values must match.
+	Pattern string   `json:"pattern,omitempty"`
+	Min     *float64 `json:"min,omitempty"`
+	Max     *float64 `json:"max,omitempty"`
+	// Integer requires numbers to be whole.
+	Integer bool `json:"integer,omitempty"`
+	// Enum lists the allowed values; empty allows any value.
+	Enum []any `json:"enum,omitempty"`
+	// Items is checked against every element of an array field.
+	Items *FieldRule `json:"items,omitempty"`
+}
+
+type Violation struct {
+	Field   string
+	Rule    string
+	Message string
+}
+
+// ValidationError lists every rule a document breaks, ordered by field.
+type ValidationError struct {
+	Violations []Violation
+}
+
+func (e *ValidationError) Error() string {
+	msgs := make([]string, 0, len(e.Violations))
+	for _, v := range e.Violations {
+		msgs = append(msgs, fmt.Sprintf("%s: %s", v.Field, v.Message))
+	}
+	return fmt.Sprintf("%s: %s", ErrValidationFailed, strings.Join(msgs, "; "))
+}
+
+func (e *ValidationError) Unwrap() error {
+	return ErrValidationFailed
+}
+
+type compiledSchema struct {
+	Schema
+	patterns map[string]*regexp.Regexp
+}
+
+func compileSchema(s Schema) (*compiledSchema, error) {
+	cs := &compiledSchema{Schema: s, patterns: make(map[string]*regexp.Regexp)}
+
+	for name, rule := range s.Fields {
+		if err := cs.compileRule(name, rule, true); err != nil {
+			return nil, err
+		}
+		if rule.Items != nil {
+			if err := cs.compileRule(name+"[]", *rule.Items, false); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return cs, nil
+}
+
+// compileRule checks the types of a rule and compiles its pattern under
+// name. Array element rules may not hold arrays themselves.
+func (cs *compiledSchema) compileRule(name string, rule FieldRule, allowArray bool) error {
+	for _, typ := range rule.Types {
+		switch typ {
+		case DocumentFieldTypeString, DocumentFieldTypeNumber, DocumentFieldTypeBool:
+		case DocumentFieldTypeArray:
+			if !allowArray {
+				return fmt.Errorf("%w: field %q: nested arrays are not supported", ErrInvalidSchema, name)
+			}
+		default:
+			return fmt.Errorf("%w: field %q: unknown type %q", ErrInvalidSchema, name, typ)
+		}
+	}
+	if !allowArray && rule.Items != nil {
+		return fmt.Errorf("%w: field %q: nested arrays are not supported", ErrInvalidSchema, name)
+	}
+
+	if rule.Pattern != "" {
+		re, err := regexp.Compile(rule.Pattern)
+		if err != nil {
+			return fmt.Errorf("%w: field %q: %v", ErrInvalidSchema, name, err)
+		}
+		cs.patterns[name] = re
+	}
+	return nil
+}
+
+func (cs *compiledSchema) validate(doc Document) error {
+	var violations []Violation
+	add := func(field, rule, format string, args ...any) {
+		violations = append(violations, Violation{Field: field, Rule: rule, Message: fmt.Sprintf(format, args...)})
+	}
+
+	for name, rule := range cs.Fields {
+		field, ok := doc.Fields[name]
+		if !ok {
+			if rule.Required {
+				add(name, "required", "is required")
+			}
+			continue
+		}
+
+		cs.check(name, name, rule, field, add)
+
+		if field.Type != DocumentFieldTypeArray || rule.Items == nil {
+			continue
+		}
+		elems, _ := field.Value.([]any)
+		for i, v := range elems {
+			elemName := fmt.Sprintf("%s[%d]", name, i)
+			elem, ok := arrayElement(v)
+			if !ok {
+				add(elemName, "type", "has unsupported value %v", v)
+				continue
+			}
+			cs.check(elemName, name+"[]", *rule.Items, elem, add)
+		}
+	}
+
+	if !cs.AllowUnknownFields {
+		for name := range doc.Fields {
+			if _, ok := cs.Fields[name]; !ok {
+				add(name, "unknown", "is not allowed")
+			}
+		}
+	}
+
+	if len(violations) == 0 {
+		return nil
+	}
+
+	sort.Slice(violations, func(i, j int) bool {
+		if violations[i].Field != violations[j].Field {
+			return violations[i].Field < violations[j].Field
+		}
+		return violations[i].Rule < violations[j].Rule
+	})
+	return &ValidationError{Violations: violations}
+}
+
+// check applies rule to a single value; pattern names the compiled pattern
+// of the rule.
+func (cs *compiledSchema) check(name, pattern string, rule FieldRule, field DocumentField, add func(field, rule, format string, args ...any)) {
+	if len(rule.Types) > 0 && !slices.Contains(rule.Types, field.Type) {
+		add(name, "type", "has type %s, want one of %v", field.Type, rule.Types)
+		return
+	}
+
+	switch field.Type {
+	case DocumentFieldTypeString:
+		s, _ := field.Value.(string)
+		n := utf8.RuneCountInString(s)
+		if rule.MinLength != nil && n < *rule.MinLength {
+			add(name, "min_length", "is shorter than %d characters", *rule.MinLength)
+		}
+		if rule.MaxLength != nil && n > *rule.MaxLength {
+			add(name, "max_length", "is longer than %d characters", *rule.MaxLength)
+		}
+		if re, ok := cs.patterns[pattern]; ok && !re.MatchString(s) {
+			add(name, "pattern", "does not match %q", rule.Pattern)
+		}
+	case DocumentFieldTypeNumber:
+		n, ok := numberValue(field.Value)
+		if !ok {
+			add(name, "type", "is not a number")
+			return
+		}
+		if rule.Min != nil && n < *rule.Min {
+			add(name, "min", "is less than %v", *rule.Min)
+		}
+		if rule.Max != nil && n > *rule.Max {
+			add(name, "max", "is greater than %v", *rule.Max)
+		}
+		if rule.Integer && n != math.Trunc(n) {
+			add(name, "integer", "is not a whole number")
+		}
+	case DocumentFieldTypeArray:
+		if _, ok := field.Value.([]any); !ok {
+			add(name, "type", "is not an array")
+		}
+		// Enum values are compared with ==, which arrays do not support.
+		return
+	}
+
+	if len(rule.Enum) > 0 && !slices.ContainsFunc(rule.Enum, func(v any) bool { return enumEqual(v, field.Value) }) {
+		add(name, "enum", "is not one of %v", rule.Enum)
+	}
+}
+
+// enumEqual compares numbers by value so that 1, int64(1) and 1.0 match.
+func enumEqual(want, got any) bool {
+	if w, ok := numberValue(want); ok {
+		g, ok := numberValue(got)
+		return ok && w == g
+	}
+	return want == got
+}
+
+// Validate checks doc against the collection schema. It returns nil for
+// collections without one.
+func (c *Collection) Validate(doc Document) error {
+	if c.schema == nil {
+		return nil
+	}
+	return c.schema.validate(doc)
+}
+
+// SchemaFromStruct derives a schema from the exported fields of a struct,
+// naming fields the way MarshalDocument does. Fields are required unless
+// their json tag has omitempty, and integer fields only accept whole
+// numbers. Limits come from an optional schema tag,
+// e.g. `schema:"minlen=1,maxlen=64,min=0,max=150,pattern=^[a-z]+$"`;
+// pattern must come last as it may contain commas.
+func SchemaFromStruct(v any) (*Schema, error) {
+	t := reflect.TypeOf(v)
+	if t != nil && t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("%w: not a struct", ErrInvalidSchema)
+	}
+
+	schema := &Schema{Fields: make(map[string]FieldRule)}
+
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		name, omitempty, skip := documentFieldName(sf)
+		if skip {
+			continue
+		}
+
+		rule := FieldRule{Required: !omitempty}
+		switch sf.Type.Kind() {
+		case reflect.String:
+			rule.Types = []DocumentFieldType{DocumentFieldTypeString}
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			rule.Types = []DocumentFieldType{DocumentFieldTypeNumber}
+			rule.Integer = true
+		case reflect.Bool:
+			rule.Types = []DocumentFieldType{DocumentFieldTypeBool}
+		default:
+			return nil, fmt.Errorf("%w: %s", ErrUnsupportedDocumentField, sf.Type.Kind())
+		}
+
+		if err := parseSchemaTag(sf.Tag.Get("schema"), &rule); err != nil {
+			return nil, fmt.Errorf("%w: field %q: %v", ErrInvalidSchema, name, err)
+		}
+
+		schema.Fields[name] = rule
+	}
+
+	return schema, nil
+}
+
+func parseSchemaTag(tag string, rule *FieldRule) error {
+	for tag != "" {
+		var part string
+		if strings.HasPrefix(tag, "pattern=") {
+			part, tag = tag, ""
+		} else {
+			part, tag, _ = strings.Cut(tag, ",")
+		}
+
+		key, value, _ := strings.Cut(part, "=")
+		switch key {
+		case "minlen", "maxlen":
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				return err
+			}
+			if key == "minlen" {
+				rule.MinLength = &n
+			} else {
+				rule.MaxLength = &n
+			}
+		case "min", "max":
+			f, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return err
+			}
+			if key == "min" {
+				rule.Min = &f
+			} else {
+				rule.Max = &f
+			}
+		case "pattern":
+			rule.Pattern = value
+		default:
+			return fmt.Errorf("unknown option %q", key)
+		}
+	}
+	return nil
+}
+
+// documentFieldName returns the document field name of a struct field
+// from its json tag.
+func documentFieldName(sf reflect.StructField) (name string, omitempty, skip bool) {
+	if !sf.IsExported() {
+		return "", false, true
+	}
+
+	tag := sf.Tag.Get("json")
+	if tag == "-" {
+		return "", false, true
+	}
+
+	name, opts, _ := strings.Cut(tag, ",")
+	if name == "" {
+		name = sf.Name
+	}
+	return name, slices.Contains(strings.Split(opts, ","), "omitempty"), false
+}
@@ -0,0 +1,228 @@
+package documentstore
+
+import (
+	"errors"
+	"maps"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type schemaUser struct {
+	ID     string `json:"id"`
+	Email  string `json:"email" schema:"maxlen=64,pattern=^[^@]+@[^@]+$"`
+	Age    int64  `json:"age,omitempty" schema:"min=0,max=150"`
+	Active bool   `json:"active"`
+	secret string
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+func TestSchema_Validate(t *testing.T) {
+	schema := Schema{
+		Fields: map[string]FieldRule{
+			"id":   {Required: true, Types: []DocumentFieldType{DocumentFieldTypeString}},
+			"name": {Required: true, Types: []DocumentFieldType{DocumentFieldTypeString}, MinLength: ptr(2), MaxLength: ptr(5)},
+			"code": {Pattern: "^[A-Z]{3}$"},
+			"age":  {Types: []DocumentFieldType{DocumentFieldTypeNumber}, Min: ptr(0.0), Max: ptr(150.0)},
+		},
+	}
+
+	doc := func(fields map[string]DocumentField) Document {
+		fields["id"] = DocumentField{Type: DocumentFieldTypeString, Value: "u1"}
+		return Document{Fields: fields}
+	}
+	str := func(s string) DocumentField { return DocumentField{Type: DocumentFieldTypeString, Value: s} }
+	num := func(n float64) DocumentField { return DocumentField{Type: DocumentFieldTypeNumber, Value: n} }
+
+	tests := []struct {
+		name         string
+		allowUnknown bool
+		doc          Document
+		wantRules    map[string]string
+	}{
+		{
+			name: "valid document",
+			doc:  doc(map[string]DocumentField{"name": str("Alice"), "code": str("ABC"), "age": num(30)}),
+		},
+		{
+			name:      "missing required field",
+			doc:       doc(map[string]DocumentField{}),
+			wantRules: map[string]string{"name": "required"},
+		},
+		{
+			name:      "wrong type",
+			doc:       doc(map[string]DocumentField{"name": num(1)}),
+			wantRules: map[string]string{"name": "type"},
+		},
+		{
+			name:      "too short",
+			doc:       doc(map[string]DocumentField{"name": str("A")}),
+			wantRules: map[string]string{"name": "min_length"},
+		},
+		{
+			name:      "too long",
+			doc:       doc(map[string]DocumentField{"name": str("Alexander")}),
+			wantRules: map[string]string{"name": "max_length"},
+		},
+		{
+			name:      "pattern mismatch",
+			doc:       doc(map[string]DocumentField{"name": str("Bob"), "code": str("abc")}),
+			wantRules: map[string]string{"code": "pattern"},
+		},
+		{
+			name:      "out of range",
+			doc:       doc(map[string]DocumentField{"name": str("Bob"), "age": num(200)}),
+			wantRules: map[string]string{"age": "max"},
+		},
+		{
+			name:      "unknown field rejected",
+			doc:       doc(map[string]DocumentField{"name": str("Bob"), "extra": str("x")}),
+			wantRules: map[string]string{"extra": "unknown"},
+		},
+		{
+			name:         "unknown field allowed",
+			allowUnknown: true,
+			doc:          doc(map[string]DocumentField{"name": str("Bob"), "extra": str("x")}),
+		},
+		{
+			name: "every violation is reported",
+			doc:  doc(map[string]DocumentField{"code": str("x"), "age": num(-1), "extra": str("x")}),
+			wantRules: map[string]string{
+				"age":   "min",
+				"code":  "pattern",
+				"extra": "unknown",
+				"name":  "required",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := schema
+			s.AllowUnknownFields = tt.allowUnknown
+			coll, err := OpenCollection(CollectionConfig{PrimaryKey: "id", Schema: &s})
+			require.NoError(t, err)
+			defer coll.Close()
+
+			err = coll.Put(tt.doc)
+			if tt.wantRules == nil {
+				require.NoError(t, err)
+				return
+			}
+
+			assert.ErrorIs(t, err, ErrValidationFailed)
+			var verr *ValidationError
+			require.True(t, errors.As(err, &verr))
+
+			got := make(map[string]string)
+			for _, v := range verr.Violations {
+				got[v.Field] = v.Rule
+			}
+			assert.Equal(t, tt.wantRules, got)
+			assert.Equal(t, 0, coll.Len())
+		})
+	}
+}
+
+func TestSchemaFromStruct(t *testing.T) {
+	schema, err := SchemaFromStruct(&schemaUser{})
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]FieldRule{
+		"id":     {Required: true, Types: []DocumentFieldType{DocumentFieldTypeString}},
+		"email":  {Required: true, Types: []DocumentFieldType{DocumentFieldTypeString}, MaxLength: ptr(64), Pattern: "^[^@]+@[^@]+$"},
+		"age":    {Types: []DocumentFieldType{DocumentFieldTypeNumber}, Integer: true, Min: ptr(0.0), Max: ptr(150.0)},
+		"active": {Required: true, Types: []DocumentFieldType{DocumentFieldTypeBool}},
+	}, schema.Fields)
+
+	coll, err := OpenCollection(CollectionConfig{PrimaryKey: "id", Schema: schema})
+	require.NoError(t, err)
+	defer coll.Close()
+
+	valid, err := MarshalDocument(schemaUser{ID: "u1", Email: "a@example.com", Age: 30, Active: true})
+	require.NoError(t, err)
+	assert.NoError(t, coll.Put(*valid))
+
+	invalid, err := MarshalDocument(schemaUser{ID: "u2", Email: "nope", Age: -1})
+	require.NoError(t, err)
+	assert.ErrorIs(t, coll.Put(*invalid), ErrValidationFailed)
+
+	fractional := *valid
+	fractional.Fields = maps.Clone(valid.Fields)
+	fractional.Fields["age"] = DocumentField{Type: DocumentFieldTypeNumber, Value: 30.5}
+	assert.ErrorIs(t, coll.Put(fractional), ErrValidationFailed)
+
+	t.Run("every integer kind", func(t *testing.T) {
+		schema, err := SchemaFromStruct(struct {
+			A int8
+			B int32
+			C uint
+			D uint16
+			E uint64
+		}{})
+		require.NoError(t, err)
+		for name, rule := range schema.Fields {
+			assert.True(t, rule.Integer, name)
+			assert.Equal(t, []DocumentFieldType{DocumentFieldTypeNumber}, rule.Types, name)
+		}
+		assert.Len(t, schema.Fields, 5)
+	})
+}
+
+func TestSchemaFromStruct_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   any
+		wantErr error
+	}{
+		{name: "not a struct", input: "user", wantErr: ErrInvalidSchema},
+		{name: "unsupported field", input: struct{ Tags []string }{}, wantErr: ErrUnsupportedDocumentField},
+		{
+			name: "bad tag",
+			input: struct {
+				Age int64 `schema:"min=young"`
+			}{},
+			wantErr: ErrInvalidSchema,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := SchemaFromStruct(tt.input)
+			assert.ErrorIs(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestOpenCollection_InvalidSchema(t *testing.T) {
+	for _, rule := range []FieldRule{{Pattern: "("}, {Types: []DocumentFieldType{"date"}}} {
+		coll, err := OpenCollection(CollectionConfig{
+			PrimaryKey: "id",
+			Schema:     &Schema{Fields: map[string]FieldRule{"id": rule}},
+		})
+		assert.ErrorIs(t, err, ErrInvalidSchema)
+		assert.Nil(t, coll)
+	}
+}
+
+func TestStore_DumpKeepsSchema(t *testing.T) {
+	store := NewStore()
+	schema, err := SchemaFromStruct(schemaUser{})
+	require.NoError(t, err)
+	_, err = store.CreateCollection("users", &CollectionConfig{PrimaryKey: "id", Schema: schema})
+	require.NoError(t, err)
+
+	data, err := store.Dump()
+	require.NoError(t, err)
+
+	restored, err := NewStoreFromDump(data)
+	require.NoError(t, err)
+	coll, err := restored.GetCollection("users")
+	require.NoError(t, err)
+
+	assert.ErrorIs(t, coll.Put(userDoc("u1", "Alice")), ErrValidationFailed)
+}
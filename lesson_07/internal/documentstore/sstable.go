@@ -0,0 +1,229 @@
+package documentstore
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"os"
+	"sort"
+)
+
+// SSTable layout: values | index | bloom filter | footer.
+//
+// Index entries: flags | key length | key | value offset | value length.
+// Footer: index offset | bloom offset | entry count | bloom k | crc32 | magic,
+// where the checksum covers everything before it.
+const (
+	sstableFooterSize = 32
+	sstableMagic      = 0x4c534d31 // "LSM1"
+)
+
+// lsmItem is a key with either a value or a tombstone, as held by the
+// memtable and produced by merges.
+type lsmItem struct {
+	key       string
+	value     []byte
+	tombstone bool
+}
+
+type sstableEntry struct {
+	key       string
+	offset    int64
+	size      uint32
+	tombstone bool
+}
+
+type sstable struct {
+	id      uint64
+	path    string
+	file    *os.File
+	entries []sstableEntry
+	bloom   *bloomFilter
+	size    int64
+}
+
+func writeSSTable(path string, id uint64, items []lsmItem) (*sstable, error) {
+	var (
+		values bytes.Buffer
+		index  bytes.Buffer
+		num    [8]byte
+	)
+	bloom := newBloomFilter(len(items))
+
+	for _, item := range items {
+		offset := int64(values.Len())
+		values.Write(item.value)
+		bloom.add(item.key)
+
+		var flags byte
+		if item.tombstone {
+			flags = logRecordTombstone
+		}
+		index.WriteByte(flags)
+		binary.BigEndian.PutUint32(num[:4], uint32(len(item.key)))
+		index.Write(num[:4])
+		index.WriteString(item.key)
+		binary.BigEndian.PutUint64(num[:], uint64(offset))
+		index.Write(num[:])
+		binary.BigEndian.PutUint32(num[:4], uint32(len(item.value)))
+		index.Write(num[:4])
+	}
+
+	indexOffset := values.Len()
+	bloomOffset := indexOffset + index.Len()
+
+	footer := make([]byte, sstableFooterSize)
+	binary.BigEndian.PutUint64(footer[0:8], uint64(indexOffset))
+	binary.BigEndian.PutUint64(footer[8:16], uint64(bloomOffset))
+	binary.BigEndian.PutUint32(footer[16:20], uint32(len(items)))
+	binary.BigEndian.PutUint32(footer[20:24], bloom.k)
+
+	crc := crc32.NewIEEE()
+	crc.Write(values.Bytes())
+	crc.Write(index.Bytes())
+	crc.Write(bloom.bits)
+	crc.Write(footer[:24])
+	binary.BigEndian.PutUint32(footer[24:28], crc.Sum32())
+	binary.BigEndian.PutUint32(footer[28:32], sstableMagic)
+
+	tmp := path + ".tmp"
+	file, err := os.Create(tmp)
+	if err != nil {
+		return nil, err
+	}
+
+	w := bufio.NewWriter(file)
+	for _, part := range [][]byte{values.Bytes(), index.Bytes(), bloom.bits, footer} {
+		if _, err := w.Write(part); err != nil {
+			file.Close()
+			return nil, err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		file.Close()
+		return nil, err
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return nil, err
+	}
+	if err := file.Close(); err != nil {
+		return nil, err
+	}
+
+	if err := os.Rename(tmp, path); err != nil {
+		return nil, err
+	}
+
+	return openSSTable(path, id)
+}
+
+func openSSTable(path string, id uint64) (*sstable, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	corrupt := fmt.Errorf("%w: %s", ErrCorruptDataFile, path)
+	if len(data) < sstableFooterSize {
+		return nil, corrupt
+	}
+
+	footer := data[len(data)-sstableFooterSize:]
+	if binary.BigEndian.Uint32(footer[28:32]) != sstableMagic {
+		return nil, corrupt
+	}
+
+	crc := crc32.NewIEEE()
+	crc.Write(data[:len(data)-sstableFooterSize+24])
+	if crc.Sum32() != binary.BigEndian.Uint32(footer[24:28]) {
+		return nil, corrupt
+	}
+
+	indexOffset := int(binary.BigEndian.Uint64(footer[0:8]))
+	bloomOffset := int(binary.BigEndian.Uint64(footer[8:16]))
+	count := int(binary.BigEndian.Uint32(footer[16:20]))
+	if indexOffset > bloomOffset || bloomOffset > len(data)-sstableFooterSize {
+		return nil, corrupt
+	}
+
+	entries := make([]sstableEntry, 0, count)
+	index := data[indexOffset:bloomOffset]
+	for len(index) > 0 {
+		if len(index) < 5 {
+			return nil, corrupt
+		}
+		flags := index[0]
+		keyLen := int(binary.BigEndian.Uint32(index[1:5]))
+		if len(index) < 5+keyLen+12 {
+			return nil, corrupt
+		}
+		key := string(index[5 : 5+keyLen])
+		rest := index[5+keyLen:]
+
+		entries = append(entries, sstableEntry{
+			key:       key,
+			offset:    int64(binary.BigEndian.Uint64(rest[0:8])),
+			size:      binary.BigEndian.Uint32(rest[8:12]),
+			tombstone: flags&logRecordTombstone != 0,
+		})
+		index = rest[12:]
+	}
+
+	if len(entries) != count {
+		return nil, corrupt
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &sstable{
+		id:      id,
+		path:    path,
+		file:    file,
+		entries: entries,
+		bloom: &bloomFilter{
+			bits: append([]byte(nil), data[bloomOffset:len(data)-sstableFooterSize]...),
+			k:    binary.BigEndian.Uint32(footer[20:24]),
+		},
+		size: int64(len(data)),
+	}, nil
+}
+
+func (t *sstable) minKey() string {
+	return t.entries[0].key
+}
+
+func (t *sstable) maxKey() string {
+	return t.entries[len(t.entries)-1].key
+}
+
+func (t *sstable) lookup(key string) (sstableEntry, bool) {
+	if len(t.entries) == 0 || !t.bloom.mayContain(key) {
+		return sstableEntry{}, false
+	}
+
+	i := sort.Search(len(t.entries), func(i int) bool {
+		return t.entries[i].key >= key
+	})
+	if i == len(t.entries) || t.entries[i].key != key {
+		return sstableEntry{}, false
+	}
+	return t.entries[i], true
+}
+
+func (t *sstable) readValue(entry sstableEntry) ([]byte, error) {
+	value := make([]byte, entry.size)
+	if _, err := t.file.ReadAt(value, entry.offset); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+func (t *sstable) close() error {
+	return t.file.Close()
+}
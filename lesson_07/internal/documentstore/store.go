@@ -1,23 +1,46 @@
 package documentstore
 
-import "log/slog"
+import (
+	"errors"
+	"log/slog"
+	"os"
+	"time"
+)
 
 type Store struct {
 	collections map[string]*Collection
 	logger      *slog.Logger
+	changes     *changeLog
+	createdAt   time.Time
+	lastDumpAt  time.Time
+	labels      map[string]string
+	events      *eventHub
+	// namespaces holds the child stores of the root store; a namespace
+	// itself has none and keeps its own name in namespace.
+	namespaces map[string]*Store
+	namespace  string
+	quota      *quotaTracker
+	// scratch is set on a store rebuilt by RestoreAt: its disk-engine
+	// collections get fresh data dirs below it, removed on Close.
+	scratch string
+	// now, when set, is the clock of new collections; RestoreAt runs it
+	// from the instant it restores.
+	now func() time.Time
 }
 
 func NewStore() *Store {
-	return &Store{
-		collections: make(map[string]*Collection),
-		logger:      slog.Default(),
-	}
+	return NewStoreWithLogger(slog.Default())
 }
 
 func NewStoreWithLogger(logger *slog.Logger) *Store {
 	return &Store{
 		collections: make(map[string]*Collection),
 		logger:      logger,
+		createdAt:   time.Now(),
+		labels:      make(map[string]string),
+		events:      newEventHub(),
+		namespaces:  make(map[string]*Store),
+		quota:       &quotaTracker{},
 	}
 }
 
@@ -32,11 +55,39 @@ func (s *Store) CreateCollection(name string, cfg *CollectionConfig) (*Collectio
 		return nil, ErrCollectionAlreadyExists
 	}
 
-	coll := NewCollection(*cfg)
+	coll, err := OpenCollection(*cfg)
+	if err != nil {
+		s.logger.Error("failed to create collection: engine open failed", "collection", name, "engine", cfg.Engine, "error", err)
+		return nil, err
+	}
+
+	if s.changes != nil {
+		if err := s.changes.append(ChangeRecord{Op: ChangeOpCreateCollection, Namespace: s.namespace, Collection: name, Config: cfg}); err != nil {
+			s.logger.Error("failed to create collection: change log write failed", "collection", name, "error", err)
+			coll.Close()
+			return nil, err
+		}
+	}
+
+	if s.now != nil {
+		coll.mu.Lock()
+		coll.now = s.now
+		coll.mu.Unlock()
+	}
+
+	coll.name = name
+	coll.namespace = s.namespace
 	coll.logger = s.logger
+	coll.changes = s.changes
+	coll.events, coll.ownsEvents = s.events, false
+	coll.quota.scope = name
+	coll.storeQuota = s.quota
+	s.quota.add(coll.quota.stats().Usage)
 	s.collections[name] = coll
 
-	s.logger.Info("collection created", "collection", name, "primary_key", cfg.PrimaryKey)
+	s.events.publish(ChangeEvent{Time: time.Now(), Type: EventCollectionCreated, Collection: name})
+
+	s.logger.Info("collection created", "collection", name, "primary_key", cfg.keyFields())
 	return coll, nil
 }
 
@@ -50,12 +101,87 @@ func (s *Store) GetCollection(name string) (*Collection, error) {
 }
 
 func (s *Store) DeleteCollection(name string) error {
-	if _, ok := s.collections[name]; !ok {
+	coll, ok := s.collections[name]
+	if !ok {
 		s.logger.Warn("failed to delete collection: not found", "collection", name)
 		return ErrCollectionNotFound
 	}
 
+	if s.changes != nil {
+		if err := s.changes.append(ChangeRecord{Op: ChangeOpDeleteCollection, Namespace: s.namespace, Collection: name}); err != nil {
+			s.logger.Error("failed to delete collection: change log write failed", "collection", name, "error", err)
+			return err
+		}
+	}
+
 	delete(s.collections, name)
+	s.quota.add(coll.quota.stats().Usage.negate())
+	s.events.publish(ChangeEvent{Time: time.Now(), Type: EventCollectionDropped, Collection: name})
+	if err := coll.Close(); err != nil {
+		s.logger.Error("failed to close deleted collection", "collection", name, "error", err)
+	}
+
 	s.logger.Info("collection deleted", "collection", name)
 	return nil
 }
+
+// rebuildConfig gives a disk-engine collection of a store rebuilt by
+// RestoreAt a fresh DataDir, so the rebuild never touches live files.
+func (s *Store) rebuildConfig(cfg CollectionConfig) (CollectionConfig, error) {
+	if s.scratch == "" || !cfg.Engine.onDisk() {
+		return cfg, nil
+	}
+
+	dir, err := os.MkdirTemp(s.scratch, "collection-")
+	if err != nil {
+		return cfg, err
+	}
+	cfg.DataDir = dir
+	return cfg, nil
+}
+
+// Close ends all change streams and releases the change log and the
+// storage engines of all collections. The store must not be used afterwards.
+func (s *Store) Close() error {
+	var errs []error
+
+	for name, ns := range s.namespaces {
+		if err := ns.Close(); err != nil {
+			s.logger.Error("failed to close namespace", "namespace", name, "error", err)
+			errs = append(errs, err)
+		}
+	}
+
+	s.events.close()
+
+	// Namespaces share the change log of the root store, which closes it.
+	if s.changes != nil && s.namespace == "" {
+		if err := s.changes.close(); err != nil {
+			s.logger.Error("failed to close change log", "error", err)
+			errs = append(errs, err)
+		}
+	}
+	s.changes = nil
+
+	for name, coll := range s.collections {
+		coll.changes = nil
+		if err := coll.Close(); err != nil {
+			s.logger.Error("failed to close collection", "collection", name, "error", err)
+			errs = append(errs, err)
+		}
+	}
+
+	if s.scratch != "" && s.namespace == "" {
+		if err := os.RemoveAll(s.scratch); err != nil {
+			s.logger.Error("failed to remove scratch dir", "dir", s.scratch, "error", err)
+			errs = append(errs, err)
+		}
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		return err
+	}
+
+	s.logger.Info("store closed")
+	return nil
+}
@@ -0,0 +1,122 @@
+package documentstore
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+)
+
+// DumpFormatVersion is the StoreDump format written by this code. Bump it
+// whenever the dump shape changes and register an upgrade from the
+// previous version.
+const DumpFormatVersion = 4
+
+// DumpUpgrade transforms a decoded dump of one format version in place so
+// that it matches the next version.
+type DumpUpgrade func(dump map[string]any) error
+
+var (
+	dumpUpgradesMu sync.RWMutex
+	dumpUpgrades   = map[int]DumpUpgrade{
+		0: upgradeDumpV0,
+		1: upgradeDumpV1,
+		2: upgradeDumpV2,
+		3: upgradeDumpV3,
+	}
+)
+
+// RegisterDumpUpgrade registers the upgrade from version from to from+1,
+// replacing any previously registered one.
+func RegisterDumpUpgrade(from int, upgrade DumpUpgrade) {
+	dumpUpgradesMu.Lock()
+	defer dumpUpgradesMu.Unlock()
+
+	dumpUpgrades[from] = upgrade
+}
+
+// upgradeDumpV0 handles dumps written before versioning existed. Their
+// shape is identical to version 1.
+func upgradeDumpV0(dump map[string]any) error {
+	if _, ok := dump["collections"]; !ok {
+		dump["collections"] = map[string]any{}
+	}
+	return nil
+}
+
+func decodeDump(data []byte) (*StoreDump, error) {
+	var raw map[string]any
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+
+	version, err := dumpVersion(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	if version != DumpFormatVersion {
+		dumpUpgradesMu.RLock()
+		err := upgradeDump(raw, version, DumpFormatVersion, dumpUpgrades)
+		dumpUpgradesMu.RUnlock()
+		if err != nil {
+			return nil, err
+		}
+
+		if data, err = json.Marshal(raw); err != nil {
+			return nil, err
+		}
+	}
+
+	var dump StoreDump
+	if err := json.Unmarshal(data, &dump); err != nil {
+		return nil, err
+	}
+
+	return &dump, nil
+}
+
+func dumpVersion(raw map[string]any) (int, error) {
+	v, ok := raw["version"]
+	if !ok {
+		return 0, nil
+	}
+
+	n, ok := v.(float64)
+	if !ok || n < 0 || n != float64(int(n)) {
+		return 0, fmt.Errorf("%w: %v", ErrUnsupportedDumpVersion, v)
+	}
+
+	return int(n), nil
+}
+
+func upgradeDump(raw map[string]any, from, to int, upgrades map[int]DumpUpgrade) error {
+	if from > to {
+		return fmt.Errorf("%w: dump version %d is newer than supported version %d", ErrUnsupportedDumpVersion, from, to)
+	}
+
+	for v := from; v < to; v++ {
+		upgrade, ok := upgrades[v]
+		if !ok {
+			return fmt.Errorf("%w: no upgrade from version %d", ErrUnsupportedDumpVersion, v)
+		}
+
+		if err := upgrade(raw); err != nil {
+			return fmt.Errorf("upgrade dump from version %d: %w", v, err)
+		}
+		raw["version"] = v + 1
+	}
+
+	return nil
+}
+
+// upgradeDumpV2 handles dumps written before document expiry existed.
+// Their documents never expire, so no expiries section is needed.
+func upgradeDumpV2(map[string]any) error {
+	return nil
+}
+
+// upgradeDumpV3 handles dumps written before namespaces existed. Their
+// collections all belong to the root store.
+func upgradeDumpV3(map[string]any) error {
+	return nil
+}
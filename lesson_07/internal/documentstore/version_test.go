@@ -0,0 +1,113 @@
+package documentstore
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStore_Dump_WritesVersion(t *testing.T) {
+	store := NewStore()
+
+	data, err := store.Dump()
+	require.NoError(t, err)
+
+	var dump StoreDump
+	require.NoError(t, json.Unmarshal(data, &dump))
+	assert.Equal(t, DumpFormatVersion, dump.Version)
+}
+
+func TestNewStoreFromDump_Versions(t *testing.T) {
+	tests := []struct {
+		name    string
+		dump    string
+		wantErr error
+		check   func(*testing.T, *Store)
+	}{
+		{
+			name: "loads unversioned dump",
+			dump: `{"collections":{"users":{"config":{"PrimaryKey":"id"},"documents":[` +
+				`{"Fields":{"id":{"Type":"string","Value":"user:1"}}}]}}}`,
+			check: func(t *testing.T, s *Store) {
+				coll, err := s.GetCollection("users")
+				require.NoError(t, err)
+				_, err = coll.Get("user:1")
+				assert.NoError(t, err)
+			},
+		},
+		{
+			name: "loads unversioned dump without collections",
+			dump: `{}`,
+			check: func(t *testing.T, s *Store) {
+				assert.Empty(t, s.collections)
+			},
+		},
+		{
+			name:    "rejects dump from newer code",
+			dump:    fmt.Sprintf(`{"version":%d,"collections":{}}`, DumpFormatVersion+1),
+			wantErr: ErrUnsupportedDumpVersion,
+		},
+		{
+			name:    "rejects malformed version",
+			dump:    `{"version":"one","collections":{}}`,
+			wantErr: ErrUnsupportedDumpVersion,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store, err := NewStoreFromDump([]byte(tt.dump))
+
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				assert.Nil(t, store)
+			} else {
+				require.NoError(t, err)
+				tt.check(t, store)
+			}
+		})
+	}
+}
+
+func TestUpgradeDump(t *testing.T) {
+	upgrades := map[int]DumpUpgrade{
+		1: func(dump map[string]any) error {
+			dump["renamed"] = dump["old"]
+			delete(dump, "old")
+			return nil
+		},
+		2: func(dump map[string]any) error {
+			dump["added"] = true
+			return nil
+		},
+	}
+
+	t.Run("applies upgrades in order", func(t *testing.T) {
+		raw := map[string]any{"version": float64(1), "old": "value"}
+
+		require.NoError(t, upgradeDump(raw, 1, 3, upgrades))
+		assert.Equal(t, map[string]any{"version": 3, "renamed": "value", "added": true}, raw)
+	})
+
+	t.Run("returns error for missing upgrade", func(t *testing.T) {
+		err := upgradeDump(map[string]any{}, 0, 3, upgrades)
+		assert.ErrorIs(t, err, ErrUnsupportedDumpVersion)
+	})
+
+	t.Run("returns error for newer dump", func(t *testing.T) {
+		err := upgradeDump(map[string]any{}, 4, 3, upgrades)
+		assert.ErrorIs(t, err, ErrUnsupportedDumpVersion)
+	})
+
+	t.Run("propagates upgrade error", func(t *testing.T) {
+		errBroken := errors.New("broken")
+		err := upgradeDump(map[string]any{}, 0, 1, map[int]DumpUpgrade{
+			0: func(map[string]any) error { return errBroken },
+		})
+		assert.ErrorIs(t, err, errBroken)
+	})
+}
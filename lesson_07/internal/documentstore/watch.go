@@ -0,0 +1,372 @@
+package documentstore
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+)
+
+type EventType string
+
+const (
+	EventInsert            EventType = "insert"
+	EventUpdate            EventType = "update"
+	EventDelete            EventType = "delete"
+	EventCollectionCreated EventType = "collection_created"
+	EventCollectionDropped EventType = "collection_dropped"
+)
+
+// ChangeEvent describes one mutation. Seq increases by one per event and
+// can be passed back as WatchOptions.ResumeAfter to continue a stream.
+// Expired and evicted documents are reported as deletes.
+type ChangeEvent struct {
+	Seq        uint64
+	Time       time.Time
+	Type       EventType
+	Collection string
+	Key        string
+	Before     *Document
+	After      *Document
+}
+
+type OverflowPolicy string
+
+const (
+	// OverflowDisconnect ends the stream of a consumer whose buffer is full
+	// with ErrSlowConsumer; it can resume from the last event it received.
+	OverflowDisconnect OverflowPolicy = "disconnect"
+	// OverflowBlock queues events for a consumer whose buffer is full and
+	// makes writers to the watched collections wait, once they have released
+	// their locks, until the consumer catches up or its context is cancelled.
+	OverflowBlock OverflowPolicy = "block"
+)
+
+const (
+	defaultWatchBuffer = 64
+	changeHistorySize  = 1024
+)
+
+type WatchOptions struct {
+	// Collections limits Store.Watch to the named collections. Empty means
+	// all collections. Ignored by Collection.Watch.
+	Collections []string
+	// ResumeAfter replays retained events with a greater sequence number
+	// before streaming new ones. Zero streams only new events.
+	ResumeAfter uint64
+	// BufferSize defaults to 64.
+	BufferSize int
+	// Overflow defaults to OverflowDisconnect.
+	Overflow OverflowPolicy
+}
+
+// ChangeStream delivers events until its context is cancelled, the store
+// is closed, the consumer falls behind, or the watched collection is
+// dropped. Err explains why Events was closed.
+type ChangeStream struct {
+	events chan ChangeEvent
+	stop   chan struct{}
+
+	mu  sync.Mutex
+	err error
+}
+
+func (cs *ChangeStream) Events() <-chan ChangeEvent {
+	return cs.events
+}
+
+func (cs *ChangeStream) Err() error {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	return cs.err
+}
+
+type subscription struct {
+	ctx        context.Context
+	stream     *ChangeStream
+	match      func(ChangeEvent) bool
+	collection string
+	overflow   OverflowPolicy
+
+	// Events that did not fit the stream's buffer wait in pending, which
+	// a pump goroutine feeds to the stream in order. drained is closed when
+	// pending empties or the subscription ends. dropped marks a
+	// subscription whose collection was dropped; it ends once pending is
+	// delivered. All guarded by the hub's mu.
+	pending []ChangeEvent
+	drained chan struct{}
+	pumping bool
+	dropped bool
+	ended   bool
+}
+
+// eventHub numbers events, keeps a bounded history for resuming and fans
+// events out to subscribers. A store shares one hub among its collections.
+type eventHub struct {
+	mu      sync.Mutex
+	seq     uint64
+	history []ChangeEvent
+	subs    map[*subscription]struct{}
+	closed  bool
+}
+
+func newEventHub() *eventHub {
+	return &eventHub{subs: make(map[*subscription]struct{})}
+}
+
+func (h *eventHub) subscribe(ctx context.Context, opts WatchOptions, collection string, match func(ChangeEvent) bool) (*ChangeStream, error) {
+	if opts.BufferSize < 0 {
+		return nil, fmt.Errorf("%w: negative buffer size", ErrInvalidWatchOptions)
+	}
+	if opts.BufferSize == 0 {
+		opts.BufferSize = defaultWatchBuffer
+	}
+	if opts.Overflow == "" {
+		opts.Overflow = OverflowDisconnect
+	}
+	if opts.Overflow != OverflowDisconnect && opts.Overflow != OverflowBlock {
+		return nil, fmt.Errorf("%w: unknown overflow policy %q", ErrInvalidWatchOptions, opts.Overflow)
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.closed {
+		return nil, ErrStoreClosed
+	}
+
+	var replay []ChangeEvent
+	if opts.ResumeAfter > 0 {
+		if opts.ResumeAfter > h.seq {
+			return nil, fmt.Errorf("%w: %d is ahead of %d", ErrInvalidResumeToken, opts.ResumeAfter, h.seq)
+		}
+		if len(h.history) > 0 && opts.ResumeAfter < h.history[0].Seq-1 {
+			return nil, fmt.Errorf("%w: %d is older than retained history", ErrInvalidResumeToken, opts.ResumeAfter)
+		}
+		for _, ev := range h.history {
+			if ev.Seq > opts.ResumeAfter && match(ev) {
+				replay = append(replay, ev)
+			}
+		}
+	}
+
+	// The buffer grows by the replayed events so that a resume never
+	// overflows before the consumer has had a chance to read.
+	stream := &ChangeStream{
+		events: make(chan ChangeEvent, opts.BufferSize+len(replay)),
+		stop:   make(chan struct{}),
+	}
+	for _, ev := range replay {
+		stream.events <- ev
+	}
+
+	sub := &subscription{
+		ctx:        ctx,
+		stream:     stream,
+		match:      match,
+		collection: collection,
+		overflow:   opts.Overflow,
+	}
+	h.subs[sub] = struct{}{}
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			h.mu.Lock()
+			defer h.mu.Unlock()
+			h.end(sub, ctx.Err())
+		case <-stream.stop:
+		}
+	}()
+
+	return stream, nil
+}
+
+// end closes a subscription, discarding any pending events. The caller
+// holds h.mu.
+func (h *eventHub) end(sub *subscription, err error) {
+	if sub.ended {
+		return
+	}
+	sub.ended = true
+	delete(h.subs, sub)
+
+	sub.stream.mu.Lock()
+	sub.stream.err = err
+	sub.stream.mu.Unlock()
+
+	sub.pending = nil
+	if sub.drained != nil {
+		close(sub.drained)
+		sub.drained = nil
+	}
+	close(sub.stream.stop)
+	// A running pump owns the events channel and closes it when it exits.
+	if !sub.pumping {
+		close(sub.stream.events)
+	}
+}
+
+func (h *eventHub) publish(ev ChangeEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.closed {
+		return
+	}
+
+	h.seq++
+	ev.Seq = h.seq
+	ev.Before = cloneDocument(ev.Before)
+	ev.After = cloneDocument(ev.After)
+
+	if len(h.history) == changeHistorySize {
+		h.history = slices.Delete(h.history, 0, 1)
+	}
+	h.history = append(h.history, ev)
+
+	for sub := range h.subs {
+		if sub.dropped || !sub.match(ev) {
+			continue
+		}
+		h.deliver(sub, ev)
+
+		if !sub.ended && ev.Type == EventCollectionDropped && sub.collection != "" && ev.Collection == sub.collection {
+			if len(sub.pending) == 0 {
+				h.end(sub, nil)
+			} else {
+				sub.dropped = true
+			}
+		}
+	}
+}
+
+// deliver hands ev to a subscriber without blocking. The caller holds h.mu.
+func (h *eventHub) deliver(sub *subscription, ev ChangeEvent) {
+	if len(sub.pending) == 0 {
+		select {
+		case sub.stream.events <- ev:
+			return
+		default:
+		}
+	}
+
+	if sub.overflow == OverflowDisconnect {
+		h.end(sub, ErrSlowConsumer)
+		return
+	}
+
+	if len(sub.pending) == 0 {
+		sub.drained = make(chan struct{})
+	}
+	sub.pending = append(sub.pending, ev)
+	if !sub.pumping {
+		sub.pumping = true
+		go h.pump(sub)
+	}
+}
+
+// pump sends a subscriber's pending events to its stream until none are
+// left or the subscription ends.
+func (h *eventHub) pump(sub *subscription) {
+	h.mu.Lock()
+	for !sub.ended && len(sub.pending) > 0 {
+		ev := sub.pending[0]
+		h.mu.Unlock()
+
+		select {
+		case sub.stream.events <- ev:
+		case <-sub.stream.stop:
+		}
+
+		h.mu.Lock()
+		if sub.ended {
+			break
+		}
+		sub.pending = slices.Delete(sub.pending, 0, 1)
+		if len(sub.pending) == 0 {
+			close(sub.drained)
+			sub.drained = nil
+			if sub.dropped {
+				h.end(sub, nil)
+			}
+		}
+	}
+
+	sub.pumping = false
+	if sub.ended {
+		close(sub.stream.events)
+	}
+	h.mu.Unlock()
+}
+
+// wait blocks until every OverflowBlock subscriber watching the collection
+// has caught up with the events queued for it, or has ended. Writers call
+// it after releasing their locks.
+func (h *eventHub) wait(collection string) {
+	h.mu.Lock()
+	var drained []chan struct{}
+	for sub := range h.subs {
+		if sub.drained != nil && sub.match(ChangeEvent{Collection: collection}) {
+			drained = append(drained, sub.drained)
+		}
+	}
+	h.mu.Unlock()
+
+	for _, ch := range drained {
+		<-ch
+	}
+}
+
+func (h *eventHub) close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.closed = true
+	for sub := range h.subs {
+		h.end(sub, ErrStoreClosed)
+	}
+}
+
+func cloneDocument(doc *Document) *Document {
+	if doc == nil {
+		return nil
+	}
+	return &Document{Fields: maps.Clone(doc.Fields)}
+}
+
+// Watch streams changes to the documents of this collection. For a
+// collection owned by a store, the stream ends when the collection is
+// dropped.
+func (c *Collection) Watch(ctx context.Context, opts WatchOptions) (*ChangeStream, error) {
+	name := c.name
+	return c.events.subscribe(ctx, opts, name, func(ev ChangeEvent) bool {
+		return ev.Collection == name
+	})
+}
+
+func (c *Collection) publish(typ EventType, key string, before, after *Document) {
+	c.events.publish(ChangeEvent{
+		Time:       c.now(),
+		Type:       typ,
+		Collection: c.name,
+		Key:        key,
+		Before:     before,
+		After:      after,
+	})
+}
+
+// Watch streams document and collection changes across the store.
+func (s *Store) Watch(ctx context.Context, opts WatchOptions) (*ChangeStream, error) {
+	match := func(ChangeEvent) bool { return true }
+	if len(opts.Collections) > 0 {
+		names := slices.Clone(opts.Collections)
+		match = func(ev ChangeEvent) bool {
+			return slices.Contains(names, ev.Collection)
+		}
+	}
+	return s.events.subscribe(ctx, opts, "", match)
+}
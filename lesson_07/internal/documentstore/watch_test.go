@@ -0,0 +1,362 @@
+package documentstore
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func drain(t *testing.T, stream *ChangeStream) []ChangeEvent {
+	t.Helper()
+
+	var events []ChangeEvent
+	timeout := time.After(time.Second)
+	for {
+		select {
+		case ev, ok := <-stream.Events():
+			if !ok {
+				return events
+			}
+			events = append(events, ev)
+		case <-timeout:
+			t.Fatal("change stream was not closed")
+		}
+	}
+}
+
+func next(t *testing.T, stream *ChangeStream) ChangeEvent {
+	t.Helper()
+
+	select {
+	case ev, ok := <-stream.Events():
+		require.True(t, ok, "change stream closed: %v", stream.Err())
+		return ev
+	case <-time.After(time.Second):
+		t.Fatal("no change event")
+		return ChangeEvent{}
+	}
+}
+
+func TestStore_Watch(t *testing.T) {
+	store := NewStore()
+	stream, err := store.Watch(context.Background(), WatchOptions{})
+	require.NoError(t, err)
+
+	users, err := store.CreateCollection("users", &CollectionConfig{PrimaryKey: "id"})
+	require.NoError(t, err)
+	require.NoError(t, users.Put(userDoc("u1", "Alice")))
+	require.NoError(t, users.Put(userDoc("u1", "Alicia")))
+	require.NoError(t, users.Delete("u1"))
+	require.NoError(t, store.DeleteCollection("users"))
+	require.NoError(t, store.Close())
+
+	events := drain(t, stream)
+	assert.ErrorIs(t, stream.Err(), ErrStoreClosed)
+
+	require.Len(t, events, 5)
+	for i, ev := range events {
+		assert.Equal(t, uint64(i+1), ev.Seq)
+		assert.Equal(t, "users", ev.Collection)
+	}
+
+	assert.Equal(t, EventCollectionCreated, events[0].Type)
+
+	assert.Equal(t, EventInsert, events[1].Type)
+	assert.Equal(t, "u1", events[1].Key)
+	assert.Nil(t, events[1].Before)
+	assert.Equal(t, "Alice", events[1].After.Fields["name"].Value)
+
+	assert.Equal(t, EventUpdate, events[2].Type)
+	assert.Equal(t, "Alice", events[2].Before.Fields["name"].Value)
+	assert.Equal(t, "Alicia", events[2].After.Fields["name"].Value)
+
+	assert.Equal(t, EventDelete, events[3].Type)
+	assert.Equal(t, "Alicia", events[3].Before.Fields["name"].Value)
+	assert.Nil(t, events[3].After)
+
+	assert.Equal(t, EventCollectionDropped, events[4].Type)
+}
+
+func TestStore_WatchCollectionsFilter(t *testing.T) {
+	store := NewStore()
+	defer store.Close()
+
+	stream, err := store.Watch(context.Background(), WatchOptions{Collections: []string{"orders"}})
+	require.NoError(t, err)
+
+	users, err := store.CreateCollection("users", &CollectionConfig{PrimaryKey: "id"})
+	require.NoError(t, err)
+	orders, err := store.CreateCollection("orders", &CollectionConfig{PrimaryKey: "id"})
+	require.NoError(t, err)
+	require.NoError(t, users.Put(userDoc("u1", "Alice")))
+	require.NoError(t, orders.Put(userDoc("o1", "Order")))
+
+	assert.Equal(t, EventCollectionCreated, next(t, stream).Type)
+	ev := next(t, stream)
+	assert.Equal(t, EventInsert, ev.Type)
+	assert.Equal(t, "o1", ev.Key)
+}
+
+func TestCollection_Watch(t *testing.T) {
+	store := NewStore()
+	defer store.Close()
+
+	users, err := store.CreateCollection("users", &CollectionConfig{PrimaryKey: "id"})
+	require.NoError(t, err)
+	orders, err := store.CreateCollection("orders", &CollectionConfig{PrimaryKey: "id"})
+	require.NoError(t, err)
+
+	stream, err := users.Watch(context.Background(), WatchOptions{})
+	require.NoError(t, err)
+
+	require.NoError(t, orders.Put(userDoc("o1", "Order")))
+	require.NoError(t, users.Put(userDoc("u1", "Alice")))
+	require.NoError(t, store.DeleteCollection("users"))
+
+	events := drain(t, stream)
+	require.NoError(t, stream.Err())
+	require.Len(t, events, 2)
+	assert.Equal(t, "u1", events[0].Key)
+	assert.Equal(t, EventCollectionDropped, events[1].Type)
+}
+
+func TestCollection_WatchStandalone(t *testing.T) {
+	coll := NewCollection(CollectionConfig{PrimaryKey: "id"})
+
+	stream, err := coll.Watch(context.Background(), WatchOptions{})
+	require.NoError(t, err)
+
+	require.NoError(t, coll.Put(userDoc("a", "A")))
+	require.NoError(t, coll.Close())
+
+	events := drain(t, stream)
+	assert.ErrorIs(t, stream.Err(), ErrStoreClosed)
+	require.Len(t, events, 1)
+	assert.Equal(t, EventInsert, events[0].Type)
+}
+
+func TestWatch_Resume(t *testing.T) {
+	store := NewStore()
+	defer store.Close()
+
+	users, err := store.CreateCollection("users", &CollectionConfig{PrimaryKey: "id"})
+	require.NoError(t, err)
+	for _, key := range []string{"a", "b", "c"} {
+		require.NoError(t, users.Put(userDoc(key, key)))
+	}
+
+	tests := []struct {
+		name     string
+		after    uint64
+		wantKeys []string
+		wantErr  error
+	}{
+		{name: "from start", after: 1, wantKeys: []string{"a", "b", "c"}},
+		{name: "from middle", after: 3, wantKeys: []string{"c"}},
+		{name: "up to date", after: 4, wantKeys: nil},
+		{name: "ahead of store", after: 5, wantErr: ErrInvalidResumeToken},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			stream, err := users.Watch(ctx, WatchOptions{ResumeAfter: tt.after})
+			if tt.wantErr != nil {
+				cancel()
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+
+			cancel()
+			events := drain(t, stream)
+			assert.ErrorIs(t, stream.Err(), context.Canceled)
+
+			var keys []string
+			for _, ev := range events {
+				keys = append(keys, ev.Key)
+			}
+			assert.Equal(t, tt.wantKeys, keys)
+		})
+	}
+}
+
+func TestWatch_ResumeTokenExpired(t *testing.T) {
+	coll := NewCollection(CollectionConfig{PrimaryKey: "id"})
+	defer coll.Close()
+
+	for i := 0; i < changeHistorySize+2; i++ {
+		require.NoError(t, coll.Put(userDoc("a", "A")))
+	}
+
+	_, err := coll.Watch(context.Background(), WatchOptions{ResumeAfter: 1})
+	assert.ErrorIs(t, err, ErrInvalidResumeToken)
+
+	stream, err := coll.Watch(context.Background(), WatchOptions{ResumeAfter: 2})
+	require.NoError(t, err)
+	assert.Equal(t, uint64(3), next(t, stream).Seq)
+}
+
+func TestWatch_SlowConsumerDisconnected(t *testing.T) {
+	coll := NewCollection(CollectionConfig{PrimaryKey: "id"})
+	defer coll.Close()
+
+	stream, err := coll.Watch(context.Background(), WatchOptions{BufferSize: 1})
+	require.NoError(t, err)
+
+	require.NoError(t, coll.Put(userDoc("a", "A")))
+	require.NoError(t, coll.Put(userDoc("b", "B")))
+
+	events := drain(t, stream)
+	assert.ErrorIs(t, stream.Err(), ErrSlowConsumer)
+	require.Len(t, events, 1)
+
+	// The consumer catches up by resuming from the last event it saw.
+	resumed, err := coll.Watch(context.Background(), WatchOptions{ResumeAfter: events[0].Seq})
+	require.NoError(t, err)
+	assert.Equal(t, "b", next(t, resumed).Key)
+}
+
+func TestWatch_OverflowBlock(t *testing.T) {
+	coll := NewCollection(CollectionConfig{PrimaryKey: "id"})
+	defer coll.Close()
+
+	stream, err := coll.Watch(context.Background(), WatchOptions{BufferSize: 1, Overflow: OverflowBlock})
+	require.NoError(t, err)
+
+	keys := []string{"a", "b", "c", "d"}
+	written := make(chan error, 1)
+	go func() {
+		for _, key := range keys {
+			if err := coll.Put(userDoc(key, key)); err != nil {
+				written <- err
+				return
+			}
+		}
+		written <- nil
+	}()
+
+	for _, key := range keys {
+		assert.Equal(t, key, next(t, stream).Key)
+	}
+	require.NoError(t, <-written)
+}
+
+func TestWatch_OverflowBlockReleasedByCancel(t *testing.T) {
+	coll := NewCollection(CollectionConfig{PrimaryKey: "id"})
+	defer coll.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stream, err := coll.Watch(ctx, WatchOptions{BufferSize: 1, Overflow: OverflowBlock})
+	require.NoError(t, err)
+
+	require.NoError(t, coll.Put(userDoc("a", "A")))
+
+	written := make(chan error, 1)
+	go func() { written <- coll.Put(userDoc("b", "B")) }()
+
+	select {
+	case <-written:
+		t.Fatal("writer was not blocked by a full consumer")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	cancel()
+	require.NoError(t, <-written)
+	drain(t, stream)
+	assert.ErrorIs(t, stream.Err(), context.Canceled)
+}
+
+func TestWatch_OverflowBlockWaitsOutsideLocks(t *testing.T) {
+	store := NewStore()
+	defer store.Close()
+
+	users, err := store.CreateCollection("users", &CollectionConfig{PrimaryKey: "id"})
+	require.NoError(t, err)
+	orders, err := store.CreateCollection("orders", &CollectionConfig{PrimaryKey: "id"})
+	require.NoError(t, err)
+
+	stream, err := users.Watch(context.Background(), WatchOptions{BufferSize: 1, Overflow: OverflowBlock})
+	require.NoError(t, err)
+
+	require.NoError(t, users.Put(userDoc("a", "A")))
+	written := make(chan error, 1)
+	go func() { written <- users.Put(userDoc("b", "B")) }()
+
+	select {
+	case <-written:
+		t.Fatal("writer was not blocked by a full consumer")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	// The blocked writer holds neither the collection nor the hub.
+	doc, err := users.Get("b")
+	require.NoError(t, err)
+	assert.Equal(t, "B", doc.Fields["name"].Value)
+	require.NoError(t, orders.Put(userDoc("o1", "Order")))
+	_, err = store.Watch(context.Background(), WatchOptions{})
+	require.NoError(t, err)
+
+	assert.Equal(t, "a", next(t, stream).Key)
+	assert.Equal(t, "b", next(t, stream).Key)
+	require.NoError(t, <-written)
+}
+
+func TestWatch_OverflowBlockDeliversQueuedEventsBeforeDrop(t *testing.T) {
+	store := NewStore()
+	defer store.Close()
+
+	users, err := store.CreateCollection("users", &CollectionConfig{PrimaryKey: "id"})
+	require.NoError(t, err)
+	stream, err := users.Watch(context.Background(), WatchOptions{BufferSize: 1, Overflow: OverflowBlock})
+	require.NoError(t, err)
+
+	require.NoError(t, users.Put(userDoc("a", "A")))
+	written := make(chan error, 1)
+	go func() { written <- users.Put(userDoc("b", "B")) }()
+	assert.Eventually(t, func() bool {
+		store.events.mu.Lock()
+		defer store.events.mu.Unlock()
+		return store.events.seq == 3
+	}, time.Second, time.Millisecond)
+
+	// The drop is queued behind "b", and the stream ends after both.
+	require.NoError(t, store.DeleteCollection("users"))
+
+	events := drain(t, stream)
+	require.NoError(t, <-written)
+	require.NoError(t, stream.Err())
+	require.Len(t, events, 3)
+	assert.Equal(t, "a", events[0].Key)
+	assert.Equal(t, "b", events[1].Key)
+	assert.Equal(t, EventCollectionDropped, events[2].Type)
+}
+
+func TestWatch_ExpiryReportedAsDelete(t *testing.T) {
+	coll, clock := expiringCollection(t, ExpiryConfig{DefaultTTL: time.Minute})
+
+	stream, err := coll.Watch(context.Background(), WatchOptions{})
+	require.NoError(t, err)
+
+	require.NoError(t, coll.Put(userDoc("a", "A")))
+	clock.Advance(time.Minute)
+	coll.ReapExpired()
+
+	assert.Equal(t, EventInsert, next(t, stream).Type)
+	ev := next(t, stream)
+	assert.Equal(t, EventDelete, ev.Type)
+	assert.Equal(t, "a", ev.Key)
+}
+
+func TestWatch_InvalidOptions(t *testing.T) {
+	coll := NewCollection(CollectionConfig{PrimaryKey: "id"})
+	defer coll.Close()
+
+	for _, opts := range []WatchOptions{{BufferSize: -1}, {Overflow: "drop"}} {
+		_, err := coll.Watch(context.Background(), opts)
+		assert.ErrorIs(t, err, ErrInvalidWatchOptions)
+	}
+}
@@ -0,0 +1,75 @@
+package httpapi
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/Nick2603/golang/lesson_07/internal/documentstore"
+)
+
+var errBadRequest = errors.New("bad request")
+
+// ErrorResponse is the body of every failed request. Code names the store
+// error behind the failure so that clients can map it back.
+type ErrorResponse struct {
+	Error      string                    `json:"error"`
+	Code       string                    `json:"code,omitempty"`
+	Violations []documentstore.Violation `json:"violations,omitempty"`
+}
+
+type errorMapping struct {
+	err    error
+	status int
+	code   string
+}
+
+var errorMappings = []errorMapping{
+	{documentstore.ErrDocumentNotFound, http.StatusNotFound, "document_not_found"},
+	{documentstore.ErrDocumentAlreadyExists, http.StatusConflict, "document_already_exists"},
+	{documentstore.ErrCollectionNotFound, http.StatusNotFound, "collection_not_found"},
+	{documentstore.ErrCollectionAlreadyExists, http.StatusConflict, "collection_already_exists"},
+	{documentstore.ErrInvalidPrimaryKey, http.StatusBadRequest, "invalid_primary_key"},
+	{documentstore.ErrUnsupportedDocumentField, http.StatusBadRequest, "unsupported_document_field"},
+	{documentstore.ErrMalformedDocument, http.StatusBadRequest, "malformed_document"},
+	{documentstore.ErrNilValue, http.StatusBadRequest, "nil_value"},
+	{documentstore.ErrValidationFailed, http.StatusUnprocessableEntity, "validation_failed"},
+	{documentstore.ErrInvalidSchema, http.StatusBadRequest, "invalid_schema"},
+	{documentstore.ErrInvalidKeyGenerator, http.StatusBadRequest, "invalid_key_generator"},
+	{documentstore.ErrInvalidEngineConfig, http.StatusBadRequest, "invalid_engine_config"},
+	{documentstore.ErrDataDirLocked, http.StatusConflict, "data_dir_in_use"},
+	{documentstore.ErrInvalidCacheConfig, http.StatusBadRequest, "invalid_cache_config"},
+	{documentstore.ErrInvalidExpiry, http.StatusBadRequest, "invalid_expiry"},
+	{documentstore.ErrInvalidQuota, http.StatusBadRequest, "invalid_quota"},
+	{documentstore.ErrQuotaExceeded, http.StatusInsufficientStorage, "quota_exceeded"},
+	{documentstore.ErrUnknownConflictPolicy, http.StatusBadRequest, "unknown_conflict_policy"},
+	{documentstore.ErrUnsupportedDumpVersion, http.StatusBadRequest, "unsupported_dump_version"},
+	{documentstore.ErrConfigMismatch, http.StatusConflict, "config_mismatch"},
+	{documentstore.ErrRestoreConflict, http.StatusConflict, "restore_conflict"},
+	{documentstore.ErrMigrationInProgress, http.StatusConflict, "migration_in_progress"},
+	{documentstore.ErrUnauthenticated, http.StatusUnauthorized, "unauthenticated"},
+	{documentstore.ErrPermissionDenied, http.StatusForbidden, "permission_denied"},
+	{documentstore.ErrStoreClosed, http.StatusServiceUnavailable, "store_closed"},
+	{errBadRequest, http.StatusBadRequest, "bad_request"},
+}
+
+// errorStatus returns the HTTP status and error code for err. Unknown
+// errors are internal server errors.
+func errorStatus(err error) (int, string) {
+	for _, m := range errorMappings {
+		if errors.Is(err, m.err) {
+			return m.status, m.code
+		}
+	}
+	return http.StatusInternalServerError, "internal"
+}
+
+// ErrorForCode returns the store error named by code, or nil if the code is
+// unknown. It is the inverse of the mapping the server applies.
+func ErrorForCode(code string) error {
+	for _, m := range errorMappings {
+		if m.code == code && m.err != errBadRequest {
+			return m.err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,640 @@
+// Package httpapi exposes a documentstore.Store over HTTP as a JSON REST API.
+package httpapi
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Nick2603/golang/lesson_07/internal/documentstore"
+)
+
+const (
+	defaultPageLimit = 100
+	maxPageLimit     = 1000
+
+	maxDocumentSize = 1 << 20
+	maxDumpSize     = 256 << 20
+)
+
+// CreateCollectionRequest is the body of POST /collections.
+type CreateCollectionRequest struct {
+	Name   string                         `json:"name"`
+	Config documentstore.CollectionConfig `json:"config"`
+}
+
+// CollectionInfo describes a collection in responses.
+type CollectionInfo struct {
+	Name          string                         `json:"name"`
+	Config        documentstore.CollectionConfig `json:"config"`
+	CreatedAt     time.Time                      `json:"created_at"`
+	DocumentCount int                            `json:"document_count"`
+	SchemaVersion int                            `json:"schema_version,omitempty"`
+	Usage         documentstore.Usage            `json:"usage"`
+}
+
+// InsertResponse is the body returned when a document is created. Key
+// holds the primary key values, in key field order.
+type InsertResponse struct {
+	Key []any `json:"key"`
+}
+
+// Page is one page of a document listing, in key order. Next is the cursor
+// to pass as ?after= for the following page; it is empty on the last page.
+type Page struct {
+	Documents []map[string]any `json:"documents"`
+	Next      string           `json:"next,omitempty"`
+	Limit     int              `json:"limit"`
+}
+
+// Server serves the REST API for a store:
+//
+//	GET    /collections                             list collections
+//	POST   /collections                             create a collection
+//	GET    /collections/{name}                      describe a collection
+//	DELETE /collections/{name}                      delete a collection
+//	GET    /collections/{name}/documents            list documents (?after=&limit=)
+//	POST   /collections/{name}/documents            insert or replace a document (?expires_at=&ttl=&if_absent=&if_exists=)
+//	GET    /collections/{name}/documents/{key...}   get a document
+//	PUT    /collections/{name}/documents/{key...}   replace a document (?expires_at=&ttl=&if_absent=&if_exists=)
+//	DELETE /collections/{name}/documents/{key...}   delete a document
+//	GET    /dump                                    dump the store
+//	POST   /restore                                 merge a dump (?conflict=&collections=)
+//
+// A document path holds its primary key values, one segment per key field,
+// e.g. /collections/lines/documents/o1/2; see KeyPath.
+//
+// Clients may only create persistent collections once a data root is set;
+// their data directories are then kept inside it.
+type Server struct {
+	// mu guards the store's collection map: requests that add or remove
+	// collections hold it exclusively, all others share it. Collections
+	// lock themselves for document operations.
+	mu       sync.RWMutex
+	store    *documentstore.Store
+	logger   *slog.Logger
+	mux      *http.ServeMux
+	dataRoot string
+}
+
+func NewServer(store *documentstore.Store, logger *slog.Logger) *Server {
+	s := &Server{store: store, logger: logger, mux: http.NewServeMux()}
+
+	s.mux.HandleFunc("GET /collections", s.listCollections)
+	s.mux.HandleFunc("POST /collections", s.createCollection)
+	s.mux.HandleFunc("GET /collections/{name}", s.getCollection)
+	s.mux.HandleFunc("DELETE /collections/{name}", s.deleteCollection)
+	s.mux.HandleFunc("GET /collections/{name}/documents", s.listDocuments)
+	s.mux.HandleFunc("POST /collections/{name}/documents", s.insertDocument)
+	s.mux.HandleFunc("GET /collections/{name}/documents/{key...}", s.getDocument)
+	s.mux.HandleFunc("PUT /collections/{name}/documents/{key...}", s.putDocument)
+	s.mux.HandleFunc("DELETE /collections/{name}/documents/{key...}", s.deleteDocument)
+	s.mux.HandleFunc("GET /stats", s.stats)
+	s.mux.HandleFunc("GET /dump", s.dump)
+	s.mux.HandleFunc("POST /restore", s.restore)
+
+	return s
+}
+
+// SetDataRoot sets the directory that client-created collections keep their
+// data in. It must be called before the server handles requests.
+func (s *Server) SetDataRoot(dir string) {
+	if abs, err := filepath.Abs(dir); err == nil {
+		dir = abs
+	}
+	s.dataRoot = dir
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+func (s *Server) listCollections(w http.ResponseWriter, r *http.Request) {
+	s.mu.RLock()
+	info := s.store.Info()
+	s.mu.RUnlock()
+
+	out := make([]CollectionInfo, 0, len(info.Collections))
+	for _, ci := range info.Collections {
+		out = append(out, collectionInfo(ci))
+	}
+	s.writeJSON(w, http.StatusOK, out)
+}
+
+func (s *Server) createCollection(w http.ResponseWriter, r *http.Request) {
+	var req CreateCollectionRequest
+	if err := decodeJSON(w, r, maxDocumentSize, &req); err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+	if req.Name == "" {
+		s.writeError(w, r, fmt.Errorf("%w: collection name is required", errBadRequest))
+		return
+	}
+
+	coll, err := s.create(req.Name, req.Config)
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Location", "/collections/"+url.PathEscape(req.Name))
+	s.writeJSON(w, http.StatusCreated, collectionInfo(coll.Info()))
+}
+
+// create adds a collection with a client-supplied config, confined while
+// the collection map is locked so that no other request claims its data
+// directory in between.
+func (s *Server) create(name string, cfg documentstore.CollectionConfig) (*documentstore.Collection, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cfg, err := s.confineConfig(name, cfg, s.dataDirsInUse())
+	if err != nil {
+		return nil, err
+	}
+	return s.store.CreateCollection(name, &cfg)
+}
+
+func (s *Server) getCollection(w http.ResponseWriter, r *http.Request) {
+	coll, err := s.collection(r)
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+	s.writeJSON(w, http.StatusOK, collectionInfo(coll.Info()))
+}
+
+func (s *Server) deleteCollection(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	err := s.store.DeleteCollection(r.PathValue("name"))
+	s.mu.Unlock()
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) listDocuments(w http.ResponseWriter, r *http.Request) {
+	limit, err := queryInt(r, "limit", defaultPageLimit)
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+	if limit <= 0 || limit > maxPageLimit {
+		s.writeError(w, r, fmt.Errorf("%w: limit must be between 1 and %d", errBadRequest, maxPageLimit))
+		return
+	}
+
+	// The cursor is the last key of the previous page; the listing resumes
+	// right after it, so concurrent writes never shift the pages.
+	start := ""
+	if after := r.URL.Query().Get("after"); after != "" {
+		key, err := base64.RawURLEncoding.DecodeString(after)
+		if err != nil || len(key) == 0 {
+			s.writeError(w, r, fmt.Errorf("%w: invalid after cursor", errBadRequest))
+			return
+		}
+		start = string(key) + "\x00"
+	}
+
+	coll, err := s.collection(r)
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+
+	page := Page{Documents: []map[string]any{}, Limit: limit}
+	var last string
+	err = coll.ScanFrom(start, func(key string, doc documentstore.Document) bool {
+		if len(page.Documents) == limit {
+			page.Next = base64.RawURLEncoding.EncodeToString([]byte(last))
+			return false
+		}
+		page.Documents = append(page.Documents, documentstore.EncodeDocument(doc))
+		last = key
+		return true
+	})
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+	s.writeJSON(w, http.StatusOK, page)
+}
+
+func (s *Server) insertDocument(w http.ResponseWriter, r *http.Request) {
+	doc, err := decodeDocumentBody(w, r)
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+
+	coll, err := s.collection(r)
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+
+	opts, err := putOptions(r)
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+
+	key, err := coll.Insert(doc, opts...)
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+
+	values, err := documentstore.DecodeKey(key)
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Location", r.URL.Path+"/"+KeyPath(values))
+	s.writeJSON(w, http.StatusCreated, InsertResponse{Key: values})
+}
+
+func (s *Server) getDocument(w http.ResponseWriter, r *http.Request) {
+	coll, err := s.collection(r)
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+
+	key, err := pathKey(r, coll)
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+
+	doc, err := coll.Get(key)
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+	s.writeJSON(w, http.StatusOK, documentstore.EncodeDocument(*doc))
+}
+
+func (s *Server) putDocument(w http.ResponseWriter, r *http.Request) {
+	doc, err := decodeDocumentBody(w, r)
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+
+	coll, err := s.collection(r)
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+
+	key, err := coll.DocumentKey(doc)
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+	values, err := documentstore.DecodeKey(key)
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+	segments, err := keySegments(r)
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+	if !slices.Equal(keyTexts(values), segments) {
+		s.writeError(w, r, fmt.Errorf("%w: document key does not match %q", documentstore.ErrInvalidPrimaryKey, r.PathValue("key")))
+		return
+	}
+
+	opts, err := putOptions(r)
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+
+	if err := coll.PutWithOptions(doc, opts...); err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+	s.writeJSON(w, http.StatusOK, documentstore.EncodeDocument(doc))
+}
+
+func (s *Server) deleteDocument(w http.ResponseWriter, r *http.Request) {
+	coll, err := s.collection(r)
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+
+	key, err := pathKey(r, coll)
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+
+	if err := coll.Delete(key); err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// stats reports the store quota and usage; per-collection usage is part of
+// the collection info.
+func (s *Server) stats(w http.ResponseWriter, r *http.Request) {
+	s.mu.RLock()
+	stats := s.store.QuotaStats()
+	s.mu.RUnlock()
+
+	s.writeJSON(w, http.StatusOK, stats)
+}
+
+func (s *Server) dump(w http.ResponseWriter, r *http.Request) {
+	// Dump records the dump time in the store metadata.
+	s.mu.Lock()
+	data, err := s.store.Dump()
+	s.mu.Unlock()
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(data)
+}
+
+func (s *Server) restore(w http.ResponseWriter, r *http.Request) {
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxDumpSize))
+	if err != nil {
+		s.writeError(w, r, fmt.Errorf("%w: %v", errBadRequest, err))
+		return
+	}
+
+	opts := documentstore.RestoreOptions{
+		Conflict:     documentstore.ConflictPolicy(r.URL.Query().Get("conflict")),
+		VersionField: r.URL.Query().Get("version_field"),
+	}
+	if names := r.URL.Query().Get("collections"); names != "" {
+		opts.Collections = strings.Split(names, ",")
+	}
+
+	s.mu.Lock()
+	inUse := s.dataDirsInUse()
+	opts.Config = func(name string, cfg documentstore.CollectionConfig) (documentstore.CollectionConfig, error) {
+		return s.confineConfig(name, cfg, inUse)
+	}
+	report, err := s.store.RestoreFromDump(data, opts)
+	s.mu.Unlock()
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+
+	// Clients see keys in their text form, as in document paths.
+	for _, keys := range []map[string][]string{report.Restored, report.Skipped, report.Conflicts} {
+		for _, list := range keys {
+			for i, key := range list {
+				list[i] = documentstore.FormatKey(key)
+			}
+		}
+	}
+	s.writeJSON(w, http.StatusOK, report)
+}
+
+// confineConfig keeps a client-supplied data directory inside the data
+// root. A relative directory is resolved against the root; an absolute one,
+// as found in dumps of this server, must already lie within it. A directory
+// in inUse is rejected; the one returned is claimed there for collection
+// name.
+func (s *Server) confineConfig(name string, cfg documentstore.CollectionConfig, inUse map[string]string) (documentstore.CollectionConfig, error) {
+	persistent := cfg.Engine != "" && cfg.Engine != documentstore.EngineMemory
+	if !persistent && cfg.DataDir == "" {
+		return cfg, nil
+	}
+	if s.dataRoot == "" {
+		return cfg, fmt.Errorf("%w: persistent collections are disabled", documentstore.ErrInvalidEngineConfig)
+	}
+
+	dir := cfg.DataDir
+	if dir == "" {
+		return cfg, fmt.Errorf("%w: %s engine requires DataDir", documentstore.ErrInvalidEngineConfig, cfg.Engine)
+	}
+	if filepath.IsAbs(dir) {
+		rel, err := filepath.Rel(s.dataRoot, dir)
+		if err != nil {
+			return cfg, fmt.Errorf("%w: data dir %q is outside the data root", documentstore.ErrInvalidEngineConfig, cfg.DataDir)
+		}
+		dir = rel
+	}
+	if !filepath.IsLocal(dir) {
+		return cfg, fmt.Errorf("%w: data dir %q is outside the data root", documentstore.ErrInvalidEngineConfig, cfg.DataDir)
+	}
+
+	cfg.DataDir = filepath.Join(s.dataRoot, dir)
+	if owner, ok := inUse[cfg.DataDir]; ok {
+		return cfg, fmt.Errorf("%w: data dir %q is used by collection %q", documentstore.ErrDataDirLocked, dir, owner)
+	}
+	inUse[cfg.DataDir] = name
+	return cfg, nil
+}
+
+// dataDirsInUse maps the data directory of every persistent collection to
+// the collection's name. The caller holds s.mu.
+func (s *Server) dataDirsInUse() map[string]string {
+	inUse := make(map[string]string)
+	for _, ci := range s.store.Info().Collections {
+		if ci.Config.DataDir != "" {
+			inUse[filepath.Clean(ci.Config.DataDir)] = ci.Name
+		}
+	}
+	return inUse
+}
+
+func (s *Server) collection(r *http.Request) (*documentstore.Collection, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.store.GetCollection(r.PathValue("name"))
+}
+
+func (s *Server) writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		s.logger.Error("failed to write response", "error", err)
+	}
+}
+
+func (s *Server) writeError(w http.ResponseWriter, r *http.Request, err error) {
+	if status, _ := errorStatus(err); status >= http.StatusInternalServerError {
+		s.logger.Error("request failed", "method", r.Method, "path", r.URL.Path, "error", err)
+	}
+	WriteError(w, err)
+}
+
+// WriteError writes err as an ErrorResponse with the status the server
+// uses for it, so that middleware in front of the server fails the same way.
+func WriteError(w http.ResponseWriter, err error) {
+	status, code := errorStatus(err)
+	resp := ErrorResponse{Error: err.Error(), Code: code}
+	var verr *documentstore.ValidationError
+	if errors.As(err, &verr) {
+		resp.Violations = verr.Violations
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(resp)
+}
+
+func collectionInfo(ci documentstore.CollectionInfo) CollectionInfo {
+	return CollectionInfo{
+		Name:          ci.Name,
+		Config:        ci.Config,
+		CreatedAt:     ci.CreatedAt,
+		DocumentCount: ci.DocumentCount,
+		SchemaVersion: ci.SchemaVersion,
+		Usage:         ci.Usage,
+	}
+}
+
+func decodeJSON(w http.ResponseWriter, r *http.Request, limit int64, v any) error {
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, limit))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		return fmt.Errorf("%w: %v", errBadRequest, err)
+	}
+	return nil
+}
+
+func decodeDocumentBody(w http.ResponseWriter, r *http.Request) (documentstore.Document, error) {
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxDocumentSize))
+	if err != nil {
+		return documentstore.Document{}, fmt.Errorf("%w: %v", errBadRequest, err)
+	}
+	return documentstore.DecodeDocument(data)
+}
+
+// KeyPath formats primary key values as the key part of a document path,
+// one escaped segment per value. Numbers are written in decimal; the
+// server reads a segment as a number when the key field holds one.
+func KeyPath(values []any) string {
+	segments := keyTexts(values)
+	for i, text := range segments {
+		segments[i] = url.PathEscape(text)
+	}
+	return strings.Join(segments, "/")
+}
+
+func keyTexts(values []any) []string {
+	texts := make([]string, len(values))
+	for i, v := range values {
+		switch v := v.(type) {
+		case string:
+			texts[i] = v
+		case int64:
+			texts[i] = strconv.FormatInt(v, 10)
+		case float64:
+			texts[i] = strconv.FormatFloat(v, 'f', -1, 64)
+		default:
+			texts[i] = fmt.Sprint(v)
+		}
+	}
+	return texts
+}
+
+// keySegments returns the unescaped key segments of a document path. They
+// are split before unescaping so that a string value may hold "/" as %2F.
+func keySegments(r *http.Request) ([]string, error) {
+	// The escaped path is "/collections/{name}/documents/{key...}".
+	segments := strings.Split(r.URL.EscapedPath(), "/")[4:]
+	for i, segment := range segments {
+		text, err := url.PathUnescape(segment)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", errBadRequest, err)
+		}
+		segments[i] = text
+	}
+	return segments, nil
+}
+
+// pathKey resolves the key of the document a request addresses.
+func pathKey(r *http.Request, coll *documentstore.Collection) (string, error) {
+	segments, err := keySegments(r)
+	if err != nil {
+		return "", err
+	}
+	return coll.ParseKey(segments...)
+}
+
+// putOptions reads a document's expiry from the expires_at (RFC 3339) and
+// ttl (Go duration) query parameters, and the conditions if_absent, which
+// fails the write if the document exists, and if_exists, which fails it if
+// the document does not.
+func putOptions(r *http.Request) ([]documentstore.PutOption, error) {
+	var opts []documentstore.PutOption
+	if raw := r.URL.Query().Get("expires_at"); raw != "" {
+		at, err := time.Parse(time.RFC3339Nano, raw)
+		if err != nil {
+			return nil, fmt.Errorf("%w: expires_at must be an RFC 3339 time", errBadRequest)
+		}
+		opts = append(opts, documentstore.WithExpiry(at))
+	}
+	if raw := r.URL.Query().Get("ttl"); raw != "" {
+		ttl, err := time.ParseDuration(raw)
+		if err != nil || ttl <= 0 {
+			return nil, fmt.Errorf("%w: ttl must be a positive duration", errBadRequest)
+		}
+		opts = append(opts, documentstore.WithTTL(ttl))
+	}
+	conditions := []struct {
+		param string
+		opt   documentstore.PutOption
+	}{
+		{"if_absent", documentstore.IfAbsent()},
+		{"if_exists", documentstore.IfExists()},
+	}
+	for _, c := range conditions {
+		raw := r.URL.Query().Get(c.param)
+		if raw == "" {
+			continue
+		}
+		set, err := strconv.ParseBool(raw)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s must be a boolean", errBadRequest, c.param)
+		}
+		if set {
+			opts = append(opts, c.opt)
+		}
+	}
+	return opts, nil
+}
+
+func queryInt(r *http.Request, name string, def int) (int, error) {
+	raw := r.URL.Query().Get(name)
+	if raw == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(raw)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("%w: %s must be a non-negative integer", errBadRequest, name)
+	}
+	return n, nil
+}
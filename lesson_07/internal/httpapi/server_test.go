@@ -0,0 +1,424 @@
+package httpapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Nick2603/golang/lesson_07/internal/documentstore"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupServer(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	store := documentstore.NewStoreWithLogger(logger)
+	srv := httptest.NewServer(NewServer(store, logger))
+	t.Cleanup(func() {
+		srv.Close()
+		store.Close()
+	})
+	return srv
+}
+
+func do(t *testing.T, srv *httptest.Server, method, path string, body any) (int, []byte) {
+	t.Helper()
+
+	var reader io.Reader
+	switch b := body.(type) {
+	case nil:
+	case string:
+		reader = strings.NewReader(b)
+	case []byte:
+		reader = bytes.NewReader(b)
+	default:
+		data, err := json.Marshal(b)
+		require.NoError(t, err)
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequest(method, srv.URL+path, reader)
+	require.NoError(t, err)
+	resp, err := srv.Client().Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return resp.StatusCode, data
+}
+
+func createUsers(t *testing.T, srv *httptest.Server, cfg documentstore.CollectionConfig) {
+	t.Helper()
+
+	status, body := do(t, srv, http.MethodPost, "/collections", CreateCollectionRequest{Name: "users", Config: cfg})
+	require.Equal(t, http.StatusCreated, status, string(body))
+}
+
+func errorCode(t *testing.T, body []byte) string {
+	t.Helper()
+
+	var resp ErrorResponse
+	require.NoError(t, json.Unmarshal(body, &resp))
+	return resp.Code
+}
+
+func TestServer_Collections(t *testing.T) {
+	srv := setupServer(t)
+	createUsers(t, srv, documentstore.CollectionConfig{PrimaryKey: "id"})
+
+	status, body := do(t, srv, http.MethodPost, "/collections", CreateCollectionRequest{Name: "users", Config: documentstore.CollectionConfig{PrimaryKey: "id"}})
+	assert.Equal(t, http.StatusConflict, status)
+	assert.Equal(t, "collection_already_exists", errorCode(t, body))
+
+	status, body = do(t, srv, http.MethodGet, "/collections", nil)
+	require.Equal(t, http.StatusOK, status)
+	var list []CollectionInfo
+	require.NoError(t, json.Unmarshal(body, &list))
+	require.Len(t, list, 1)
+	assert.Equal(t, "users", list[0].Name)
+	assert.Equal(t, "id", list[0].Config.PrimaryKey)
+
+	status, _ = do(t, srv, http.MethodGet, "/collections/users", nil)
+	assert.Equal(t, http.StatusOK, status)
+
+	status, _ = do(t, srv, http.MethodDelete, "/collections/users", nil)
+	assert.Equal(t, http.StatusNoContent, status)
+
+	status, body = do(t, srv, http.MethodGet, "/collections/users", nil)
+	assert.Equal(t, http.StatusNotFound, status)
+	assert.Equal(t, "collection_not_found", errorCode(t, body))
+}
+
+func TestServer_CreateCollectionErrors(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       any
+		wantStatus int
+		wantCode   string
+	}{
+		{name: "malformed body", body: `{"name":`, wantStatus: http.StatusBadRequest, wantCode: "bad_request"},
+		{name: "missing name", body: CreateCollectionRequest{Config: documentstore.CollectionConfig{PrimaryKey: "id"}}, wantStatus: http.StatusBadRequest, wantCode: "bad_request"},
+		{name: "missing primary key", body: CreateCollectionRequest{Name: "users"}, wantStatus: http.StatusBadRequest, wantCode: "invalid_primary_key"},
+		{
+			name:       "unknown key generator",
+			body:       CreateCollectionRequest{Name: "users", Config: documentstore.CollectionConfig{PrimaryKey: "id", KeyGen: "snowflake"}},
+			wantStatus: http.StatusBadRequest,
+			wantCode:   "invalid_key_generator",
+		},
+		{
+			name:       "persistent engine without data root",
+			body:       CreateCollectionRequest{Name: "users", Config: documentstore.CollectionConfig{PrimaryKey: "id", Engine: documentstore.EngineBitcask, DataDir: "/tmp/users"}},
+			wantStatus: http.StatusBadRequest,
+			wantCode:   "invalid_engine_config",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := setupServer(t)
+
+			status, body := do(t, srv, http.MethodPost, "/collections", tt.body)
+			assert.Equal(t, tt.wantStatus, status)
+			assert.Equal(t, tt.wantCode, errorCode(t, body))
+		})
+	}
+}
+
+func TestServer_DataRoot(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	store := documentstore.NewStoreWithLogger(logger)
+	root := t.TempDir()
+	server := NewServer(store, logger)
+	server.SetDataRoot(root)
+	srv := httptest.NewServer(server)
+	t.Cleanup(func() {
+		srv.Close()
+		store.Close()
+	})
+
+	bitcask := func(dir string) documentstore.CollectionConfig {
+		return documentstore.CollectionConfig{PrimaryKey: "id", Engine: documentstore.EngineBitcask, DataDir: dir}
+	}
+
+	for _, dir := range []string{"../users", "/tmp/users", ""} {
+		status, body := do(t, srv, http.MethodPost, "/collections", CreateCollectionRequest{Name: "users", Config: bitcask(dir)})
+		assert.Equal(t, http.StatusBadRequest, status, dir)
+		assert.Equal(t, "invalid_engine_config", errorCode(t, body), dir)
+	}
+
+	status, body := do(t, srv, http.MethodPost, "/collections", CreateCollectionRequest{Name: "users", Config: bitcask("users")})
+	require.Equal(t, http.StatusCreated, status, string(body))
+	var info CollectionInfo
+	require.NoError(t, json.Unmarshal(body, &info))
+	assert.Equal(t, filepath.Join(root, "users"), info.Config.DataDir)
+
+	for _, dir := range []string{"users", filepath.Join(root, "users", ".")} {
+		status, body = do(t, srv, http.MethodPost, "/collections", CreateCollectionRequest{Name: "copy", Config: bitcask(dir)})
+		assert.Equal(t, http.StatusConflict, status, dir)
+		assert.Equal(t, "data_dir_in_use", errorCode(t, body), dir)
+	}
+
+	// A dump of this server restores into the root; one pointing elsewhere
+	// is rejected before anything is written.
+	do(t, srv, http.MethodPost, "/collections/users/documents", `{"id": "1"}`)
+	_, dump := do(t, srv, http.MethodGet, "/dump", nil)
+	status, _ = do(t, srv, http.MethodDelete, "/collections/users", nil)
+	require.Equal(t, http.StatusNoContent, status)
+
+	escaped := bytes.ReplaceAll(dump, []byte(filepath.Join(root, "users")), []byte("/tmp/users"))
+	status, body = do(t, srv, http.MethodPost, "/restore", escaped)
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.Equal(t, "invalid_engine_config", errorCode(t, body))
+	status, _ = do(t, srv, http.MethodGet, "/collections/users", nil)
+	assert.Equal(t, http.StatusNotFound, status)
+
+	status, body = do(t, srv, http.MethodPost, "/restore", dump)
+	require.Equal(t, http.StatusOK, status, string(body))
+	status, _ = do(t, srv, http.MethodGet, "/collections/users/documents/1", nil)
+	assert.Equal(t, http.StatusOK, status)
+}
+
+func TestServer_DocumentCRUD(t *testing.T) {
+	srv := setupServer(t)
+	createUsers(t, srv, documentstore.CollectionConfig{PrimaryKey: "id"})
+
+	status, body := do(t, srv, http.MethodPost, "/collections/users/documents", `{"id": "1", "name": "Alice", "age": 30, "score": 9.5, "active": true, "tags": ["a", 1]}`)
+	require.Equal(t, http.StatusCreated, status, string(body))
+	assert.JSONEq(t, `{"key": ["1"]}`, string(body))
+
+	status, body = do(t, srv, http.MethodPost, "/collections/users/documents?if_absent=true", `{"id": "1", "name": "Mallory"}`)
+	assert.Equal(t, http.StatusConflict, status)
+	assert.Equal(t, "document_already_exists", errorCode(t, body))
+
+	status, body = do(t, srv, http.MethodGet, "/collections/users/documents/1", nil)
+	require.Equal(t, http.StatusOK, status)
+	assert.JSONEq(t, `{"id": "1", "name": "Alice", "age": 30, "score": 9.5, "active": true, "tags": ["a", 1]}`, string(body))
+
+	status, body = do(t, srv, http.MethodPut, "/collections/users/documents/1", `{"id": "1", "name": "Alicia"}`)
+	require.Equal(t, http.StatusOK, status, string(body))
+	_, body = do(t, srv, http.MethodGet, "/collections/users/documents/1", nil)
+	assert.JSONEq(t, `{"id": "1", "name": "Alicia"}`, string(body))
+
+	status, _ = do(t, srv, http.MethodDelete, "/collections/users/documents/1", nil)
+	assert.Equal(t, http.StatusNoContent, status)
+
+	status, body = do(t, srv, http.MethodGet, "/collections/users/documents/1", nil)
+	assert.Equal(t, http.StatusNotFound, status)
+	assert.Equal(t, "document_not_found", errorCode(t, body))
+}
+
+func TestServer_DocumentKeyPaths(t *testing.T) {
+	srv := setupServer(t)
+	status, body := do(t, srv, http.MethodPost, "/collections", CreateCollectionRequest{
+		Name:   "lines",
+		Config: documentstore.CollectionConfig{PrimaryKeys: []string{"order_id", "line_no"}},
+	})
+	require.Equal(t, http.StatusCreated, status, string(body))
+
+	status, body = do(t, srv, http.MethodPost, "/collections/lines/documents", `{"order_id": "o/1", "line_no": 2}`)
+	require.Equal(t, http.StatusCreated, status, string(body))
+	assert.JSONEq(t, `{"key": ["o/1", 2]}`, string(body))
+	assert.Equal(t, "o%2F1/2", KeyPath([]any{"o/1", int64(2)}))
+
+	status, body = do(t, srv, http.MethodGet, "/collections/lines/documents/o%2F1/2", nil)
+	require.Equal(t, http.StatusOK, status, string(body))
+	assert.JSONEq(t, `{"order_id": "o/1", "line_no": 2}`, string(body))
+
+	status, body = do(t, srv, http.MethodPut, "/collections/lines/documents/o%2F1/2", `{"order_id": "o/1", "line_no": 2, "qty": 3}`)
+	require.Equal(t, http.StatusOK, status, string(body))
+
+	// A string "2" is a different key from the number 2.
+	status, body = do(t, srv, http.MethodPost, "/collections/lines/documents", `{"order_id": "o/1", "line_no": "2"}`)
+	require.Equal(t, http.StatusCreated, status, string(body))
+	_, body = do(t, srv, http.MethodGet, "/collections/lines/documents/o%2F1/2", nil)
+	assert.JSONEq(t, `{"order_id": "o/1", "line_no": "2"}`, string(body))
+
+	status, body = do(t, srv, http.MethodGet, "/collections/lines/documents/o%2F1", nil)
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.Equal(t, "invalid_primary_key", errorCode(t, body))
+
+	status, _ = do(t, srv, http.MethodDelete, "/collections/lines/documents/o%2F1/2", nil)
+	assert.Equal(t, http.StatusNoContent, status)
+	_, body = do(t, srv, http.MethodGet, "/collections/lines/documents/o%2F1/2", nil)
+	assert.JSONEq(t, `{"order_id": "o/1", "line_no": 2, "qty": 3}`, string(body))
+}
+
+func TestServer_DocumentErrors(t *testing.T) {
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		body       any
+		wantStatus int
+		wantCode   string
+	}{
+		{name: "unknown collection", method: http.MethodGet, path: "/collections/orders/documents/1", wantStatus: http.StatusNotFound, wantCode: "collection_not_found"},
+		{name: "delete missing document", method: http.MethodDelete, path: "/collections/users/documents/9", wantStatus: http.StatusNotFound, wantCode: "document_not_found"},
+		{name: "missing primary key", method: http.MethodPost, path: "/collections/users/documents", body: `{"name": "Alice"}`, wantStatus: http.StatusBadRequest, wantCode: "invalid_primary_key"},
+		{name: "nested array field", method: http.MethodPost, path: "/collections/users/documents", body: `{"id": "1", "tags": [["a"]]}`, wantStatus: http.StatusBadRequest, wantCode: "unsupported_document_field"},
+		{name: "not an object", method: http.MethodPost, path: "/collections/users/documents", body: `null`, wantStatus: http.StatusBadRequest, wantCode: "malformed_document"},
+		{name: "key mismatch", method: http.MethodPut, path: "/collections/users/documents/2", body: `{"id": "1"}`, wantStatus: http.StatusBadRequest, wantCode: "invalid_primary_key"},
+		{name: "bad limit", method: http.MethodGet, path: "/collections/users/documents?limit=0", wantStatus: http.StatusBadRequest, wantCode: "bad_request"},
+		{name: "bad cursor", method: http.MethodGet, path: "/collections/users/documents?after=%21", wantStatus: http.StatusBadRequest, wantCode: "bad_request"},
+		{name: "bad ttl", method: http.MethodPost, path: "/collections/users/documents?ttl=soon", body: `{"id": "1"}`, wantStatus: http.StatusBadRequest, wantCode: "bad_request"},
+		{name: "bad expiry", method: http.MethodPut, path: "/collections/users/documents/1?expires_at=tomorrow", body: `{"id": "1"}`, wantStatus: http.StatusBadRequest, wantCode: "bad_request"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := setupServer(t)
+			createUsers(t, srv, documentstore.CollectionConfig{PrimaryKey: "id"})
+
+			status, body := do(t, srv, tt.method, tt.path, tt.body)
+			assert.Equal(t, tt.wantStatus, status, string(body))
+			assert.Equal(t, tt.wantCode, errorCode(t, body))
+		})
+	}
+}
+
+func TestServer_ValidationError(t *testing.T) {
+	srv := setupServer(t)
+	createUsers(t, srv, documentstore.CollectionConfig{
+		PrimaryKey: "id",
+		Schema: &documentstore.Schema{
+			AllowUnknownFields: true,
+			Fields: map[string]documentstore.FieldRule{
+				"name": {Required: true, Types: []documentstore.DocumentFieldType{documentstore.DocumentFieldTypeString}},
+			},
+		},
+	})
+
+	status, body := do(t, srv, http.MethodPost, "/collections/users/documents", `{"id": "1"}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, status)
+
+	var resp ErrorResponse
+	require.NoError(t, json.Unmarshal(body, &resp))
+	assert.Equal(t, "validation_failed", resp.Code)
+	require.Len(t, resp.Violations, 1)
+	assert.Equal(t, "name", resp.Violations[0].Field)
+}
+
+func TestServer_InsertGeneratesKey(t *testing.T) {
+	srv := setupServer(t)
+	createUsers(t, srv, documentstore.CollectionConfig{PrimaryKey: "id", KeyGen: documentstore.KeyGenSequence})
+
+	status, body := do(t, srv, http.MethodPost, "/collections/users/documents", `{"name": "Alice"}`)
+	require.Equal(t, http.StatusCreated, status, string(body))
+	assert.JSONEq(t, `{"key": [1]}`, string(body))
+
+	status, body = do(t, srv, http.MethodGet, "/collections/users/documents/1", nil)
+	require.Equal(t, http.StatusOK, status, string(body))
+	assert.JSONEq(t, `{"id": 1, "name": "Alice"}`, string(body))
+}
+
+func TestServer_ListDocumentsPaging(t *testing.T) {
+	srv := setupServer(t)
+	createUsers(t, srv, documentstore.CollectionConfig{PrimaryKey: "id"})
+	for _, id := range []string{"e", "a", "d", "b", "c"} {
+		status, body := do(t, srv, http.MethodPost, "/collections/users/documents", map[string]any{"id": id})
+		require.Equal(t, http.StatusCreated, status, string(body))
+	}
+
+	list := func(t *testing.T, query string) ([]string, string) {
+		t.Helper()
+
+		status, body := do(t, srv, http.MethodGet, "/collections/users/documents"+query, nil)
+		require.Equal(t, http.StatusOK, status, string(body))
+
+		var page Page
+		require.NoError(t, json.Unmarshal(body, &page))
+		var ids []string
+		for _, doc := range page.Documents {
+			ids = append(ids, doc["id"].(string))
+		}
+		return ids, page.Next
+	}
+
+	t.Run("default", func(t *testing.T) {
+		ids, next := list(t, "")
+		assert.Equal(t, []string{"a", "b", "c", "d", "e"}, ids)
+		assert.Empty(t, next)
+	})
+
+	t.Run("follows the cursor across concurrent writes", func(t *testing.T) {
+		ids, next := list(t, "?limit=2")
+		assert.Equal(t, []string{"a", "b"}, ids)
+		require.NotEmpty(t, next)
+
+		status, _ := do(t, srv, http.MethodDelete, "/collections/users/documents/a", nil)
+		require.Equal(t, http.StatusNoContent, status)
+		status, _ = do(t, srv, http.MethodPost, "/collections/users/documents", map[string]any{"id": "bb"})
+		require.Equal(t, http.StatusCreated, status)
+
+		ids, next = list(t, "?limit=2&after="+next)
+		assert.Equal(t, []string{"bb", "c"}, ids)
+		ids, next = list(t, "?limit=2&after="+next)
+		assert.Equal(t, []string{"d", "e"}, ids)
+		assert.Empty(t, next, "the last page has no cursor")
+	})
+}
+
+func TestServer_DumpAndRestore(t *testing.T) {
+	srv := setupServer(t)
+	createUsers(t, srv, documentstore.CollectionConfig{PrimaryKey: "id"})
+	do(t, srv, http.MethodPost, "/collections/users/documents", `{"id": "1", "name": "Alice"}`)
+	do(t, srv, http.MethodPost, "/collections/users/documents", `{"id": "2", "name": "Bob"}`)
+
+	status, dump := do(t, srv, http.MethodGet, "/dump", nil)
+	require.Equal(t, http.StatusOK, status)
+	assert.True(t, json.Valid(dump))
+
+	do(t, srv, http.MethodDelete, "/collections/users/documents/2", nil)
+	do(t, srv, http.MethodPut, "/collections/users/documents/1", `{"id": "1", "name": "Alicia"}`)
+
+	status, body := do(t, srv, http.MethodPost, "/restore?conflict=skip", dump)
+	require.Equal(t, http.StatusOK, status, string(body))
+	var report documentstore.RestoreReport
+	require.NoError(t, json.Unmarshal(body, &report))
+	assert.Equal(t, []string{"2"}, report.Restored["users"])
+	assert.Equal(t, []string{"1"}, report.Skipped["users"])
+
+	_, body = do(t, srv, http.MethodGet, "/collections/users/documents/2", nil)
+	assert.JSONEq(t, `{"id": "2", "name": "Bob"}`, string(body))
+
+	status, body = do(t, srv, http.MethodPost, "/restore?conflict=fail", dump)
+	assert.Equal(t, http.StatusConflict, status)
+	assert.Equal(t, "restore_conflict", errorCode(t, body))
+
+	status, body = do(t, srv, http.MethodPost, "/restore?conflict=newest", dump)
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.Equal(t, "unknown_conflict_policy", errorCode(t, body))
+}
+
+func TestServer_Quota(t *testing.T) {
+	srv := setupServer(t)
+	createUsers(t, srv, documentstore.CollectionConfig{PrimaryKey: "id", Quota: &documentstore.Quota{MaxDocuments: 1}})
+
+	status, body := do(t, srv, http.MethodPut, "/collections/users/documents/1", `{"id": "1"}`)
+	require.Equal(t, http.StatusOK, status, string(body))
+
+	status, body = do(t, srv, http.MethodPut, "/collections/users/documents/2", `{"id": "2"}`)
+	assert.Equal(t, http.StatusInsufficientStorage, status)
+	assert.Equal(t, "quota_exceeded", errorCode(t, body))
+
+	status, body = do(t, srv, http.MethodGet, "/collections/users", nil)
+	require.Equal(t, http.StatusOK, status)
+	var info CollectionInfo
+	require.NoError(t, json.Unmarshal(body, &info))
+	assert.Equal(t, 1, info.Usage.Documents)
+
+	status, body = do(t, srv, http.MethodGet, "/stats", nil)
+	require.Equal(t, http.StatusOK, status)
+	var stats documentstore.QuotaStats
+	require.NoError(t, json.Unmarshal(body, &stats))
+	assert.Equal(t, info.Usage, stats.Usage)
+}
@@ -0,0 +1,434 @@
+package resp
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Nick2603/golang/lesson_07/internal/auth"
+	"github.com/Nick2603/golang/lesson_07/internal/documentstore"
+)
+
+const defaultScanCount = 10
+
+type command struct {
+	// minArgs and maxArgs bound the arguments after the command name;
+	// maxArgs is -1 for no limit.
+	minArgs, maxArgs int
+	// public commands may run before AUTH.
+	public          bool
+	needsCollection bool
+	// perm is the permission needed on the selected collection.
+	perm auth.Permission
+	run  func(sess *session, args []string)
+}
+
+var commands = map[string]command{
+	"PING":    {minArgs: 0, maxArgs: 1, public: true, run: cmdPing},
+	"QUIT":    {minArgs: 0, maxArgs: 0, public: true, run: cmdQuit},
+	"COMMAND": {minArgs: 0, maxArgs: -1, public: true, run: cmdCommand},
+	"AUTH":    {minArgs: 1, maxArgs: 2, public: true, run: cmdAuth},
+	"SELECT":  {minArgs: 1, maxArgs: 1, run: cmdSelect},
+	"INFO":    {minArgs: 0, maxArgs: 1, run: cmdInfo},
+	"GET":     {minArgs: 1, maxArgs: 1, needsCollection: true, perm: auth.PermRead, run: cmdGet},
+	"SET":     {minArgs: 2, maxArgs: 2, needsCollection: true, perm: auth.PermWrite, run: cmdSet},
+	"DEL":     {minArgs: 1, maxArgs: -1, needsCollection: true, perm: auth.PermWrite, run: cmdDel},
+	"EXISTS":  {minArgs: 1, maxArgs: -1, needsCollection: true, perm: auth.PermRead, run: cmdExists},
+	"SCAN":    {minArgs: 1, maxArgs: 5, needsCollection: true, perm: auth.PermRead, run: cmdScan},
+	"HSET":    {minArgs: 3, maxArgs: -1, needsCollection: true, perm: auth.PermWrite, run: cmdHSet},
+	"HGET":    {minArgs: 2, maxArgs: 2, needsCollection: true, perm: auth.PermRead, run: cmdHGet},
+}
+
+func cmdPing(sess *session, args []string) {
+	if len(args) == 1 {
+		sess.w.bulk(args[0])
+		return
+	}
+	sess.w.simple("PONG")
+}
+
+func cmdQuit(sess *session, _ []string) {
+	sess.w.simple("OK")
+}
+
+// cmdCommand answers the COMMAND probe redis-cli sends on connect.
+func cmdCommand(sess *session, _ []string) {
+	sess.w.array(0)
+}
+
+// cmdAuth implements AUTH key and AUTH username key. The key is an API key
+// of the server's authenticator; a username must match its principal.
+func cmdAuth(sess *session, args []string) {
+	a := sess.server.auth
+	if a == nil {
+		sess.w.error("ERR AUTH called without any authentication configured")
+		return
+	}
+
+	key := args[len(args)-1]
+	p, err := a.Authenticate(key)
+	if err != nil || (len(args) == 2 && args[0] != p.Name) {
+		sess.server.logger.Warn("RESP authentication failed")
+		sess.w.error("WRONGPASS invalid username-password pair")
+		return
+	}
+	sess.principal = &p
+	sess.w.simple("OK")
+}
+
+// cmdSelect chooses the collection later commands work on. Unlike Redis
+// it takes a collection name rather than a database number.
+func cmdSelect(sess *session, args []string) {
+	if !sess.authorize(args[0], auth.PermRead) {
+		return
+	}
+	coll, err := sess.server.store.GetCollection(args[0])
+	if err != nil {
+		sess.w.error("ERR " + err.Error())
+		return
+	}
+	sess.name, sess.coll = args[0], coll
+	sess.w.simple("OK")
+}
+
+func cmdInfo(sess *session, _ []string) {
+	info := sess.server.store.Info()
+
+	var b strings.Builder
+	b.WriteString("# Store\r\n")
+	fmt.Fprintf(&b, "created_at:%s\r\n", info.CreatedAt.UTC().Format(time.RFC3339))
+	if !info.LastDumpAt.IsZero() {
+		fmt.Fprintf(&b, "last_dump_at:%s\r\n", info.LastDumpAt.UTC().Format(time.RFC3339))
+	}
+	visible := make([]documentstore.CollectionInfo, 0, len(info.Collections))
+	for _, ci := range info.Collections {
+		if sess.canRead(ci.Name) {
+			visible = append(visible, ci)
+		}
+	}
+
+	fmt.Fprintf(&b, "collections:%d\r\n", len(visible))
+	if sess.name != "" {
+		fmt.Fprintf(&b, "selected:%s\r\n", sess.name)
+	}
+
+	b.WriteString("\r\n# Collections\r\n")
+	for _, ci := range visible {
+		engine := ci.Config.Engine
+		if engine == "" {
+			engine = documentstore.EngineMemory
+		}
+		fmt.Fprintf(&b, "%s:documents=%d,engine=%s,schema_version=%d\r\n", ci.Name, ci.DocumentCount, engine, ci.SchemaVersion)
+	}
+
+	sess.w.bulk(b.String())
+}
+
+func cmdGet(sess *session, args []string) {
+	key, err := docKey(sess.coll, args[0])
+	if err != nil {
+		sess.w.error("ERR " + err.Error())
+		return
+	}
+
+	doc, err := sess.coll.Get(key)
+	if errors.Is(err, documentstore.ErrDocumentNotFound) {
+		sess.w.null()
+		return
+	}
+	if err != nil {
+		sess.w.error("ERR " + err.Error())
+		return
+	}
+
+	data, err := json.Marshal(documentstore.EncodeDocument(*doc))
+	if err != nil {
+		sess.w.error("ERR " + err.Error())
+		return
+	}
+	sess.w.bulk(string(data))
+}
+
+// cmdSet stores a JSON document under key. A document without its
+// (single) primary key field gets it from key; otherwise the document's
+// key must equal key.
+func cmdSet(sess *session, args []string) {
+	key := args[0]
+	doc, err := documentstore.DecodeDocument([]byte(args[1]))
+	if err != nil {
+		sess.w.error("ERR " + err.Error())
+		return
+	}
+
+	fields := keyFields(sess.coll)
+	if len(fields) == 1 {
+		if _, ok := doc.Fields[fields[0]]; !ok {
+			doc.Fields[fields[0]] = documentstore.DocumentField{Type: documentstore.DocumentFieldTypeString, Value: key}
+		}
+	}
+
+	docKey, err := sess.coll.DocumentKey(doc)
+	if err != nil {
+		sess.w.error("ERR " + err.Error())
+		return
+	}
+	if documentstore.FormatKey(docKey) != key {
+		sess.w.error("ERR document key does not match " + strconv.Quote(key))
+		return
+	}
+
+	if err := sess.coll.Put(doc); err != nil {
+		sess.w.error("ERR " + err.Error())
+		return
+	}
+	sess.w.simple("OK")
+}
+
+func cmdDel(sess *session, args []string) {
+	deleted := 0
+	for _, arg := range args {
+		key, err := docKey(sess.coll, arg)
+		if err == nil {
+			err = sess.coll.Delete(key)
+		}
+		if errors.Is(err, documentstore.ErrDocumentNotFound) || errors.Is(err, documentstore.ErrInvalidPrimaryKey) {
+			continue
+		}
+		if err != nil {
+			sess.w.error("ERR " + err.Error())
+			return
+		}
+		deleted++
+	}
+	sess.w.integer(deleted)
+}
+
+func cmdExists(sess *session, args []string) {
+	found := 0
+	for _, arg := range args {
+		key, err := docKey(sess.coll, arg)
+		if err != nil {
+			continue
+		}
+		if _, err := sess.coll.Get(key); err == nil {
+			found++
+		}
+	}
+	sess.w.integer(found)
+}
+
+// cmdScan implements SCAN cursor [MATCH pattern] [COUNT count]. A cursor
+// other than "0" is the last key returned, base64 encoded, so a scan picks up
+// where it stopped even if keys were written or deleted in between. As in
+// Redis, MATCH filters the keys of each batch, so a batch may come back empty
+// before the scan is complete.
+func cmdScan(sess *session, args []string) {
+	var start string
+	if args[0] != "0" {
+		last, err := base64.RawURLEncoding.DecodeString(args[0])
+		if err != nil || len(last) == 0 {
+			sess.w.error("ERR invalid cursor")
+			return
+		}
+		start = string(last) + "\x00"
+	}
+
+	pattern, count := "*", defaultScanCount
+	for i := 1; i < len(args); i += 2 {
+		if i+1 >= len(args) {
+			sess.w.error("ERR syntax error")
+			return
+		}
+		switch strings.ToUpper(args[i]) {
+		case "MATCH":
+			pattern = args[i+1]
+			if _, err := path.Match(pattern, ""); err != nil {
+				sess.w.error("ERR invalid pattern")
+				return
+			}
+		case "COUNT":
+			var err error
+			count, err = strconv.Atoi(args[i+1])
+			if err != nil || count <= 0 {
+				sess.w.error("ERR value is not an integer or out of range")
+				return
+			}
+		default:
+			sess.w.error("ERR syntax error")
+			return
+		}
+	}
+
+	keys := make([]string, 0)
+	var last string
+	seen, more := 0, false
+	err := sess.coll.ScanFrom(start, func(encoded string, _ documentstore.Document) bool {
+		if seen == count {
+			more = true
+			return false
+		}
+		seen++
+		last = encoded
+		key := documentstore.FormatKey(encoded)
+		if ok, _ := path.Match(pattern, key); ok {
+			keys = append(keys, key)
+		}
+		return true
+	})
+	if err != nil {
+		sess.w.error("ERR " + err.Error())
+		return
+	}
+
+	next := "0"
+	if more {
+		next = base64.RawURLEncoding.EncodeToString([]byte(last))
+	}
+
+	sess.w.array(2)
+	sess.w.bulk(next)
+	sess.w.array(len(keys))
+	for _, key := range keys {
+		sess.w.bulk(key)
+	}
+}
+
+// cmdHSet sets document fields. A value keeps the type of the field it
+// replaces, so "42" stays a number in a number field; new fields are
+// strings. A missing document is created if the key is a single field.
+func cmdHSet(sess *session, args []string) {
+	pairs := args[1:]
+	if len(pairs)%2 != 0 {
+		sess.w.error("ERR wrong number of arguments for 'hset' command")
+		return
+	}
+
+	key, err := docKey(sess.coll, args[0])
+	if err != nil {
+		sess.w.error("ERR " + err.Error())
+		return
+	}
+
+	fields := keyFields(sess.coll)
+	var doc documentstore.Document
+	existing, err := sess.coll.Get(key)
+	switch {
+	case err == nil:
+		doc = documentstore.Document{Fields: maps.Clone(existing.Fields)}
+	case errors.Is(err, documentstore.ErrDocumentNotFound) && len(fields) == 1:
+		doc = documentstore.Document{Fields: map[string]documentstore.DocumentField{
+			fields[0]: {Type: documentstore.DocumentFieldTypeString, Value: args[0]},
+		}}
+	default:
+		sess.w.error("ERR " + err.Error())
+		return
+	}
+
+	added := 0
+	for i := 0; i < len(pairs); i += 2 {
+		name, raw := pairs[i], pairs[i+1]
+		if slices.Contains(fields, name) {
+			sess.w.error("ERR cannot change primary key field " + strconv.Quote(name))
+			return
+		}
+
+		old, exists := doc.Fields[name]
+		field, err := parseField(old.Type, raw)
+		if err != nil {
+			sess.w.error(fmt.Sprintf("ERR field %q: %v", name, err))
+			return
+		}
+		if !exists {
+			added++
+		}
+		doc.Fields[name] = field
+	}
+
+	if err := sess.coll.Put(doc); err != nil {
+		sess.w.error("ERR " + err.Error())
+		return
+	}
+	sess.w.integer(added)
+}
+
+func cmdHGet(sess *session, args []string) {
+	key, err := docKey(sess.coll, args[0])
+	if err != nil {
+		sess.w.error("ERR " + err.Error())
+		return
+	}
+
+	doc, err := sess.coll.Get(key)
+	if errors.Is(err, documentstore.ErrDocumentNotFound) {
+		sess.w.null()
+		return
+	}
+	if err != nil {
+		sess.w.error("ERR " + err.Error())
+		return
+	}
+
+	field, ok := doc.Fields[args[1]]
+	if !ok {
+		sess.w.null()
+		return
+	}
+	sess.w.bulk(formatField(field))
+}
+
+// docKey resolves a key argument. Composite keys join their values with
+// "/", the way SCAN returns them.
+func docKey(coll *documentstore.Collection, arg string) (string, error) {
+	return coll.ParseKey(strings.SplitN(arg, "/", len(keyFields(coll)))...)
+}
+
+func keyFields(coll *documentstore.Collection) []string {
+	cfg := coll.Info().Config
+	if len(cfg.PrimaryKeys) > 0 {
+		return cfg.PrimaryKeys
+	}
+	return []string{cfg.PrimaryKey}
+}
+
+func parseField(typ documentstore.DocumentFieldType, raw string) (documentstore.DocumentField, error) {
+	switch typ {
+	case documentstore.DocumentFieldTypeNumber:
+		if n, err := strconv.ParseInt(raw, 10, 64); err == nil {
+			return documentstore.DocumentField{Type: typ, Value: n}, nil
+		}
+		f, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return documentstore.DocumentField{}, errors.New("value is not a number")
+		}
+		return documentstore.DocumentField{Type: typ, Value: f}, nil
+	case documentstore.DocumentFieldTypeBool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return documentstore.DocumentField{}, errors.New("value is not a boolean")
+		}
+		return documentstore.DocumentField{Type: typ, Value: b}, nil
+	default:
+		return documentstore.DocumentField{Type: documentstore.DocumentFieldTypeString, Value: raw}, nil
+	}
+}
+
+func formatField(field documentstore.DocumentField) string {
+	switch v := field.Value.(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case []any:
+		data, _ := json.Marshal(v)
+		return string(data)
+	default:
+		return fmt.Sprint(v)
+	}
+}
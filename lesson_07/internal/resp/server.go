@@ -0,0 +1,215 @@
+// Package resp serves a documentstore.Store over the Redis protocol (RESP2)
+// so that Redis clients and tools can read and write documents.
+package resp
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"strings"
+	"sync"
+
+	"github.com/Nick2603/golang/lesson_07/internal/auth"
+	"github.com/Nick2603/golang/lesson_07/internal/documentstore"
+)
+
+// Server accepts RESP2 connections. Each connection picks a collection with
+// SELECT and then works on its documents, which are keyed by primary key
+// and exchanged as JSON objects.
+//
+// The server never adds or removes collections, so it may share a store
+// with code that only reads and writes documents.
+//
+// With an authenticator set, connections must AUTH with an API key before
+// anything but PING, QUIT and COMMAND, and each command needs read or write
+// permission on the selected collection.
+type Server struct {
+	store  *documentstore.Store
+	logger *slog.Logger
+	auth   *auth.Authenticator
+
+	mu       sync.Mutex
+	listener net.Listener
+	conns    map[net.Conn]struct{}
+	closed   bool
+	wg       sync.WaitGroup
+}
+
+func NewServer(store *documentstore.Store, logger *slog.Logger) *Server {
+	return &Server{store: store, logger: logger, conns: make(map[net.Conn]struct{})}
+}
+
+// SetAuthenticator makes connections authenticate against a. It must be
+// called before the server accepts connections.
+func (s *Server) SetAuthenticator(a *auth.Authenticator) {
+	s.auth = a
+}
+
+// ListenAndServe listens on the TCP address addr and calls Serve.
+func (s *Server) ListenAndServe(addr string) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(ln)
+}
+
+// Serve accepts connections on ln until Close is called, then returns nil.
+func (s *Server) Serve(ln net.Listener) error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		ln.Close()
+		return nil
+	}
+	s.listener = ln
+	s.mu.Unlock()
+
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			s.mu.Lock()
+			closed := s.closed
+			s.mu.Unlock()
+			if closed {
+				return nil
+			}
+			s.logger.Error("failed to accept RESP connection", "error", err)
+			return err
+		}
+
+		s.mu.Lock()
+		if s.closed {
+			s.mu.Unlock()
+			conn.Close()
+			return nil
+		}
+		s.conns[conn] = struct{}{}
+		s.wg.Add(1)
+		s.mu.Unlock()
+
+		go s.serveConn(conn)
+	}
+}
+
+// Close stops accepting connections, closes open ones and waits for their
+// handlers to return.
+func (s *Server) Close() error {
+	s.mu.Lock()
+	s.closed = true
+	var err error
+	if s.listener != nil {
+		err = s.listener.Close()
+	}
+	for conn := range s.conns {
+		conn.Close()
+	}
+	s.mu.Unlock()
+
+	s.wg.Wait()
+	return err
+}
+
+func (s *Server) serveConn(conn net.Conn) {
+	defer func() {
+		conn.Close()
+		s.mu.Lock()
+		delete(s.conns, conn)
+		s.mu.Unlock()
+		s.wg.Done()
+	}()
+
+	sess := &session{server: s, r: newReader(conn), w: newWriter(conn)}
+	for {
+		args, err := sess.r.readCommand()
+		if err != nil {
+			if errors.Is(err, errProtocol) {
+				sess.w.error("ERR " + err.Error())
+				sess.w.flush()
+			} else if !errors.Is(err, io.EOF) && !errors.Is(err, net.ErrClosed) {
+				s.logger.Warn("RESP connection failed", "remote", conn.RemoteAddr().String(), "error", err)
+			}
+			return
+		}
+		if len(args) == 0 {
+			continue
+		}
+
+		quit := sess.dispatch(args)
+		if err := sess.w.flush(); err != nil || quit {
+			return
+		}
+	}
+}
+
+// session is the state of one connection.
+type session struct {
+	server *Server
+	r      *reader
+	w      *writer
+
+	name string
+	coll *documentstore.Collection
+
+	// principal is the caller authenticated with AUTH, if any.
+	principal *auth.Principal
+}
+
+func (sess *session) dispatch(args []string) (quit bool) {
+	name := strings.ToUpper(args[0])
+	cmd, ok := commands[name]
+	if !ok {
+		sess.w.error("ERR unknown command '" + args[0] + "'")
+		return false
+	}
+	if len(args)-1 < cmd.minArgs || (cmd.maxArgs >= 0 && len(args)-1 > cmd.maxArgs) {
+		sess.w.error("ERR wrong number of arguments for '" + strings.ToLower(name) + "' command")
+		return false
+	}
+	if sess.server.auth != nil && !cmd.public && sess.principal == nil {
+		sess.w.error("NOAUTH Authentication required.")
+		return false
+	}
+	if cmd.needsCollection {
+		if sess.name == "" {
+			sess.w.error("ERR no collection selected, use SELECT <collection>")
+			return false
+		}
+		if !sess.authorize(sess.name, cmd.perm) {
+			return false
+		}
+		// Look the collection up again in case it was dropped meanwhile.
+		coll, err := sess.server.store.GetCollection(sess.name)
+		if err != nil {
+			sess.w.error("ERR " + err.Error())
+			return false
+		}
+		sess.coll = coll
+	}
+
+	cmd.run(sess, args[1:])
+	return name == "QUIT"
+}
+
+// authorize checks that the session may use the collection at the given
+// level, replying NOPERM if not. It always succeeds without an
+// authenticator.
+func (sess *session) authorize(collection string, need auth.Permission) bool {
+	a := sess.server.auth
+	if a == nil {
+		return true
+	}
+	if err := a.Authorize(*sess.principal, collection, need); err != nil {
+		sess.server.logger.Warn("permission denied", "principal", sess.principal.Name, "collection", collection, "need", need.String())
+		sess.w.error("NOPERM " + err.Error())
+		return false
+	}
+	return true
+}
+
+// canRead reports whether the session may see the collection.
+func (sess *session) canRead(collection string) bool {
+	a := sess.server.auth
+	return a == nil || a.Permission(*sess.principal, collection) >= auth.PermRead
+}
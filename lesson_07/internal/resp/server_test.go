@@ -0,0 +1,299 @@
+package resp
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/Nick2603/golang/lesson_07/internal/auth"
+	"github.com/Nick2603/golang/lesson_07/internal/documentstore"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testClient is a minimal RESP2 client: it sends commands as arrays of bulk
+// strings and decodes replies into Go values. Errors become errReply, null
+// bulk strings nil.
+type testClient struct {
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+type errReply string
+
+func (e errReply) Error() string { return string(e) }
+
+func (c *testClient) do(t *testing.T, args ...string) any {
+	t.Helper()
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(&b, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	_, err := io.WriteString(c.conn, b.String())
+	require.NoError(t, err)
+
+	reply, err := c.read()
+	require.NoError(t, err)
+	return reply
+}
+
+func (c *testClient) read() (any, error) {
+	line, err := c.r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return errReply(line[1:]), nil
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(c.r, buf); err != nil {
+			return nil, err
+		}
+		return string(buf[:n]), nil
+	case '*':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, err
+		}
+		items := make([]any, 0, n)
+		for i := 0; i < n; i++ {
+			item, err := c.read()
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+		}
+		return items, nil
+	default:
+		return nil, fmt.Errorf("unexpected reply %q", line)
+	}
+}
+
+func setupServer(t *testing.T) (*documentstore.Store, *testClient) {
+	t.Helper()
+
+	return setupServerWithAuth(t, nil)
+}
+
+// setupServerWithAuth is setupServer with an authenticator, which may be
+// nil.
+func setupServerWithAuth(t *testing.T, a *auth.Authenticator) (*documentstore.Store, *testClient) {
+	t.Helper()
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	store := documentstore.NewStoreWithLogger(logger)
+	_, err := store.CreateCollection("users", &documentstore.CollectionConfig{PrimaryKey: "id"})
+	require.NoError(t, err)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	srv := NewServer(store, logger)
+	if a != nil {
+		srv.SetAuthenticator(a)
+	}
+	go srv.Serve(ln)
+	t.Cleanup(func() {
+		srv.Close()
+		store.Close()
+	})
+
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	return store, &testClient{conn: conn, r: bufio.NewReader(conn)}
+}
+
+func TestServer_Documents(t *testing.T) {
+	_, c := setupServer(t)
+
+	assert.Equal(t, "PONG", c.do(t, "PING"))
+	assert.Equal(t, errReply("ERR no collection selected, use SELECT <collection>"), c.do(t, "GET", "1"))
+	assert.Equal(t, errReply("ERR collection not found"), c.do(t, "SELECT", "orders"))
+	assert.Equal(t, "OK", c.do(t, "SELECT", "users"))
+
+	assert.Equal(t, "OK", c.do(t, "SET", "1", `{"name": "Alice", "age": 30}`))
+	assert.Equal(t, "OK", c.do(t, "SET", "2", `{"id": "2", "name": "Bob"}`))
+	assert.Equal(t, `{"age":30,"id":"1","name":"Alice"}`, c.do(t, "GET", "1"))
+	assert.Nil(t, c.do(t, "GET", "missing"))
+
+	assert.Equal(t, int64(2), c.do(t, "EXISTS", "1", "2", "3"))
+	assert.Equal(t, int64(1), c.do(t, "DEL", "2", "3"))
+	assert.Equal(t, int64(0), c.do(t, "EXISTS", "2"))
+}
+
+func TestServer_SetErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+	}{
+		{name: "invalid json", args: []string{"SET", "1", `{"name":`}},
+		{name: "nested value", args: []string{"SET", "1", `{"address": {"city": "Kyiv"}}`}},
+		{name: "key mismatch", args: []string{"SET", "1", `{"id": "2"}`}},
+		{name: "wrong arity", args: []string{"SET", "1"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, c := setupServer(t)
+			c.do(t, "SELECT", "users")
+
+			reply := c.do(t, tt.args...)
+			var errRep errReply
+			require.True(t, errors.As(reply.(error), &errRep), "got %v", reply)
+			assert.True(t, strings.HasPrefix(string(errRep), "ERR "))
+			assert.Nil(t, c.do(t, "GET", "1"))
+		})
+	}
+}
+
+func TestServer_Hash(t *testing.T) {
+	_, c := setupServer(t)
+	c.do(t, "SELECT", "users")
+
+	assert.Equal(t, int64(2), c.do(t, "HSET", "1", "name", "Alice", "city", "Kyiv"))
+	assert.Equal(t, "Alice", c.do(t, "HGET", "1", "name"))
+	assert.Nil(t, c.do(t, "HGET", "1", "email"))
+	assert.Nil(t, c.do(t, "HGET", "9", "name"))
+
+	require.Equal(t, "OK", c.do(t, "SET", "2", `{"name": "Bob", "age": 30, "active": true}`))
+	assert.Equal(t, int64(0), c.do(t, "HSET", "2", "age", "31", "active", "false"))
+	assert.Equal(t, `{"active":false,"age":31,"id":"2","name":"Bob"}`, c.do(t, "GET", "2"))
+
+	assert.Equal(t, errReply(`ERR field "age": value is not a number`), c.do(t, "HSET", "2", "age", "old"))
+	assert.Equal(t, errReply(`ERR cannot change primary key field "id"`), c.do(t, "HSET", "2", "id", "3"))
+	assert.Equal(t, "31", c.do(t, "HGET", "2", "age"))
+}
+
+func TestServer_Scan(t *testing.T) {
+	_, c := setupServer(t)
+	c.do(t, "SELECT", "users")
+	for _, key := range []string{"user:3", "user:1", "admin:1", "user:2"} {
+		require.Equal(t, "OK", c.do(t, "SET", key, `{}`))
+	}
+
+	var all []any
+	cursor := "0"
+	for {
+		reply := c.do(t, "SCAN", cursor, "COUNT", "3").([]any)
+		all = append(all, reply[1].([]any)...)
+		cursor = reply[0].(string)
+		if cursor == "0" {
+			break
+		}
+	}
+	assert.Equal(t, []any{"admin:1", "user:1", "user:2", "user:3"}, all)
+
+	assert.Equal(t, []any{"0", []any{"user:1", "user:2", "user:3"}}, c.do(t, "SCAN", "0", "MATCH", "user:*", "COUNT", "100"))
+	assert.Equal(t, errReply("ERR syntax error"), c.do(t, "SCAN", "0", "LIMIT", "1"))
+	assert.Equal(t, errReply("ERR invalid cursor"), c.do(t, "SCAN", "!"))
+
+	t.Run("cursor survives writes between batches", func(t *testing.T) {
+		reply := c.do(t, "SCAN", "0", "COUNT", "2").([]any)
+		require.Equal(t, []any{"admin:1", "user:1"}, reply[1])
+
+		require.Equal(t, int64(1), c.do(t, "DEL", "admin:1"))
+		require.Equal(t, "OK", c.do(t, "SET", "admin:2", `{}`))
+
+		reply = c.do(t, "SCAN", reply[0].(string), "COUNT", "10").([]any)
+		assert.Equal(t, []any{"0", []any{"user:2", "user:3"}}, reply)
+	})
+}
+
+func TestServer_Info(t *testing.T) {
+	store, c := setupServer(t)
+	_, err := store.CreateCollection("orders", &documentstore.CollectionConfig{PrimaryKey: "id"})
+	require.NoError(t, err)
+	c.do(t, "SELECT", "users")
+	c.do(t, "SET", "1", `{}`)
+
+	info := c.do(t, "INFO").(string)
+	assert.Contains(t, info, "collections:2\r\n")
+	assert.Contains(t, info, "selected:users\r\n")
+	assert.Contains(t, info, "users:documents=1,engine=memory,schema_version=0\r\n")
+	assert.Contains(t, info, "orders:documents=0,engine=memory,schema_version=0\r\n")
+}
+
+func TestServer_Auth(t *testing.T) {
+	a, err := auth.NewAuthenticatorFromConfig(auth.Config{
+		Roles: []auth.Role{
+			{Name: "users-ro", Grants: map[string]auth.Permission{"users": auth.PermRead}},
+			{Name: "users-rw", Grants: map[string]auth.Permission{"users": auth.PermWrite}},
+		},
+		Keys: []auth.KeyConfig{
+			{Key: "reader-key", Principal: auth.Principal{Name: "reader", Roles: []string{"users-ro"}}},
+			{Key: "writer-key", Principal: auth.Principal{Name: "writer", Roles: []string{"users-rw"}}},
+		},
+	})
+	require.NoError(t, err)
+	store, c := setupServerWithAuth(t, a)
+	_, err = store.CreateCollection("orders", &documentstore.CollectionConfig{PrimaryKey: "id"})
+	require.NoError(t, err)
+
+	assert.Equal(t, "PONG", c.do(t, "PING"))
+	assert.Equal(t, errReply("NOAUTH Authentication required."), c.do(t, "SELECT", "users"))
+	assert.Equal(t, errReply("NOAUTH Authentication required."), c.do(t, "INFO"))
+	assert.Equal(t, errReply("WRONGPASS invalid username-password pair"), c.do(t, "AUTH", "bogus"))
+	assert.Equal(t, errReply("WRONGPASS invalid username-password pair"), c.do(t, "AUTH", "writer", "reader-key"))
+
+	assert.Equal(t, "OK", c.do(t, "AUTH", "reader", "reader-key"))
+	assert.Equal(t, "OK", c.do(t, "SELECT", "users"))
+	assert.Nil(t, c.do(t, "GET", "1"))
+	reply := c.do(t, "SET", "1", `{}`)
+	require.IsType(t, errReply(""), reply)
+	assert.True(t, strings.HasPrefix(string(reply.(errReply)), "NOPERM "), "got %v", reply)
+	reply = c.do(t, "SELECT", "orders")
+	require.IsType(t, errReply(""), reply)
+	assert.True(t, strings.HasPrefix(string(reply.(errReply)), "NOPERM "), "got %v", reply)
+
+	info := c.do(t, "INFO").(string)
+	assert.Contains(t, info, "collections:1\r\n")
+	assert.NotContains(t, info, "orders:")
+
+	assert.Equal(t, "OK", c.do(t, "AUTH", "writer-key"))
+	assert.Equal(t, "OK", c.do(t, "SET", "1", `{}`))
+	assert.Equal(t, int64(1), c.do(t, "DEL", "1"))
+}
+
+func TestServer_AuthWithoutAuthenticator(t *testing.T) {
+	_, c := setupServer(t)
+
+	assert.Equal(t, errReply("ERR AUTH called without any authentication configured"), c.do(t, "AUTH", "key"))
+	assert.Equal(t, "OK", c.do(t, "SELECT", "users"))
+}
+
+func TestServer_InlineCommandsAndErrors(t *testing.T) {
+	_, c := setupServer(t)
+
+	_, err := io.WriteString(c.conn, "PING\r\n")
+	require.NoError(t, err)
+	reply, err := c.read()
+	require.NoError(t, err)
+	assert.Equal(t, "PONG", reply)
+
+	assert.Equal(t, errReply("ERR unknown command 'FLUSHALL'"), c.do(t, "FLUSHALL"))
+	assert.Equal(t, errReply("ERR wrong number of arguments for 'get' command"), c.do(t, "GET"))
+
+	assert.Equal(t, "OK", c.do(t, "QUIT"))
+	_, err = c.read()
+	assert.ErrorIs(t, err, io.EOF)
+}
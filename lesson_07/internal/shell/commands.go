@@ -0,0 +1,466 @@
+package shell
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/Nick2603/golang/lesson_07/internal/documentstore"
+	"github.com/Nick2603/golang/lesson_07/internal/documentstore/bulk"
+	"github.com/Nick2603/golang/lesson_07/internal/httpapi"
+)
+
+type command struct {
+	usage string
+	help  string
+	// minArgs and maxArgs bound the arguments after the command name;
+	// maxArgs is -1 for no limit.
+	minArgs, maxArgs int
+	// mutates marks commands that leave unsaved changes.
+	mutates bool
+	run     func(sh *Shell, args []string) error
+}
+
+var commands map[string]command
+
+// commands refers to cmdHelp, which reads commands, so it is filled in
+// init to avoid an initialization cycle.
+func init() {
+	commands = map[string]command{
+		"help":        {usage: "", help: "list commands", maxArgs: 0, run: cmdHelp},
+		"collections": {usage: "", help: "list collections with document counts", maxArgs: 0, run: cmdCollections},
+		"create":      {usage: "<collection> <key[,key...]|config-json>", help: "create a collection", minArgs: 2, maxArgs: 2, mutates: true, run: cmdCreate},
+		"drop":        {usage: "<collection>", help: "delete a collection and its documents", minArgs: 1, maxArgs: 1, mutates: true, run: cmdDrop},
+		"get":         {usage: "<collection> <key...>", help: "print a document as JSON; a composite key takes one value per key field", minArgs: 2, maxArgs: -1, run: cmdGet},
+		"put":         {usage: "<collection> <document-json>", help: "insert or replace a document", minArgs: 2, maxArgs: 2, mutates: true, run: cmdPut},
+		"delete":      {usage: "<collection> <key...>", help: "delete a document", minArgs: 2, maxArgs: -1, mutates: true, run: cmdDelete},
+		"find":        {usage: "<collection> [field<op>value...] [limit <n>]", help: "print matching documents, one JSON object per line; op is = != < <= > >=", minArgs: 1, maxArgs: -1, run: cmdFind},
+		"import":      {usage: "<collection> <file> [csv|jsonl] [column=type...]", help: "load documents from JSON Lines or CSV; the format defaults to the file extension", minArgs: 2, maxArgs: -1, mutates: true, run: cmdImport},
+		"export":      {usage: "<collection> <file|-> [csv|jsonl]", help: "write all documents as JSON Lines or CSV, - for the output", minArgs: 2, maxArgs: 3, run: cmdExport},
+		"stats":       {usage: "[collection|dump-file]", help: "print store, collection or dump file statistics as JSON", maxArgs: 1, run: cmdStats},
+		"verify":      {usage: "<dump-file>", help: "check the keys, field types and metadata of every document in a dump", minArgs: 1, maxArgs: 1, run: cmdVerify},
+		"diff":        {usage: "<old-dump-file> <new-dump-file>", help: "list documents added, removed and changed between two dumps", minArgs: 2, maxArgs: 2, run: cmdDiff},
+		"namespaces":  {usage: "", help: "list namespaces", maxArgs: 0, run: cmdNamespaces},
+		"use":         {usage: "[namespace]", help: "work in a namespace, or the root store without one", maxArgs: 1, run: cmdUse},
+		"save":        {usage: "", help: "write the store back to its dump file or data directory", maxArgs: 0, run: cmdSave},
+		"exit":        {usage: "", help: "leave the shell", maxArgs: 0, run: cmdQuit},
+		"quit":        {usage: "", help: "leave the shell", maxArgs: 0, run: cmdQuit},
+	}
+}
+
+func cmdHelp(sh *Shell, _ []string) error {
+	tw := tabwriter.NewWriter(sh.out, 0, 4, 2, ' ', 0)
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	for _, name := range names {
+		cmd := commands[name]
+		fmt.Fprintf(tw, "%s %s\t%s\n", name, cmd.usage, cmd.help)
+	}
+	return tw.Flush()
+}
+
+func cmdCollections(sh *Shell, _ []string) error {
+	tw := tabwriter.NewWriter(sh.out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAME\tKEY\tDOCUMENTS\tBYTES")
+	for _, ci := range sh.store.Info().Collections {
+		key := ci.Config.PrimaryKeys
+		if len(key) == 0 {
+			key = []string{ci.Config.PrimaryKey}
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%d\n", ci.Name, strings.Join(key, ","), ci.DocumentCount, ci.Usage.Bytes)
+	}
+	return tw.Flush()
+}
+
+func cmdCreate(sh *Shell, args []string) error {
+	var cfg documentstore.CollectionConfig
+	if strings.HasPrefix(args[1], "{") {
+		if err := json.Unmarshal([]byte(args[1]), &cfg); err != nil {
+			return fmt.Errorf("invalid config: %w", err)
+		}
+	} else {
+		keys := strings.Split(args[1], ",")
+		cfg.PrimaryKey = keys[0]
+		if len(keys) > 1 {
+			cfg.PrimaryKeys = keys
+		}
+	}
+
+	_, err := sh.store.CreateCollection(args[0], &cfg)
+	return err
+}
+
+func cmdDrop(sh *Shell, args []string) error {
+	return sh.store.DeleteCollection(args[0])
+}
+
+func cmdGet(sh *Shell, args []string) error {
+	coll, err := sh.store.GetCollection(args[0])
+	if err != nil {
+		return err
+	}
+
+	key, err := coll.ParseKey(args[1:]...)
+	if err != nil {
+		return err
+	}
+
+	doc, err := coll.Get(key)
+	if err != nil {
+		return err
+	}
+	return sh.printJSON(documentstore.EncodeDocument(*doc), true)
+}
+
+func cmdPut(sh *Shell, args []string) error {
+	coll, err := sh.store.GetCollection(args[0])
+	if err != nil {
+		return err
+	}
+
+	doc, err := documentstore.DecodeDocument([]byte(args[1]))
+	if err != nil {
+		return err
+	}
+
+	key, err := coll.Insert(doc)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(sh.out, documentstore.FormatKey(key))
+	return nil
+}
+
+func cmdDelete(sh *Shell, args []string) error {
+	coll, err := sh.store.GetCollection(args[0])
+	if err != nil {
+		return err
+	}
+	key, err := coll.ParseKey(args[1:]...)
+	if err != nil {
+		return err
+	}
+	return coll.Delete(key)
+}
+
+func cmdFind(sh *Shell, args []string) error {
+	coll, err := sh.store.GetCollection(args[0])
+	if err != nil {
+		return err
+	}
+
+	var filters []Filter
+	limit := -1
+	for i := 1; i < len(args); i++ {
+		if args[i] == "limit" {
+			if i+1 >= len(args) {
+				return errors.New("limit needs a number")
+			}
+			if limit, err = strconv.Atoi(args[i+1]); err != nil || limit < 0 {
+				return fmt.Errorf("invalid limit %q", args[i+1])
+			}
+			i++
+			continue
+		}
+
+		f, err := ParseFilter(args[i])
+		if err != nil {
+			return err
+		}
+		filters = append(filters, f)
+	}
+
+	for _, doc := range coll.List() {
+		if limit == 0 {
+			break
+		}
+		if !matchAll(filters, doc) {
+			continue
+		}
+		if err := sh.printJSON(documentstore.EncodeDocument(doc), false); err != nil {
+			return err
+		}
+		limit--
+	}
+	return nil
+}
+
+func matchAll(filters []Filter, doc documentstore.Document) bool {
+	for _, f := range filters {
+		if !f.Match(doc) {
+			return false
+		}
+	}
+	return true
+}
+
+func cmdImport(sh *Shell, args []string) error {
+	coll, err := sh.store.GetCollection(args[0])
+	if err != nil {
+		return err
+	}
+
+	opts := bulk.ImportOptions{ColumnTypes: make(map[string]documentstore.DocumentFieldType)}
+	for _, arg := range args[2:] {
+		if column, typ, ok := strings.Cut(arg, "="); ok {
+			opts.ColumnTypes[column] = documentstore.DocumentFieldType(typ)
+			continue
+		}
+		if opts.Format, err = bulk.ParseFormat(arg); err != nil {
+			return err
+		}
+	}
+	if opts.Format == "" {
+		if opts.Format, err = bulk.FormatFromPath(args[1]); err != nil {
+			return fmt.Errorf("%w, name the format after the file", err)
+		}
+	}
+
+	f, err := os.Open(args[1])
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	report, err := bulk.Import(coll, f, opts)
+	if report != nil {
+		for _, lerr := range report.Errors {
+			fmt.Fprintf(sh.out, "%s: %v\n", args[1], lerr)
+		}
+		fmt.Fprintf(sh.out, "imported %d documents, %d failed\n", report.Imported, len(report.Errors))
+	}
+	return err
+}
+
+func cmdExport(sh *Shell, args []string) (err error) {
+	coll, err := sh.store.GetCollection(args[0])
+	if err != nil {
+		return err
+	}
+
+	var opts bulk.ExportOptions
+	switch {
+	case len(args) == 3:
+		opts.Format, err = bulk.ParseFormat(args[2])
+	case args[1] == "-":
+		opts.Format = bulk.FormatJSONL
+	default:
+		opts.Format, err = bulk.FormatFromPath(args[1])
+	}
+	if err != nil {
+		return err
+	}
+
+	if args[1] == "-" {
+		_, err = bulk.Export(coll, sh.out, opts)
+		return err
+	}
+
+	f, err := os.Create(args[1])
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+	}()
+
+	n, err := bulk.Export(coll, f, opts)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(sh.out, "exported %d documents\n", n)
+	return nil
+}
+
+type storeStats struct {
+	Namespace   string                   `json:"namespace,omitempty"`
+	Collections int                      `json:"collections"`
+	Namespaces  []string                 `json:"namespaces,omitempty"`
+	Quota       documentstore.QuotaStats `json:"quota"`
+}
+
+type collectionStats struct {
+	httpapi.CollectionInfo
+	Quota documentstore.Quota       `json:"quota"`
+	Cache *documentstore.CacheStats `json:"cache,omitempty"`
+}
+
+func cmdStats(sh *Shell, args []string) error {
+	if len(args) == 0 {
+		return sh.printJSON(storeStats{
+			Namespace:   sh.namespace,
+			Collections: len(sh.store.Info().Collections),
+			Namespaces:  sh.store.Namespaces(),
+			Quota:       sh.store.QuotaStats(),
+		}, true)
+	}
+
+	coll, err := sh.store.GetCollection(args[0])
+	if errors.Is(err, documentstore.ErrCollectionNotFound) {
+		if info, statErr := os.Stat(args[0]); statErr == nil && info.Mode().IsRegular() {
+			return dumpStats(sh, args[0])
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	ci := coll.Info()
+	stats := collectionStats{
+		CollectionInfo: httpapi.CollectionInfo{
+			Name:          args[0],
+			Config:        ci.Config,
+			CreatedAt:     ci.CreatedAt,
+			DocumentCount: ci.DocumentCount,
+			SchemaVersion: ci.SchemaVersion,
+			Usage:         ci.Usage,
+		},
+		Quota: coll.QuotaStats().Quota,
+	}
+	if ci.Config.Cache != nil {
+		cache := coll.CacheStats()
+		stats.Cache = &cache
+	}
+	return sh.printJSON(stats, true)
+}
+
+func dumpStats(sh *Shell, filename string) error {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+
+	summary, err := documentstore.SummarizeDump(data)
+	if err != nil {
+		return fmt.Errorf("%s: %w", filename, err)
+	}
+	return sh.printJSON(summary, true)
+}
+
+func cmdVerify(sh *Shell, args []string) error {
+	data, err := os.ReadFile(args[0])
+	if err != nil {
+		return err
+	}
+
+	report, err := documentstore.VerifyDump(data)
+	if err != nil {
+		return fmt.Errorf("%s: %w", args[0], err)
+	}
+
+	for _, issue := range report.Issues {
+		fmt.Fprintln(sh.out, issue)
+	}
+	fmt.Fprintf(sh.out, "version %d, %d collections, %d documents, %d issues\n",
+		report.Version, report.Collections, report.Documents, len(report.Issues))
+	if !report.OK() {
+		return fmt.Errorf("%s: verification failed", args[0])
+	}
+	return nil
+}
+
+func cmdDiff(sh *Shell, args []string) error {
+	oldData, err := os.ReadFile(args[0])
+	if err != nil {
+		return err
+	}
+	newData, err := os.ReadFile(args[1])
+	if err != nil {
+		return err
+	}
+
+	diff, err := documentstore.DiffDumps(oldData, newData)
+	if err != nil {
+		return err
+	}
+
+	if diff.Empty() {
+		fmt.Fprintln(sh.out, "no differences")
+		return nil
+	}
+	sh.printDiff("", diff)
+	return nil
+}
+
+// printDiff writes diff in a patch-like form, prefixing collection names
+// with their namespace.
+func (sh *Shell) printDiff(prefix string, diff *documentstore.DumpDiff) {
+	for _, name := range diff.AddedCollections {
+		fmt.Fprintf(sh.out, "+ collection %s%s\n", prefix, name)
+	}
+	for _, name := range diff.RemovedCollections {
+		fmt.Fprintf(sh.out, "- collection %s%s\n", prefix, name)
+	}
+
+	for _, name := range slices.Sorted(maps.Keys(diff.Collections)) {
+		cd := diff.Collections[name]
+		fmt.Fprintf(sh.out, "%s%s: %d added, %d removed, %d changed", prefix, name, len(cd.Added), len(cd.Removed), len(cd.Changed))
+		if cd.ConfigChanged {
+			fmt.Fprint(sh.out, ", config changed")
+		}
+		fmt.Fprintln(sh.out)
+
+		for _, key := range cd.Added {
+			fmt.Fprintf(sh.out, "  + %s\n", key)
+		}
+		for _, key := range cd.Removed {
+			fmt.Fprintf(sh.out, "  - %s\n", key)
+		}
+		for _, key := range cd.Changed {
+			fmt.Fprintf(sh.out, "  ~ %s\n", key)
+		}
+	}
+
+	for _, name := range slices.Sorted(maps.Keys(diff.Namespaces)) {
+		sh.printDiff(name+"/", diff.Namespaces[name])
+	}
+}
+
+func cmdNamespaces(sh *Shell, _ []string) error {
+	for _, name := range sh.root.Namespaces() {
+		fmt.Fprintln(sh.out, name)
+	}
+	return nil
+}
+
+func cmdUse(sh *Shell, args []string) error {
+	if len(args) == 0 {
+		sh.store, sh.namespace = sh.root, ""
+		return nil
+	}
+
+	ns, err := sh.root.Namespace(args[0])
+	if err != nil {
+		return err
+	}
+	sh.store, sh.namespace = ns, args[0]
+	return nil
+}
+
+func cmdSave(sh *Shell, _ []string) error {
+	return sh.Save()
+}
+
+func cmdQuit(*Shell, []string) error {
+	return ErrQuit
+}
+
+func (sh *Shell) printJSON(v any, indent bool) error {
+	enc := json.NewEncoder(sh.out)
+	if indent {
+		enc.SetIndent("", "  ")
+	}
+	return enc.Encode(v)
+}
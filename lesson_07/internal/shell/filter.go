@@ -0,0 +1,108 @@
+package shell
+
+import (
+	"cmp"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/Nick2603/golang/lesson_07/internal/documentstore"
+)
+
+// operators are tried in order, so two-character operators must come
+// before their one-character prefixes.
+var operators = []string{"!=", ">=", "<=", "=", ">", "<"}
+
+// Filter matches documents whose field compares to a value, e.g. age>=30
+// or name=Alice. Strings compare lexically, numbers numerically, and bools
+// support only = and !=.
+type Filter struct {
+	Field string
+	Op    string
+	Value string
+}
+
+// ParseFilter parses field<op>value. The value may be quoted to keep
+// operator characters or leading spaces.
+func ParseFilter(s string) (Filter, error) {
+	for i := range s {
+		for _, op := range operators {
+			if strings.HasPrefix(s[i:], op) {
+				if i == 0 {
+					return Filter{}, fmt.Errorf("filter %q: missing field", s)
+				}
+				value := s[i+len(op):]
+				if unquoted, err := strconv.Unquote(value); err == nil {
+					value = unquoted
+				}
+				return Filter{Field: s[:i], Op: op, Value: value}, nil
+			}
+		}
+	}
+	return Filter{}, fmt.Errorf("filter %q: missing operator, want one of %s", s, strings.Join(operators, " "))
+}
+
+// Match reports whether doc satisfies f. Documents without the field, or
+// whose field cannot be compared with the value, never match.
+func (f Filter) Match(doc documentstore.Document) bool {
+	field, ok := doc.Fields[f.Field]
+	if !ok {
+		return false
+	}
+
+	var c int
+	switch field.Type {
+	case documentstore.DocumentFieldTypeString:
+		s, ok := field.Value.(string)
+		if !ok {
+			return false
+		}
+		c = cmp.Compare(s, f.Value)
+	case documentstore.DocumentFieldTypeNumber:
+		n, ok := numberValue(field.Value)
+		want, err := strconv.ParseFloat(f.Value, 64)
+		if !ok || err != nil {
+			return false
+		}
+		c = cmp.Compare(n, want)
+	case documentstore.DocumentFieldTypeBool:
+		b, ok := field.Value.(bool)
+		want, err := strconv.ParseBool(f.Value)
+		if !ok || err != nil || (f.Op != "=" && f.Op != "!=") {
+			return false
+		}
+		if b != want {
+			c = 1
+		}
+	default:
+		return false
+	}
+
+	switch f.Op {
+	case "=":
+		return c == 0
+	case "!=":
+		return c != 0
+	case ">":
+		return c > 0
+	case ">=":
+		return c >= 0
+	case "<":
+		return c < 0
+	default:
+		return c <= 0
+	}
+}
+
+func numberValue(v any) (float64, bool) {
+	switch n := v.(type) {
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case float64:
+		return n, true
+	default:
+		return 0, false
+	}
+}
@@ -0,0 +1,72 @@
+package shell
+
+import (
+	"testing"
+
+	"github.com/Nick2603/golang/lesson_07/internal/documentstore"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseFilter(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Filter
+		wantErr bool
+	}{
+		{in: "age>=30", want: Filter{Field: "age", Op: ">=", Value: "30"}},
+		{in: "name!=Bob", want: Filter{Field: "name", Op: "!=", Value: "Bob"}},
+		{in: `name="a<b"`, want: Filter{Field: "name", Op: "=", Value: "a<b"}},
+		{in: "name=", want: Filter{Field: "name", Op: "=", Value: ""}},
+		{in: "=Bob", wantErr: true},
+		{in: "name", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParseFilter(tt.in)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestFilter_Match(t *testing.T) {
+	doc := documentstore.Document{Fields: map[string]documentstore.DocumentField{
+		"name":   {Type: documentstore.DocumentFieldTypeString, Value: "Alice"},
+		"age":    {Type: documentstore.DocumentFieldTypeNumber, Value: int64(30)},
+		"score":  {Type: documentstore.DocumentFieldTypeNumber, Value: 4.5},
+		"active": {Type: documentstore.DocumentFieldTypeBool, Value: true},
+	}}
+
+	tests := []struct {
+		filter string
+		want   bool
+	}{
+		{filter: "name=Alice", want: true},
+		{filter: "name!=Alice", want: false},
+		{filter: "name<Bob", want: true},
+		{filter: "age>29", want: true},
+		{filter: "age>=30", want: true},
+		{filter: "age<30", want: false},
+		{filter: "age=30.0", want: true},
+		{filter: "score<=4.5", want: true},
+		{filter: "age=thirty", want: false},
+		{filter: "active=true", want: true},
+		{filter: "active!=true", want: false},
+		{filter: "active>false", want: false},
+		{filter: "missing=1", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.filter, func(t *testing.T) {
+			f, err := ParseFilter(tt.filter)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, f.Match(doc))
+		})
+	}
+}
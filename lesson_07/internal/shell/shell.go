@@ -0,0 +1,231 @@
+// Package shell implements the docstore CLI: commands to inspect and edit a
+// store, run either one at a time or from an interactive shell that reads
+// plain lines, so it can also be fed a script on stdin.
+package shell
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/Nick2603/golang/lesson_07/internal/documentstore"
+)
+
+// ErrQuit is returned by Exec for the exit and quit commands.
+var ErrQuit = errors.New("quit")
+
+// Source says where a store is loaded from and saved to. File is a dump
+// file; Dir is a recovery directory of snapshots and a change log. Both
+// empty means an in-memory store that cannot be saved.
+type Source struct {
+	File string
+	Dir  string
+}
+
+type Shell struct {
+	root   *documentstore.Store
+	store  *documentstore.Store
+	source Source
+	out    io.Writer
+	// namespace is the one selected with use; store is root when empty.
+	namespace string
+	// dirty is set by commands that changed the store since it was last
+	// saved.
+	dirty bool
+	// recovering is set once the first save enabled recovery in the data
+	// directory.
+	recovering bool
+}
+
+// Open loads the store named by src. A missing dump file or an empty
+// directory starts a new store.
+func Open(src Source, out io.Writer, logger *slog.Logger) (*Shell, error) {
+	if src.File != "" && src.Dir != "" {
+		return nil, errors.New("a dump file and a data directory cannot both be opened")
+	}
+
+	store, err := load(src, logger)
+	if err != nil {
+		return nil, err
+	}
+	return &Shell{root: store, store: store, source: src, out: out}, nil
+}
+
+func load(src Source, logger *slog.Logger) (*documentstore.Store, error) {
+	switch {
+	case src.File != "":
+		if _, err := os.Stat(src.File); errors.Is(err, os.ErrNotExist) {
+			return documentstore.NewStoreWithLogger(logger), nil
+		}
+		return documentstore.NewStoreFromFile(src.File)
+	case src.Dir != "":
+		store, err := documentstore.RestoreAt(src.Dir, time.Now())
+		if errors.Is(err, documentstore.ErrNoSnapshot) || errors.Is(err, os.ErrNotExist) {
+			return documentstore.NewStoreWithLogger(logger), nil
+		}
+		return store, err
+	default:
+		return documentstore.NewStoreWithLogger(logger), nil
+	}
+}
+
+// Dirty reports whether the store has unsaved changes.
+func (sh *Shell) Dirty() bool {
+	return sh.dirty
+}
+
+// Exec runs one command with its arguments.
+func (sh *Shell) Exec(args []string) error {
+	if len(args) == 0 {
+		return nil
+	}
+
+	name := strings.ToLower(args[0])
+	cmd, ok := commands[name]
+	if !ok {
+		return fmt.Errorf("unknown command %q, try help", args[0])
+	}
+
+	args = args[1:]
+	if len(args) < cmd.minArgs || (cmd.maxArgs >= 0 && len(args) > cmd.maxArgs) {
+		return fmt.Errorf("usage: %s %s", name, cmd.usage)
+	}
+
+	if err := cmd.run(sh, args); err != nil {
+		return err
+	}
+	if cmd.mutates {
+		sh.dirty = true
+	}
+	return nil
+}
+
+// Run reads commands from in, one per line, until it ends or a quit
+// command. Errors are reported on the output and do not stop the shell.
+// prompt is written before each line; pass "" when in is not a terminal.
+func (sh *Shell) Run(in io.Reader, prompt string) error {
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16<<20)
+
+	for {
+		fmt.Fprint(sh.out, prompt)
+		if !scanner.Scan() {
+			break
+		}
+
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		args, err := SplitLine(line)
+		if err == nil {
+			err = sh.Exec(args)
+		}
+		if errors.Is(err, ErrQuit) {
+			break
+		}
+		if err != nil {
+			fmt.Fprintf(sh.out, "error: %v\n", err)
+		}
+	}
+
+	if sh.dirty {
+		fmt.Fprintln(sh.out, "warning: unsaved changes discarded, use save before quitting")
+	}
+	return scanner.Err()
+}
+
+// Save writes the whole store, including all namespaces, back to its
+// source. A data directory gets a new snapshot; changes made after that
+// are appended to its change log as they happen.
+func (sh *Shell) Save() error {
+	var err error
+	switch {
+	case sh.source.File != "":
+		err = sh.root.DumpToFile(sh.source.File)
+	case sh.source.Dir != "" && sh.recovering:
+		err = sh.root.Snapshot()
+	case sh.source.Dir != "":
+		if err = sh.root.EnableRecovery(sh.source.Dir); err == nil {
+			sh.recovering = true
+		}
+	default:
+		err = errors.New("nothing to save to, open a dump file or a data directory")
+	}
+	if err != nil {
+		return err
+	}
+
+	sh.dirty = false
+	return nil
+}
+
+func (sh *Shell) Close() error {
+	return sh.root.Close()
+}
+
+// SplitLine splits a shell line into arguments at spaces. Single or double
+// quotes group words, and a JSON object starting with { is kept verbatim
+// up to its matching }, so documents can be typed without extra quoting.
+func SplitLine(line string) ([]string, error) {
+	var args []string
+	for i := 0; i < len(line); {
+		switch c := line[i]; {
+		case c == ' ' || c == '\t':
+			i++
+		case c == '{':
+			end, err := objectEnd(line, i)
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, line[i:end])
+			i = end
+		default:
+			var b strings.Builder
+			for i < len(line) && line[i] != ' ' && line[i] != '\t' {
+				if q := line[i]; q == '\'' || q == '"' {
+					end := strings.IndexByte(line[i+1:], q)
+					if end < 0 {
+						return nil, fmt.Errorf("unterminated %c quote", q)
+					}
+					b.WriteString(line[i+1 : i+1+end])
+					i += end + 2
+					continue
+				}
+				b.WriteByte(line[i])
+				i++
+			}
+			args = append(args, b.String())
+		}
+	}
+	return args, nil
+}
+
+// objectEnd returns the index just past the } that closes the object
+// starting at line[start], skipping braces inside JSON strings.
+func objectEnd(line string, start int) (int, error) {
+	depth, inString := 0, false
+	for i := start; i < len(line); i++ {
+		switch c := line[i]; {
+		case inString && c == '\\':
+			i++
+		case c == '"':
+			inString = !inString
+		case inString:
+		case c == '{':
+			depth++
+		case c == '}':
+			depth--
+			if depth == 0 {
+				return i + 1, nil
+			}
+		}
+	}
+	return 0, errors.New("unterminated JSON object")
+}
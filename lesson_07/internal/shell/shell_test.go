@@ -0,0 +1,227 @@
+package shell
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Nick2603/golang/lesson_07/internal/documentstore"
+	"github.com/Nick2603/golang/lesson_07/internal/documentstore/bulk"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var discardLogger = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+func openShell(t *testing.T, src Source) (*Shell, *bytes.Buffer) {
+	t.Helper()
+
+	var out bytes.Buffer
+	sh, err := Open(src, &out, discardLogger)
+	require.NoError(t, err)
+	t.Cleanup(func() { sh.Close() })
+	return sh, &out
+}
+
+func TestSplitLine(t *testing.T) {
+	tests := []struct {
+		line    string
+		want    []string
+		wantErr bool
+	}{
+		{line: "get users 1", want: []string{"get", "users", "1"}},
+		{line: "  find\tusers  ", want: []string{"find", "users"}},
+		{line: `put users {"id": "1", "note": "a } b"}`, want: []string{"put", "users", `{"id": "1", "note": "a } b"}`}},
+		{line: `find users name="Alice Smith"`, want: []string{"find", "users", "name=Alice Smith"}},
+		{line: `put users '{"id": "1"}'`, want: []string{"put", "users", `{"id": "1"}`}},
+		{line: `put users {"id": "1"`, wantErr: true},
+		{line: `find users name="Alice`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.line, func(t *testing.T) {
+			got, err := SplitLine(tt.line)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestShell_Run(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "store.json")
+	sh, out := openShell(t, Source{File: file})
+
+	script := `# set up
+create users id
+put users {"id": "1", "name": "Alice", "age": 30}
+put users {"id": "2", "name": "Bob", "age": 25}
+put users {"id": "3", "name": "Carol", "age": 41}
+find users age>26 limit 1
+get users 2
+get users 9
+delete users 3
+bogus
+save
+quit
+collections
+`
+	require.NoError(t, sh.Run(strings.NewReader(script), ""))
+
+	assert.Equal(t, `1
+2
+3
+{"age":30,"id":"1","name":"Alice"}
+{
+  "age": 25,
+  "id": "2",
+  "name": "Bob"
+}
+error: document not found
+error: unknown command "bogus", try help
+`, out.String())
+	assert.False(t, sh.Dirty())
+
+	store, err := documentstore.NewStoreFromFile(file)
+	require.NoError(t, err)
+	defer store.Close()
+	users, err := store.GetCollection("users")
+	require.NoError(t, err)
+	assert.Equal(t, 2, users.Len())
+}
+
+func TestShell_Exec(t *testing.T) {
+	sh, out := openShell(t, Source{})
+
+	assert.ErrorContains(t, sh.Exec([]string{"get", "users"}), "usage: get <collection> <key...>")
+	assert.ErrorIs(t, sh.Exec([]string{"get", "users", "1"}), documentstore.ErrCollectionNotFound)
+	assert.ErrorIs(t, sh.Exec([]string{"quit"}), ErrQuit)
+	assert.Error(t, sh.Exec([]string{"save"}))
+
+	require.NoError(t, sh.Exec([]string{"use", "acme"}))
+	require.NoError(t, sh.Exec([]string{"create", "events", `{"PrimaryKeys": ["day", "seq"]}`}))
+	require.NoError(t, sh.Exec([]string{"put", "events", `{"day": "mon", "seq": 1}`}))
+	assert.True(t, sh.Dirty())
+
+	out.Reset()
+	require.NoError(t, sh.Exec([]string{"get", "events", "mon", "1"}))
+	assert.Equal(t, "{\n  \"day\": \"mon\",\n  \"seq\": 1\n}\n", out.String())
+	assert.ErrorIs(t, sh.Exec([]string{"get", "events", "mon"}), documentstore.ErrInvalidPrimaryKey)
+	require.NoError(t, sh.Exec([]string{"delete", "events", "mon", "1"}))
+	assert.ErrorIs(t, sh.Exec([]string{"get", "events", "mon", "1"}), documentstore.ErrDocumentNotFound)
+
+	require.NoError(t, sh.Exec([]string{"use"}))
+	out.Reset()
+	require.NoError(t, sh.Exec([]string{"collections"}))
+	assert.Equal(t, "NAME  KEY  DOCUMENTS  BYTES\n", out.String())
+
+	out.Reset()
+	require.NoError(t, sh.Exec([]string{"namespaces"}))
+	assert.Equal(t, "acme\n", out.String())
+}
+
+func TestShell_SaveDir(t *testing.T) {
+	dir := t.TempDir()
+
+	sh, err := Open(Source{Dir: dir}, io.Discard, discardLogger)
+	require.NoError(t, err)
+	require.NoError(t, sh.Exec([]string{"create", "users", "id"}))
+	require.NoError(t, sh.Exec([]string{"put", "users", `{"id": "1"}`}))
+	require.NoError(t, sh.Save())
+	require.NoError(t, sh.Exec([]string{"put", "users", `{"id": "2"}`}))
+	require.NoError(t, sh.Close())
+
+	// The second put was never saved but is in the change log.
+	reopened, out := openShell(t, Source{Dir: dir})
+	require.NoError(t, reopened.Exec([]string{"find", "users"}))
+	assert.Equal(t, "{\"id\":\"1\"}\n{\"id\":\"2\"}\n", out.String())
+}
+
+func TestShell_ImportExport(t *testing.T) {
+	dir := t.TempDir()
+	csvFile := filepath.Join(dir, "users.csv")
+	require.NoError(t, os.WriteFile(csvFile, []byte("id,name,zip\n1,Alice,01234\n2,Bob,x\n3\n"), 0o644))
+
+	sh, out := openShell(t, Source{})
+	require.NoError(t, sh.Exec([]string{"create", "users", "id"}))
+
+	require.NoError(t, sh.Exec([]string{"import", "users", csvFile, "zip=string"}))
+	assert.Equal(t, csvFile+": line 4: wrong number of fields\nimported 2 documents, 1 failed\n", out.String())
+
+	out.Reset()
+	require.NoError(t, sh.Exec([]string{"export", "users", "-", "csv"}))
+	assert.Equal(t, "id,name,zip\n1,Alice,01234\n2,Bob,x\n", out.String())
+
+	jsonlFile := filepath.Join(dir, "users.jsonl")
+	out.Reset()
+	require.NoError(t, sh.Exec([]string{"export", "users", jsonlFile}))
+	assert.Equal(t, "exported 2 documents\n", out.String())
+	data, err := os.ReadFile(jsonlFile)
+	require.NoError(t, err)
+	assert.Equal(t, "{\"id\":\"1\",\"name\":\"Alice\",\"zip\":\"01234\"}\n{\"id\":\"2\",\"name\":\"Bob\",\"zip\":\"x\"}\n", string(data))
+
+	assert.ErrorIs(t, sh.Exec([]string{"import", "users", filepath.Join(dir, "users.txt")}), bulk.ErrUnknownFormat)
+}
+
+func TestShell_DumpTools(t *testing.T) {
+	dir := t.TempDir()
+	oldFile := filepath.Join(dir, "old.json")
+	newFile := filepath.Join(dir, "new.json")
+
+	sh, out := openShell(t, Source{File: oldFile})
+	for _, line := range []string{
+		"create users id",
+		`put users {"id": "1", "name": "Alice"}`,
+		`put users {"id": "2", "name": "Bob"}`,
+		"save",
+		`put users {"id": "1", "name": "Alicia"}`,
+		"delete users 2",
+		`put users {"id": "3", "name": "Carol"}`,
+		"use acme",
+		"create orders id",
+	} {
+		args, err := SplitLine(line)
+		require.NoError(t, err)
+		require.NoError(t, sh.Exec(args), line)
+	}
+	require.NoError(t, sh.root.DumpToFile(newFile))
+
+	out.Reset()
+	require.NoError(t, sh.Exec([]string{"diff", oldFile, newFile}))
+	assert.Equal(t, `users: 1 added, 1 removed, 1 changed
+  + 3
+  - 2
+  ~ 1
++ collection acme/orders
+`, out.String())
+
+	out.Reset()
+	require.NoError(t, sh.Exec([]string{"diff", newFile, newFile}))
+	assert.Equal(t, "no differences\n", out.String())
+
+	out.Reset()
+	require.NoError(t, sh.Exec([]string{"verify", newFile}))
+	assert.Equal(t, "version 4, 2 collections, 2 documents, 0 issues\n", out.String())
+
+	badFile := filepath.Join(dir, "bad.json")
+	require.NoError(t, os.WriteFile(badFile, []byte(`{"collections":{"users":{"config":{"PrimaryKey":"id"},"documents":[{"Fields":{"name":{"Type":"string","Value":"x"}}}]}}}`), 0o644))
+	out.Reset()
+	assert.Error(t, sh.Exec([]string{"verify", badFile}))
+	assert.Equal(t, "users document 1: invalid primary key: missing field \"id\"\n"+
+		"version 0, 1 collections, 1 documents, 1 issues\n", out.String())
+
+	out.Reset()
+	require.NoError(t, sh.Exec([]string{"stats", oldFile}))
+	var summary documentstore.DumpSummary
+	require.NoError(t, json.Unmarshal(out.Bytes(), &summary))
+	require.Len(t, summary.Collections, 1)
+	assert.Equal(t, 2, summary.Collections[0].Documents)
+}
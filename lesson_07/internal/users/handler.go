@@ -0,0 +1,218 @@
+package users
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/Nick2603/golang/lesson_07/internal/documentstore"
+)
+
+const (
+	maxNameLength    = 100
+	maxIDLength      = 128
+	maxRequestSize   = 64 << 10
+	defaultPageLimit = 50
+	maxPageLimit     = 500
+)
+
+var errInvalidRequest = errors.New("invalid request")
+
+type CreateUserRequest struct {
+	ID   string `json:"id,omitempty"`
+	Name string `json:"name"`
+}
+
+type UpdateUserRequest struct {
+	Name *string `json:"name"`
+}
+
+type UserPage struct {
+	Users []User `json:"users"`
+	// Next is the id to pass as ?after= for the following page; it is
+	// empty on the last page.
+	Next  string `json:"next,omitempty"`
+	Limit int    `json:"limit"`
+}
+
+type ErrorResponse struct {
+	Error string `json:"error"`
+}
+
+// Handler serves the users REST API:
+//
+//	POST   /users        create a user; the id may be omitted if the
+//	                     collection generates keys
+//	GET    /users        list users in id order (?after=&limit=)
+//	GET    /users/{id}   get a user
+//	PATCH  /users/{id}   update a user's name
+//	DELETE /users/{id}   delete a user
+type Handler struct {
+	svc    *Service
+	logger *slog.Logger
+	mux    *http.ServeMux
+}
+
+func NewHandler(svc *Service, logger *slog.Logger) *Handler {
+	h := &Handler{svc: svc, logger: logger, mux: http.NewServeMux()}
+
+	h.mux.HandleFunc("POST /users", h.createUser)
+	h.mux.HandleFunc("GET /users", h.listUsers)
+	h.mux.HandleFunc("GET /users/{id}", h.getUser)
+	h.mux.HandleFunc("PATCH /users/{id}", h.updateUser)
+	h.mux.HandleFunc("DELETE /users/{id}", h.deleteUser)
+
+	return h
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mux.ServeHTTP(w, r)
+}
+
+func (h *Handler) createUser(w http.ResponseWriter, r *http.Request) {
+	var req CreateUserRequest
+	if err := decodeRequest(w, r, &req); err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+	if err := validateName(req.Name); err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+	if len(req.ID) > maxIDLength {
+		h.writeError(w, r, fmt.Errorf("%w: id must be at most %d bytes", errInvalidRequest, maxIDLength))
+		return
+	}
+
+	user, err := h.svc.CreateUser(req.ID, req.Name)
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Location", "/users/"+url.PathEscape(user.ID))
+	h.writeJSON(w, http.StatusCreated, user)
+}
+
+func (h *Handler) listUsers(w http.ResponseWriter, r *http.Request) {
+	limit, err := queryInt(r, "limit", defaultPageLimit)
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+	if limit <= 0 || limit > maxPageLimit {
+		h.writeError(w, r, fmt.Errorf("%w: limit must be between 1 and %d", errInvalidRequest, maxPageLimit))
+		return
+	}
+
+	users, next, err := h.svc.ListUsersAfter(r.URL.Query().Get("after"), limit)
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, UserPage{Users: users, Next: next, Limit: limit})
+}
+
+func (h *Handler) getUser(w http.ResponseWriter, r *http.Request) {
+	user, err := h.svc.GetUser(r.PathValue("id"))
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+	h.writeJSON(w, http.StatusOK, user)
+}
+
+func (h *Handler) updateUser(w http.ResponseWriter, r *http.Request) {
+	var req UpdateUserRequest
+	if err := decodeRequest(w, r, &req); err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+	if req.Name == nil {
+		h.writeError(w, r, fmt.Errorf("%w: nothing to update", errInvalidRequest))
+		return
+	}
+	if err := validateName(*req.Name); err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+
+	user, err := h.svc.UpdateUser(r.PathValue("id"), *req.Name)
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+	h.writeJSON(w, http.StatusOK, user)
+}
+
+func (h *Handler) deleteUser(w http.ResponseWriter, r *http.Request) {
+	if err := h.svc.DeleteUser(r.PathValue("id")); err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		h.logger.Error("failed to write response", "error", err)
+	}
+}
+
+func (h *Handler) writeError(w http.ResponseWriter, r *http.Request, err error) {
+	var status int
+	switch {
+	case errors.Is(err, ErrUserNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, ErrUserAlreadyExists):
+		status = http.StatusConflict
+	case errors.Is(err, errInvalidRequest), errors.Is(err, documentstore.ErrInvalidPrimaryKey):
+		status = http.StatusBadRequest
+	case errors.Is(err, documentstore.ErrValidationFailed):
+		status = http.StatusUnprocessableEntity
+	default:
+		status = http.StatusInternalServerError
+		h.logger.Error("users request failed", "method", r.Method, "path", r.URL.Path, "error", err)
+	}
+	h.writeJSON(w, status, ErrorResponse{Error: err.Error()})
+}
+
+func decodeRequest(w http.ResponseWriter, r *http.Request, v any) error {
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestSize))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		return fmt.Errorf("%w: %v", errInvalidRequest, err)
+	}
+	return nil
+}
+
+func validateName(name string) error {
+	if strings.TrimSpace(name) == "" {
+		return fmt.Errorf("%w: name is required", errInvalidRequest)
+	}
+	if utf8.RuneCountInString(name) > maxNameLength {
+		return fmt.Errorf("%w: name must be at most %d characters", errInvalidRequest, maxNameLength)
+	}
+	return nil
+}
+
+func queryInt(r *http.Request, name string, def int) (int, error) {
+	raw := r.URL.Query().Get(name)
+	if raw == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(raw)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("%w: %s must be a non-negative integer", errInvalidRequest, name)
+	}
+	return n, nil
+}
@@ -0,0 +1,171 @@
+package users
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Nick2603/golang/lesson_07/internal/documentstore"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupHandler(t *testing.T, cfg documentstore.CollectionConfig) *httptest.Server {
+	t.Helper()
+
+	coll := documentstore.NewCollection(cfg)
+	t.Cleanup(func() { coll.Close() })
+
+	srv := httptest.NewServer(NewHandler(NewService(coll), slog.New(slog.NewTextHandler(io.Discard, nil))))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func request(t *testing.T, srv *httptest.Server, method, path, body string) (int, string) {
+	t.Helper()
+
+	req, err := http.NewRequest(method, srv.URL+path, strings.NewReader(body))
+	require.NoError(t, err)
+	resp, err := srv.Client().Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return resp.StatusCode, string(data)
+}
+
+func TestHandler_UserLifecycle(t *testing.T) {
+	srv := setupHandler(t, documentstore.CollectionConfig{PrimaryKey: "id"})
+
+	status, body := request(t, srv, http.MethodPost, "/users", `{"id": "1", "name": "Alice"}`)
+	require.Equal(t, http.StatusCreated, status, body)
+	assert.JSONEq(t, `{"id": "1", "name": "Alice"}`, body)
+
+	status, body = request(t, srv, http.MethodGet, "/users/1", "")
+	require.Equal(t, http.StatusOK, status)
+	assert.JSONEq(t, `{"id": "1", "name": "Alice"}`, body)
+
+	status, body = request(t, srv, http.MethodPatch, "/users/1", `{"name": "Alicia"}`)
+	require.Equal(t, http.StatusOK, status, body)
+	assert.JSONEq(t, `{"id": "1", "name": "Alicia"}`, body)
+
+	status, _ = request(t, srv, http.MethodDelete, "/users/1", "")
+	assert.Equal(t, http.StatusNoContent, status)
+
+	status, body = request(t, srv, http.MethodGet, "/users/1", "")
+	assert.Equal(t, http.StatusNotFound, status)
+	assert.JSONEq(t, `{"error": "user not found"}`, body)
+}
+
+func TestHandler_CreateUserGeneratesID(t *testing.T) {
+	srv := setupHandler(t, documentstore.CollectionConfig{PrimaryKey: "id", KeyGen: documentstore.KeyGenULID})
+
+	status, body := request(t, srv, http.MethodPost, "/users", `{"name": "Alice"}`)
+	require.Equal(t, http.StatusCreated, status, body)
+
+	var user User
+	require.NoError(t, json.Unmarshal([]byte(body), &user))
+	assert.Len(t, user.ID, 26)
+	assert.Equal(t, "Alice", user.Name)
+
+	status, _ = request(t, srv, http.MethodGet, "/users/"+user.ID, "")
+	assert.Equal(t, http.StatusOK, status)
+}
+
+func TestHandler_Errors(t *testing.T) {
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		body       string
+		wantStatus int
+	}{
+		{name: "malformed json", method: http.MethodPost, path: "/users", body: `{"name":`, wantStatus: http.StatusBadRequest},
+		{name: "unknown field", method: http.MethodPost, path: "/users", body: `{"id": "2", "name": "Bob", "admin": true}`, wantStatus: http.StatusBadRequest},
+		{name: "missing name", method: http.MethodPost, path: "/users", body: `{"id": "2"}`, wantStatus: http.StatusBadRequest},
+		{name: "blank name", method: http.MethodPost, path: "/users", body: `{"id": "2", "name": "  "}`, wantStatus: http.StatusBadRequest},
+		{name: "name too long", method: http.MethodPost, path: "/users", body: `{"id": "2", "name": "` + strings.Repeat("x", maxNameLength+1) + `"}`, wantStatus: http.StatusBadRequest},
+		{name: "missing id without generator", method: http.MethodPost, path: "/users", body: `{"name": "Bob"}`, wantStatus: http.StatusBadRequest},
+		{name: "duplicate id", method: http.MethodPost, path: "/users", body: `{"id": "1", "name": "Bob"}`, wantStatus: http.StatusConflict},
+		{name: "patch missing user", method: http.MethodPatch, path: "/users/9", body: `{"name": "Bob"}`, wantStatus: http.StatusNotFound},
+		{name: "patch without fields", method: http.MethodPatch, path: "/users/1", body: `{}`, wantStatus: http.StatusBadRequest},
+		{name: "delete missing user", method: http.MethodDelete, path: "/users/9", wantStatus: http.StatusNotFound},
+		{name: "bad limit", method: http.MethodGet, path: "/users?limit=1000", wantStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := setupHandler(t, documentstore.CollectionConfig{PrimaryKey: "id"})
+			status, _ := request(t, srv, http.MethodPost, "/users", `{"id": "1", "name": "Alice"}`)
+			require.Equal(t, http.StatusCreated, status)
+
+			status, body := request(t, srv, tt.method, tt.path, tt.body)
+			assert.Equal(t, tt.wantStatus, status, body)
+
+			var resp ErrorResponse
+			require.NoError(t, json.Unmarshal([]byte(body), &resp))
+			assert.NotEmpty(t, resp.Error)
+		})
+	}
+}
+
+func TestHandler_ListUsersPaging(t *testing.T) {
+	srv := setupHandler(t, documentstore.CollectionConfig{PrimaryKey: "id"})
+	for _, id := range []string{"3", "1", "2"} {
+		status, _ := request(t, srv, http.MethodPost, "/users", `{"id": "`+id+`", "name": "user `+id+`"}`)
+		require.Equal(t, http.StatusCreated, status)
+	}
+
+	tests := []struct {
+		name     string
+		query    string
+		want     []string
+		wantNext string
+	}{
+		{name: "all", query: "", want: []string{"1", "2", "3"}},
+		{name: "first page", query: "?limit=2", want: []string{"1", "2"}, wantNext: "2"},
+		{name: "second page", query: "?after=2&limit=2", want: []string{"3"}},
+		{name: "after a missing id", query: "?after=15", want: []string{"2", "3"}},
+		{name: "past the end", query: "?after=3", want: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, body := request(t, srv, http.MethodGet, "/users"+tt.query, "")
+			require.Equal(t, http.StatusOK, status)
+
+			var page UserPage
+			require.NoError(t, json.Unmarshal([]byte(body), &page))
+			assert.Equal(t, tt.wantNext, page.Next)
+			assert.NotNil(t, page.Users)
+
+			var ids []string
+			for _, u := range page.Users {
+				ids = append(ids, u.ID)
+			}
+			assert.Equal(t, tt.want, ids)
+		})
+	}
+
+	t.Run("cursor survives a delete", func(t *testing.T) {
+		status, body := request(t, srv, http.MethodGet, "/users?limit=1", "")
+		require.Equal(t, http.StatusOK, status)
+		var page UserPage
+		require.NoError(t, json.Unmarshal([]byte(body), &page))
+		require.Equal(t, "1", page.Next)
+
+		status, _ = request(t, srv, http.MethodDelete, "/users/1", "")
+		require.Equal(t, http.StatusNoContent, status)
+
+		status, body = request(t, srv, http.MethodGet, "/users?limit=1&after="+page.Next, "")
+		require.Equal(t, http.StatusOK, status)
+		require.NoError(t, json.Unmarshal([]byte(body), &page))
+		require.Len(t, page.Users, 1)
+		assert.Equal(t, "2", page.Users[0].ID)
+	})
+}
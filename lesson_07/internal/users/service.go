@@ -2,11 +2,17 @@ package users
 
 import (
 	"errors"
+	"fmt"
+	"slices"
+	"strings"
 
 	"github.com/Nick2603/golang/lesson_07/internal/documentstore"
 )
 
-var ErrUserNotFound = errors.New("user not found")
+var (
+	ErrUserNotFound      = errors.New("user not found")
+	ErrUserAlreadyExists = errors.New("user already exists")
+)
 
 type User struct {
 	ID   string `json:"id"`
@@ -22,6 +28,18 @@ type CollectionStore interface {
 	Delete(id string) error
 }
 
+// keyInserter is implemented by collections that take put options and can
+// generate primary keys, such as a *documentstore.Collection.
+type keyInserter interface {
+	Insert(doc documentstore.Document, opts ...documentstore.PutOption) (string, error)
+}
+
+// keyScanner is implemented by collections that can list documents from a
+// key onwards, such as a *documentstore.Collection.
+type keyScanner interface {
+	ScanFrom(start string, fn func(key string, doc documentstore.Document) bool) error
+}
+
 type Service struct {
 	coll CollectionStore
 }
@@ -30,6 +48,10 @@ func NewService(coll CollectionStore) *Service {
 	return &Service{coll: coll}
 }
 
+// CreateUser stores a new user and fails with ErrUserAlreadyExists if the
+// id is taken. An empty id lets the collection generate one when it
+// generates string keys, such as UUIDs or ULIDs; sequence keys are
+// numbers and cannot be user ids.
 func (s *Service) CreateUser(id, name string) (*User, error) {
 	user := &User{ID: id, Name: name}
 
@@ -38,6 +60,25 @@ func (s *Service) CreateUser(id, name string) (*User, error) {
 		return nil, err
 	}
 
+	if inserter, ok := s.coll.(keyInserter); ok {
+		key, err := inserter.Insert(*doc, documentstore.IfAbsent())
+		if errors.Is(err, documentstore.ErrDocumentAlreadyExists) {
+			return nil, fmt.Errorf("%w: %s", ErrUserAlreadyExists, id)
+		}
+		if err != nil {
+			return nil, err
+		}
+		user.ID = key
+		return user, nil
+	}
+
+	// Collections without Insert cannot check and write in one step.
+	if _, err := s.GetUser(id); err == nil {
+		return nil, fmt.Errorf("%w: %s", ErrUserAlreadyExists, id)
+	} else if !errors.Is(err, ErrUserNotFound) {
+		return nil, err
+	}
+
 	if err := s.coll.Put(*doc); err != nil {
 		return nil, err
 	}
@@ -60,11 +101,75 @@ func (s *Service) ListUsers() ([]User, error) {
 	return users, nil
 }
 
+// ListUsersAfter returns up to limit users whose ids sort after the given
+// one, in id order, and the id to pass as after for the next page, which is
+// empty on the last page. An empty after starts from the first user.
+func (s *Service) ListUsersAfter(after string, limit int) ([]User, string, error) {
+	scanner, ok := s.coll.(keyScanner)
+	if !ok {
+		return s.listUsersAfter(after, limit)
+	}
+
+	start := ""
+	if after != "" {
+		start = after + "\x00"
+	}
+
+	users := make([]User, 0, limit)
+	var next string
+	var decodeErr error
+	err := scanner.ScanFrom(start, func(_ string, doc documentstore.Document) bool {
+		if len(users) == limit {
+			next = users[limit-1].ID
+			return false
+		}
+		var u User
+		if decodeErr = documentstore.UnmarshalDocument(&doc, &u); decodeErr != nil {
+			return false
+		}
+		users = append(users, u)
+		return true
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	if decodeErr != nil {
+		return nil, "", decodeErr
+	}
+
+	return users, next, nil
+}
+
+// listUsersAfter pages through a full listing, for collections that cannot
+// scan from a key.
+func (s *Service) listUsersAfter(after string, limit int) ([]User, string, error) {
+	all, err := s.ListUsers()
+	if err != nil {
+		return nil, "", err
+	}
+	slices.SortFunc(all, func(a, b User) int { return strings.Compare(a.ID, b.ID) })
+
+	i, _ := slices.BinarySearchFunc(all, after, func(u User, id string) int { return strings.Compare(u.ID, id) })
+	for i < len(all) && all[i].ID <= after {
+		i++
+	}
+	users := all[i:min(i+limit, len(all))]
+
+	var next string
+	if i+limit < len(all) {
+		next = users[len(users)-1].ID
+	}
+	return users, next, nil
+}
+
 func (s *Service) GetUser(userID string) (*User, error) {
 	doc, err := s.coll.Get(userID)
-	if err != nil {
+	if errors.Is(err, documentstore.ErrDocumentNotFound) {
 		return nil, ErrUserNotFound
 	}
+	if err != nil {
+		return nil, err
+	}
 
 	var user User
 	if err := documentstore.UnmarshalDocument(doc, &user); err != nil {
@@ -74,9 +179,43 @@ func (s *Service) GetUser(userID string) (*User, error) {
 	return &user, nil
 }
 
+// UpdateUser renames an existing user. It fails with ErrUserNotFound if
+// the user does not exist, including when it is deleted concurrently.
+func (s *Service) UpdateUser(userID, name string) (*User, error) {
+	user := &User{ID: userID, Name: name}
+
+	doc, err := documentstore.MarshalDocument(user)
+	if err != nil {
+		return nil, err
+	}
+
+	if inserter, ok := s.coll.(keyInserter); ok {
+		_, err := inserter.Insert(*doc, documentstore.IfExists())
+		if errors.Is(err, documentstore.ErrDocumentNotFound) {
+			return nil, fmt.Errorf("%w: %s", ErrUserNotFound, userID)
+		}
+		if err != nil {
+			return nil, err
+		}
+		return user, nil
+	}
+
+	// Collections without Insert cannot check and write in one step.
+	if _, err := s.GetUser(userID); err != nil {
+		return nil, err
+	}
+
+	if err := s.coll.Put(*doc); err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
 func (s *Service) DeleteUser(userID string) error {
-	if err := s.coll.Delete(userID); err != nil {
+	err := s.coll.Delete(userID)
+	if errors.Is(err, documentstore.ErrDocumentNotFound) {
 		return ErrUserNotFound
 	}
-	return nil
+	return err
 }
@@ -1,6 +1,9 @@
 package users
 
 import (
+	"fmt"
+	"sync"
+	"sync/atomic"
 	"testing"
 
 	"github.com/Nick2603/golang/lesson_07/internal/documentstore"
@@ -58,11 +61,19 @@ func TestService_CreateUser(t *testing.T) {
 				assert.Equal(t, "", user.Name)
 			},
 		},
+		{
+			name:     "rejects existing id",
+			id:       "dup",
+			userName: "Mallory",
+			wantErr:  ErrUserAlreadyExists,
+		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			svc := setupService(t)
+			_, err := svc.CreateUser("dup", "Alice")
+			require.NoError(t, err)
 
 			user, err := svc.CreateUser(tt.id, tt.userName)
 
@@ -83,6 +94,9 @@ func TestService_CreateUser(t *testing.T) {
 		mockColl := mocks.NewCollectionStore(t)
 		svc := NewService(mockColl)
 
+		mockColl.On("Get", "1").
+			Return(nil, documentstore.ErrDocumentNotFound).
+			Once()
 		mockColl.On("Put", mock.AnythingOfType("documentstore.Document")).
 			Return(nil).
 			Once()
@@ -96,6 +110,46 @@ func TestService_CreateUser(t *testing.T) {
 
 		mockColl.AssertExpectations(t)
 	})
+
+	t.Run("does not create on read errors", func(t *testing.T) {
+		mockColl := mocks.NewCollectionStore(t)
+		svc := NewService(mockColl)
+
+		mockColl.On("Get", "1").
+			Return(nil, documentstore.ErrStoreClosed).
+			Once()
+
+		user, err := svc.CreateUser("1", "Alice")
+
+		assert.ErrorIs(t, err, documentstore.ErrStoreClosed)
+		assert.NotErrorIs(t, err, ErrUserNotFound)
+		assert.Nil(t, user)
+	})
+}
+
+func TestService_CreateUserGeneratesID(t *testing.T) {
+	coll := documentstore.NewCollection(documentstore.CollectionConfig{
+		PrimaryKey: "id",
+		KeyGen:     documentstore.KeyGenULID,
+	})
+	svc := NewService(coll)
+
+	alice, err := svc.CreateUser("", "Alice")
+	require.NoError(t, err)
+	bob, err := svc.CreateUser("", "Bob")
+	require.NoError(t, err)
+
+	assert.Len(t, alice.ID, 26)
+	assert.NotEqual(t, alice.ID, bob.ID)
+
+	got, err := svc.GetUser(bob.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "Bob", got.Name)
+
+	t.Run("without a key generator", func(t *testing.T) {
+		_, err := setupService(t).CreateUser("", "Alice")
+		assert.ErrorIs(t, err, documentstore.ErrInvalidPrimaryKey)
+	})
 }
 
 func TestService_GetUser(t *testing.T) {
@@ -302,6 +356,104 @@ func TestService_ListUsers(t *testing.T) {
 	})
 }
 
+func TestService_ListUsersAfter(t *testing.T) {
+	userDoc := func(id string) documentstore.Document {
+		return documentstore.Document{Fields: map[string]documentstore.DocumentField{
+			"id":   {Type: documentstore.DocumentFieldTypeString, Value: id},
+			"name": {Type: documentstore.DocumentFieldTypeString, Value: "user " + id},
+		}}
+	}
+
+	tests := []struct {
+		name     string
+		after    string
+		limit    int
+		want     []string
+		wantNext string
+	}{
+		{name: "first page", after: "", limit: 2, want: []string{"1", "2"}, wantNext: "2"},
+		{name: "last page", after: "2", limit: 2, want: []string{"3"}},
+		{name: "exact fit", after: "1", limit: 2, want: []string{"2", "3"}},
+		{name: "past the end", after: "3", limit: 2, want: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := setupService(t)
+			for _, id := range []string{"3", "1", "2"} {
+				_, err := svc.CreateUser(id, "user "+id)
+				require.NoError(t, err)
+			}
+
+			mockColl := mocks.NewCollectionStore(t)
+			mockColl.On("List").
+				Return([]documentstore.Document{userDoc("3"), userDoc("1"), userDoc("2")}).
+				Once()
+
+			for name, svc := range map[string]*Service{"scan": svc, "list": NewService(mockColl)} {
+				users, next, err := svc.ListUsersAfter(tt.after, tt.limit)
+				require.NoError(t, err, name)
+				assert.NotNil(t, users, name)
+
+				var ids []string
+				for _, u := range users {
+					ids = append(ids, u.ID)
+				}
+				assert.Equal(t, tt.want, ids, name)
+				assert.Equal(t, tt.wantNext, next, name)
+			}
+		})
+	}
+}
+
+func TestService_UpdateUser(t *testing.T) {
+	tests := []struct {
+		name      string
+		setupFunc func(*Service)
+		userID    string
+		newName   string
+		wantErr   error
+	}{
+		{
+			name: "renames existing user",
+			setupFunc: func(svc *Service) {
+				svc.CreateUser("1", "Alice")
+			},
+			userID:  "1",
+			newName: "Alicia",
+			wantErr: nil,
+		},
+		{
+			name:      "returns error when user does not exist",
+			setupFunc: func(svc *Service) {},
+			userID:    "999",
+			newName:   "Nobody",
+			wantErr:   ErrUserNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := setupService(t)
+			tt.setupFunc(svc)
+
+			user, err := svc.UpdateUser(tt.userID, tt.newName)
+
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				assert.Nil(t, user)
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, &User{ID: tt.userID, Name: tt.newName}, user)
+
+				stored, err := svc.GetUser(tt.userID)
+				require.NoError(t, err)
+				assert.Equal(t, tt.newName, stored.Name)
+			}
+		})
+	}
+}
+
 func TestService_DeleteUser(t *testing.T) {
 	tests := []struct {
 		name      string
@@ -363,6 +515,17 @@ func TestService_DeleteUser(t *testing.T) {
 		})
 	}
 
+	t.Run("passes on store errors", func(t *testing.T) {
+		mockColl := mocks.NewCollectionStore(t)
+		svc := NewService(mockColl)
+
+		mockColl.On("Delete", "1").
+			Return(documentstore.ErrStoreClosed).
+			Once()
+
+		assert.ErrorIs(t, svc.DeleteUser("1"), documentstore.ErrStoreClosed)
+	})
+
 	t.Run("calls Delete on collection store", func(t *testing.T) {
 		mockColl := mocks.NewCollectionStore(t)
 		svc := NewService(mockColl)
@@ -442,4 +605,54 @@ func TestService_ConcurrentOperations(t *testing.T) {
 			assert.Equal(t, u.name, retrieved.Name)
 		}
 	})
+
+	t.Run("creates a contested id once", func(t *testing.T) {
+		svc := setupService(t)
+
+		var (
+			wg      sync.WaitGroup
+			created atomic.Int32
+		)
+		for i := 0; i < 20; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, err := svc.CreateUser("1", fmt.Sprintf("user %d", i))
+				if err == nil {
+					created.Add(1)
+				} else {
+					assert.ErrorIs(t, err, ErrUserAlreadyExists)
+				}
+			}()
+		}
+		wg.Wait()
+
+		assert.Equal(t, int32(1), created.Load())
+	})
+	t.Run("update racing a delete never revives the user", func(t *testing.T) {
+		svc := setupService(t)
+
+		for i := 0; i < 50; i++ {
+			_, err := svc.CreateUser("1", "Alice")
+			require.NoError(t, err)
+
+			var wg sync.WaitGroup
+			wg.Add(2)
+			go func() {
+				defer wg.Done()
+				_, err := svc.UpdateUser("1", "Alicia")
+				if err != nil {
+					assert.ErrorIs(t, err, ErrUserNotFound)
+				}
+			}()
+			go func() {
+				defer wg.Done()
+				assert.NoError(t, svc.DeleteUser("1"))
+			}()
+			wg.Wait()
+
+			_, err = svc.GetUser("1")
+			require.ErrorIs(t, err, ErrUserNotFound)
+		}
+	})
 }